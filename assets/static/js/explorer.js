@@ -41,11 +41,65 @@ apiExplorer.setBeforeSendCallback = function( func ) {
 
 // Read the API get from the explorer input parameters.
 apiExplorer.readApiKey = function() {
-    return $('#api-key-select').val() || $('#api-key-input').val() || "";
+    return $('#api-key-select').val() || $('#api-key-input').val() || apiExplorer.environments.apiKey() || "";
 };
 apiExplorer.readAccessToken = function() {
     return $('#access-token-input').val() || "";
 };
+// Fill in the access token acquired by the server for an OAuth2 security scheme, if
+// the reader has signed in.
+apiExplorer.loadOAuth2Token = function(spec, scheme) {
+    $.ajax({
+        url: '/_dapperdox/oauth/token',
+        data: { spec: spec, scheme: scheme },
+        dataType: 'json',
+        success: function(token) {
+            if( token.access_token && !$('#access-token-input').val() ) {
+                $('#access-token-input').val(token.access_token);
+            }
+        }
+    });
+};
+// Have the server request an access token with the client credentials or password
+// flow, using the credentials entered in the explorer. The server caches the token
+// until it expires.
+apiExplorer.acquireOAuth2Token = function(spec, scheme, csrf, done) {
+    $.ajax({
+        url: '/_dapperdox/oauth/acquire',
+        type: 'POST',
+        headers: { 'X-CSRF-Token': csrf },
+        data: {
+            spec:          spec,
+            scheme:        scheme,
+            client_id:     $('#oauth-client-id-input').val() || "",
+            client_secret: $('#oauth-client-secret-input').val() || "",
+            username:      $('#oauth-username-input').val() || "",
+            password:      $('#oauth-password-input').val() || ""
+        },
+        dataType: 'json',
+        success: function(token) {
+            $('#access-token-input').val(token.access_token);
+            done();
+        },
+        error: function(xhr) {
+            done(xhr.responseText || xhr.statusText);
+        }
+    });
+};
+$(document).on('click', '.oauth-acquire', function(event) {
+    event.preventDefault();
+    var $button = $(this);
+    var $error  = $button.siblings('.oauth-acquire-error');
+
+    $error.hide();
+    $button.attr('disabled', 'disabled');
+    apiExplorer.acquireOAuth2Token($button.data('spec'), $button.data('scheme'), $button.data('csrf'), function(err) {
+        $button.removeAttr('disabled');
+        if( err ) {
+            $error.text(err).show();
+        }
+    });
+});
 apiExplorer.readBasicUsername = function() {
     return $('#basic-username-input').val() || "";
 };
@@ -60,6 +114,524 @@ apiExplorer.getBasicAuthentication = function() {
        return btoa(token); 
 };
 
+// --------------------------------------------------------------------------------------
+// Environments are named sets of values substituted into requests. Those configured
+// for the site are merged with those the reader adds, which are kept in local storage,
+// or in the server side session if a store URL is given.
+apiExplorer.environments = { _configured: [], _own: [], _store: "", _csrf: "" };
+
+apiExplorer.environments.init = function(configured, store, csrf) {
+    var self = this;
+
+    this._configured = configured || [];
+    this._store = store;
+    this._csrf  = csrf;
+
+    if( store ) {
+        $.getJSON(store, function(environments) {
+            self._own = environments || [];
+            self.render();
+        });
+    } else {
+        try {
+            this._own = JSON.parse(localStorage.getItem('dapperdox.environments') || '[]');
+        } catch(e) {
+            this._own = [];
+        }
+        this.render();
+    }
+};
+apiExplorer.environments.list = function() {
+    var byName = {};
+    var names  = [];
+    var all    = this._configured.concat(this._own); // The reader's own replace configured ones of the same name
+
+    for( var i = 0; i < all.length; i++ ) {
+        if( !(all[i].name in byName) ) {
+            names.push(all[i].name);
+        }
+        byName[all[i].name] = all[i];
+    }
+    return names.map(function(name) { return byName[name]; });
+};
+apiExplorer.environments.current = function() {
+    var name = $('#environment-select').val();
+    var list = this.list();
+
+    for( var i = 0; i < list.length; i++ ) {
+        if( list[i].name == name ) return list[i];
+    }
+    return null;
+};
+apiExplorer.environments.render = function() {
+    var select = $('#environment-select');
+    var list   = this.list();
+    var chosen = localStorage.getItem('dapperdox.environment') || "";
+
+    select.find('option:gt(0)').remove();
+    for( var i = 0; i < list.length; i++ ) {
+        $('<option>').attr('value', list[i].name).text(list[i].name).appendTo(select);
+    }
+    select.val(chosen);
+    if( select.val() == null ) select.val("");
+    this.showHeaders();
+};
+// Custom headers are kept with the current environment, or on their own when there is none
+apiExplorer.environments.headers = function() {
+    var environment = this.current();
+    if( environment ) return environment.headers || {};
+
+    try {
+        return JSON.parse(localStorage.getItem('dapperdox.headers') || '{}');
+    } catch(e) {
+        return {};
+    }
+};
+apiExplorer.environments.showHeaders = function() {
+    var headers = this.headers();
+    var lines   = [];
+
+    for( var name in headers ) {
+        lines.push(name + ': ' + headers[name]);
+    }
+    $('#custom-headers').val(lines.join('\n'));
+};
+apiExplorer.environments.saveHeaders = function(text) {
+    var headers = {};
+    var lines   = text.split('\n');
+
+    for( var i = 0; i < lines.length; i++ ) {
+        var at = lines[i].indexOf(':');
+        if( at < 1 ) continue;
+        headers[$.trim(lines[i].substring(0, at))] = $.trim(lines[i].substring(at + 1));
+    }
+
+    var environment = this.current();
+    if( environment ) {
+        this.update($.extend({}, environment, { headers: headers }));
+    } else {
+        localStorage.setItem('dapperdox.headers', JSON.stringify(headers));
+    }
+};
+apiExplorer.environments.save = function() {
+    if( this._store ) {
+        $.ajax({ url: this._store, type: 'PUT', headers: { 'X-CSRF-Token': this._csrf },
+                 contentType: 'application/json', data: JSON.stringify(this._own) });
+    } else {
+        localStorage.setItem('dapperdox.environments', JSON.stringify(this._own));
+    }
+    this.render();
+};
+apiExplorer.environments.update = function(environment) {
+    for( var i = 0; i < this._own.length; i++ ) {
+        if( this._own[i].name == environment.name ) {
+            this._own[i] = environment;
+            this.save();
+            return;
+        }
+    }
+    this._own.push(environment);
+    this.save();
+};
+// Replace {{name}} with the value of the variable in the current environment
+apiExplorer.environments.substitute = function(text) {
+    var environment = this.current();
+
+    if( !environment || !environment.variables || typeof text != "string" ) return text;
+
+    return text.replace(/\{\{\s*([\w.-]+)\s*\}\}/g, function(match, name) {
+        return (name in environment.variables) ? environment.variables[name] : match;
+    });
+};
+// Send the request to the current environment's host, if it has one, or else to the
+// chosen server
+apiExplorer.environments.rebase = function(url) {
+    var environment = this.current();
+    var host = (environment && environment.host) || $('#server-select').val();
+
+    if( !host ) return url;
+
+    return url.replace(/^([a-z][a-z0-9+.-]*:)?\/\/[^\/]*/i, host.replace(/\/$/, ''));
+};
+apiExplorer.environments.apiKey = function() {
+    var environment = this.current();
+    return environment ? environment.apiKey : "";
+};
+
+$(document).on('change', '#server-select', function() {
+    localStorage.setItem('dapperdox.server', $(this).val());
+});
+$(document).ready(function() {
+    var server = localStorage.getItem('dapperdox.server');
+    if( server && $('#server-select option[value="' + server + '"]').length ) {
+        $('#server-select').val(server);
+    }
+});
+$(document).on('change', '#environment-select', function() {
+    localStorage.setItem('dapperdox.environment', $(this).val());
+    $('#environment-editor').hide();
+    apiExplorer.environments.showHeaders();
+});
+$(document).on('change', '#custom-headers', function() {
+    apiExplorer.environments.saveHeaders($(this).val());
+});
+$(document).on('click', '#environment-edit', function(event) {
+    event.preventDefault();
+    var environment = apiExplorer.environments.current();
+    if( !environment ) return;
+
+    var lines = [];
+    if( environment.host )   lines.push('host=' + environment.host);
+    if( environment.apiKey ) lines.push('apiKey=' + environment.apiKey);
+    $.each(['signingKey', 'signingSecret', 'signingToken'], function(i, name) {
+        if( environment[name] ) lines.push(name + '=' + environment[name]);
+    });
+    for( var name in environment.variables || {} ) {
+        lines.push(name + '=' + environment.variables[name]);
+    }
+    $('#environment-variables').val(lines.join('\n'));
+    $('#environment-editor').show();
+});
+$(document).on('click', '#environment-new', function(event) {
+    event.preventDefault();
+    var name = window.prompt($(this).data('prompt'));
+    if( !name ) return;
+
+    apiExplorer.environments.update({ name: name, variables: {} });
+    localStorage.setItem('dapperdox.environment', name);
+    apiExplorer.environments.render();
+    $('#environment-edit').click();
+});
+$(document).on('click', '#environment-save', function(event) {
+    event.preventDefault();
+    var current = apiExplorer.environments.current();
+    if( !current ) return;
+
+    var environment = { name: current.name, variables: {}, headers: current.headers };
+    var lines = $('#environment-variables').val().split('\n');
+    for( var i = 0; i < lines.length; i++ ) {
+        var at = lines[i].indexOf('=');
+        if( at < 1 ) continue;
+        var name  = $.trim(lines[i].substring(0, at));
+        var value = $.trim(lines[i].substring(at + 1));
+        if( name == 'host' || name == 'apiKey' || /^signing(Key|Secret|Token)$/.test(name) ) {
+            environment[name] = value;
+        } else {
+            environment.variables[name] = value;
+        }
+    }
+    apiExplorer.environments.update(environment);
+    $('#environment-editor').hide();
+});
+
+// --------------------------------------------------------------------------------------
+// History of the requests made in this browser session. Each entry keeps the values
+// entered, so that the request can be replayed.
+apiExplorer.history = { _key: 'dapperdox.history', _max: 50 };
+
+apiExplorer.history.entries = function() {
+    try {
+        return JSON.parse(sessionStorage.getItem(this._key) || '[]');
+    } catch(e) {
+        return [];
+    }
+};
+apiExplorer.history.record = function(entry) {
+    var entries = this.entries();
+
+    entries.unshift(entry);
+    try {
+        sessionStorage.setItem(this._key, JSON.stringify(entries.slice(0, this._max)));
+    } catch(e) {
+        // Storage full or unavailable. History is a convenience, so carry on without it
+    }
+    this.render();
+};
+apiExplorer.history.render = function() {
+    var tbody   = $('#history-entries');
+    var entries = this.entries();
+    var shown   = 0;
+
+    tbody.empty();
+    for( var i = 0; i < entries.length; i++ ) {
+        var entry = entries[i];
+        if( entry.page != window.location.pathname ) continue;
+
+        $('<tr>')
+            .append($('<td>').text(new Date(entry.time).toLocaleTimeString()))
+            .append($('<td>').append($('<code>').text(entry.method.toUpperCase() + ' ' + entry.url)))
+            .append($('<td>').text(entry.status || '-'))
+            .append($('<td>').text(entry.duration + ' ms'))
+            .append($('<td>').append($('<a href="#" class="btn btn-default btn-xs history-replay">').attr('data-index', i).text(tbody.data('replay'))))
+            .appendTo(tbody);
+        shown++;
+    }
+    $('#history').toggle(shown > 0);
+};
+// Put back the values of an earlier request and send it again
+apiExplorer.history.replay = function(index) {
+    var entry = this.entries()[index];
+    if( !entry ) return;
+
+    $('#apiexplorer :input').each( function() {
+        var $input = $(this);
+        var name   = $input.prop('name');
+
+        if( $input.data('type') != 'file' && name in entry.inputs ) {
+            $input.val(entry.inputs[name]);
+        }
+    });
+    $('#exploreButton').click();
+};
+
+$(document).on('click', '.history-replay', function(event) {
+    event.preventDefault();
+    apiExplorer.history.replay($(this).data('index'));
+});
+$(document).on('click', '#history-clear', function(event) {
+    event.preventDefault();
+    sessionStorage.removeItem(apiExplorer.history._key);
+    apiExplorer.history.render();
+});
+$(document).ready(function() {
+    apiExplorer.history.render();
+});
+
+// --------------------------------------------------------------------------------------
+// Code snippets that make the request last composed by the explorer. A request has a
+// method, url, headers, and either a body or multipart form fields and files.
+apiExplorer.snippets = { _request: null, _language: 'curl' };
+
+var _shell_quote = function(text) { return "'" + String(text).replace(/'/g, "'\\''") + "'"; }
+
+apiExplorer.snippets.curl = function(request) {
+    var lines = ['curl -X ' + request.method + ' ' + _shell_quote(request.url)];
+
+    for( var i = 0; i < request.headers.length; i++ ) {
+        lines.push('  -H ' + _shell_quote(_form_header(request.headers[i])));
+    }
+    for( var i = 0; i < request.form.length; i++ ) {
+        lines.push('  -F ' + _shell_quote(request.form[i].name + '=' + request.form[i].value));
+    }
+    for( var i = 0; i < request.files.length; i++ ) {
+        lines.push('  -F ' + _shell_quote(request.files[i].name + '=@' + request.files[i].filename));
+    }
+    if( request.body ) {
+        lines.push('  --data-raw ' + _shell_quote(request.body));
+    }
+    return lines.join(' \\\n');
+};
+apiExplorer.snippets.python = function(request) {
+    var headers = {};
+    for( var i = 0; i < request.headers.length; i++ ) {
+        headers[request.headers[i].name] = request.headers[i].value;
+    }
+
+    var lines = ['import requests', '', 'response = requests.request(',
+                 '    ' + JSON.stringify(request.method) + ',',
+                 '    ' + JSON.stringify(request.url) + ',',
+                 '    headers=' + JSON.stringify(headers, null, 4).replace(/\n/g, '\n    ') + ','];
+
+    if( request.form.length ) {
+        var fields = {};
+        for( var i = 0; i < request.form.length; i++ ) {
+            fields[request.form[i].name] = request.form[i].value;
+        }
+        lines.push('    data=' + JSON.stringify(fields, null, 4).replace(/\n/g, '\n    ') + ',');
+    }
+    if( request.files.length ) {
+        var files = [];
+        for( var i = 0; i < request.files.length; i++ ) {
+            files.push('        ' + JSON.stringify(request.files[i].name) + ': open(' + JSON.stringify(request.files[i].filename) + ', "rb"),');
+        }
+        lines.push('    files={', files.join('\n'), '    },');
+    }
+    if( request.body ) {
+        lines.push('    data=' + JSON.stringify(request.body) + ',');
+    }
+    lines.push(')', '', 'print(response.status_code)', 'print(response.text)');
+    return lines.join('\n');
+};
+apiExplorer.snippets.javascript = function(request) {
+    var headers = {};
+    for( var i = 0; i < request.headers.length; i++ ) {
+        headers[request.headers[i].name] = request.headers[i].value;
+    }
+
+    var lines = [];
+    var body;
+
+    if( request.form.length || request.files.length ) {
+        lines.push('const form = new FormData();');
+        for( var i = 0; i < request.form.length; i++ ) {
+            lines.push('form.append(' + JSON.stringify(request.form[i].name) + ', ' + JSON.stringify(request.form[i].value) + ');');
+        }
+        for( var i = 0; i < request.files.length; i++ ) {
+            lines.push('form.append(' + JSON.stringify(request.files[i].name) + ', fileInput.files[0]); // ' + request.files[i].filename);
+        }
+        lines.push('');
+        body = 'form';
+    } else if( request.body ) {
+        body = JSON.stringify(request.body);
+    }
+
+    lines.push('const response = await fetch(' + JSON.stringify(request.url) + ', {',
+               '  method: ' + JSON.stringify(request.method) + ',',
+               '  headers: ' + JSON.stringify(headers, null, 2).replace(/\n/g, '\n  ') + (body ? ',' : ''));
+    if( body ) {
+        lines.push('  body: ' + body);
+    }
+    lines.push('});', '', 'console.log(response.status, await response.text());');
+    return lines.join('\n');
+};
+apiExplorer.snippets.show = function(request) {
+    if( request ) this._request = request;
+    if( !this._request ) return;
+
+    $('#snippet').text(this[this._language](this._request));
+    $('.snippet-language').each( function() {
+        $(this).parent().toggleClass('active', $(this).data('language') == apiExplorer.snippets._language);
+    });
+};
+
+$(document).on('click', '.snippet-language', function(event) {
+    event.preventDefault();
+    apiExplorer.snippets._language = $(this).data('language');
+    apiExplorer.snippets.show();
+});
+$(document).on('click', '#snippet-copy', function(event) {
+    event.preventDefault();
+    var button = $(this);
+    var label  = button.text();
+
+    navigator.clipboard.writeText($('#snippet').text()).then( function() {
+        button.text(button.data('copied'));
+        setTimeout(function() { button.text(label); }, 1500);
+    });
+});
+
+// --------------------------------------------------------------------------------------
+// Show the names of the files chosen for an upload beside its Browse button
+$(document).on('change', '#apiexplorer :file', function() {
+    var files = this.files || [];
+    var shown = $(this).parents('.input-group').find(':text');
+
+    shown.val( files.length > 1 ? files.length + ' ' + shown.data('files') : $(this).val().replace(/\\/g, '/').replace(/.*\//, '') );
+});
+
+// --------------------------------------------------------------------------------------
+// Send requests through the documentation server, for APIs that do not allow
+// cross-origin requests. The server forwards <path>/<scheme>/<host>/<path> requests.
+apiExplorer._proxy = null;
+
+apiExplorer.useProxy = function(path, csrf, spec) {
+    this._proxy = { path: path, csrf: csrf, spec: spec };
+};
+
+// --------------------------------------------------------------------------------------
+// Validation of responses against the documented response for their status, using the
+// parts of JSON schema that OpenAPI 2 allows. spec/validate.go does the same for the
+// verify command, and the two should be kept in step.
+apiExplorer._responseSchemas = {};
+
+apiExplorer.addResponseSchema = function(status, schema) {
+    this._responseSchemas[status] = schema ? JSON.parse(schema) : null;
+};
+
+// Returns the problems with value, each prefixed with the JSON pointer to where it is
+var _validate = function(schema, value, path, errors) {
+    if( !schema || schema.$ref ) return errors; // Unexpanded (circular) references are not followed
+
+    if( value === null ) {
+        if( !schema['x-nullable'] && schema.type ) errors.push(path + ': ' + 'null is not allowed');
+        return errors;
+    }
+
+    var type = Array.isArray(value) ? 'array' : typeof value;
+    if( type == 'number' && value % 1 === 0 ) type = 'integer';
+
+    var types = [].concat(schema.type || []);
+    if( types.length && types.indexOf(type) < 0 && !(type == 'integer' && types.indexOf('number') >= 0) ) {
+        errors.push(path + ': expected ' + types.join(' or ') + ', got ' + type);
+        return errors;
+    }
+    if( schema['enum'] && schema['enum'].indexOf(value) < 0 ) {
+        errors.push(path + ': ' + JSON.stringify(value) + ' is not one of ' + JSON.stringify(schema['enum']));
+    }
+    if( type == 'string' ) {
+        if( schema.minLength != null && value.length < schema.minLength ) errors.push(path + ': shorter than ' + schema.minLength);
+        if( schema.maxLength != null && value.length > schema.maxLength ) errors.push(path + ': longer than ' + schema.maxLength);
+        if( schema.pattern && !new RegExp(schema.pattern).test(value) ) errors.push(path + ': does not match ' + schema.pattern);
+    }
+    if( type == 'number' || type == 'integer' ) {
+        if( schema.minimum != null && value < schema.minimum ) errors.push(path + ': less than ' + schema.minimum);
+        if( schema.maximum != null && value > schema.maximum ) errors.push(path + ': greater than ' + schema.maximum);
+    }
+    if( type == 'array' ) {
+        if( schema.minItems != null && value.length < schema.minItems ) errors.push(path + ': fewer than ' + schema.minItems + ' items');
+        if( schema.maxItems != null && value.length > schema.maxItems ) errors.push(path + ': more than ' + schema.maxItems + ' items');
+        for( var i = 0; i < value.length; i++ ) {
+            _validate(schema.items, value[i], path + '/' + i, errors);
+        }
+    }
+    if( type == 'object' ) {
+        var properties = schema.properties || {};
+        var required   = schema.required || [];
+
+        for( var i = 0; i < required.length; i++ ) {
+            if( !(required[i] in value) ) errors.push(path + '/' + required[i] + ': is required');
+        }
+        for( var name in value ) {
+            if( name in properties ) {
+                _validate(properties[name], value[name], path + '/' + name, errors);
+            } else if( schema.additionalProperties === false ) {
+                errors.push(path + '/' + name + ': is not a documented property');
+            } else if( typeof schema.additionalProperties == 'object' ) {
+                _validate(schema.additionalProperties, value[name], path + '/' + name, errors);
+            }
+        }
+    }
+    for( var i = 0; i < (schema.allOf || []).length; i++ ) {
+        _validate(schema.allOf[i], value, path, errors);
+    }
+    return errors;
+};
+
+apiExplorer.validateResponse = function(status, content, text) {
+    var $verdict = $('#response_validation');
+    var schemas  = this._responseSchemas;
+    var schema   = (status in schemas) ? schemas[status] : schemas['default'];
+
+    $verdict.empty().removeClass('alert alert-success alert-warning alert-danger');
+    if( status == 0 || $.isEmptyObject(schemas) ) {
+        $verdict.hide();
+        return;
+    }
+
+    if( schema === undefined ) {
+        $verdict.addClass('alert alert-warning').text($verdict.data('undocumented'));
+    } else {
+        var errors = [];
+        if( schema && content.match(/json/) ) {
+            try {
+                _validate(schema, JSON.parse(text), '', errors);
+            } catch(e) {
+                errors.push(e.message);
+            }
+        }
+        if( errors.length ) {
+            var $list = $('<ul>');
+            for( var i = 0; i < errors.length; i++ ) {
+                $('<li>').text(errors[i].replace(/^: /, '/: ')).appendTo($list);
+            }
+            $verdict.addClass('alert alert-danger').text($verdict.data('nonconforming')).append($list);
+        } else {
+            $verdict.addClass('alert alert-success').text($verdict.data('conforms'));
+        }
+    }
+    $verdict.show();
+};
+
 apiExplorer.addRequestMime   = function(type) { this._bodyMime[type] = type; }
 apiExplorer.listRequestMime  = function()     { return Object.keys(this._bodyMime); }
 apiExplorer.getRequestMime   = function(type) { return this._bodyMime[type]; }
@@ -173,6 +745,8 @@ var _process = function(text, status, xhr, fullhost) {
         $('#response_body').text( "Unexpected error: " + err.message + ' ' + err.line );
     }
 
+    apiExplorer.validateResponse( xhr.status, content, text );
+
     $('#results').show();
     $('#response').fadeIn().show();
 
@@ -228,6 +802,46 @@ var _get_url = function(url,query) {
     return { fullUrl: full_url, fullhost:urlp.hostname + port, requestUrl: urlp.pathname + urlp.search };
 };
 
+// --------------------------------------------------------------------------------------
+// Serialize an array or object parameter value in the parameter's style, returning the
+// name and value pairs to send. Arrays are entered comma separated or as a JSON array,
+// and objects as a JSON object.
+var _serialize_param = function( name, val, style, explode ) {
+    var parsed;
+    try {
+        parsed = JSON.parse(val);
+    } catch(e) {
+        parsed = null;
+    }
+
+    if( parsed && typeof parsed == 'object' && !$.isArray(parsed) ) {
+        var keys = Object.keys(parsed);
+        if( style == 'deepObject' ) {
+            return keys.map( function(k) { return { name: name + '[' + k + ']', value: parsed[k] }; });
+        }
+        if( explode && style == 'form' ) {
+            return keys.map( function(k) { return { name: k, value: parsed[k] }; });
+        }
+        var pairs = keys.map( function(k) { return k + (explode ? '=' : ',') + parsed[k]; });
+        return [ { name: name, value: pairs.join(',') } ];
+    }
+
+    var items = $.isArray(parsed) ? parsed : val.split(',').map( function(v) { return v.trim(); });
+    if( explode && style == 'form' ) {
+        return items.map( function(v) { return { name: name, value: v }; });
+    }
+    var separators = { spaceDelimited: ' ', pipeDelimited: '|', tabDelimited: '\t' };
+    var value = items.join(separators[style] || ',');
+    if( style == 'label' ) {
+        value = '.' + items.join(explode ? '.' : ',');
+    }
+    if( style == 'matrix' ) {
+        value = explode ? items.map( function(v) { return ';' + name + '=' + v; }).join('')
+                        : ';' + name + '=' + value;
+    }
+    return [ { name: name, value: value } ];
+};
+
 // --------------------------------------------------------------------------------------
 //
 apiExplorer.go = function( method, url ){
@@ -243,6 +857,7 @@ apiExplorer.go = function( method, url ){
     var request_content_type = "application/json";
     var response_content_type = "application/json";
     var form_data = new FormData();
+    var inputs    = {}; // As entered, for the history
 
     $('#apiexplorer :input').each( function() {
         var $input   = $(this);
@@ -251,6 +866,11 @@ apiExplorer.go = function( method, url ){
         var val      = $input.val(); //.trim();
         var name     = $input.prop('name');
 
+        if( type != 'file' ) {
+            if( name ) inputs[name] = val;
+            val = apiExplorer.environments.substitute(val);
+        }
+
         $input.removeClass("errorfield");
 
         // Pick up any missing mandatory fields
@@ -259,22 +879,27 @@ apiExplorer.go = function( method, url ){
         }
 
         var obj = { "name":name, "value":val}
+        var params = [ obj ];
+        if( $input.data('style') && val ) {
+            params = _serialize_param( name, val, $input.data('style'), $input.data('explode') );
+        }
 
         if( type=='path' ) {
-            url = url.replace('{'+name+'}', val);
+            url = url.replace('{'+name+'}', params[0].value);
         }
         if( type=='query' && val ) {
-            query.push( obj );
+            query.push.apply( query, params );
         }
         if( type=='header' && val ) {
-            headers.push( obj );
+            headers.push.apply( headers, params );
         }
         if( type=='form' && val ) {
-            form.push( obj );
+            form.push.apply( form, params );
         }
         if( type=='file' && val ) {
-            obj.file = $input[0].files[0];
-            file.push( obj );
+            for( var i = 0; i < $input[0].files.length; i++ ) {
+                file.push( { "name": name, "value": val, "file": $input[0].files[i] } );
+            }
         }
         if( type=='body' && val ) {
             body = val;
@@ -299,6 +924,13 @@ apiExplorer.go = function( method, url ){
         }
     });
 
+    url = apiExplorer.environments.rebase(url);
+
+    var custom = apiExplorer.environments.headers();
+    for( var name in custom ) {
+        headers.push( { "name": name, "value": apiExplorer.environments.substitute(custom[name]) } );
+    }
+
     // Handle errors
     if( errors.length ) {
         $.each( errors, function( index, value ) {
@@ -312,29 +944,35 @@ apiExplorer.go = function( method, url ){
     }
 
     var body_text;
+    var data;
 
     $('#request_body').hide();
     $('#jsonerror').hide();
 
+    // Form parameters are sent url encoded, unless there are files to upload or the
+    // multipart/form-data request Content-Type was chosen.
+    var multipart = file.length > 0 || /multipart\/form-data/.test(request_content_type);
+
     if( form.length )
     {
-        body_text = $.param(form);
-        var ct = "application/x-www-form-urlencoded";// Just for display purposes. formData will set it's own.
-        display_content_type = "\nContent-Type: "+ct;
-        $('#request_body').html( hljs.highlightAuto( body_text ).value );
-        $('#request_body').show();
-
-        for( var p in form ) {
-            form_data.append( form[p].name, form[p].value);
+        if( multipart ) {
+            for( var p in form ) {
+                form_data.append( form[p].name, form[p].value);
+            }
+        } else {
+            body_text = $.param(form);
+            data = body_text;
+            request_content_type = "application/x-www-form-urlencoded";
+            display_content_type = "\nContent-Type: "+request_content_type;
+            $('#request_body').html( hljs.highlightAuto( body_text ).value );
+            $('#request_body').show();
         }
     }
 
     if( file.length )
     {
-        var ct = "application/x-www-form-urlencoded"; // Just for display purposes. formData will set it's own.
-        display_content_type = "\nContent-Type: "+ct;
         for( var p in file ) {
-            form_data.append( file[p].name, file[p].file);
+            form_data.append( file[p].name, file[p].file, file[p].file.name);
         }
     }
 
@@ -372,7 +1010,6 @@ apiExplorer.go = function( method, url ){
         break;
     }
 
-    var data;
     if( gotbody )
     {
         // TODO need to handle outer mime types, such as XML, text etc
@@ -388,7 +1025,6 @@ apiExplorer.go = function( method, url ){
             if( got_form_data ) {
                 var blob = new Blob([JSON.parse(body_text)], {type: request_content_type});
                 form_data.append(body_name, blob, body_name); // name, content, filename
-                request_content_type = "application/x-www-form-urlencoded"; // Just for display purposes. multipart will contain real
             } else {
                 data = body;
             }
@@ -398,6 +1034,25 @@ apiExplorer.go = function( method, url ){
 
     if( got_form_data ) {
         data = form_data;
+
+        // The browser sets the real Content-Type, with the part boundary. Show the parts
+        // without the content of the files.
+        var parts = [];
+        for( var p in form ) {
+            parts.push( 'Content-Disposition: form-data; name="' + form[p].name + '"\n\n' + form[p].value );
+        }
+        for( var p in file ) {
+            parts.push( 'Content-Disposition: form-data; name="' + file[p].name + '"; filename="' + file[p].file.name + '"\n' +
+                        'Content-Type: ' + (file[p].file.type || 'application/octet-stream') + '\n\n' +
+                        '<' + file[p].file.size + ' bytes>' );
+        }
+        if( body_name && body_text != '{}' ) {
+            parts.push( 'Content-Disposition: form-data; name="' + body_name + '"; filename="' + body_name + '"\n' +
+                        'Content-Type: ' + request_content_type + '\n\n' + body );
+        }
+        display_content_type = "\nContent-Type: multipart/form-data";
+        $('#request_body').text( parts.join('\n\n') );
+        $('#request_body').show();
     }
 
     // Set up Accept header
@@ -415,12 +1070,43 @@ apiExplorer.go = function( method, url ){
     // TODO Get protocol from passed in url
     $('#request_url').html( hljs.highlight( 'http', method.toUpperCase() + ' ' + display_url + ' HTTP/1.1\nHost: ' + constructed_request.fullhost + display_content_type + display_headers ).value );
 
+    var snippet_body = (!got_form_data && typeof data == 'string') ? data : '';
+    apiExplorer.snippets.show({
+        method:  method.toUpperCase(),
+        url:     constructed_request.fullUrl,
+        headers: snippet_body ? headers.concat([{ name: "Content-Type", value: request_content_type }]) : headers,
+        body:    snippet_body,
+        form:    got_form_data ? form : [],
+        files:   file.map(function(f) { return { name: f.name, filename: f.file.name }; })
+    });
+
     $('#exploreButton').attr('disabled', 'disabled');
 
     $.support.cors = true;
 
+    var request_url = constructed_request.fullUrl;
+    if( apiExplorer._proxy ) {
+        request_url = apiExplorer._proxy.path + '/' + request_url.replace(/^([a-z]+):\/\//i, '$1/');
+        headers.push( { "name": "X-CSRF-Token", "value": apiExplorer._proxy.csrf } );
+        headers.push( { "name": "X-Dapperdox-Spec", "value": apiExplorer._proxy.spec } );
+
+        // The server signs the request with these, if the API requires it, and presents
+        // the environment's client certificate, if it has one.
+        var environment = apiExplorer.environments.current();
+        if( environment ) {
+            headers.push( { "name": "X-Dapperdox-Environment", "value": environment.name } );
+        }
+        if( environment && environment.signingKey ) {
+            headers.push( { "name": "X-Dapperdox-Signing-Key",    "value": environment.signingKey } );
+            headers.push( { "name": "X-Dapperdox-Signing-Secret", "value": environment.signingSecret || "" } );
+            headers.push( { "name": "X-Dapperdox-Signing-Token",  "value": environment.signingToken || "" } );
+        }
+    }
+
+    var started = Date.now();
+
     $.ajax({
-        url: constructed_request.fullUrl,
+        url: request_url,
         async: true,
         data: data,
         type: method,
@@ -436,7 +1122,11 @@ apiExplorer.go = function( method, url ){
             $('#progress').stop(1,0).hide().delay(800).fadeIn();
             $('#response').stop(1,0).delay(10).hide();
         },
-        complete:   function() { $('#progress').stop(1,0).hide(); }
+        complete:   function(xhr) {
+            $('#progress').stop(1,0).hide();
+            apiExplorer.history.record({ page: window.location.pathname, time: started, method: method, url: display_url,
+                                         status: xhr.status, duration: Date.now() - started, inputs: inputs });
+        }
         //,statusCode: {
         //    404: function() {
         //        alert("Not found");
@@ -0,0 +1,147 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/render/asset"
+	"github.com/gorilla/pat"
+)
+
+// Site relative links in rendered pages, which the build follows. data-segment links
+// the navigation that segmented sidebars fetch, and data-index the search index.
+var siteLink = regexp.MustCompile(`(?:href|src|data-segment|data-index)="(/[^"#?]*)`)
+
+// ---------------------------------------------------------------------------
+// buildSite renders every page reachable from the home page, and all static
+// assets, into the output directory, so that the documentation can be hosted
+// without the server. Pages are written as <path>/index.html.
+func buildSite(args []string) int {
+	cfg, err := config.Get()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error configuring app: %s\n", err)
+		return 1
+	}
+	if len(cfg.OutputDir) == 0 {
+		fmt.Fprintln(os.Stderr, "build requires -output-dir")
+		return 1
+	}
+
+	if err = loadSpecifications(); err != nil {
+		fmt.Fprintf(os.Stderr, "Load specification error: %s\n", err)
+		return 1
+	}
+
+	router, err := newSiteRouter(pat.New())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error registering handlers: %s\n", err)
+		return 1
+	}
+
+	built, failures := crawlSite(router, func(path, file string, body []byte) error {
+		file = filepath.Join(cfg.OutputDir, file)
+		err := os.MkdirAll(filepath.Dir(file), 0755)
+		if err == nil {
+			err = ioutil.WriteFile(file, body, 0644)
+		}
+		if err != nil {
+			return fmt.Errorf("error writing %s: %s", file, err)
+		}
+		fmt.Printf("  ok    %s\n", path)
+		return nil
+	})
+	if built < 0 {
+		return 1
+	}
+
+	fmt.Printf("\nBuilt %d files into %s\n", built, cfg.OutputDir)
+	if failures > 0 {
+		fmt.Printf("%d page(s) failed\n", failures)
+		return 1
+	}
+	return 0
+}
+
+// ---------------------------------------------------------------------------
+// crawlSite renders every page reachable from the home page, and all static assets,
+// giving each to write along with the file it belongs in, relative to the output
+// directory. It returns the number of files written and of paths that failed to
+// render, or -1 files if write failed.
+func crawlSite(router http.Handler, write func(path, file string, body []byte) error) (int, int) {
+	queue := []string{"/"}
+	for _, name := range asset.AssetNames() {
+		if strings.HasPrefix(name, "assets/static/") {
+			queue = append(queue, strings.TrimPrefix(name, "assets/static"))
+		}
+	}
+
+	seen := map[string]bool{}
+	written, failures := 0, 0
+
+	for len(queue) > 0 {
+		path := queue[0]
+		queue = queue[1:]
+
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		req := httptest.NewRequest("GET", path, nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		body := rec.Body.Bytes()
+
+		switch {
+		case rec.Code == http.StatusOK:
+		case rec.Code >= 300 && rec.Code < 400 && strings.HasPrefix(rec.Header().Get("Location"), "/"):
+			location := rec.Header().Get("Location")
+			body = []byte(fmt.Sprintf(`<!DOCTYPE html><meta http-equiv="refresh" content="0; url=%s"><a href="%s">%s</a>`, location, location, location))
+		default:
+			fmt.Printf("  FAIL  %s: status %d\n", path, rec.Code)
+			failures++
+			continue
+		}
+
+		file := filepath.FromSlash(strings.TrimPrefix(path, "/"))
+		isPage := strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") || rec.Code != http.StatusOK
+		if isPage {
+			file = filepath.Join(file, "index.html")
+			for _, match := range siteLink.FindAllSubmatch(body, -1) {
+				queue = append(queue, string(match[1]))
+			}
+		}
+
+		if err := write(path, file, body); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return -1, failures
+		}
+		written++
+	}
+	return written, failures
+}
@@ -0,0 +1,221 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/render"
+	"github.com/dapperdox/dapperdox/spec"
+)
+
+// checkReport collects the outcome of each configuration check.
+type checkReport struct {
+	failures int
+}
+
+func (r *checkReport) pass(format string, args ...interface{}) {
+	fmt.Printf("  ok    "+format+"\n", args...)
+}
+
+func (r *checkReport) fail(format string, args ...interface{}) {
+	r.failures++
+	fmt.Printf("  FAIL  "+format+"\n", args...)
+}
+
+func (r *checkReport) section(name string) {
+	fmt.Printf("\n%s\n", name)
+}
+
+// ---------------------------------------------------------------------------
+// checkConfig fully parses the configuration, loads the specifications and
+// compiles the theme and overlays, without starting the server. It prints a
+// report and returns the process exit code, which is non-zero if any check failed.
+func checkConfig(args []string) int {
+	r := &checkReport{}
+
+	cfg, err := config.Get()
+	if err != nil {
+		fmt.Printf("error configuring app: %s\n", err)
+		return 1
+	}
+
+	fmt.Printf("DapperDox %s configuration check\n", VERSION)
+
+	r.section("Settings")
+	if len(cfg.ConfigFile) > 0 {
+		r.pass("configuration file %s", cfg.ConfigFile)
+	}
+	if _, _, err := net.SplitHostPort(cfg.BindAddr); err != nil {
+		r.fail("bind-addr %s: %s", cfg.BindAddr, err)
+	} else {
+		r.pass("bind-addr %s", cfg.BindAddr)
+	}
+	if (len(cfg.TLSCertificate) > 0) != (len(cfg.TLSKey) > 0) {
+		r.fail("tls-certificate and tls-key must be given together")
+	}
+	checkFile(r, "tls-certificate", cfg.TLSCertificate)
+	checkFile(r, "tls-key", cfg.TLSKey)
+	if cfg.AccessLogFormat != "combined" && cfg.AccessLogFormat != "json" {
+		r.fail("access-log-format %s is not combined or json", cfg.AccessLogFormat)
+	}
+	switch cfg.AnalyticsProvider {
+	case "":
+	case "google", "matomo", "plausible":
+		if len(cfg.AnalyticsSiteID) == 0 {
+			r.fail("analytics-provider %s requires analytics-site-id", cfg.AnalyticsProvider)
+		}
+		if cfg.AnalyticsProvider == "matomo" && len(cfg.AnalyticsURL) == 0 {
+			r.fail("analytics-provider matomo requires analytics-url")
+		}
+	default:
+		r.fail("analytics-provider %s is not google, matomo or plausible", cfg.AnalyticsProvider)
+	}
+	if cfg.DebugPprof && !strings.Contains(cfg.DebugCredentials, ":") {
+		r.fail("debug-pprof requires debug-credentials in the form username:password")
+	}
+	for _, p := range cfg.ProxyPath {
+		if len(strings.Split(p, "=")) != 2 {
+			r.fail("proxy-path %s is not of the form local-path=scheme://host/dst-path", p)
+		}
+	}
+	if len(cfg.ExplorerAudit) > 0 && !cfg.ExplorerProxy {
+		r.fail("explorer-audit requires explorer-proxy, as only proxied requests are audited")
+	}
+	if len(cfg.RecordUpstream) > 0 {
+		if u, err := url.Parse(cfg.RecordUpstream); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			r.fail("record-upstream %s is not an http or https URL", cfg.RecordUpstream)
+		}
+		if len(cfg.RecordBindAddr) == 0 {
+			r.fail("record-upstream requires record-bind-addr")
+		}
+	}
+	switch cfg.ExplorerReplay {
+	case "", "off":
+	case "record", "replay", "fallback":
+		if !cfg.ExplorerProxy || len(cfg.ExplorerReplayDir) == 0 {
+			r.fail("explorer-replay requires explorer-proxy and explorer-replay-dir")
+		}
+	default:
+		r.fail("explorer-replay %s is not off, record, replay or fallback", cfg.ExplorerReplay)
+	}
+
+	if cfg.SpecFailures != "stop" && cfg.SpecFailures != "skip" {
+		r.fail("spec-failures %s is not stop or skip", cfg.SpecFailures)
+	}
+
+	r.section("Assets and themes")
+	checkDir(r, "default-assets-dir", cfg.DefaultAssetsDir)
+	checkDir(r, "assets-dir", cfg.AssetsDir)
+	checkDir(r, "theme-dir", cfg.ThemeDir)
+	if len(cfg.Theme) > 0 {
+		dir := filepath.Join(cfg.DefaultAssetsDir, "themes")
+		if len(cfg.ThemeDir) > 0 {
+			dir = cfg.ThemeDir
+		}
+		checkDir(r, "theme "+cfg.Theme, filepath.Join(dir, cfg.Theme))
+	}
+
+	r.section("Specifications")
+	checkDir(r, "spec-dir", cfg.SpecDir)
+	for _, location := range cfg.SpecFilename {
+		if len(cfg.SpecDir) > 0 && !strings.Contains(location, "://") {
+			checkFile(r, "spec-filename", filepath.Join(cfg.SpecDir, location))
+		}
+	}
+
+	if err = loadSpecifications(); err != nil {
+		r.fail("loading specifications: %s", err)
+	}
+
+	for id, s := range spec.APISuite {
+		r.pass("specification %s (%s), %d APIs", id, s.APIInfo.Title, len(s.APIs))
+	}
+	for _, failure := range spec.LoadFailures {
+		r.fail("specification %s was skipped: %s", failure.Location, failure.Error)
+	}
+
+	r.section("Templates and overlays")
+	if r.failures == 0 {
+		if err := compileTemplates(); err != nil {
+			r.fail("compiling templates: %s", err)
+		} else {
+			r.pass("templates compiled")
+		}
+	} else {
+		fmt.Println("  skipped, fix the errors above first")
+	}
+
+	return report(r)
+}
+
+// ---------------------------------------------------------------------------
+
+func report(r *checkReport) int {
+	if r.failures > 0 {
+		fmt.Printf("\n%d check(s) failed\n", r.failures)
+		return 1
+	}
+	fmt.Println("\nConfiguration OK")
+	return 0
+}
+
+// ---------------------------------------------------------------------------
+// compileTemplates compiles the assets as the server would, recovering from the
+// panic that a template parse error raises.
+func compileTemplates() (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%v", p)
+		}
+	}()
+	render.Register()
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+
+func checkDir(r *checkReport, setting string, dir string) {
+	if len(dir) == 0 {
+		return
+	}
+	if info, err := os.Stat(dir); err != nil {
+		r.fail("%s %s: %s", setting, dir, err)
+	} else if !info.IsDir() {
+		r.fail("%s %s is not a directory", setting, dir)
+	} else {
+		r.pass("%s %s", setting, dir)
+	}
+}
+
+func checkFile(r *checkReport, setting string, file string) {
+	if len(file) == 0 {
+		return
+	}
+	if _, err := os.Stat(file); err != nil {
+		r.fail("%s %s: %s", setting, file, err)
+	} else {
+		r.pass("%s %s", setting, file)
+	}
+}
@@ -0,0 +1,127 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/handlers/specs"
+	"github.com/dapperdox/dapperdox/spec"
+	"github.com/gorilla/pat"
+)
+
+// A command is a subcommand of the dapperdox binary. Options common to all commands
+// are the usual flags and environment variables, and command specific options are
+// flags that only that command uses. Positional arguments follow the command name.
+type command struct {
+	name        string
+	usage       string
+	description string
+	run         func(args []string) int // nil for serve
+}
+
+var commands = []command{
+	{"serve", "serve", "Serve the documentation. This is the default.", nil},
+	{"build", "build -output-dir <dir>", "Render the whole site to static files.", buildSite},
+	{"validate", "validate", "Check the configuration, specifications and theme, without serving.", checkConfig},
+	{"lint", "lint", "Report documentation gaps, such as undocumented operations and parameters.", lintSpecs},
+	{"diff", "diff <old spec> <new spec>", "Report the differences between two specification files, flagging breaking changes.", diffSpecs},
+	{"export", "export [-output-dir <dir>]", "Export the parsed API model as JSON, one file per specification.", exportSpecs},
+	{"verify", "verify <environment or URL>", "Call each documented operation with its example values, reporting responses that do not match the documented status codes and schemas.", verifyAPI},
+	{"snapshot", "snapshot <dir>", "Render the whole site and compare it with the snapshot in a directory, reporting the differences. -snapshot-update replaces the snapshot.", snapshotSite},
+	{"reload", "reload [spec ID]", "Ask the server at site-url to reload the site, or only the specification with an ID, without restarting. Requires reload-token.", requestReload},
+}
+
+// ---------------------------------------------------------------------------
+// parseCommand takes the command and its positional arguments from the command
+// line, leaving only flags for the configuration to parse.
+func parseCommand() (command, []string) {
+	if len(os.Args) < 2 || strings.HasPrefix(os.Args[1], "-") {
+		return commands[0], nil
+	}
+
+	name := os.Args[1]
+	rest := os.Args[2:]
+
+	var args []string
+	for len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+		args = append(args, rest[0])
+		rest = rest[1:]
+	}
+	os.Args = append([]string{os.Args[0]}, rest...)
+
+	for _, c := range commands {
+		if c.name == name {
+			return c, args
+		}
+	}
+	if name == "help" {
+		usage()
+		os.Exit(0)
+	}
+
+	fmt.Fprintf(os.Stderr, "Unknown command %s\n\n", name)
+	usage()
+	os.Exit(2)
+	return command{}, nil
+}
+
+// ---------------------------------------------------------------------------
+
+func usage() {
+	fmt.Printf("Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Printf("  %-30s %s\n", c.usage, c.description)
+	}
+	fmt.Printf("  %-30s %s\n", "help", "Show this help.")
+	fmt.Printf("\nRun %s -help for the flags common to all commands.\n", os.Args[0])
+}
+
+// ---------------------------------------------------------------------------
+// loadSpecifications loads the configured specifications for commands that do not
+// start the server. They are served to the loader over HTTP, as at startup, but
+// from a private port so that a running server is not disturbed.
+func loadSpecifications() error {
+	cfg, _ := config.Get()
+	return serveSpecifications(func(specHost string) error {
+		return spec.LoadSpecifications(specHost, cfg.SpecMerge)
+	})
+}
+
+// serveSpecifications serves the specification files from a private port while load
+// reads them from the host it is given
+func serveSpecifications(load func(specHost string) error) error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("cannot listen to serve specifications: %s", err)
+	}
+	defer listener.Close()
+
+	router := pat.New()
+	go http.Serve(listener, router)
+
+	specs.Register(router)
+	spec.LoadStatusCodes()
+
+	return load(listener.Addr().String())
+}
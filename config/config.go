@@ -18,8 +18,11 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>.
 package config
 
 import (
+	"fmt"
 	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/dapperdox/dapperdox/logger"
 	"github.com/ian-kent/gofigure"
@@ -27,6 +30,7 @@ import (
 
 type config struct {
 	gofigure           interface{} `order:"env,flag"`
+	ConfigFile         string      `env:"CONFIG_FILE" flag:"config" flagDesc:"YAML configuration file. Settings are named after their command line flag. Command line flags override DAPPERDOX_ prefixed environment variables, which override the other environment variables, which override the file."`
 	BindAddr           string      `env:"BIND_ADDR" flag:"bind-addr" flagDesc:"Bind address"`
 	AssetsDir          string      `env:"ASSETS_DIR" flag:"assets-dir" flagDesc:"Assets to serve. Effectively the document root."`
 	DefaultAssetsDir   string      `env:"DEFAULT_ASSETS_DIR" flag:"default-assets-dir" flagDesc:"Default assets."`
@@ -43,23 +47,160 @@ type config struct {
 	ProxyPath          []string    `env:"PROXY_PATH" flag:"proxy-path" flagDesc:"Give a path to proxy though to another service. May be multiply defined. Format is local-path=scheme://host/dst-path."`
 	TLSCertificate     string      `env:"TLS_CERTIFICATE" flag:"tls-certificate" flagDesc:"The fully qualified path to the TLS certificate file. For HTTP over TLS (HTTPS) both a certificate and a key must be provided."`
 	TLSKey             string      `env:"TLS_KEY" flag:"tls-key" flagDesc:"The fully qualified path to the TLS private key file. For HTTP over TLS (HTTPS) both a certificate and a key must be provided."`
+	AccessLog          string      `env:"ACCESS_LOG" flag:"access-log" flagDesc:"File to write HTTP access logs to. Use stdout or stderr to log to the console. Access logging is disabled if not set."`
+	AccessLogFormat    string      `env:"ACCESS_LOG_FORMAT" flag:"access-log-format" flagDesc:"Format of the access log, either combined or json."`
+	AccessLogExclude   []string    `env:"ACCESS_LOG_EXCLUDE" flag:"access-log-exclude" flagDesc:"Path prefix to exclude from the access log, such as /css or a health check. May be multiply defined."`
+	Metrics            bool        `env:"METRICS" flag:"metrics" flagDesc:"Expose Prometheus metrics at /metrics."`
+	TraceEndpoint      string      `env:"TRACE_ENDPOINT" flag:"trace-endpoint" flagDesc:"The host:port of an OTLP/HTTP collector to export traces to. Tracing is disabled if not set."`
+	TraceInsecure      bool        `env:"TRACE_INSECURE" flag:"trace-insecure" flagDesc:"Export traces over plain HTTP rather than HTTPS."`
+	DebugPprof         bool        `env:"DEBUG_PPROF" flag:"debug-pprof" flagDesc:"Serve Go runtime profiling data under /debug/pprof/. Requires debug-credentials."`
+	DebugCredentials   string      `env:"DEBUG_CREDENTIALS" flag:"debug-credentials" flagDesc:"The username:password required to access the /debug/pprof/ endpoints."`
+	Check              bool        `env:"CHECK" flag:"check" flagDesc:"Check the configuration, specifications and theme, print a report and exit without starting the server. Exits non-zero if any check fails."`
+	OutputDir          string      `env:"OUTPUT_DIR" flag:"output-dir" flagDesc:"Directory to write to, for the build and export commands."`
+	SnapshotUpdate     bool        `env:"SNAPSHOT_UPDATE" flag:"snapshot-update" flagDesc:"Replace the snapshot with the pages rendered, rather than comparing them with it, for the snapshot command."`
+	VerifyUnsafe       bool        `env:"VERIFY_UNSAFE" flag:"verify-unsafe" flagDesc:"Also call operations that may change data, such as POST and DELETE, for the verify command. Only GET, HEAD and OPTIONS operations are called if not set."`
+	SpecConnectTimeout string      `env:"SPEC_CONNECT_TIMEOUT" flag:"spec-connect-timeout" flagDesc:"Timeout for connecting to a specification's host, such as 10s."`
+	SpecReadTimeout    string      `env:"SPEC_READ_TIMEOUT" flag:"spec-read-timeout" flagDesc:"Timeout for reading a specification once connected, such as 30s."`
+	SpecFetchRetries   int         `env:"SPEC_FETCH_RETRIES" flag:"spec-fetch-retries" flagDesc:"Number of times to retry fetching a specification, with backoff, before giving up."`
+	SpecOptional       []string    `env:"SPEC_OPTIONAL" flag:"spec-optional" flagDesc:"A spec-filename that is skipped, rather than stopping startup, if it cannot be loaded. May be multiply defined."`
+	SpecFailures       string      `env:"SPEC_FAILURES" flag:"spec-failures" flagDesc:"What to do when a spec-filename cannot be loaded. Either stop, which stops startup, or skip, which serves the rest and lists those that failed on the /status page."`
+	SpecMerge          bool        `env:"SPEC_MERGE" flag:"spec-merge" flagDesc:"Merge all specifications into one, collating API groups of the same name."`
+	SpecMergeTitle     string      `env:"SPEC_MERGE_TITLE" flag:"spec-merge-title" flagDesc:"Title of the merged specification. Defaults to the title of the first specification."`
+	LinkResources      string      `env:"SPEC_LINK_RESOURCES" flag:"spec-link-resources" flagDesc:"Link resources shared between specifications, so that resource pages show usage across them. Either off, title (same title) or definition (same title and schema)."`
+	SpecPollInterval   string      `env:"SPEC_POLL_INTERVAL" flag:"spec-poll-interval" flagDesc:"How often to check URL based specifications for changes, such as 5m. Changed specifications are reloaded. Polling is disabled if not set."`
+	GitRepoDir         string      `env:"GIT_REPO_DIR" flag:"git-repo-dir" flagDesc:"A git working copy holding the specifications and assets. It is updated at startup and on reload, and the /hooks/git webhook reloads the site."`
+	GitRepoURL         string      `env:"GIT_REPO_URL" flag:"git-repo-url" flagDesc:"URL of a git repository to clone into git-repo-dir, rather than pulling an existing working copy."`
+	GitRepoRef         string      `env:"GIT_REPO_REF" flag:"git-repo-ref" flagDesc:"Branch, tag or commit of git-repo-url to check out. Defaults to the repository's default branch."`
+	GitHookSecret      string      `env:"GIT_HOOK_SECRET" flag:"git-hook-secret" flagDesc:"The shared secret that /hooks/git calls are verified with, as a GitHub signature or GitLab token."`
+	ReloadToken        string      `env:"RELOAD_TOKEN" flag:"reload-token" flagDesc:"The bearer token that /reload calls, which reload the site or one specification, must give. The endpoint is disabled if not set."`
+	OutboundProxy      string      `env:"OUTBOUND_PROXY" flag:"outbound-proxy" flagDesc:"Proxy URL for fetching specifications and for the explorer proxy. Defaults to the HTTPS_PROXY and HTTP_PROXY environment variables."`
+	OutboundCABundle   []string    `env:"OUTBOUND_CA_BUNDLE" flag:"outbound-ca-bundle" flagDesc:"PEM file of additional CAs to trust for outbound connections. Format is file, or host=file to trust it for one host only. May be multiply defined."`
+	OutboundInsecure   []string    `env:"OUTBOUND_INSECURE_HOST" flag:"outbound-insecure-host" flagDesc:"Host for which outbound TLS certificates are not verified. May be multiply defined."`
+	NavExpanded        []string    `env:"NAV_EXPANDED" flag:"nav-expanded" flagDesc:"API group ID to start expanded in the navigation, or * for all. May be multiply defined."`
+	NavMaxDepth        int         `env:"NAV_MAX_DEPTH" flag:"nav-max-depth" flagDesc:"Depth of the API navigation: 1 lists API groups only, 2 also lists their methods."`
+	NavResources       bool        `env:"NAV_RESOURCES" flag:"nav-resources" flagDesc:"Give resources their own section in the navigation."`
+	NavFixed           bool        `env:"NAV_FIXED" flag:"nav-fixed" flagDesc:"Show the navigation fully expanded, without collapsing API groups."`
+	NavSegment         int         `env:"NAV_SEGMENT" flag:"nav-segment" flagDesc:"Fetch the methods of each API group in the navigation when it is expanded, for specifications with more methods than this. 0 never does."`
+	Watch              bool        `env:"WATCH" flag:"watch" flagDesc:"Development mode. Watch specifications, guides and templates for changes, reload them and refresh open browser pages."`
+	Locale             string      `env:"LOCALE" flag:"locale" flagDesc:"Locale of the interface text, used when the browser asks for none that is available."`
+	LocaleFixed        bool        `env:"LOCALE_FIXED" flag:"locale-fixed" flagDesc:"Always use the configured locale, ignoring the browser's Accept-Language."`
+	EnvSession         bool        `env:"EXPLORER_ENV_SESSION" flag:"explorer-env-session" flagDesc:"Keep the environments readers add in the explorer in a server side session, rather than in browser local storage."`
+	ExplorerProxy      bool        `env:"EXPLORER_PROXY" flag:"explorer-proxy" flagDesc:"Send explorer requests through the server, so that they work against APIs that do not allow cross-origin requests. Only the hosts of the loaded specifications can be reached."`
+	SpecStats          bool        `env:"SPEC_STATS" flag:"spec-stats" flagDesc:"Serve a page of documentation statistics for each specification at /<specification-id>/stats, such as the share of operations with descriptions and examples, and the same as JSON at /<specification-id>/stats.json."`
+	ExplorerAudit      string      `env:"EXPLORER_AUDIT" flag:"explorer-audit" flagDesc:"Where to write an audit trail of the requests sent through the explorer-proxy, as a line of JSON each: stdout, stderr, a file name or webhook:<url>. Records give the client, session, target URL, method and status. Auditing is disabled if not set."`
+	ExplorerAuditBody  bool        `env:"EXPLORER_AUDIT_BODIES" flag:"explorer-audit-bodies" flagDesc:"Include the request and response bodies, up to 16KB each, in the explorer-audit trail."`
+	ExplorerReplay     string      `env:"EXPLORER_REPLAY" flag:"explorer-replay" flagDesc:"Record the responses to explorer-proxy requests, to replay them as an offline sandbox. Either off (the default), record, which forwards every request and records its response, replay, which never reaches the API, or fallback, which replays recorded responses and forwards and records the rest."`
+	ExplorerReplayDir  string      `env:"EXPLORER_REPLAY_DIR" flag:"explorer-replay-dir" flagDesc:"Directory that explorer-replay keeps recorded responses in."`
+	Mock               bool        `env:"MOCK" flag:"mock" flagDesc:"Serve the example responses of every operation under /mock/<specification-id>/<path>. Choose a response status with a Prefer: code=<status> header."`
+	ExampleValues      bool        `env:"EXAMPLE_VALUES" flag:"example-values" flagDesc:"Show plausible values in example JSON, from the enum, format and pattern of each property, rather than the name of its type."`
+	ExampleSeed        int         `env:"EXAMPLE_SEED" flag:"example-seed" flagDesc:"Seed for the example-values. They are the same each time the specifications are loaded, for a given seed."`
+	ExampleRandom      bool        `env:"EXAMPLE_RANDOM" flag:"example-random" flagDesc:"Vary the example-values each time the specifications are loaded, instead of seeding them with example-seed."`
+	SchemaNames        string      `env:"SCHEMA_NAMES" flag:"schema-names" flagDesc:"How schemas without a title are named. Either require, which stops with an error, or derive, which names them from their x-schema-name, their definition name, or the operation ID and role, such as createUserRequest."`
+	AllDefinitions     bool        `env:"ALL_DEFINITIONS" flag:"all-definitions" flagDesc:"Document every definition as a data model, including those that no operation uses."`
+	AnalyticsProvider  string      `env:"ANALYTICS_PROVIDER" flag:"analytics-provider" flagDesc:"Web analytics to add to every page. Either google, matomo or plausible. Analytics are disabled if not set."`
+	AnalyticsSiteID    string      `env:"ANALYTICS_SITE_ID" flag:"analytics-site-id" flagDesc:"The Google Analytics measurement ID, Matomo site ID or Plausible domain."`
+	AnalyticsURL       string      `env:"ANALYTICS_URL" flag:"analytics-url" flagDesc:"Base URL of the Matomo server, or of a self-hosted Plausible server."`
+	AnalyticsConsent   bool        `env:"ANALYTICS_CONSENT" flag:"analytics-consent" flagDesc:"Ask readers for consent before loading analytics, remembering their answer in the browser."`
+	FeedbackStore      string      `env:"FEEDBACK_STORE" flag:"feedback-store" flagDesc:"Where to keep the answers readers give to \"Was this page helpful?\": file:<path>, webhook:<url> or sql:<driver>:<data source name>. The question is not asked if not set."`
+	FeedbackReportAuth string      `env:"FEEDBACK_REPORT_CREDENTIALS" flag:"feedback-report-credentials" flagDesc:"The username:password required to read the feedback report at /feedback/report. The report is not served if not set."`
+	EmbedAncestors     []string    `env:"EMBED_ANCESTORS" flag:"embed-ancestors" flagDesc:"Origin of a site that may embed method and resource pages requested with ?embed=1, such as https://www.example.com. May be multiply defined. Any site may embed them if not set."`
+	ChangeFeedFile     string      `env:"CHANGE_FEED_FILE" flag:"change-feed-file" flagDesc:"File to keep the history of changes to each specification in, for its change feed at /<specification-id>/changes.atom. Only changes found since the server started are in the feed if not set."`
+	TermsSecret        string      `env:"TERMS_SECRET" flag:"terms-secret" flagDesc:"Key that signs the cookies recording readers' acceptance of a specification's terms. Acceptances are forgotten when the server restarts if not set."`
+	RateLimit          int         `env:"RATE_LIMIT" flag:"rate-limit" flagDesc:"Requests a minute that each client IP address may make to the explorer proxy, proxied paths, mock server, search indexes, schema exports, printable pages and feedback. Rate limiting is disabled if not set."`
+	RateLimitBurst     int         `env:"RATE_LIMIT_BURST" flag:"rate-limit-burst" flagDesc:"Requests a client may make at once before rate-limit applies. Defaults to rate-limit."`
+	RateLimitPath      []string    `env:"RATE_LIMIT_PATH" flag:"rate-limit-path" flagDesc:"Further path prefix to rate limit. May be multiply defined."`
+	ClientIPHeader     string      `env:"CLIENT_IP_HEADER" flag:"client-ip-header" flagDesc:"Request header giving the client IP address, such as X-Forwarded-For, when behind a proxy. The last address in it is used for rate limiting and access-rules. Only set this if the proxy always sets the header."`
+	Locales            []string    `env:"LOCALES" flag:"locales" flagDesc:"Further locale the documentation is published in. Its guides are read from guides/<locale> and its overlays from templates/<locale>. May be multiply defined."`
+	HARFile            []string    `env:"HAR_FILE" flag:"har-file" flagDesc:"HAR capture, such as one saved from browser developer tools or an API gateway, whose requests and responses are shown as examples of the methods they match. Credentials in them are redacted. May be multiply defined."`
+	RecordUpstream     string      `env:"RECORD_UPSTREAM" flag:"record-upstream" flagDesc:"URL of the real API to run a recording proxy in front of. Requests made through the proxy, and their responses, are shown as examples of the methods they match, with credentials redacted."`
+	RecordBindAddr     string      `env:"RECORD_BIND_ADDR" flag:"record-bind-addr" flagDesc:"Bind address of the recording proxy."`
+	RecordFile         string      `env:"RECORD_FILE" flag:"record-file" flagDesc:"HAR file to keep the examples harvested by the recording proxy in, so that they are shown again after a restart. They are forgotten if not set."`
 }
 
-var cfg *config
+// current is the published configuration, a *settings. It is never changed once
+// published, so that requests can read it while Reload publishes a new one.
+var current atomic.Value
+
+// loadLock serializes reading the configuration
+var loadLock sync.Mutex
 
 // Get configures the application and returns the configuration
 func Get() (*config, error) {
-	if cfg != nil {
-		return cfg, nil
+	if s, ok := current.Load().(*settings); ok {
+		return s.cfg, nil
+	}
+
+	loadLock.Lock()
+	defer loadLock.Unlock()
+
+	if s, ok := current.Load().(*settings); ok {
+		return s.cfg, nil
+	}
+	s, err := load()
+	if err != nil {
+		return nil, err
+	}
+	current.Store(s)
+	return s.cfg, nil
+}
+
+// Reload reads the configuration afresh from the file, environment and flags, and
+// publishes it for later calls of Get. Those holding the previous configuration keep
+// it, so long running handlers call Get for each request. The configuration is left as
+// it was if the new one has an error. Otherwise the function returned publishes the
+// previous configuration again, for when the site cannot be served with the new one.
+func Reload() (func(), error) {
+	loadLock.Lock()
+	defer loadLock.Unlock()
+
+	s, err := load()
+	if err != nil {
+		return nil, err
+	}
+	previous := published()
+	current.Store(s)
+
+	return func() {
+		loadLock.Lock()
+		defer loadLock.Unlock()
+		current.Store(previous)
+	}, nil
+}
+
+// published is the published configuration, or empty settings if it has not been read
+func published() *settings {
+	if s, ok := current.Load().(*settings); ok {
+		return s
+	}
+	return &settings{}
+}
+
+// load reads the configuration from the file, environment and flags
+func load() (*settings, error) {
+	cfg := &config{
+		BindAddr:           "localhost:3123",
+		SpecDir:            "",
+		DefaultAssetsDir:   "assets",
+		LogLevel:           "info",
+		SiteURL:            "http://localhost:3123/",
+		ShowAssets:         false,
+		AccessLogFormat:    "combined",
+		SpecConnectTimeout: "10s",
+		SpecReadTimeout:    "30s",
+		SpecFetchRetries:   2,
+		NavMaxDepth:        2,
+		Locale:             "en",
+		SchemaNames:        "require",
+		SpecFailures:       "stop",
 	}
+	s := &settings{cfg: cfg, specs: map[string]*SpecConfig{}}
 
-	cfg = &config{
-		BindAddr:         "localhost:3123",
-		SpecDir:          "",
-		DefaultAssetsDir: "assets",
-		LogLevel:         "info",
-		SiteURL:          "http://localhost:3123/",
-		ShowAssets:       false,
+	// The configuration file is read first, so that environment and flags override it,
+	// and the DAPPERDOX_ overrides last, so that they override the plain environment.
+	if name := configFileName(); len(name) > 0 || len(pathOverrides()) > 0 {
+		if err := s.loadFile(name); err != nil {
+			return nil, err
+		}
 	}
 
 	err := gofigure.Gofigure(cfg)
@@ -67,13 +208,46 @@ func Get() (*config, error) {
 		return nil, err
 	}
 
+	if err := cfg.loadEnvOverrides(); err != nil {
+		return nil, err
+	}
+
 	if len(cfg.SpecFilename) == 0 {
 		cfg.SpecFilename = append(cfg.SpecFilename, "/swagger.json")
 	}
 
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
 	cfg.print()
 
-	return cfg, nil
+	return s, nil
+}
+
+// validate checks the settings that depend on each other, so that a configuration the
+// handlers could not be registered with is refused when it is read, and so on reload.
+func (c *config) validate() error {
+	if c.DebugPprof && !isCredentials(c.DebugCredentials) {
+		return fmt.Errorf("debug-pprof requires debug-credentials to be given as username:password")
+	}
+	if len(c.GitRepoDir) > 0 && len(c.GitHookSecret) == 0 {
+		return fmt.Errorf("git-repo-dir requires git-hook-secret, to verify webhook calls")
+	}
+	if len(c.FeedbackReportAuth) > 0 && !isCredentials(c.FeedbackReportAuth) {
+		return fmt.Errorf("feedback-report-credentials must be given as username:password")
+	}
+	for _, proxyPath := range c.ProxyPath {
+		if len(strings.Split(proxyPath, "=")) != 2 {
+			return fmt.Errorf("proxy-path %s must be given as local-path=scheme://host/dst-path", proxyPath)
+		}
+	}
+	return nil
+}
+
+func isCredentials(s string) bool {
+	credentials := strings.SplitN(s, ":", 2)
+	return len(credentials) == 2 && len(credentials[0]) > 0 && len(credentials[1]) > 0
 }
 
 func (c *config) print() {
@@ -0,0 +1,673 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dapperdox/dapperdox/logger"
+	"gopkg.in/yaml.v2"
+)
+
+// SpecConfig holds the settings for a single specification, given in the specs
+// section of the configuration file and keyed by specification ID.
+type SpecConfig struct {
+	Title                 string   `yaml:"title"`                    // Overrides info.title for display
+	Category              string   `yaml:"category"`                 // Overrides x-category, grouping the specification list
+	Logo                  string   `yaml:"logo"`                     // Image URL. Overrides x-logo
+	Favicon               string   `yaml:"favicon"`                  // Icon URL for the specification's pages
+	AccentColor           string   `yaml:"accent-color"`             // CSS color of the header, links and buttons, such as #0a7d3e
+	Theme                 string   `yaml:"theme"`                    // Theme variant for this specification's pages
+	NavigateMethodsByName *bool    `yaml:"navigate-methods-by-name"` // Overrides x-navigateMethodsByName
+	SortMethodsBy         []string `yaml:"sort-methods-by"`          // Overrides x-sortMethodsBy
+	MethodOrder           string   `yaml:"method-order"`             // spec, summary or method. Overrides sort-methods-by
+	PropertyOrder         string   `yaml:"property-order"`           // spec, name or required (first). Of resource properties
+	APIOrder              []string `yaml:"api-order"`                // Tag names or API IDs, in navigation order
+	DefaultVersion        string   `yaml:"default-version"`          // Version shown when none is requested
+	Hidden                bool     `yaml:"hidden"`                   // Served, but not shown in the specification list
+	Overlays              []string `yaml:"overlays"`                 // Merge patch or OpenAPI Overlay files to apply
+	BasePath              string   `yaml:"base-path"`                // Overrides basePath, e.g. the gateway's prefix
+	Host                  string   `yaml:"host"`                     // Overrides host, e.g. the gateway's public hostname
+	Schemes               []string `yaml:"schemes"`                  // Overrides schemes
+	IncludeTags           []string `yaml:"include-tags"`             // Only operations with one of these tags are documented
+	ExcludeTags           []string `yaml:"exclude-tags"`             // Operations with any of these tags are left out
+	ExcludePaths          []string `yaml:"exclude-paths"`            // Patterns of paths to leave out, such as /internal/**
+	ExcludeMethods        []string `yaml:"exclude-methods"`          // HTTP methods of operations to leave out, such as delete
+
+	OAuth2       map[string]OAuth2Client `yaml:"oauth2"`              // Explorer client registrations, by security scheme name
+	Servers      []Server                `yaml:"servers"`             // Alternative targets for explorer requests
+	Signing      *Signing                `yaml:"signing"`             // How the explorer proxy signs requests, if the API requires it
+	Deprecations map[string]Deprecation  `yaml:"deprecated-versions"` // Versions that readers are warned off, by version
+	Terms        *Terms                  `yaml:"terms"`               // Terms readers must accept before viewing the specification
+}
+
+// Terms are what readers must accept before viewing a specification, such as the
+// agreement of a partner API programme. Acceptance is remembered in a signed cookie.
+type Terms struct {
+	Title       string   `yaml:"title"`        // Heading of the terms page. Defaults to "Terms of use"
+	Text        string   `yaml:"text"`         // Markdown
+	File        string   `yaml:"file"`         // Markdown file of the terms, instead of text
+	Version     string   `yaml:"version"`      // Changing it asks every reader to accept the terms again
+	ExemptPaths []string `yaml:"exempt-paths"` // Patterns of pages anyone may view, as for exclude-paths
+}
+
+// Deprecation marks a version of a specification as deprecated. Every page of the
+// version carries a banner saying so, linking to the replacement.
+type Deprecation struct {
+	Sunset      string `yaml:"sunset"`      // Date the version is withdrawn, as 2006-01-02
+	Replacement string `yaml:"replacement"` // Version to use instead
+	Message     string `yaml:"message"`     // Further explanation, such as a link to a migration guide
+}
+
+// Signing is how the explorer proxy signs requests with the credentials a reader gives
+// in the explorer's environment, for APIs that require signed requests.
+type Signing struct {
+	Type      string `yaml:"type"`      // hmac or aws-sigv4
+	Header    string `yaml:"header"`    // hmac: header that carries the signature. Defaults to Authorization
+	Algorithm string `yaml:"algorithm"` // hmac: sha256 (the default) or sha512
+	Region    string `yaml:"region"`    // aws-sigv4: region of the service, such as eu-west-1
+	Service   string `yaml:"service"`   // aws-sigv4: service name, such as execute-api
+}
+
+// Server is an alternative target that the API explorer can send requests to, such
+// as a sandbox, in addition to the hosts declared by the specification.
+type Server struct {
+	URL         string `yaml:"url"` // Scheme and host, e.g. https://sandbox.example.com
+	Description string `yaml:"description"`
+}
+
+// OAuth2Client is the client registration that the API explorer uses to acquire
+// tokens for an OAuth2 security scheme.
+type OAuth2Client struct {
+	ClientID     string `yaml:"client-id"`
+	ClientSecret string `yaml:"client-secret"` // Only for confidential clients. Never sent to the browser
+	Shared       bool   `yaml:"shared"`        // Readers may acquire client credentials and password tokens with it
+}
+
+// NavigationLink is an extra entry in the navigation sidebar, given in the
+// navigation-links section of the configuration file.
+type NavigationLink struct {
+	Title     string `yaml:"title"`
+	URL       string `yaml:"url"`
+	Position  string `yaml:"position"`  // top, before-reference, after-reference or bottom (the default)
+	Separator bool   `yaml:"separator"` // A section separator, with Title as its heading if given
+	External  bool   `yaml:"external"`  // Open in a new window
+	Spec      string `yaml:"spec"`      // Only show on this specification's pages
+}
+
+// Redirect sends requests for a page that no longer exists to its new location,
+// given in the redirects section of the configuration file. A From path ending in
+// /* matches every path below it, and the rest of the path is appended to To.
+type Redirect struct {
+	From   string `yaml:"from"`
+	To     string `yaml:"to"`
+	Status int    `yaml:"status"` // 301 (the default), 302, 307 or 308
+}
+
+// AccessRule limits the client IP addresses that may request paths beginning with
+// one of its Paths, given in the access-rules section of the configuration file. The
+// first rule with a matching path applies. An address in Deny is refused, as is one
+// not in Allow, if Allow is given.
+type AccessRule struct {
+	Paths []string `yaml:"paths"` // Path prefixes, such as /debug/ or / for every page
+	Allow []string `yaml:"allow"` // CIDR ranges or addresses, such as 10.0.0.0/8
+	Deny  []string `yaml:"deny"`
+
+	allow, deny []*net.IPNet
+}
+
+// Environment is a named set of values that the API explorer substitutes into requests,
+// given in the environments section of the configuration file. Readers may add their
+// own environments in the explorer.
+type Environment struct {
+	Name      string            `yaml:"name" json:"name"`
+	Host      string            `yaml:"host" json:"host,omitempty"`       // Scheme and host that requests are sent to, e.g. https://staging.example.com
+	APIKey    string            `yaml:"api-key" json:"apiKey,omitempty"`  // Used when no API key is entered
+	Variables map[string]string `yaml:"variables" json:"variables"`       // Substituted for {{name}} in request values
+	Headers   map[string]string `yaml:"headers" json:"headers,omitempty"` // Added to every request
+
+	// Credentials the explorer proxy signs requests with, for specifications that configure signing
+	SigningKey    string `yaml:"signing-key" json:"signingKey,omitempty"`
+	SigningSecret string `yaml:"signing-secret" json:"signingSecret,omitempty"`
+	SigningToken  string `yaml:"signing-token" json:"signingToken,omitempty"` // aws-sigv4 session token, if any
+
+	// PEM files of the client certificate that the explorer proxy presents, for APIs secured
+	// with mutual TLS. Only for configured environments, and never sent to the browser.
+	ClientCert string `yaml:"client-certificate" json:"-"`
+	ClientKey  string `yaml:"client-key" json:"-"`
+}
+
+// fileConfig is the structured part of the configuration file. Everything else in
+// the file is a top level setting named after its command line flag.
+type fileConfig struct {
+	Specs           map[string]*SpecConfig `yaml:"specs"`
+	NavigationLinks []NavigationLink       `yaml:"navigation-links"`
+	Redirects       []Redirect             `yaml:"redirects"`
+	Environments    []Environment          `yaml:"environments"`
+	AccessRules     []AccessRule           `yaml:"access-rules"`
+}
+
+// settings is a complete configuration: the settings and the structured part of the
+// configuration file. A new one is published by each Reload.
+type settings struct {
+	cfg             *config
+	specs           map[string]*SpecConfig
+	navigationLinks []NavigationLink
+	redirects       []Redirect
+	environments    []Environment
+	accessRules     []AccessRule
+}
+
+// envPrefix prefixes the environment variable of a setting to give an override
+// that is applied over the configuration file, e.g. DAPPERDOX_BIND_ADDR. Settings
+// within the sections of the file are named by their path, with envPathSeparator
+// between the keys, e.g. DAPPERDOX_SPECS__PETSTORE__OAUTH2__CLIENT_SECRET. Within a
+// key, _ stands for -, and a list item is given by its index, from 0.
+//
+// Command line flags override DAPPERDOX_ variables, which override the plain
+// environment variables, e.g. BIND_ADDR, which override the configuration file.
+const envPrefix = "DAPPERDOX_"
+
+const envPathSeparator = "__"
+
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// cssColor is a color that can be put in a stylesheet as it is: a hex color or a name
+var cssColor = regexp.MustCompile(`^(#[0-9A-Fa-f]{3,8}|[A-Za-z]+)$`)
+
+// ---------------------------------------------------------------------------
+// Spec returns the settings for the specification with the given ID. If the
+// specification has no section in the configuration file, empty settings are returned.
+func (c *config) Spec(id string) *SpecConfig {
+	if s, ok := published().specs[id]; ok && s != nil {
+		return s
+	}
+	return &SpecConfig{}
+}
+
+// ---------------------------------------------------------------------------
+// NavigationLinks returns the extra navigation entries for a page, keyed by position.
+// specID is the specification of the page, or "" for pages of no specification.
+func (c *config) NavigationLinks(specID string) map[string][]NavigationLink {
+	links := make(map[string][]NavigationLink)
+	for _, link := range published().navigationLinks {
+		if len(link.Spec) == 0 || link.Spec == specID {
+			links[link.Position] = append(links[link.Position], link)
+		}
+	}
+	return links
+}
+
+// ---------------------------------------------------------------------------
+// Redirects returns the redirect rules, in the order they were given.
+func (c *config) Redirects() []Redirect {
+	return published().redirects
+}
+
+// ---------------------------------------------------------------------------
+// Environments returns the explorer environments, in the order they were given.
+func (c *config) Environments() []Environment {
+	return published().environments
+}
+
+// ---------------------------------------------------------------------------
+// AccessRule returns the access rule that applies to a path, or nil if none does.
+func (c *config) AccessRule(path string) *AccessRule {
+	rules := published().accessRules
+	for i, rule := range rules {
+		for _, prefix := range rule.Paths {
+			if strings.HasPrefix(path, prefix) {
+				return &rules[i]
+			}
+		}
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Allows reports whether the rule lets a client IP address through. An address that
+// cannot be parsed is in no range.
+func (r *AccessRule) Allows(address string) bool {
+	ip := net.ParseIP(address)
+	if contains(r.deny, ip) {
+		return false
+	}
+	return len(r.allow) == 0 || contains(r.allow, ip)
+}
+
+func contains(ranges []*net.IPNet, ip net.IP) bool {
+	for _, r := range ranges {
+		if ip != nil && r.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ---------------------------------------------------------------------------
+// parseRanges parses CIDR ranges, taking a bare address as a range of one.
+func parseRanges(ranges []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, r := range ranges {
+		if !strings.Contains(r, "/") {
+			if ip := net.ParseIP(r); ip != nil && ip.To4() != nil {
+				r += "/32"
+			} else {
+				r += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(r)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// ---------------------------------------------------------------------------
+// configFileName finds the configuration file name, if one is given. This must be
+// known before gofigure parses the flags and environment, as these override the file.
+func configFileName() string {
+	name := os.Getenv("CONFIG_FILE")
+	if override, ok := os.LookupEnv(envPrefix + "CONFIG_FILE"); ok {
+		name = override
+	}
+
+	args := os.Args[1:]
+	for i, arg := range args {
+		arg = strings.TrimLeft(arg, "-")
+		if arg == "config" && i+1 < len(args) {
+			name = args[i+1]
+		} else if strings.HasPrefix(arg, "config=") {
+			name = strings.TrimPrefix(arg, "config=")
+		}
+	}
+	return name
+}
+
+// ---------------------------------------------------------------------------
+// flagGiven reports whether a command line flag is given, which gofigure will have set
+func flagGiven(flag string) bool {
+	for _, arg := range os.Args[1:] {
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		arg = strings.TrimLeft(arg, "-")
+		if arg == flag || strings.HasPrefix(arg, flag+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// ---------------------------------------------------------------------------
+// pathOverrides returns the DAPPERDOX_ environment variables that name settings within
+// the sections of the configuration file, with their values.
+func pathOverrides() map[string]string {
+	overrides := make(map[string]string)
+	for _, env := range os.Environ() {
+		pair := strings.SplitN(env, "=", 2)
+		if len(pair) == 2 && strings.HasPrefix(pair[0], envPrefix) && strings.Contains(pair[0], envPathSeparator) {
+			overrides[pair[0]] = pair[1]
+		}
+	}
+	return overrides
+}
+
+// envPath is the path of keys to the setting that an environment variable names
+func envPath(variable string) []string {
+	var path []string
+	for _, key := range strings.Split(strings.TrimPrefix(variable, envPrefix), envPathSeparator) {
+		path = append(path, strings.Replace(strings.ToLower(key), "_", "-", -1))
+	}
+	return path
+}
+
+// setPath sets the setting at the path of keys in the parsed configuration file,
+// adding the maps that lead to it if they are not there.
+func setPath(settings map[string]interface{}, path []string, value interface{}) error {
+	var node interface{} = settings
+	for i, key := range path {
+		last := i == len(path)-1
+		switch n := node.(type) {
+		case map[string]interface{}:
+			if last {
+				n[key] = value
+			} else if _, ok := n[key]; !ok {
+				n[key] = map[interface{}]interface{}{}
+			}
+			node = n[key]
+		case map[interface{}]interface{}:
+			if last {
+				n[key] = value
+			} else if _, ok := n[key]; !ok {
+				n[key] = map[interface{}]interface{}{}
+			}
+			node = n[key]
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(n) {
+				return fmt.Errorf("%s has no item %s", strings.Join(path[:i], "."), key)
+			}
+			if last {
+				n[index] = value
+			}
+			node = n[index]
+		default:
+			return fmt.Errorf("%s is not a section", strings.Join(path[:i], "."))
+		}
+	}
+	return nil
+}
+
+// envScalar gives an override the type that the file would, for the values of
+// switches and numbers, so that it can set any setting. Numbers with a leading zero
+// are left as strings, as YAML would read them as octal.
+func envScalar(value string) interface{} {
+	if b, err := strconv.ParseBool(value); err == nil && (value == "true" || value == "false") {
+		return b
+	}
+	if i, err := strconv.Atoi(value); err == nil && strconv.Itoa(i) == value {
+		return i
+	}
+	return value
+}
+
+// ---------------------------------------------------------------------------
+// loadFile reads a YAML configuration file, if one is named, and applies the
+// DAPPERDOX_ overrides of settings within its sections. Top level keys are the names
+// of the command line flags, so bind-addr: localhost:3123 is equivalent to -bind-addr.
+func (s *settings) loadFile(name string) error {
+	var data []byte
+	var err error
+	if len(name) > 0 {
+		logger.Infof(nil, "Reading configuration file %s", name)
+
+		if data, err = ioutil.ReadFile(name); err != nil {
+			return err
+		}
+	} else {
+		name = "the environment" // For the errors of the overrides
+	}
+
+	var settings map[string]interface{}
+	if err = yaml.Unmarshal(data, &settings); err != nil {
+		return fmt.Errorf("error parsing %s: %s", name, err)
+	}
+	if settings == nil {
+		settings = make(map[string]interface{})
+	}
+
+	// References are expanded in the parsed values, so that a value cannot change the
+	// structure of the file, and are then parsed again into the structured settings.
+	for key, value := range settings {
+		settings[key] = expandEnv(value)
+	}
+	for variable, value := range pathOverrides() {
+		if err = setPath(settings, envPath(variable), envScalar(value)); err != nil {
+			return fmt.Errorf("error in %s: %s", variable, err)
+		}
+	}
+	if data, err = yaml.Marshal(settings); err != nil {
+		return fmt.Errorf("error parsing %s: %s", name, err)
+	}
+
+	var structured fileConfig
+	if err = yaml.Unmarshal(data, &structured); err != nil {
+		return fmt.Errorf("error parsing %s: %s", name, err)
+	}
+	if structured.Specs != nil {
+		s.specs = structured.Specs
+	}
+	for id, spec := range s.specs {
+		for _, server := range spec.Servers {
+			if len(server.URL) == 0 {
+				return fmt.Errorf("error in %s: every server of specification '%s' needs a url", name, id)
+			}
+		}
+		if len(spec.AccentColor) > 0 && !cssColor.MatchString(spec.AccentColor) {
+			return fmt.Errorf("error in %s: accent-color of specification '%s' must be a color name or #rrggbb", name, id)
+		}
+		if len(spec.BasePath) > 0 && !strings.HasPrefix(spec.BasePath, "/") {
+			return fmt.Errorf("error in %s: base-path of specification '%s' must begin with /", name, id)
+		}
+		for _, scheme := range spec.Schemes {
+			if scheme != "http" && scheme != "https" && scheme != "ws" && scheme != "wss" {
+				return fmt.Errorf("error in %s: specification '%s' has unknown scheme '%s'", name, id, scheme)
+			}
+		}
+		for _, pattern := range spec.ExcludePaths {
+			if _, err := path.Match(strings.TrimSuffix(pattern, "/**"), ""); err != nil {
+				return fmt.Errorf("error in %s: exclude-paths pattern '%s' of specification '%s' is invalid", name, pattern, id)
+			}
+		}
+		if terms := spec.Terms; terms != nil {
+			if (len(terms.Text) == 0) == (len(terms.File) == 0) {
+				return fmt.Errorf("error in %s: terms of specification '%s' need either a text or a file", name, id)
+			}
+			for _, pattern := range terms.ExemptPaths {
+				if _, err := path.Match(strings.TrimSuffix(pattern, "/**"), ""); err != nil || !strings.HasPrefix(pattern, "/") {
+					return fmt.Errorf("error in %s: exempt-paths pattern '%s' of specification '%s' is invalid", name, pattern, id)
+				}
+			}
+		}
+		for version, deprecation := range spec.Deprecations {
+			if len(deprecation.Sunset) > 0 {
+				if _, err := time.Parse("2006-01-02", deprecation.Sunset); err != nil {
+					return fmt.Errorf("error in %s: sunset of version '%s' of specification '%s' must be a date, as 2006-01-02", name, version, id)
+				}
+			}
+		}
+		if signing := spec.Signing; signing != nil {
+			switch {
+			case signing.Type == "hmac" && (signing.Algorithm == "" || signing.Algorithm == "sha256" || signing.Algorithm == "sha512"):
+			case signing.Type == "aws-sigv4" && len(signing.Region) > 0 && len(signing.Service) > 0:
+			default:
+				return fmt.Errorf("error in %s: specification '%s' has invalid signing: type must be hmac, with algorithm sha256 or sha512, or aws-sigv4, with a region and service", name, id)
+			}
+		}
+	}
+	for _, link := range structured.NavigationLinks {
+		switch link.Position {
+		case "":
+			link.Position = "bottom"
+		case "top", "before-reference", "after-reference", "bottom":
+		default:
+			return fmt.Errorf("error in %s: navigation link '%s' has unknown position '%s'", name, link.Title, link.Position)
+		}
+		s.navigationLinks = append(s.navigationLinks, link)
+	}
+	for _, redirect := range structured.Redirects {
+		switch redirect.Status {
+		case 0:
+			redirect.Status = 301
+		case 301, 302, 307, 308:
+		default:
+			return fmt.Errorf("error in %s: redirect from '%s' has unsupported status %d", name, redirect.From, redirect.Status)
+		}
+		if !strings.HasPrefix(redirect.From, "/") || len(redirect.To) == 0 {
+			return fmt.Errorf("error in %s: redirect from '%s' needs an absolute from path and a to location", name, redirect.From)
+		}
+		s.redirects = append(s.redirects, redirect)
+	}
+	for _, environment := range structured.Environments {
+		if len(environment.Name) == 0 {
+			return fmt.Errorf("error in %s: every environment needs a name", name)
+		}
+		if (len(environment.ClientCert) == 0) != (len(environment.ClientKey) == 0) {
+			return fmt.Errorf("error in %s: environment '%s' needs both a client-certificate and a client-key", name, environment.Name)
+		}
+		s.environments = append(s.environments, environment)
+	}
+	for _, rule := range structured.AccessRules {
+		if len(rule.Paths) == 0 {
+			return fmt.Errorf("error in %s: every access rule needs paths", name)
+		}
+		for _, prefix := range rule.Paths {
+			if !strings.HasPrefix(prefix, "/") {
+				return fmt.Errorf("error in %s: access rule path '%s' must begin with /", name, prefix)
+			}
+		}
+		if rule.allow, err = parseRanges(rule.Allow); err != nil {
+			return fmt.Errorf("error in %s: access rule for %s has an invalid allow range: %s", name, strings.Join(rule.Paths, ", "), err)
+		}
+		if rule.deny, err = parseRanges(rule.Deny); err != nil {
+			return fmt.Errorf("error in %s: access rule for %s has an invalid deny range: %s", name, strings.Join(rule.Paths, ", "), err)
+		}
+		s.accessRules = append(s.accessRules, rule)
+	}
+	delete(settings, "specs")
+	delete(settings, "navigation-links")
+	delete(settings, "redirects")
+	delete(settings, "environments")
+	delete(settings, "access-rules")
+
+	v := reflect.ValueOf(s.cfg).Elem()
+	t := v.Type()
+
+	for key, value := range settings {
+		var field reflect.Value
+		for i := 0; i < v.NumField(); i++ {
+			if t.Field(i).Tag.Get("flag") == key && v.Field(i).CanSet() {
+				field = v.Field(i)
+				break
+			}
+		}
+		if !field.IsValid() {
+			return fmt.Errorf("error in %s: unknown setting '%s'", name, key)
+		}
+		if err = setField(field, value); err != nil {
+			return fmt.Errorf("error in %s: setting '%s' %s", name, key, err)
+		}
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// expandEnv replaces ${VAR} references in the strings of a parsed value with the
+// value of the environment variable, so that secrets need not be written into the
+// configuration file. A bare $ is left alone.
+func expandEnv(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return envReference.ReplaceAllStringFunc(v, func(ref string) string {
+			name := envReference.FindStringSubmatch(ref)[1]
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				logger.Warnf(nil, "Configuration references unset environment variable %s", name)
+			}
+			return value
+		})
+	case map[interface{}]interface{}:
+		for key, item := range v {
+			v[key] = expandEnv(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = expandEnv(item)
+		}
+	}
+	return value
+}
+
+// ---------------------------------------------------------------------------
+// loadEnvOverrides applies DAPPERDOX_ prefixed environment variables, one for every
+// setting, except those given as command line flags. It is called after gofigure, so
+// that they override the plain environment variables. List settings are comma separated.
+func (c *config) loadEnvOverrides() error {
+	s := reflect.ValueOf(c).Elem()
+	t := s.Type()
+
+	for i := 0; i < s.NumField(); i++ {
+		env := t.Field(i).Tag.Get("env")
+		if len(env) == 0 || !s.Field(i).CanSet() {
+			continue
+		}
+		value, ok := os.LookupEnv(envPrefix + env)
+		if !ok || flagGiven(t.Field(i).Tag.Get("flag")) {
+			continue
+		}
+		var v interface{} = value
+		if s.Field(i).Kind() == reflect.Slice {
+			var list []interface{}
+			for _, item := range strings.Split(value, ",") {
+				list = append(list, strings.TrimSpace(item))
+			}
+			v = list
+		}
+		if err := setField(s.Field(i), v); err != nil {
+			return fmt.Errorf("error in %s%s: %s", envPrefix, env, err)
+		}
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+
+func setField(field reflect.Value, value interface{}) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(fmt.Sprintf("%v", value))
+	case reflect.Bool:
+		b, ok := value.(bool)
+		if str, isString := value.(string); isString {
+			var err error
+			b, err = strconv.ParseBool(str)
+			ok = err == nil
+		}
+		if !ok {
+			return fmt.Errorf("must be true or false")
+		}
+		field.SetBool(b)
+	case reflect.Int:
+		switch v := value.(type) {
+		case int:
+			field.SetInt(int64(v))
+		case string:
+			i, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("must be a whole number")
+			}
+			field.SetInt(int64(i))
+		default:
+			return fmt.Errorf("must be a whole number")
+		}
+	case reflect.Slice:
+		var list []string
+		if items, ok := value.([]interface{}); ok {
+			for _, item := range items {
+				list = append(list, fmt.Sprintf("%v", item))
+			}
+		} else {
+			list = append(list, fmt.Sprintf("%v", value))
+		}
+		field.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("has an unsupported type")
+	}
+	return nil
+}
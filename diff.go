@@ -0,0 +1,121 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/dapperdox/dapperdox/spec"
+)
+
+// ---------------------------------------------------------------------------
+// diffSpecs compares two specification files operation by operation. Removed
+// operations, parameters and responses, and newly required parameters, are
+// breaking changes and make the command exit non-zero.
+func diffSpecs(args []string) int {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "diff requires two specification files: diff <old spec> <new spec>")
+		return 2
+	}
+
+	before, err := operations(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading %s: %s\n", args[0], err)
+		return 1
+	}
+	after, err := operations(args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading %s: %s\n", args[1], err)
+		return 1
+	}
+
+	changes := spec.DiffOperations(before, after)
+	breaking := 0
+	for _, c := range changes {
+		prefix := "  "
+		if c.Breaking {
+			prefix = "! "
+			breaking++
+		}
+		fmt.Println(prefix + c.String())
+	}
+	if len(changes) == 0 {
+		fmt.Println("No changes")
+	}
+	if breaking > 0 {
+		fmt.Printf("\n%d breaking change(s)\n", breaking)
+		return 1
+	}
+	return 0
+}
+
+// ---------------------------------------------------------------------------
+// operations loads a specification file, returning its operations keyed by "METHOD path"
+func operations(location string) (map[string]spec.OperationSummary, error) {
+	specification := &spec.APISpecification{}
+	if err := specification.LoadFile(location); err != nil {
+		return nil, err
+	}
+	return specification.OperationSummaries(), nil
+}
+
+// ---------------------------------------------------------------------------
+// parameters returns the parameters of a method keyed by "location name"
+func parameters(method *spec.Method) map[string]spec.Parameter {
+	params := make(map[string]spec.Parameter)
+	for _, list := range [][]spec.Parameter{method.PathParams, method.QueryParams, method.HeaderParams, method.FormParams} {
+		for _, p := range list {
+			params[p.In+" "+p.Name] = p
+		}
+	}
+	if method.BodyParam != nil {
+		params["body "+method.BodyParam.Name] = *method.BodyParam
+	}
+	return params
+}
+
+// ---------------------------------------------------------------------------
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]*spec.Method:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]spec.Parameter:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ---------------------------------------------------------------------------
+
+func responseCodes(method *spec.Method) []int {
+	var codes []int
+	for code := range method.Responses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	return codes
+}
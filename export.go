@@ -0,0 +1,145 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/spec"
+)
+
+// The exported model is a flattened view of spec.APISpecification, which cannot be
+// marshalled directly as methods and resources refer back to their parents.
+type exportSpec struct {
+	ID          string      `json:"id"`
+	Title       string      `json:"title"`
+	Description string      `json:"description,omitempty"`
+	URL         string      `json:"url"`
+	APIs        []exportAPI `json:"apis"`
+}
+
+type exportAPI struct {
+	ID      string         `json:"id"`
+	Name    string         `json:"name"`
+	Version string         `json:"version"`
+	Methods []exportMethod `json:"methods"`
+}
+
+type exportMethod struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Operation   string            `json:"operationId,omitempty"`
+	Method      string            `json:"method"`
+	Path        string            `json:"path"`
+	Description string            `json:"description,omitempty"`
+	Parameters  []exportParameter `json:"parameters,omitempty"`
+	Responses   []int             `json:"responses,omitempty"`
+}
+
+type exportParameter struct {
+	Name        string   `json:"name"`
+	In          string   `json:"in"`
+	Required    bool     `json:"required"`
+	Type        []string `json:"type,omitempty"`
+	Description string   `json:"description,omitempty"`
+}
+
+// ---------------------------------------------------------------------------
+// exportSpecs writes the parsed model of each loaded specification as JSON, to
+// <output-dir>/<spec ID>.json, or to stdout if no output directory is given.
+func exportSpecs(args []string) int {
+	cfg, err := config.Get()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error configuring app: %s\n", err)
+		return 1
+	}
+	if err = loadSpecifications(); err != nil {
+		fmt.Fprintf(os.Stderr, "Load specification error: %s\n", err)
+		return 1
+	}
+
+	var all []exportSpec
+	for _, specification := range spec.APISuite {
+		e := exportSpecification(specification)
+
+		if len(cfg.OutputDir) == 0 {
+			all = append(all, e)
+			continue
+		}
+
+		b, _ := json.MarshalIndent(e, "", "  ")
+		file := filepath.Join(cfg.OutputDir, specification.ID+".json")
+		if err = os.MkdirAll(cfg.OutputDir, 0755); err == nil {
+			err = ioutil.WriteFile(file, b, 0644)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error writing %s: %s\n", file, err)
+			return 1
+		}
+	}
+
+	if len(cfg.OutputDir) == 0 {
+		b, _ := json.MarshalIndent(all, "", "  ")
+		fmt.Println(string(b))
+	}
+	return 0
+}
+
+// ---------------------------------------------------------------------------
+
+func exportSpecification(specification *spec.APISpecification) exportSpec {
+	e := exportSpec{
+		ID:          specification.ID,
+		Title:       specification.APIInfo.Title,
+		Description: specification.APIInfo.Description,
+		URL:         specification.URL,
+	}
+	for _, api := range specification.APIs {
+		a := exportAPI{ID: api.ID, Name: api.Name, Version: api.CurrentVersion}
+		for _, method := range api.Methods {
+			m := exportMethod{
+				ID:          method.ID,
+				Name:        method.Name,
+				Operation:   method.OperationName,
+				Method:      method.Method,
+				Path:        method.Path,
+				Description: method.Description,
+				Responses:   responseCodes(&method),
+			}
+			params := parameters(&method)
+			for _, key := range sortedKeys(params) {
+				p := params[key]
+				m.Parameters = append(m.Parameters, exportParameter{
+					Name:        p.Name,
+					In:          p.In,
+					Required:    p.Required,
+					Type:        p.Type,
+					Description: p.Description,
+				})
+			}
+			a.Methods = append(a.Methods, m)
+		}
+		e.APIs = append(e.APIs, a)
+	}
+	return e
+}
@@ -0,0 +1,89 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package gitrepo
+
+// Package gitrepo keeps a local working copy of the git repository holding the
+// specifications and assets up to date.
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/logger"
+)
+
+var syncLock sync.Mutex
+
+// ---------------------------------------------------------------------------
+// Sync brings the working copy up to date. If a repository URL is configured it is
+// cloned if need be, and the configured ref (branch, tag or commit, default HEAD)
+// checked out. Otherwise an existing working copy is pulled. Sync does nothing if
+// no working copy is configured.
+func Sync() error {
+	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+
+	if len(cfg.GitRepoDir) == 0 {
+		if len(cfg.GitRepoURL) > 0 {
+			return fmt.Errorf("git-repo-url requires git-repo-dir, to clone into")
+		}
+		return nil
+	}
+
+	syncLock.Lock()
+	defer syncLock.Unlock()
+
+	dir := cfg.GitRepoDir
+
+	if len(cfg.GitRepoURL) == 0 {
+		logger.Infof(nil, "Pulling git repository %s", dir)
+		return git("-C", dir, "pull", "--ff-only")
+	}
+
+	if _, err := os.Stat(dir + "/.git"); os.IsNotExist(err) {
+		logger.Infof(nil, "Cloning git repository %s into %s", cfg.GitRepoURL, dir)
+		if err = git("clone", "--no-checkout", cfg.GitRepoURL, dir); err != nil {
+			return err
+		}
+	}
+
+	ref := cfg.GitRepoRef
+	if len(ref) == 0 {
+		ref = "HEAD"
+	}
+	logger.Infof(nil, "Fetching %s of git repository %s", ref, cfg.GitRepoURL)
+
+	if err := git("-C", dir, "fetch", "origin", ref); err != nil {
+		return err
+	}
+	return git("-C", dir, "checkout", "--force", "FETCH_HEAD")
+}
+
+// ---------------------------------------------------------------------------
+
+func git(args ...string) error {
+	out, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git %s: %s: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	logger.Debugf(nil, "%s", out)
+	return nil
+}
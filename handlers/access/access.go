@@ -0,0 +1,51 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package access
+
+import (
+	"net/http"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/network"
+	"github.com/dapperdox/dapperdox/render"
+)
+
+// ---------------------------------------------------------------------------
+// Handler refuses requests from client IP addresses that the access-rules of the
+// configuration file do not allow to reach the path, such as admin endpoints from
+// outside an internal range.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+
+		rule := cfg.AccessRule(req.URL.Path)
+		if rule == nil {
+			h.ServeHTTP(w, req)
+			return
+		}
+		if client := network.ClientIP(req); !rule.Allows(client) {
+			logger.Warnf(req, "access to %s refused to %s", req.URL.Path, client)
+			render.LockedError(w, req, http.StatusForbidden, "403", "Forbidden")
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+// ---------------------------------------------------------------------------
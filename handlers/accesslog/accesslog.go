@@ -0,0 +1,246 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package accesslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/logger"
+)
+
+type responseCapture struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	bytes       int
+}
+
+// WriteHeader records the status of the first call, which is the one net/http sends
+func (r *responseCapture) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.statusCode = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseCapture) Write(b []byte) (int, error) {
+	if !r.wroteHeader {
+		r.statusCode = http.StatusOK
+		r.wroteHeader = true
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Flush passes flushes through, so that streamed pages reach the client as they render
+func (r *responseCapture) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// entry is a single access log record
+type entry struct {
+	RemoteAddr string  `json:"remote_addr"`
+	User       string  `json:"user,omitempty"`
+	Time       string  `json:"time"`
+	Method     string  `json:"method"`
+	URI        string  `json:"uri"`
+	Protocol   string  `json:"protocol"`
+	Status     int     `json:"status"`
+	Bytes      int     `json:"bytes"`
+	Referer    string  `json:"referer,omitempty"`
+	UserAgent  string  `json:"user_agent,omitempty"`
+	Latency    float64 `json:"latency_ms"`
+	RequestID  string  `json:"request_id,omitempty"`
+}
+
+// The access log is written under mu, to out, which is the log named opened
+var (
+	mu     sync.Mutex
+	out    io.Writer
+	opened string
+)
+
+// ---------------------------------------------------------------------------
+// Handler wraps a http.Handler and writes an access log record for each request,
+// in the configured format. The configuration is read for each request, so that a
+// reload can start, stop or move the access log.
+func Handler(h http.Handler) http.Handler {
+	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+
+	if len(cfg.AccessLog) > 0 {
+		if _, err := formatter(cfg.AccessLogFormat); err != nil {
+			logger.Errorf(nil, "Error: %s", err)
+			os.Exit(1)
+		}
+		if err := openLog(cfg.AccessLog); err != nil {
+			logger.Errorf(nil, "Error opening access log %s: %s", cfg.AccessLog, err)
+			os.Exit(1)
+		}
+		logger.Infof(nil, "Writing %s access log to %s", cfg.AccessLogFormat, cfg.AccessLog)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		cfg, _ := config.Get()
+
+		if len(cfg.AccessLog) == 0 || excluded(req.URL.Path, cfg.AccessLogExclude) {
+			h.ServeHTTP(w, req)
+			return
+		}
+
+		rc := &responseCapture{ResponseWriter: w, statusCode: http.StatusOK} // As a handler that writes nothing gives
+		s := time.Now()
+
+		h.ServeHTTP(rc, req)
+
+		e := &entry{
+			RemoteAddr: remoteHost(req),
+			Time:       s.Format("02/Jan/2006:15:04:05 -0700"),
+			Method:     req.Method,
+			URI:        req.RequestURI,
+			Protocol:   req.Proto,
+			Status:     rc.statusCode,
+			Bytes:      rc.bytes,
+			Referer:    req.Referer(),
+			UserAgent:  req.UserAgent(),
+			Latency:    float64(time.Since(s).Nanoseconds()) / float64(time.Millisecond),
+			RequestID:  req.Header.Get(logger.RequestIDHeader),
+		}
+		if user, _, ok := req.BasicAuth(); ok {
+			e.User = user
+		}
+
+		format, err := formatter(cfg.AccessLogFormat)
+		if err != nil {
+			format = combined // A reload cannot stop the server, so fall back to the default
+		}
+
+		if err := openLog(cfg.AccessLog); err != nil {
+			logger.Errorf(nil, "Error opening access log %s, continuing with the previous log: %s", cfg.AccessLog, err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if out != nil {
+			io.WriteString(out, format(e)+"\n")
+		}
+	})
+}
+
+// ---------------------------------------------------------------------------
+
+func formatter(name string) (func(*entry) string, error) {
+	switch strings.ToLower(name) {
+	case "", "combined":
+		return combined, nil
+	case "json":
+		return jsonFormat, nil
+	}
+	return nil, fmt.Errorf("Invalid access-log-format '%s', expected combined or json", name)
+}
+
+// ---------------------------------------------------------------------------
+// openLog opens the named access log for writing, in place of the one that is open.
+// The log is only tried once, so that an error is not repeated for every request.
+func openLog(name string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if name == opened {
+		return nil
+	}
+	opened = name
+
+	var w io.Writer
+	switch strings.ToLower(name) {
+	case "stdout":
+		w = os.Stdout
+	case "stderr":
+		w = os.Stderr
+	default:
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+		w = f
+	}
+
+	if f, ok := out.(*os.File); ok && f != os.Stdout && f != os.Stderr {
+		f.Close()
+	}
+	out = w
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+
+func excluded(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ---------------------------------------------------------------------------
+
+func remoteHost(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// ---------------------------------------------------------------------------
+// combined formats the entry in the Apache combined log format, with the request
+// latency in milliseconds and the request ID appended.
+func combined(e *entry) string {
+	return fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %d \"%s\" \"%s\" %.3f %s",
+		e.RemoteAddr, dash(e.User), e.Time, e.Method, e.URI, e.Protocol, e.Status, e.Bytes, dash(e.Referer), dash(e.UserAgent), e.Latency, dash(e.RequestID))
+}
+
+func jsonFormat(e *entry) string {
+	b, err := json.Marshal(e)
+	if err != nil {
+		logger.Errorf(nil, "Error encoding access log entry: %s", err)
+	}
+	return string(b)
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// ---------------------------------------------------------------------------
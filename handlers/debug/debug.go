@@ -0,0 +1,70 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package debug
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/gorilla/pat"
+)
+
+// PathPrefix is the path under which the profiling endpoints are mounted
+const PathPrefix = "/debug/pprof/"
+
+// Register mounts the net/http/pprof endpoints behind basic authentication, if enabled.
+func Register(r *pat.Router) {
+	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+
+	if !cfg.DebugPprof {
+		return
+	}
+
+	credentials := strings.SplitN(cfg.DebugCredentials, ":", 2) // Checked when the configuration is read
+
+	logger.Infof(nil, "Registering profiling endpoints at %s", PathPrefix)
+
+	r.Path(PathPrefix + "cmdline").Handler(authenticate(credentials, http.HandlerFunc(pprof.Cmdline)))
+	r.Path(PathPrefix + "profile").Handler(authenticate(credentials, http.HandlerFunc(pprof.Profile)))
+	r.Path(PathPrefix + "symbol").Handler(authenticate(credentials, http.HandlerFunc(pprof.Symbol)))
+	r.Path(PathPrefix + "trace").Handler(authenticate(credentials, http.HandlerFunc(pprof.Trace)))
+	r.PathPrefix(PathPrefix).Handler(authenticate(credentials, http.HandlerFunc(pprof.Index)))
+}
+
+// ---------------------------------------------------------------------------
+
+func authenticate(credentials []string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		user, password, ok := req.BasicAuth()
+
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(user), []byte(credentials[0])) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(credentials[1])) != 1 {
+
+			logger.Warnf(req, "Unauthorised request for %s", req.URL.Path)
+			w.Header().Set("WWW-Authenticate", `Basic realm="DapperDox debug"`)
+			http.Error(w, "Unauthorised", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
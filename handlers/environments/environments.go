@@ -0,0 +1,76 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package environments
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/session"
+	"github.com/gorilla/pat"
+)
+
+// Path is where the explorer reads and saves the environments a reader has added,
+// when they are kept in the server side session rather than in browser local storage.
+const Path = "/_dapperdox/environments"
+
+const sessionKey = "environments"
+
+// maxBody limits the size of the environments a reader can save
+const maxBody = 64 << 10
+
+// ---------------------------------------------------------------------------
+// Register creates the environment routes, if environments are kept in the session
+func Register(r *pat.Router) {
+	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+
+	if !cfg.EnvSession {
+		return
+	}
+	logger.Debugln(nil, "registering handlers for environments package")
+
+	r.Path(Path).Methods("GET").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		environments := []config.Environment{}
+		if s := session.Find(req); s != nil {
+			if saved, ok := s.Value(sessionKey).([]config.Environment); ok {
+				environments = saved
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(environments)
+	})
+
+	r.Path(Path).Methods("PUT").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var environments []config.Environment
+		if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxBody)).Decode(&environments); err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		for _, environment := range environments {
+			if len(environment.Name) == 0 {
+				http.Error(w, "Every environment needs a name", http.StatusBadRequest)
+				return
+			}
+		}
+		session.Get(w, req).SetValue(sessionKey, environments)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
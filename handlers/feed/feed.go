@@ -0,0 +1,201 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package feed
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/spec"
+	"github.com/gorilla/pat"
+)
+
+// Each specification has a feed of the changes to its operations and guides, found
+// by comparing each load of the site with the one before, so that readers can
+// subscribe to them. The feed is served as Atom and as RSS.
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title   string   `xml:"title"`
+	ID      string   `xml:"id"`
+	Updated string   `xml:"updated"`
+	Link    atomLink `xml:"link"`
+	Content atomText `xml:"content"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+}
+
+// ---------------------------------------------------------------------------
+// Register records the changes found in this load of the site. RegisterSpecification
+// creates the change feed routes of each specification.
+func Register(r *pat.Router) {
+	logger.Debugln(nil, "registering change feeds")
+
+	Record()
+}
+
+// ---------------------------------------------------------------------------
+// RegisterSpecification creates the change feed routes of a specification
+func RegisterSpecification(r *pat.Router, specification *spec.APISpecification) {
+	r.Path("/" + specification.ID + "/changes.atom").Methods("GET").HandlerFunc(atomHandler(specification))
+	r.Path("/" + specification.ID + "/changes.rss").Methods("GET").HandlerFunc(rssHandler(specification))
+}
+
+// ---------------------------------------------------------------------------
+
+func atomHandler(specification *spec.APISpecification) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		site := siteURL()
+		page := site + "/" + specification.ID + "/reference"
+
+		feed := atomFeed{
+			Title:   title(specification),
+			ID:      site + "/" + specification.ID + "/changes.atom",
+			Updated: time.Now().UTC().Format(time.RFC3339),
+			Links:   []atomLink{{Href: page}, {Href: site + req.URL.Path, Rel: "self"}},
+		}
+		list := entries(specification.ID)
+		if len(list) > 0 {
+			feed.Updated = list[0].Time.Format(time.RFC3339)
+		}
+		for _, entry := range list {
+			feed.Entries = append(feed.Entries, atomEntry{
+				Title:   entryTitle(entry),
+				ID:      fmt.Sprintf("%s#%d", feed.ID, entry.Time.UnixNano()),
+				Updated: entry.Time.Format(time.RFC3339),
+				Link:    atomLink{Href: page},
+				Content: atomText{Type: "html", Body: entryHTML(entry)},
+			})
+		}
+		write(w, "application/atom+xml", feed)
+	}
+}
+
+// ---------------------------------------------------------------------------
+
+func rssHandler(specification *spec.APISpecification) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		site := siteURL()
+		page := site + "/" + specification.ID + "/reference"
+
+		feed := rssFeed{
+			Version: "2.0",
+			Channel: rssChannel{
+				Title:       title(specification),
+				Link:        page,
+				Description: "Changes to the operations and guides of " + specification.APIInfo.Title,
+			},
+		}
+		for _, entry := range entries(specification.ID) {
+			feed.Channel.Items = append(feed.Channel.Items, rssItem{
+				Title:       entryTitle(entry),
+				Link:        page,
+				Description: entryHTML(entry),
+				GUID:        fmt.Sprintf("%s/%s/changes.rss#%d", site, specification.ID, entry.Time.UnixNano()),
+				PubDate:     entry.Time.Format(time.RFC1123Z),
+			})
+		}
+		write(w, "application/rss+xml", feed)
+	}
+}
+
+// ---------------------------------------------------------------------------
+
+func write(w http.ResponseWriter, contentType string, feed interface{}) {
+	b, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		logger.Errorf(nil, "Error marshalling change feed: %s", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	w.Write(b)
+}
+
+// ---------------------------------------------------------------------------
+
+func siteURL() string {
+	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+	return strings.TrimSuffix(cfg.SiteURL, "/")
+}
+
+func title(specification *spec.APISpecification) string {
+	return specification.APIInfo.Title + " changes"
+}
+
+func entryTitle(entry Entry) string {
+	t := fmt.Sprintf("%d change(s)", len(entry.Changes))
+	if entry.Breaking {
+		t += ", including breaking changes"
+	}
+	return t
+}
+
+func entryHTML(entry Entry) string {
+	var b strings.Builder
+	b.WriteString("<ul>")
+	for _, c := range entry.Changes {
+		b.WriteString("<li>" + html.EscapeString(c) + "</li>")
+	}
+	b.WriteString("</ul>")
+	return b.String()
+}
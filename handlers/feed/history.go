@@ -0,0 +1,197 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package feed
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/handlers/guides"
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/render/asset"
+	"github.com/dapperdox/dapperdox/spec"
+)
+
+// maxEntries limits the history kept of each specification
+const maxEntries = 100
+
+// Entry is the changes to a specification found when it was loaded
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Changes  []string  `json:"changes"`
+	Breaking bool      `json:"breaking,omitempty"`
+}
+
+// specHistory is what was last loaded of a specification, to compare the next load
+// with, and the changes found so far.
+type specHistory struct {
+	Operations map[string]spec.OperationSummary `json:"operations"`
+	Guides     map[string]string                `json:"guides"`  // Hash of the content, by route
+	Entries    []Entry                          `json:"entries"` // Newest first
+}
+
+var history map[string]*specHistory // By specification ID
+var lock sync.Mutex
+
+// ---------------------------------------------------------------------------
+// Record compares each loaded specification, and its guides, with what was loaded
+// before, adding an entry to its history if anything changed. The first load of a
+// specification is the baseline, and has no entry.
+func Record() {
+	cfg, _ := config.Get()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if history == nil {
+		history = load(cfg.ChangeFeedFile)
+	}
+
+	now := time.Now().UTC()
+	changed := false
+
+	for _, specification := range spec.APISuite {
+		operations := specification.OperationSummaries()
+		guidePages := guideHashes(specification.ID)
+
+		h, ok := history[specification.ID]
+		if !ok {
+			history[specification.ID] = &specHistory{Operations: operations, Guides: guidePages}
+			changed = true
+			continue
+		}
+
+		entry := Entry{Time: now}
+		for _, c := range spec.DiffOperations(h.Operations, operations) {
+			entry.Changes = append(entry.Changes, c.String())
+			entry.Breaking = entry.Breaking || c.Breaking
+		}
+		entry.Changes = append(entry.Changes, diffGuides(h.Guides, guidePages)...)
+		if len(entry.Changes) == 0 {
+			continue
+		}
+		logger.Infof(nil, "Specification '%s' has %d change(s)", specification.ID, len(entry.Changes))
+
+		h.Operations, h.Guides = operations, guidePages
+		h.Entries = append([]Entry{entry}, h.Entries...)
+		if len(h.Entries) > maxEntries {
+			h.Entries = h.Entries[:maxEntries]
+		}
+		changed = true
+	}
+
+	if changed && len(cfg.ChangeFeedFile) > 0 {
+		save(cfg.ChangeFeedFile)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// entries returns the history of a specification, newest first
+func entries(specID string) []Entry {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if h, ok := history[specID]; ok {
+		return h.Entries
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// guideHashes hashes the content of each guide of a specification, by route
+func guideHashes(specID string) map[string]string {
+	base := "assets/templates/" + specID + "/templates/guides"
+
+	hashes := make(map[string]string)
+	for _, name := range asset.AssetNames() {
+		if ext := filepath.Ext(name); !strings.HasPrefix(name, base+"/") || (ext != ".md" && ext != ".tmpl") {
+			continue
+		}
+		content, _ := asset.Asset(name)
+		sum := sha256.Sum256(content)
+		hashes["/"+specID+"/guides"+guides.StripBasepathAndExtension(name, base)] = hex.EncodeToString(sum[:8])
+	}
+	return hashes
+}
+
+// ---------------------------------------------------------------------------
+
+func diffGuides(before, after map[string]string) []string {
+	var changes []string
+	for route := range before {
+		if _, ok := after[route]; !ok {
+			changes = append(changes, "Guide "+route+" removed")
+		}
+	}
+	for route, hash := range after {
+		was, ok := before[route]
+		switch {
+		case !ok:
+			changes = append(changes, "Guide "+route+" added")
+		case was != hash:
+			changes = append(changes, "Guide "+route+" updated")
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+// ---------------------------------------------------------------------------
+// load reads the history kept by a previous run, if there is one
+func load(name string) map[string]*specHistory {
+	h := make(map[string]*specHistory)
+	if len(name) == 0 {
+		return h
+	}
+	data, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return h
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &h)
+	}
+	if err != nil {
+		logger.Errorf(nil, "Error reading change-feed-file %s: %s", name, err)
+		os.Exit(1)
+	}
+	return h
+}
+
+// ---------------------------------------------------------------------------
+// save writes the history, replacing the file only once it is complete
+func save(name string) {
+	data, err := json.Marshal(history)
+	if err == nil {
+		err = ioutil.WriteFile(name+".tmp", data, 0644)
+	}
+	if err == nil {
+		err = os.Rename(name+".tmp", name)
+	}
+	if err != nil {
+		logger.Errorf(nil, "Error writing change-feed-file %s: %s", name, err)
+	}
+}
@@ -0,0 +1,173 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package feedback
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/i18n"
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/render"
+	"github.com/gorilla/pat"
+)
+
+// Path is where pages post their "Was this page helpful?" feedback
+const Path = "/feedback"
+
+// ReportPath is where docs writers read the feedback, behind feedback-report-credentials
+const ReportPath = "/feedback/report"
+
+// maxBody limits the size of a feedback post, and maxComment the comment within it
+const maxBody = 8 << 10
+const maxComment = 2000
+
+// Feedback is a reader's answer to "Was this page helpful?"
+type Feedback struct {
+	Time    time.Time `json:"time"`
+	Page    string    `json:"page"`
+	Helpful bool      `json:"helpful"`
+	Comment string    `json:"comment,omitempty"`
+}
+
+// PageSummary totals the feedback on one page, for the report
+type PageSummary struct {
+	Page      string
+	Helpful   int
+	Unhelpful int
+	Comments  []Feedback // Newest first
+}
+
+var store Store
+
+// ---------------------------------------------------------------------------
+// Register creates the feedback routes, if a feedback-store is configured. It fails,
+// keeping the previous store, if the store cannot be opened.
+func Register(r *pat.Router) error {
+	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+
+	if len(cfg.FeedbackStore) == 0 {
+		return nil
+	}
+	opened, err := newStore(cfg.FeedbackStore)
+	if err != nil {
+		return fmt.Errorf("feedback-store %s: %s", cfg.FeedbackStore, err)
+	}
+	store = opened
+	logger.Infof(nil, "Registering page feedback at %s", Path)
+
+	r.Path(Path).Methods("POST").HandlerFunc(feedbackHandler)
+
+	if len(cfg.FeedbackReportAuth) > 0 {
+		credentials := strings.SplitN(cfg.FeedbackReportAuth, ":", 2) // Checked when the configuration is read
+		r.Path(ReportPath).Methods("GET").Handler(authenticate(credentials, http.HandlerFunc(reportHandler)))
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+
+func feedbackHandler(w http.ResponseWriter, req *http.Request) {
+	var feedback Feedback
+	if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxBody)).Decode(&feedback); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	feedback.Comment = strings.TrimSpace(feedback.Comment)
+	if !strings.HasPrefix(feedback.Page, "/") || utf8.RuneCountInString(feedback.Comment) > maxComment {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	feedback.Time = time.Now().UTC()
+
+	if err := store.Save(feedback); err != nil {
+		logger.Errorf(req, "Error saving feedback on %s: %s", feedback.Page, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ---------------------------------------------------------------------------
+// reportHandler renders the feedback on each page, least helpful first
+func reportHandler(w http.ResponseWriter, req *http.Request) {
+	all, err := store.All()
+	if err == errNotReadable {
+		render.Error(w, req, http.StatusNotImplemented, "feedback", "Feedback sent to a webhook cannot be reported on")
+		return
+	}
+	if err != nil {
+		logger.Errorf(req, "Error reading feedback: %s", err)
+		render.Error(w, req, http.StatusInternalServerError, "feedback", "Feedback could not be read")
+		return
+	}
+
+	pages := make(map[string]*PageSummary)
+	var summaries []*PageSummary
+	for _, feedback := range all {
+		summary, ok := pages[feedback.Page]
+		if !ok {
+			summary = &PageSummary{Page: feedback.Page}
+			pages[feedback.Page] = summary
+			summaries = append(summaries, summary)
+		}
+		if feedback.Helpful {
+			summary.Helpful++
+		} else {
+			summary.Unhelpful++
+		}
+		if len(feedback.Comment) > 0 {
+			summary.Comments = append([]Feedback{feedback}, summary.Comments...)
+		}
+	}
+	sort.SliceStable(summaries, func(i, j int) bool {
+		if summaries[i].Unhelpful != summaries[j].Unhelpful {
+			return summaries[i].Unhelpful > summaries[j].Unhelpful
+		}
+		return summaries[i].Page < summaries[j].Page
+	})
+
+	w.Header().Set("Cache-Control", "no-store")
+	render.HTML(w, http.StatusOK, "feedback_report", render.DefaultVars(req, nil, render.Vars{"Title": i18n.Translate(i18n.Locale(req), "Page feedback"), "FeedbackReport": true, "Pages": summaries, "FeedbackCount": len(all)}))
+}
+
+// ---------------------------------------------------------------------------
+
+func authenticate(credentials []string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		user, password, ok := req.BasicAuth()
+
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(user), []byte(credentials[0])) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(credentials[1])) != 1 {
+
+			logger.Warnf(req, "Unauthorised request for %s", req.URL.Path)
+			w.Header().Set("WWW-Authenticate", `Basic realm="DapperDox feedback"`)
+			http.Error(w, "Unauthorised", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,217 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package feedback
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dapperdox/dapperdox/logger"
+)
+
+// Store keeps the feedback that readers give
+type Store interface {
+	Save(feedback Feedback) error
+	All() ([]Feedback, error) // Oldest first
+}
+
+// errNotReadable is returned by stores that feedback can only be sent to
+var errNotReadable = errors.New("feedback store cannot be read")
+
+// webhookTimeout limits how long a webhook has to accept feedback
+const webhookTimeout = 10 * time.Second
+
+// ---------------------------------------------------------------------------
+// newStore creates the store given by the feedback-store setting: file:<path>,
+// webhook:<url> or sql:<driver>:<data source name>.
+func newStore(location string) (Store, error) {
+	kind := strings.SplitN(location, ":", 2)
+	if len(kind) != 2 || len(kind[1]) == 0 {
+		return nil, fmt.Errorf("expected file:<path>, webhook:<url> or sql:<driver>:<data source name>")
+	}
+	switch kind[0] {
+	case "file":
+		return &fileStore{path: kind[1]}, nil
+	case "webhook":
+		return &webhookStore{url: kind[1], client: &http.Client{Timeout: webhookTimeout}}, nil
+	case "sql":
+		return newSQLStore(kind[1])
+	}
+	return nil, fmt.Errorf("unknown store %s, expected file, webhook or sql", kind[0])
+}
+
+// ---------------------------------------------------------------------------
+// fileStore appends feedback to a file, as a line of JSON each
+
+type fileStore struct {
+	path string
+	lock sync.Mutex
+}
+
+func (s *fileStore) Save(feedback Feedback) error {
+	line, err := json.Marshal(feedback)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *fileStore) All() ([]Feedback, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var all []Feedback
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var feedback Feedback
+		if err := json.Unmarshal(scanner.Bytes(), &feedback); err != nil {
+			continue // A line cut short by a crash, say
+		}
+		all = append(all, feedback)
+	}
+	return all, scanner.Err()
+}
+
+// ---------------------------------------------------------------------------
+// webhookStore posts each piece of feedback as JSON to a URL, such as a chat or
+// issue tracker integration. It is sent in the background, so that a slow webhook
+// does not hold up the reader.
+
+type webhookStore struct {
+	url    string
+	client *http.Client
+}
+
+func (s *webhookStore) Save(feedback Feedback) error {
+	body, err := json.Marshal(feedback)
+	if err != nil {
+		return err
+	}
+	go func() {
+		resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			logger.Errorf(nil, "Error sending feedback to webhook: %s", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			logger.Errorf(nil, "Error sending feedback to webhook: %s", resp.Status)
+		}
+	}()
+	return nil
+}
+
+func (s *webhookStore) All() ([]Feedback, error) {
+	return nil, errNotReadable
+}
+
+// ---------------------------------------------------------------------------
+// sqlStore keeps feedback in a feedback table, which is created if it does not exist.
+// The database driver must be compiled in, by importing it in a build of DapperDox.
+
+type sqlStore struct {
+	db       *sql.DB
+	numbered bool // Parameters are $1, $2 and so on, as PostgreSQL drivers want, rather than ?
+}
+
+func newSQLStore(location string) (Store, error) {
+	dsn := strings.SplitN(location, ":", 2)
+	if len(dsn) != 2 {
+		return nil, fmt.Errorf("expected sql:<driver>:<data source name>")
+	}
+	db, err := sql.Open(dsn[0], dsn[1])
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS feedback (
+		time TIMESTAMP NOT NULL,
+		page VARCHAR(2048) NOT NULL,
+		helpful BOOLEAN NOT NULL,
+		comment TEXT
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqlStore{db: db, numbered: dsn[0] == "postgres" || dsn[0] == "pgx"}, nil
+}
+
+func (s *sqlStore) Save(feedback Feedback) error {
+	_, err := s.db.Exec(s.placeholders("INSERT INTO feedback (time, page, helpful, comment) VALUES (?, ?, ?, ?)"),
+		feedback.Time, feedback.Page, feedback.Helpful, feedback.Comment)
+	return err
+}
+
+func (s *sqlStore) All() ([]Feedback, error) {
+	rows, err := s.db.Query("SELECT time, page, helpful, comment FROM feedback ORDER BY time")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []Feedback
+	for rows.Next() {
+		var feedback Feedback
+		var comment sql.NullString
+		if err := rows.Scan(&feedback.Time, &feedback.Page, &feedback.Helpful, &comment); err != nil {
+			return nil, err
+		}
+		feedback.Comment = comment.String
+		all = append(all, feedback)
+	}
+	return all, rows.Err()
+}
+
+func (s *sqlStore) placeholders(query string) string {
+	if !s.numbered {
+		return query
+	}
+	for n := 1; strings.Contains(query, "?"); n++ {
+		query = strings.Replace(query, "?", fmt.Sprintf("$%d", n), 1)
+	}
+	return query
+}
@@ -0,0 +1,79 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package githook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/gorilla/pat"
+)
+
+// Path is the webhook endpoint that a git host is configured to call on push
+const Path = "/hooks/git"
+
+// Register mounts the git webhook, if a repository is configured. On a verified
+// call reload is called, which brings the working copy up to date, in the background
+// as a reload replaces the router that is serving the webhook request.
+func Register(r *pat.Router, reload func() error) {
+	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+
+	if len(cfg.GitRepoDir) == 0 {
+		return // git-hook-secret is required with git-repo-dir when the configuration is read
+	}
+
+	logger.Infof(nil, "Registering git webhook at %s", Path)
+
+	r.Path(Path).Methods("POST").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, 1<<20))
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		if !verify(req, body, cfg.GitHookSecret) {
+			logger.Warnf(req, "Unverified git webhook call")
+			http.Error(w, "Unauthorised", http.StatusUnauthorized)
+			return
+		}
+
+		go reload()
+
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+// ---------------------------------------------------------------------------
+// verify accepts a GitHub style HMAC signature of the body, or a GitLab style token
+func verify(req *http.Request, body []byte, secret string) bool {
+	if signature := req.Header.Get("X-Hub-Signature-256"); len(signature) > 0 {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		return hmac.Equal([]byte(signature), []byte(expected))
+	}
+	if token := req.Header.Get("X-Gitlab-Token"); len(token) > 0 {
+		return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+	}
+	return false
+}
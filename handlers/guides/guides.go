@@ -19,12 +19,13 @@ package guides
 
 import (
 	//"github.com/davecgh/go-spew/spew"
+	"fmt"
 	"net/http"
-	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 
+	"github.com/dapperdox/dapperdox/i18n"
 	"github.com/dapperdox/dapperdox/logger"
 	"github.com/dapperdox/dapperdox/navigation"
 	"github.com/dapperdox/dapperdox/render"
@@ -34,26 +35,30 @@ import (
 )
 
 // ---------------------------------------------------------------------------
-// Register routes for guide pages
-func Register(r *pat.Router) {
+// Register routes for the top level guide pages. It fails if the guides cannot be
+// arranged into their navigation.
+func Register(r *pat.Router) error {
 
 	logger.Infof(nil, "Registering guides")
 
-	// specification specific guides
-	for _, specification := range spec.APISuite {
-		logger.Debugf(nil, "- Specification guides for '%s'", specification.APIInfo.Title)
-		register(r, "assets/templates", specification)
-	}
-
 	// Top level guides
 	logger.Debugf(nil, "- Root guides")
-	register(r, "assets/templates", nil)
+	return register(r, "assets/templates", nil)
+}
 
-	logger.Debugf(nil, "\n")
+// ---------------------------------------------------------------------------
+// RegisterSpecification registers routes for the guide pages of a specification
+func RegisterSpecification(r *pat.Router, specification *spec.APISpecification) error {
+	logger.Debugf(nil, "- Specification guides for '%s'", specification.APIInfo.Title)
+	return register(r, "assets/templates", specification)
 }
 
 // ---------------------------------------------------------------------------
-func register(r *pat.Router, base string, specification *spec.APISpecification) {
+// register walks the guides of a specification, or the top level guides. Guides in
+// guides/<locale> are translations, served at the same route as the guide they
+// translate to readers of that locale. A locale's navigation lists its own guides
+// and the default guides that have no translation.
+func register(r *pat.Router, base string, specification *spec.APISpecification) error {
 
 	root_node := "/guides"
 	route_base := "/guides"
@@ -63,11 +68,11 @@ func register(r *pat.Router, base string, specification *spec.APISpecification)
 	}
 
 	path_base := base + root_node
+	locales := i18n.ContentLocales()
 
-	guidesNavigation := &navigation.NavigationNode{}
-
-	guidesNavigation.Children = make([]*navigation.NavigationNode, 0)
-	guidesNavigation.ChildMap = make(map[string]*navigation.NavigationNode)
+	// route -> content locale ("" for the default guides) -> asset path
+	pages := make(map[string]map[string]string)
+	var routes []string
 
 	logger.Tracef(nil, "  - Walk compiled asset tree %s", path_base)
 
@@ -81,37 +86,120 @@ func register(r *pat.Router, base string, specification *spec.APISpecification)
 		case ".tmpl", ".md":
 			logger.Debugf(nil, "    - File "+path)
 
+			locale := guideLocale(path, path_base, locales)
+
 			// Convert path/filename to route
-			route := route_base + StripBasepathAndExtension(path, path_base)
-			absresource := StripBasepathAndExtension(path, base)
-			resource := strings.TrimPrefix(absresource, "/")
+			route := route_base + StripBasepathAndExtension(path, localeBase(path_base, locale))
 
 			logger.Tracef(nil, "      = URL  "+route)
 
-			buildNavigation(guidesNavigation, path, path_base, route, ext)
+			if _, ok := pages[route]; !ok {
+				pages[route] = make(map[string]string)
+				routes = append(routes, route)
+			}
+			pages[route][locale] = path
+		}
+	}
+
+	var nav *spec.Navigation
+	if specification != nil {
+		nav = specification.Navigation
+	} else {
+		var err error
+		if nav, err = spec.LoadNavigation(""); err != nil {
+			return err
+		}
+	}
+
+	navigations := make(map[string]*navigation.NavigationNode)
 
-			r.Path(route).Methods("GET").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-				sid := "TOP LEVEL"
-				if specification != nil {
-					sid = specification.ID
+	for _, locale := range append([]string{""}, locales...) {
+		guidesNavigation := &navigation.NavigationNode{}
+
+		guidesNavigation.Children = make([]*navigation.NavigationNode, 0)
+		guidesNavigation.ChildMap = make(map[string]*navigation.NavigationNode)
+
+		for _, route := range routes {
+			pageLocale := locale
+			path, ok := pages[route][pageLocale]
+			if !ok {
+				pageLocale = ""
+				if path, ok = pages[route][pageLocale]; !ok {
+					continue
 				}
-				logger.Tracef(nil, "Fetching guide from '%s' for spec ID %s\n", resource, sid)
-				render.HTML(w, http.StatusOK, resource, render.DefaultVars(req, specification, render.Vars{"Guide": resource}))
-			})
+			}
+			if err := buildNavigation(guidesNavigation, path, localeBase(path_base, pageLocale), route, filepath.Ext(path)); err != nil {
+				return err
+			}
+		}
+
+		if nav != nil && len(nav.Guides) > 0 {
+			pinNavigation(guidesNavigation, nav.GuideOrder(), route_base+"/")
 		}
+		sortNavigation(guidesNavigation)
+		navigations[locale] = guidesNavigation
+
+		// Register the guides navigation with the renderer
+		render.SetGuidesNavigation(specification, locale, &guidesNavigation.Children)
 	}
 
-	sortNavigation(guidesNavigation)
+	for _, route := range routes {
+		translations := pages[route]
+
+		r.Path(route).Methods("GET").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			path, ok := translations[i18n.ContentLocale(i18n.Locale(req))]
+			if !ok {
+				if path, ok = translations[""]; !ok {
+					// Only translations exist, so serve the first of them
+					for _, locale := range locales {
+						if path, ok = translations[locale]; ok {
+							break
+						}
+					}
+				}
+			}
+			resource := strings.TrimPrefix(StripBasepathAndExtension(path, base), "/")
+
+			sid := "TOP LEVEL"
+			if specification != nil {
+				sid = specification.ID
+			}
+			logger.Tracef(nil, "Fetching guide from '%s' for spec ID %s\n", resource, sid)
+			render.HTML(w, http.StatusOK, resource, render.DefaultVars(req, specification, render.Vars{"Guide": resource}))
+		})
+	}
 
 	// Register default route for this guide set
 	r.Path(route_base).Methods("GET").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		guidesNavigation, ok := navigations[i18n.ContentLocale(i18n.Locale(req))]
+		if !ok {
+			guidesNavigation = navigations[""]
+		}
 		uri := findFirstGuideUri(guidesNavigation)
 		logger.Infof(nil, "Redirect to %s\n", uri)
 		http.Redirect(w, req, uri, 302)
 	})
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// guideLocale returns the content locale of a guide, or "" if it is a default guide.
+func guideLocale(path string, path_base string, locales []string) string {
+	for _, locale := range locales {
+		if strings.HasPrefix(path, path_base+"/"+locale+"/") {
+			return locale
+		}
+	}
+	return ""
+}
+
+// ---------------------------------------------------------------------------
 
-	// Register the guides navigation with the renderer
-	render.SetGuidesNavigation(specification, &guidesNavigation.Children)
+func localeBase(path_base string, locale string) string {
+	if len(locale) == 0 {
+		return path_base
+	}
+	return path_base + "/" + locale
 }
 
 // ---------------------------------------------------------------------------
@@ -133,6 +221,31 @@ func findFirstGuideUri(tree *navigation.NavigationNode) string {
 	return uri
 }
 
+// ---------------------------------------------------------------------------
+// pinNavigation puts the guides that nav.yaml lists first, in its order, by giving them
+// sort orders below those of the rest. A branch sorts with its first listed guide.
+func pinNavigation(tree *navigation.NavigationNode, order map[string]int, route_base string) {
+	for _, node := range tree.Children {
+		if len(node.Children) > 0 {
+			pinNavigation(node, order, route_base)
+		}
+		pinned := ""
+		if i, ok := order[strings.TrimPrefix(node.Uri, route_base)]; ok && len(node.Uri) > 0 {
+			pinned = fmt.Sprintf("0:%06d", i)
+		}
+		for _, child := range node.Children {
+			if strings.HasPrefix(child.SortOrder, "0:") && (pinned == "" || child.SortOrder < pinned) {
+				pinned = child.SortOrder
+			}
+		}
+		if len(pinned) > 0 {
+			node.SortOrder = pinned
+		} else {
+			node.SortOrder = "1:" + node.SortOrder
+		}
+	}
+}
+
 // ---------------------------------------------------------------------------
 func sortNavigation(tree *navigation.NavigationNode) {
 
@@ -168,7 +281,7 @@ func StripBasepathAndExtension(name string, basepath string) string {
 }
 
 // ---------------------------------------------------------------------------
-func buildNavigation(nav *navigation.NavigationNode, path string, path_base string, route string, ext string) {
+func buildNavigation(nav *navigation.NavigationNode, path string, path_base string, route string, ext string) error {
 
 	logger.Tracef(nil, "      - Look for metadata asset %s\n", path)
 
@@ -189,8 +302,7 @@ func buildNavigation(nav *navigation.NavigationNode, path string, path_base stri
 	parts := len(split)
 
 	if parts > 2 {
-		logger.Errorf(nil, "Error: Guide '"+hierarchy+"' contains too many nagivation levels (%d)", parts)
-		os.Exit(1)
+		return fmt.Errorf("guide '%s' contains too many navigation levels (%d)", hierarchy, parts)
 	}
 
 	if sortOrder == "" {
@@ -254,6 +366,7 @@ func buildNavigation(nav *navigation.NavigationNode, path string, path_base stri
 			}
 		}
 	}
+	return nil
 }
 
 // ---------------------------------------------------------------------------
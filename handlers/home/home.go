@@ -19,48 +19,60 @@ package home
 
 import (
 	"net/http"
+	"sort"
 
 	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/i18n"
 	"github.com/dapperdox/dapperdox/logger"
 	"github.com/dapperdox/dapperdox/render"
 	"github.com/dapperdox/dapperdox/spec"
 	"github.com/gorilla/pat"
 )
 
+// StatusPath is the status page, which is only served if an access rule covers it
+const StatusPath = "/status"
+
 // ----------------------------------------------------------------------------------------
-// Register creates routes for each home handler
+// Register creates routes for each home handler. RegisterSpecification creates the
+// homepage of each specification.
 func Register(r *pat.Router) {
 	logger.Debugln(nil, "registering handlers for home page")
 
-	count := 0
-	// Homepages for each loaded specification
-	var specification *spec.APISpecification // Ends up being populated with the last spec processed
-
-	for _, specification = range spec.APISuite {
-
-		logger.Tracef(nil, "Build homepage route for specification '%s'", specification.ID)
-
-		r.Path("/" + specification.ID + "/reference").Methods("GET").HandlerFunc(specificationSummaryHandler(specification))
-
-		// If missingh trailing slash, redirect to add it
-		r.Path("/" + specification.ID).Methods("GET").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-			http.Redirect(w, req, "/"+specification.ID+"/", 302)
-		})
+	cfg, _ := config.Get()
 
-		count++
+	// The status page gives the errors of the specifications that failed to load, which
+	// may reveal file paths and hosts, so it is only served behind an access rule
+	if cfg.AccessRule(StatusPath) != nil {
+		r.Path(StatusPath).Methods("GET").HandlerFunc(statusHandler)
+	} else {
+		logger.Infof(nil, "Status page not served, as no access rule covers %s", StatusPath)
 	}
 
-	cfg, _ := config.Get()
-
-	if count == 1 && cfg.ForceSpecList == false {
+	r.Path("/").Methods("GET").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		// If there is only one specification loaded, then hotwire '/' to redirect to the
 		// specification summary page unless DapperDox is configured to show the specification list page.
-		r.Path("/").Methods("GET").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-			http.Redirect(w, req, "/"+specification.ID+"/reference", 302)
-		})
-	} else {
-		r.Path("/").Methods("GET").HandlerFunc(specificationListHandler)
-	}
+		// It is looked up for each request, as reloading a specification may change its ID.
+		if len(spec.APISuite) == 1 && cfg.ForceSpecList == false {
+			for _, specification := range spec.APISuite {
+				http.Redirect(w, req, "/"+specification.ID+"/reference", 302)
+			}
+			return
+		}
+		specificationListHandler(w, req)
+	})
+}
+
+// ----------------------------------------------------------------------------------------
+// RegisterSpecification creates the homepage routes of a specification
+func RegisterSpecification(r *pat.Router, specification *spec.APISpecification) {
+	logger.Tracef(nil, "Build homepage route for specification '%s'", specification.ID)
+
+	r.Path("/" + specification.ID + "/reference").Methods("GET").HandlerFunc(specificationSummaryHandler(specification))
+
+	// If missingh trailing slash, redirect to add it
+	r.Path("/" + specification.ID).Methods("GET").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Redirect(w, req, "/"+specification.ID+"/", 302)
+	})
 }
 
 // ----------------------------------------------------------------------------------------
@@ -68,7 +80,22 @@ func Register(r *pat.Router) {
 func specificationListHandler(w http.ResponseWriter, req *http.Request) {
 	logger.Tracef(nil, "Render HTML for top level index page")
 
-	render.HTML(w, http.StatusOK, "specification_list", render.DefaultVars(req, nil, render.Vars{"Title": "Specifications list", "SpecificationList": true}))
+	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+
+	render.HTML(w, http.StatusOK, "specification_list", render.DefaultVars(req, nil, render.Vars{"Title": i18n.Translate(i18n.Locale(req), "Specifications list"), "SpecificationList": true, "Catalogue": spec.Catalogue(), "LoadFailures": spec.LoadFailures, "StatusPage": cfg.AccessRule(StatusPath) != nil}))
+}
+
+// ----------------------------------------------------------------------------------------
+// statusHandler is a http.Handler for the status page, which lists the specifications that
+// are served and those that could not be loaded
+func statusHandler(w http.ResponseWriter, req *http.Request) {
+	var specifications []*spec.APISpecification
+	for _, specification := range spec.APISuite {
+		specifications = append(specifications, specification)
+	}
+	sort.Slice(specifications, func(i, j int) bool { return specifications[i].ID < specifications[j].ID })
+
+	render.HTML(w, http.StatusOK, "status", render.DefaultVars(req, nil, render.Vars{"Title": i18n.Translate(i18n.Locale(req), "Status"), "Specifications": specifications, "LoadFailures": spec.LoadFailures}))
 }
 
 // ----------------------------------------------------------------------------------------
@@ -85,7 +112,7 @@ func specificationSummaryHandler(specification *spec.APISpecification) func(w ht
 		tmpl = customTmpl
 	}
 	return func(w http.ResponseWriter, req *http.Request) {
-		render.HTML(w, http.StatusOK, tmpl, render.DefaultVars(req, specification, render.Vars{"Title": "Specification summary", "SpecificationSummary": true}))
+		render.HTML(w, http.StatusOK, tmpl, render.DefaultVars(req, specification, render.Vars{"Title": i18n.Translate(i18n.Locale(req), "Specification summary"), "SpecificationSummary": true}))
 	}
 }
 
@@ -0,0 +1,105 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package livereload
+
+// Package livereload pushes a reload signal to open browser pages, as server sent
+// events, so that documentation authors see their changes as soon as they are made.
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dapperdox/dapperdox/config"
+)
+
+// Path is the event stream that pages subscribe to
+const Path = "/_dapperdox/live-reload"
+
+var clients = map[chan struct{}]bool{}
+var clientsLock sync.Mutex
+
+// ---------------------------------------------------------------------------
+// Handler serves the event stream in watch mode. It must be outside any handler that
+// wraps the ResponseWriter, holds a lock or applies a timeout, as streams are long lived.
+func Handler(h http.Handler) http.Handler {
+	cfg, _ := config.Get()
+	if !cfg.Watch {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != Path {
+			h.ServeHTTP(w, req)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		reload := make(chan struct{}, 1)
+		clientsLock.Lock()
+		clients[reload] = true
+		clientsLock.Unlock()
+
+		defer func() {
+			clientsLock.Lock()
+			delete(clients, reload)
+			clientsLock.Unlock()
+		}()
+
+		keepalive := time.NewTicker(30 * time.Second)
+		defer keepalive.Stop()
+
+		for {
+			select {
+			case <-reload:
+				fmt.Fprint(w, "event: reload\ndata: {}\n\n")
+				flusher.Flush()
+			case <-keepalive.C:
+				fmt.Fprint(w, ": keepalive\n\n")
+				flusher.Flush()
+			case <-req.Context().Done():
+				return
+			}
+		}
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Notify tells every open page to reload
+func Notify() {
+	clientsLock.Lock()
+	defer clientsLock.Unlock()
+
+	for reload := range clients {
+		select {
+		case reload <- struct{}{}:
+		default: // A reload is already pending
+		}
+	}
+}
@@ -0,0 +1,265 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package mock
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/spec"
+	"github.com/gorilla/pat"
+)
+
+// PathPrefix is where the mock server is served. Requests to PathPrefix/<spec-id>/<path>
+// are answered with the example response of the operation that matches the path.
+const PathPrefix = "/mock"
+
+// preferCode picks out the status asked for with a "Prefer: code=404" header
+var preferCode = regexp.MustCompile(`(?:^|[,;\s])code=(\d{3})`)
+
+// ---------------------------------------------------------------------------
+// Register creates the mock server route, if it is enabled
+func Register(r *pat.Router) {
+	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+
+	if !cfg.Mock {
+		return
+	}
+	logger.Debugln(nil, "registering handlers for mock package")
+
+	r.PathPrefix(PathPrefix + "/").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// Consumers call the mock server from their own pages
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Expose-Headers", "*")
+		if req.Method == "OPTIONS" && len(req.Header.Get("Access-Control-Request-Method")) > 0 {
+			w.Header().Set("Access-Control-Allow-Methods", req.Header.Get("Access-Control-Request-Method"))
+			w.Header().Set("Access-Control-Allow-Headers", req.Header.Get("Access-Control-Request-Headers"))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, PathPrefix+"/"), "/", 2)
+		specification, ok := spec.APISuite[parts[0]]
+		if !ok || len(parts) < 2 {
+			mockError(w, http.StatusNotFound, "No specification "+parts[0])
+			return
+		}
+
+		method := findMethod(specification, req.Method, "/"+parts[1])
+		if method == nil {
+			mockError(w, http.StatusNotFound, "No operation of "+specification.ID+" matches "+req.Method+" /"+parts[1])
+			return
+		}
+
+		status, response := chooseResponse(method, req.Header.Get("Prefer"))
+		if response == nil {
+			mockError(w, http.StatusNotImplemented, "Response "+strconv.Itoa(status)+" is not documented for "+req.Method+" /"+parts[1])
+			return
+		}
+
+		for _, header := range response.Headers {
+			if len(header.Default) > 0 {
+				w.Header().Set(header.Name, header.Default)
+			}
+		}
+
+		var body []byte
+		if example := response.ExampleValue(); example != nil {
+			mediaTypes := jsonMediaTypes(method.Produces)
+			if len(mediaTypes) == 0 {
+				mockError(w, http.StatusNotImplemented, "The mock server only gives JSON, and "+req.Method+" /"+parts[1]+" produces "+strings.Join(method.Produces, ", "))
+				return
+			}
+			mediaType := negotiate(req.Header.Get("Accept"), mediaTypes)
+			if len(mediaType) == 0 {
+				mockError(w, http.StatusNotAcceptable, "None of "+strings.Join(mediaTypes, ", ")+" is acceptable")
+				return
+			}
+			body, _ = spec.JSONMarshalIndent(example)
+			w.Header().Set("Content-Type", mediaType)
+		}
+		w.WriteHeader(status)
+		w.Write(body)
+	})
+}
+
+// ---------------------------------------------------------------------------
+// jsonMediaTypes returns the JSON media types that an operation produces, which are
+// those a mock response can have. An operation that does not say produces JSON.
+func jsonMediaTypes(produces []string) []string {
+	if len(produces) == 0 {
+		return []string{"application/json"}
+	}
+	var mediaTypes []string
+	for _, mediaType := range produces {
+		if strings.Contains(mediaType, "json") {
+			mediaTypes = append(mediaTypes, mediaType)
+		}
+	}
+	return mediaTypes
+}
+
+// negotiate chooses from the media types by an Accept header value, returning "" if
+// none of them are acceptable.
+func negotiate(accept string, mediaTypes []string) string {
+	best, bestQuality := "", 0.0
+	for _, mediaType := range mediaTypes {
+		if quality := acceptable(accept, mediaType); quality > bestQuality {
+			best, bestQuality = mediaType, quality
+		}
+	}
+	return best
+}
+
+// acceptable returns the quality that an Accept header value gives a media type, from
+// the most specific range that matches it. Everything is acceptable without a header.
+func acceptable(accept string, mediaType string) float64 {
+	if len(strings.TrimSpace(accept)) == 0 {
+		return 1
+	}
+	mediaType = strings.ToLower(strings.TrimSpace(strings.SplitN(mediaType, ";", 2)[0]))
+
+	quality, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		accepted := strings.ToLower(strings.TrimSpace(fields[0]))
+
+		matches := 0
+		switch {
+		case accepted == mediaType:
+			matches = 2
+		case strings.HasSuffix(accepted, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(accepted, "*")):
+			matches = 1
+		case accepted == "*/*":
+		default:
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if matches > specificity {
+			quality, specificity = q, matches
+		}
+	}
+	return quality
+}
+
+// ---------------------------------------------------------------------------
+// findMethod returns the operation whose path matches, preferring the one with the
+// most literal segments where several do, so that /pets/mine beats /pets/{id}.
+func findMethod(specification *spec.APISpecification, verb string, path string) *spec.Method {
+	var found *spec.Method
+	best := -1
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	for a := range specification.APIs {
+		for m := range specification.APIs[a].Methods {
+			method := &specification.APIs[a].Methods[m]
+			if !strings.EqualFold(method.Method, verb) {
+				continue
+			}
+			if literals, ok := matchPath(strings.Split(strings.Trim(method.Path, "/"), "/"), segments); ok && literals > best {
+				found, best = method, literals
+			}
+		}
+	}
+	return found
+}
+
+// matchPath returns how many literal segments of the template match the path, and
+// whether all of its segments do.
+func matchPath(template, segments []string) (int, bool) {
+	if len(template) != len(segments) {
+		return 0, false
+	}
+	literals := 0
+	for i, t := range template {
+		switch {
+		case strings.HasPrefix(t, "{") && strings.HasSuffix(t, "}"):
+			if len(segments[i]) == 0 {
+				return 0, false
+			}
+		case t == segments[i]:
+			literals++
+		default:
+			return 0, false
+		}
+	}
+	return literals, true
+}
+
+// ---------------------------------------------------------------------------
+// chooseResponse returns the status asked for with a Prefer header, or else the
+// lowest success status, and its documented response. Undocumented statuses use
+// the default response, if there is one.
+func chooseResponse(method *spec.Method, prefer string) (int, *spec.Response) {
+	if match := preferCode.FindStringSubmatch(prefer); match != nil {
+		status, _ := strconv.Atoi(match[1])
+		if response, ok := method.Responses[status]; ok {
+			return status, &response
+		}
+		return status, method.DefaultResponse
+	}
+
+	var statuses []int
+	for status := range method.Responses {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+
+	for _, status := range statuses {
+		if status >= 200 && status < 300 {
+			response := method.Responses[status]
+			return status, &response
+		}
+	}
+	if method.DefaultResponse != nil {
+		return http.StatusOK, method.DefaultResponse
+	}
+	if len(statuses) > 0 {
+		response := method.Responses[statuses[0]]
+		return statuses[0], &response
+	}
+	return http.StatusNoContent, &spec.Response{}
+}
+
+// ---------------------------------------------------------------------------
+
+func mockError(w http.ResponseWriter, status int, message string) {
+	body, _ := json.Marshal(struct {
+		Message string `json:"message"`
+	}{message})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(body, '\n'))
+}
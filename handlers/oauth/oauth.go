@@ -0,0 +1,407 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package oauth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/network"
+	"github.com/dapperdox/dapperdox/session"
+	"github.com/dapperdox/dapperdox/spec"
+	"github.com/gorilla/pat"
+)
+
+// The explorer acquires OAuth2 tokens through the server, so that the browser never
+// sees client secrets and tokens are held in the browser's server side session.
+//
+// For the authorization code flow, the explorer sends the browser to AuthorizePath,
+// which redirects to the authorization server with a PKCE challenge. The
+// authorization server redirects back to CallbackPath, where the code is exchanged
+// for a token. The explorer then reads the token from TokenPath.
+//
+// For the client credentials and password flows, the explorer posts the credentials
+// the reader entered to AcquirePath, which requests a token, or returns the token
+// already acquired with them if it has not expired.
+
+// PathPrefix prefixes the OAuth2 routes
+const PathPrefix = "/_dapperdox/oauth"
+
+const (
+	AuthorizePath = PathPrefix + "/authorize"
+	CallbackPath  = PathPrefix + "/callback"
+	TokenPath     = PathPrefix + "/token"
+	AcquirePath   = PathPrefix + "/acquire"
+)
+
+// pendingTimeout is how long the reader has to sign in to the authorization server
+const pendingTimeout = 10 * time.Minute
+
+// pending is an authorization in progress, held in the session under its state
+type pending struct {
+	specID   string
+	scheme   string
+	verifier string
+	returnTo string
+	started  time.Time
+}
+
+// Token is an access token, held in the session under tokenKey
+type Token struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type,omitempty"`
+	Expires     time.Time `json:"-"`
+	ExpiresIn   int       `json:"expires_in,omitempty"`
+	Credentials string    `json:"-"` // Hash of the credentials it was acquired with, by AcquirePath
+}
+
+// ---------------------------------------------------------------------------
+// Register creates the OAuth2 routes
+func Register(r *pat.Router) {
+	logger.Debugln(nil, "registering handlers for oauth package")
+
+	r.Path(AuthorizePath).Methods("GET").HandlerFunc(authorize)
+	r.Path(CallbackPath).Methods("GET").HandlerFunc(callback)
+	r.Path(TokenPath).Methods("GET").HandlerFunc(token)
+	r.Path(AcquirePath).Methods("POST").HandlerFunc(acquire)
+}
+
+// ---------------------------------------------------------------------------
+// authorize starts the authorization code flow for ?spec=<id>&scheme=<name>, returning
+// to ?return=<path> once done.
+func authorize(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	scheme, client, err := lookup(query.Get("spec"), query.Get("scheme"))
+	if err != nil {
+		logger.Warnf(req, "OAuth2 authorize: %s", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if scheme.OAuth2Flow != "accessCode" || len(scheme.AuthorizationUrl) == 0 {
+		http.Error(w, "Security scheme does not use the authorization code flow", http.StatusBadRequest)
+		return
+	}
+
+	p := &pending{
+		specID:   query.Get("spec"),
+		scheme:   query.Get("scheme"),
+		verifier: session.NewID(),
+		returnTo: localPath(query.Get("return")),
+		started:  time.Now(),
+	}
+	state := session.NewID()
+	session.Get(w, req).SetValue("oauth-pending:"+state, p)
+
+	challenge := sha256.Sum256([]byte(p.verifier))
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", client.ClientID)
+	params.Set("redirect_uri", redirectURI())
+	params.Set("state", state)
+	params.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	params.Set("code_challenge_method", "S256")
+	if scopes := query.Get("scope"); len(scopes) > 0 {
+		params.Set("scope", scopes)
+	} else {
+		params.Set("scope", strings.Join(scopeNames(scheme), " "))
+	}
+
+	target := scheme.AuthorizationUrl
+	if strings.Contains(target, "?") {
+		target += "&" + params.Encode()
+	} else {
+		target += "?" + params.Encode()
+	}
+	http.Redirect(w, req, target, http.StatusFound)
+}
+
+// ---------------------------------------------------------------------------
+// callback completes the authorization code flow, exchanging the code for a token.
+func callback(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	s := session.Find(req)
+	if s == nil {
+		http.Error(w, "No authorization in progress", http.StatusBadRequest)
+		return
+	}
+	key := "oauth-pending:" + query.Get("state")
+	p, ok := s.Value(key).(*pending)
+	s.SetValue(key, nil) // A state is only good for one callback
+	if !ok || time.Since(p.started) > pendingTimeout {
+		http.Error(w, "Unknown or expired authorization state", http.StatusBadRequest)
+		return
+	}
+
+	if e := query.Get("error"); len(e) > 0 {
+		logger.Warnf(req, "OAuth2 authorization refused: %s %s", e, query.Get("error_description"))
+		http.Error(w, "Authorization refused: "+e, http.StatusForbidden)
+		return
+	}
+
+	scheme, client, err := lookup(p.specID, p.scheme)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	params := url.Values{}
+	params.Set("grant_type", "authorization_code")
+	params.Set("code", query.Get("code"))
+	params.Set("redirect_uri", redirectURI())
+	params.Set("code_verifier", p.verifier)
+
+	t, err := RequestToken(scheme.TokenUrl, client, params)
+	if err != nil {
+		logger.Errorf(req, "OAuth2 token exchange failed: %s", err)
+		http.Error(w, "Token exchange failed", http.StatusBadGateway)
+		return
+	}
+	s.SetValue(tokenKey(p.specID, p.scheme), t)
+
+	http.Redirect(w, req, p.returnTo, http.StatusFound)
+}
+
+// ---------------------------------------------------------------------------
+// token returns the session's token for ?spec=<id>&scheme=<name> as JSON, or 404 if
+// it has none or it has expired.
+func token(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	var t *Token
+	if s := session.Find(req); s != nil {
+		t, _ = s.Value(tokenKey(query.Get("spec"), query.Get("scheme"))).(*Token)
+	}
+	if !t.valid() {
+		http.Error(w, "No token", http.StatusNotFound)
+		return
+	}
+	writeToken(w, t)
+}
+
+// ---------------------------------------------------------------------------
+// acquire gets a token for the client credentials or password flow, with the form
+// values spec, scheme, client_id, client_secret, username, password and scope. The
+// client registration in the configuration is used if no client_id is given, only if it
+// is marked shared, as anyone who can reach the documentation can then get tokens with it.
+func acquire(w http.ResponseWriter, req *http.Request) {
+	specID := req.FormValue("spec")
+	name := req.FormValue("scheme")
+
+	scheme, err := lookupScheme(specID, name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	params := url.Values{}
+	switch scheme.OAuth2Flow {
+	case "application":
+		params.Set("grant_type", "client_credentials")
+	case "password":
+		params.Set("grant_type", "password")
+		params.Set("username", req.FormValue("username"))
+		params.Set("password", req.FormValue("password"))
+	default:
+		http.Error(w, "Security scheme does not use the client credentials or password flow", http.StatusBadRequest)
+		return
+	}
+	if scopes := req.FormValue("scope"); len(scopes) > 0 {
+		params.Set("scope", scopes)
+	} else {
+		params.Set("scope", strings.Join(scopeNames(scheme), " "))
+	}
+
+	client := config.OAuth2Client{ClientID: req.FormValue("client_id"), ClientSecret: req.FormValue("client_secret")}
+	if len(client.ClientID) == 0 {
+		cfg, _ := config.Get()
+		if registered := cfg.Spec(specID).OAuth2[name]; registered.Shared {
+			client = registered
+		}
+	}
+	if len(client.ClientID) == 0 {
+		http.Error(w, "A client ID is required", http.StatusBadRequest)
+		return
+	}
+
+	// Tokens are cached against the credentials, so that changing them gets a new token
+	hash := sha256.Sum256([]byte(strings.Join([]string{client.ClientID, client.ClientSecret, params.Encode()}, "\x00")))
+	credentials := base64.RawURLEncoding.EncodeToString(hash[:])
+
+	s := session.Get(w, req)
+	if t, ok := s.Value(tokenKey(specID, name)).(*Token); ok && t.valid() && t.Credentials == credentials {
+		writeToken(w, t)
+		return
+	}
+
+	t, err := RequestToken(scheme.TokenUrl, client, params)
+	if err != nil {
+		logger.Warnf(req, "OAuth2 token request failed: %s", err)
+		http.Error(w, "Token request failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	t.Credentials = credentials
+	s.SetValue(tokenKey(specID, name), t)
+
+	writeToken(w, t)
+}
+
+// ---------------------------------------------------------------------------
+// valid is true if the token is set and has not expired. Tokens that are about to
+// expire are treated as expired, so that they do not expire in flight.
+func (t *Token) valid() bool {
+	return t != nil && (t.Expires.IsZero() || time.Now().Add(10*time.Second).Before(t.Expires))
+}
+
+// ---------------------------------------------------------------------------
+
+func writeToken(w http.ResponseWriter, t *Token) {
+	response := *t
+	if !t.Expires.IsZero() {
+		response.ExpiresIn = int(time.Until(t.Expires).Seconds())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ---------------------------------------------------------------------------
+// RequestToken posts a token request to the token endpoint, authenticating as the
+// client with HTTP basic authentication if it has a secret.
+func RequestToken(tokenURL string, client config.OAuth2Client, params url.Values) (*Token, error) {
+	if len(client.ClientSecret) == 0 {
+		params.Set("client_id", client.ClientID)
+	}
+
+	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if len(client.ClientSecret) > 0 {
+		req.SetBasicAuth(url.QueryEscape(client.ClientID), url.QueryEscape(client.ClientSecret))
+	}
+
+	httpClient := &http.Client{Transport: network.Transport(""), Timeout: 30 * time.Second}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s: %s", tokenURL, resp.Status, body)
+	}
+
+	var t Token
+	if err = json.Unmarshal(body, &t); err != nil {
+		return nil, fmt.Errorf("%s returned an invalid token response: %s", tokenURL, err)
+	}
+	if len(t.AccessToken) == 0 {
+		return nil, fmt.Errorf("%s returned no access token", tokenURL)
+	}
+	if t.ExpiresIn > 0 {
+		t.Expires = time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+	}
+	return &t, nil
+}
+
+// ---------------------------------------------------------------------------
+// lookup finds an OAuth2 security scheme and the explorer's client registration for it.
+func lookup(specID string, name string) (*spec.SecurityScheme, config.OAuth2Client, error) {
+	var client config.OAuth2Client
+
+	scheme, err := lookupScheme(specID, name)
+	if err != nil {
+		return nil, client, err
+	}
+
+	cfg, _ := config.Get()
+	if client = cfg.Spec(specID).OAuth2[name]; len(client.ClientID) == 0 {
+		return nil, client, fmt.Errorf("no client-id is configured for OAuth2 security scheme '%s' of specification '%s'", name, specID)
+	}
+	return scheme, client, nil
+}
+
+// ---------------------------------------------------------------------------
+
+func lookupScheme(specID string, name string) (*spec.SecurityScheme, error) {
+	specification, ok := spec.APISuite[specID]
+	if !ok {
+		return nil, fmt.Errorf("unknown specification '%s'", specID)
+	}
+	scheme, ok := specification.SecurityDefinitions[name]
+	if !ok || !scheme.IsOAuth2 {
+		return nil, fmt.Errorf("specification '%s' has no OAuth2 security scheme '%s'", specID, name)
+	}
+	if len(scheme.TokenUrl) == 0 {
+		return nil, fmt.Errorf("OAuth2 security scheme '%s' of specification '%s' has no tokenUrl", name, specID)
+	}
+	return &scheme, nil
+}
+
+// ---------------------------------------------------------------------------
+
+func tokenKey(specID string, scheme string) string {
+	return "oauth-token:" + specID + "/" + scheme
+}
+
+// ---------------------------------------------------------------------------
+// redirectURI is the callback URI, which must be registered with the authorization server.
+func redirectURI() string {
+	cfg, _ := config.Get()
+	return strings.TrimSuffix(cfg.SiteURL, "/") + CallbackPath
+}
+
+// ---------------------------------------------------------------------------
+// localPath only allows a return to a page of this site, so that the callback can
+// not be used to redirect elsewhere.
+func localPath(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.Contains(path, "\\") {
+		return "/"
+	}
+	return path
+}
+
+// ---------------------------------------------------------------------------
+
+func scopeNames(scheme *spec.SecurityScheme) []string {
+	var names []string
+	for name := range scheme.Scopes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,164 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/handlers/feedback"
+	"github.com/dapperdox/dapperdox/handlers/mock"
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/network"
+	"github.com/dapperdox/dapperdox/proxy"
+	"github.com/dapperdox/dapperdox/render"
+)
+
+// Each client IP address has a bucket of burst tokens, refilled at rate-limit tokens
+// a minute. A request to an expensive endpoint takes a token, and is refused with
+// 429 Too Many Requests when the bucket is empty.
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type limiter struct {
+	sync.Mutex
+	buckets map[string]*bucket
+	pruned  time.Time
+}
+
+// ---------------------------------------------------------------------------
+// Handler wraps a http.Handler and rate limits requests to the explorer proxy, the
+// proxied paths, the mock server, search indexes, schema exports, printable pages and
+// feedback, as configured. The configuration is read for each request, so that a
+// reload changes the limits.
+func Handler(h http.Handler) http.Handler {
+	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+
+	if cfg.RateLimit > 0 {
+		logger.Infof(nil, "Rate limiting expensive requests to %d a minute for each client, in bursts of up to %d", cfg.RateLimit, burst(cfg.RateLimit, cfg.RateLimitBurst))
+	}
+
+	l := &limiter{buckets: make(map[string]*bucket)}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		cfg, _ := config.Get()
+
+		if cfg.RateLimit <= 0 {
+			h.ServeHTTP(w, req)
+			return
+		}
+
+		prefixes := []string{proxy.TryItPath, mock.PathPrefix + "/"}
+		for _, p := range cfg.ProxyPath {
+			prefixes = append(prefixes, strings.SplitN(p, "=", 2)[0])
+		}
+		prefixes = append(prefixes, cfg.RateLimitPath...)
+
+		if !expensive(req, prefixes) {
+			h.ServeHTTP(w, req)
+			return
+		}
+
+		client := network.ClientIP(req)
+		rate := float64(cfg.RateLimit) / 60 // tokens a second
+		if wait := l.take(client, rate, float64(burst(cfg.RateLimit, cfg.RateLimitBurst)), time.Now()); wait > 0 {
+			logger.Warnf(req, "rate limit exceeded by %s for %s", client, req.URL.Path)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			render.LockedError(w, req, http.StatusTooManyRequests, "429", "Too many requests")
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+// burst is the size of a client's bucket, which defaults to a minute's worth of tokens
+func burst(rateLimit, rateLimitBurst int) int {
+	if rateLimitBurst <= 0 {
+		return rateLimit
+	}
+	return rateLimitBurst
+}
+
+// ---------------------------------------------------------------------------
+// take takes a token from the client's bucket, returning zero if it could, or how
+// long the client must wait for one if not.
+func (l *limiter) take(client string, rate, burst float64, now time.Time) time.Duration {
+	l.Lock()
+	defer l.Unlock()
+
+	l.prune(rate, burst, now)
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[client] = b
+	}
+
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+	b.tokens--
+	return 0
+}
+
+// prune forgets, once a minute, the buckets that have since filled up, so that the
+// limiter does not grow with every client ever seen.
+func (l *limiter) prune(rate, burst float64, now time.Time) {
+	if now.Sub(l.pruned) < time.Minute {
+		return
+	}
+	l.pruned = now
+
+	for client, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*rate >= burst {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+
+func expensive(req *http.Request, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(req.URL.Path, prefix) {
+			return true
+		}
+	}
+	if req.Method == "POST" && req.URL.Path == feedback.Path {
+		return true
+	}
+	for _, suffix := range []string{"/print", "/search.json", "/schema.json"} {
+		if strings.HasSuffix(req.URL.Path, suffix) {
+			return true
+		}
+	}
+	return len(req.URL.Query().Get("print")) > 0
+}
+
+// ---------------------------------------------------------------------------
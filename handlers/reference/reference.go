@@ -18,9 +18,13 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>.
 package reference
 
 import (
+	"encoding/json"
 	"net/http"
+	"strings"
 
 	//"github.com/davecgh/go-spew/spew"
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/i18n"
 	"github.com/dapperdox/dapperdox/logger"
 	"github.com/dapperdox/dapperdox/render"
 	"github.com/dapperdox/dapperdox/spec"
@@ -32,82 +36,221 @@ type versionedResource map[string]*spec.Resource // key is version
 
 var pathVersionMethod map[string]versionedMethod     // Key is path
 var pathVersionResource map[string]versionedResource // Key is path
+var pathVersionAPI map[string]map[string]bool        // Key is path, then version
 
-// Register creates routes for specification resource
+// deprecation is the banner on the pages of a deprecated version
+type deprecation struct {
+	*spec.VersionDeprecation
+	ReplacementURL string // The equivalent page in the replacement version
+}
+
+// printAPI is an API group and its methods in the version being printed
+type printAPI struct {
+	API     spec.APIGroup
+	Methods []spec.Method
+}
+
+// versionLink is the equivalent of a page in one version of the specification
+type versionLink struct {
+	Version string
+	URL     string
+	Current bool // The version of the page being shown
+}
+
+// Register prepares the reference documentation, whose routes RegisterSpecification
+// creates for each specification
 func Register(r *pat.Router) {
 	logger.Infof(nil, "Registering reference documentation")
 
 	pathVersionMethod = make(map[string]versionedMethod)
 	pathVersionResource = make(map[string]versionedResource)
+	pathVersionAPI = make(map[string]map[string]bool)
+}
 
-	// Loop for all APISpecification's in the APISuite
-	for _, specification := range spec.APISuite {
+// RegisterSpecification creates the reference routes of a specification, replacing the
+// pages of any specification that was registered with its ID before.
+func RegisterSpecification(r *pat.Router, specification *spec.APISpecification) {
+	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
 
-		spec_id := "/" + specification.ID
+	spec_id := "/" + specification.ID
+	forget(spec_id)
 
-		logger.Debugf(nil, "Registering reference for OpenAPI specification '%s'", specification.APIInfo.Title)
+	logger.Debugf(nil, "Registering reference for OpenAPI specification '%s'", specification.APIInfo.Title)
 
-		for _, api := range specification.APIs {
-			logger.Debugf(nil, "  - Scanning API [%s] %s", api.ID, api.Name)
-			r.Path(spec_id + "/reference/" + api.ID).Methods("GET").HandlerFunc(APIHandler(specification, api))
+	// Versioned specifications also serve the newest version of each page below /latest
+	versioned := false
+	for _, version := range specification.OrderedVersions {
+		versioned = versioned || version != "latest"
+	}
 
-			version := api.CurrentVersion
+	for _, api := range specification.APIs {
+		logger.Debugf(nil, "  - Scanning API [%s] %s", api.ID, api.Name)
+		apiPath := spec_id + "/reference/" + api.ID
+		r.Path(apiPath).Methods("GET").HandlerFunc(APIHandler(specification, api, ""))
+		if versioned {
+			r.Path(spec_id + "/latest/reference/" + api.ID).Methods("GET").HandlerFunc(APIHandler(specification, api, "latest"))
+		}
+		r.Path(apiPath + "/navigation.json").Methods("GET").HandlerFunc(NavigationHandler(spec_id+"/reference/"+api.ID, api.Methods))
+		pathVersionAPI[apiPath] = make(map[string]bool)
 
-			for _, method := range api.Methods {
-				basepath := spec_id + "/reference/" + api.ID
-				path := basepath + "/" + method.ID
+		for version, methods := range api.Versions {
+			pathVersionAPI[apiPath][version] = true
 
-				logger.Debugf(nil, "    + method %s [%s]", path, method.Name)
+			// Each version is also served below its own path, such as /spec/v2/reference/api
+			if version != "latest" {
+				r.Path(spec_id + "/" + version + "/reference/" + api.ID).Methods("GET").HandlerFunc(APIHandler(specification, api, version))
+				r.Path(spec_id + "/" + version + "/reference/" + api.ID + "/navigation.json").Methods("GET").HandlerFunc(NavigationHandler(spec_id+"/"+version+"/reference/"+api.ID, methods))
+			}
+			for _, method := range methods {
+				path := spec_id + "/reference/" + api.ID + "/" + method.ID
+				logger.Debugf(nil, "    + method %s [%s] version %s", path, method.Name, version)
 
 				// Add version->method to pathVersionMethod
 				if _, ok := pathVersionMethod[path]; !ok {
 					pathVersionMethod[path] = make(versionedMethod)
-					r.Path(path).Methods("GET").HandlerFunc(MethodHandler(specification, api, path))
-				}
-				pathVersionMethod[path][version] = method
-			}
-			for version, methods := range api.Versions {
-				for _, method := range methods {
-					logger.Debugf(nil, "    + %s %s", method.ID, method.Name)
-					path := spec_id + "/reference/" + api.ID + "/" + method.ID
-					// Add version->resource to pathVersionResource
-					if _, ok := pathVersionMethod[path]; !ok {
-						pathVersionMethod[path] = make(versionedMethod)
-						r.Path(path).Methods("GET").HandlerFunc(MethodHandler(specification, api, path))
+					r.Path(path).Methods("GET").HandlerFunc(MethodHandler(specification, api, path, ""))
+					if versioned {
+						r.Path(spec_id + "/latest/reference/" + api.ID + "/" + method.ID).Methods("GET").HandlerFunc(MethodHandler(specification, api, path, "latest"))
 					}
-					pathVersionMethod[path][version] = method
 				}
+				if version != "latest" {
+					r.Path(spec_id + "/" + version + "/reference/" + api.ID + "/" + method.ID).Methods("GET").HandlerFunc(MethodHandler(specification, api, path, version))
+				}
+				pathVersionMethod[path][version] = method
 			}
 		}
+	}
 
-		logger.Debugf(nil, "  - Registering resources")
-		for version, resources := range specification.ResourceList {
-			logger.Debugf(nil, "    - Version %s", version)
-			for id, resource := range resources {
-				path := spec_id + "/resources/" + id
-				logger.Debugf(nil, "      + resource %s", id)
-				if _, ok := pathVersionResource[path]; !ok {
-					pathVersionResource[path] = make(versionedResource)
-					r.Path(path).Methods("GET").HandlerFunc(GlobalResourceHandler(specification, path))
+	if len(specification.Errors) > 0 {
+		r.Path(spec_id + "/errors").Methods("GET").HandlerFunc(ErrorsHandler(specification))
+	}
+	if len(specification.Models) > 0 {
+		r.Path(spec_id + "/models").Methods("GET").HandlerFunc(ModelsHandler(specification))
+	}
+	if specification.RateLimited() {
+		r.Path(spec_id + "/rate-limits").Methods("GET").HandlerFunc(RateLimitsHandler(specification))
+	}
+	r.Path(spec_id + "/print").Methods("GET").HandlerFunc(PrintHandler(specification))
+	r.Path(spec_id + "/search.json").Methods("GET").HandlerFunc(SearchHandler(specification))
+	if cfg.SpecStats {
+		r.Path(spec_id + "/stats").Methods("GET").HandlerFunc(StatsHandler(specification))
+		r.Path(spec_id + "/stats.json").Methods("GET").HandlerFunc(StatsJSONHandler(specification))
+	}
+
+	logger.Debugf(nil, "  - Registering resources")
+	for version, resources := range specification.ResourceList {
+		logger.Debugf(nil, "    - Version %s", version)
+		for id, resource := range resources {
+			path := spec_id + "/resources/" + id
+			logger.Debugf(nil, "      + resource %s", id)
+			if _, ok := pathVersionResource[path]; !ok {
+				pathVersionResource[path] = make(versionedResource)
+				r.Path(path).Methods("GET").HandlerFunc(GlobalResourceHandler(specification, path, ""))
+				r.Path(path + "/schema.json").Methods("GET").HandlerFunc(ResourceSchemaHandler(specification, path, ""))
+				if versioned {
+					r.Path(spec_id + "/latest/resources/" + id).Methods("GET").HandlerFunc(GlobalResourceHandler(specification, path, "latest"))
+					r.Path(spec_id + "/latest/resources/" + id + "/schema.json").Methods("GET").HandlerFunc(ResourceSchemaHandler(specification, path, "latest"))
 				}
-				pathVersionResource[path][version] = resource
 			}
+			if version != "latest" {
+				r.Path(spec_id + "/" + version + "/resources/" + id).Methods("GET").HandlerFunc(GlobalResourceHandler(specification, path, version))
+				r.Path(spec_id + "/" + version + "/resources/" + id + "/schema.json").Methods("GET").HandlerFunc(ResourceSchemaHandler(specification, path, version))
+			}
+			pathVersionResource[path][version] = resource
 		}
 	}
 	logger.Debugf(nil, "\n")
 }
 
 // ------------------------------------------------------------------------------------------------------------
+// forget drops the pages below a path from those registered, such as those of a
+// specification that is registered again as it has been reloaded.
+func forget(prefix string) {
+	for path := range pathVersionMethod {
+		if strings.HasPrefix(path, prefix+"/") {
+			delete(pathVersionMethod, path)
+		}
+	}
+	for path := range pathVersionResource {
+		if strings.HasPrefix(path, prefix+"/") {
+			delete(pathVersionResource, path)
+		}
+	}
+	for path := range pathVersionAPI {
+		if strings.HasPrefix(path, prefix+"/") {
+			delete(pathVersionAPI, path)
+		}
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------------
+
+// requestedVersion returns the version given by the page's path, or else by its v
+// query parameter, or "" if none was requested.
+func requestedVersion(req *http.Request, pathVersion string) string {
+	if len(pathVersion) > 0 {
+		return pathVersion
+	}
+	return req.FormValue("v")
+}
+
+// ------------------------------------------------------------------------------------------------------------
+// versionLinks lists every version of a versioned specification, newest first, each
+// linking to the equivalent of the page in that version. page is the path of the page
+// below the specification, such as /reference/api/method.
+func versionLinks(specification *spec.APISpecification, version string, page string) []versionLink {
+	if len(specification.OrderedVersions) < 2 {
+		return nil
+	}
+	var links []versionLink
+	for _, v := range specification.OrderedVersions {
+		links = append(links, versionLink{Version: v, URL: equivalentPage(specification, v, page), Current: v == version})
+	}
+	return links
+}
 
-func getVersionMethod(api spec.APIGroup, version string) []spec.Method {
+// equivalentPage returns the URL of a page in another version: the same page if the
+// version has it, or else the nearest page above it that it does, such as the API
+// summary for a method that is not in the version.
+func equivalentPage(specification *spec.APISpecification, version string, page string) string {
+	spec_id := "/" + specification.ID
 
-	var methods []spec.Method
-	var ok bool
+	prefix := spec_id + "/" + version
+	if version == "latest" {
+		prefix = spec_id
+	}
+	for p := page; len(p) > 0; p = p[:strings.LastIndex(p, "/")] {
+		path := spec_id + p
+		if _, ok := pathVersionMethod[path][version]; ok {
+			return prefix + p
+		}
+		if _, ok := pathVersionResource[path][version]; ok {
+			return prefix + p
+		}
+		if pathVersionAPI[path][version] {
+			return prefix + p
+		}
+	}
+	return spec_id + "/reference"
+}
 
-	if methods, ok = api.Versions[version]; !ok {
-		methods = api.Methods
+// versionDeprecation returns the banner for a page of a deprecated version, or nil
+func versionDeprecation(specification *spec.APISpecification, version string, page string) *deprecation {
+	d, ok := specification.Deprecations[version]
+	if !ok {
+		return nil
+	}
+	banner := &deprecation{VersionDeprecation: d}
+	if len(d.Replacement) > 0 {
+		banner.ReplacementURL = equivalentPage(specification, d.Replacement, page)
 	}
-	return methods[0:]
+	return banner
+}
+
+// newestVersion returns the newest of the versions that a page is in
+func newestVersion(versions []string) string {
+	spec.SortVersions(versions)
+	return versions[0]
 }
 
 // ------------------------------------------------------------------------------------------------------------
@@ -123,6 +266,7 @@ func getMethodVersions(api spec.APIGroup, versions versionedMethod) []string {
 		keys[ix] = key
 		ix++
 	}
+	spec.SortVersions(keys)
 	return keys
 }
 
@@ -139,6 +283,7 @@ func getAPIVersions(api spec.APIGroup) []string {
 		keys[ix] = key
 		ix++
 	}
+	spec.SortVersions(keys)
 	return keys
 }
 
@@ -155,20 +300,32 @@ func getResourceVersions(api spec.APIGroup, versions versionedResource) []string
 		keys[ix] = key
 		ix++
 	}
+	spec.SortVersions(keys)
 	return keys
 }
 
 // ------------------------------------------------------------------------------------------------------------
 // APIHandler is a http.Handler for rendering API reference docs
-func APIHandler(specification *spec.APISpecification, api spec.APIGroup) func(w http.ResponseWriter, req *http.Request) {
+func APIHandler(specification *spec.APISpecification, api spec.APIGroup, pathVersion string) func(w http.ResponseWriter, req *http.Request) {
 	return func(w http.ResponseWriter, req *http.Request) {
 
-		version := req.FormValue("v") // Get the resource version
+		version := requestedVersion(req, pathVersion)
 		if version == "" {
+			version = specification.Version(api)
+		}
+		if _, ok := api.Versions[version]; !ok && version == "latest" {
 			version = api.CurrentVersion
 		}
+		methods, ok := api.Versions[version]
+		if !ok {
+			render.NotFound(w, req)
+			return
+		}
+		if len(req.FormValue("print")) > 0 {
+			renderPrint(w, req, specification, render.Vars{"Title": api.Name, "PrintAPIs": []printAPI{{API: api, Methods: methods}}, "Version": version})
+			return
+		}
 		versions := getAPIVersions(api)
-		methods := getVersionMethod(api, version)
 
 		tmpl := "api"
 		customTmpl := "reference/" + api.ID
@@ -177,22 +334,39 @@ func APIHandler(specification *spec.APISpecification, api spec.APIGroup) func(w
 		}
 
 		logger.Tracef(nil, "-- template: %s  Version %s", tmpl, version)
+		page := "/reference/" + api.ID // The page, below the specification, in any version
 
-		render.HTML(w, http.StatusOK, tmpl, render.DefaultVars(req, specification, render.Vars{"Title": api.Name, "API": api, "Methods": methods, "Version": version, "Versions": versions, "LatestVersion": api.CurrentVersion}))
+		render.StreamHTML(w, http.StatusOK, tmpl, render.DefaultVars(req, specification, render.Vars{"Title": api.Name, "API": api, "Methods": methods, "Version": version, "Versions": versions, "LatestVersion": api.CurrentVersion, "VersionLinks": versionLinks(specification, version, page), "VersionPage": page, "VersionDeprecation": versionDeprecation(specification, version, page)}))
 	}
 }
 
 // ------------------------------------------------------------------------------------------------------------
 // MethodHandler is a http.Handler for rendering API method reference docs
-func MethodHandler(specification *spec.APISpecification, api spec.APIGroup, path string) func(w http.ResponseWriter, req *http.Request) {
+func MethodHandler(specification *spec.APISpecification, api spec.APIGroup, path string, pathVersion string) func(w http.ResponseWriter, req *http.Request) {
 	return func(w http.ResponseWriter, req *http.Request) {
 
-		version := req.FormValue("v") // Get the resource version
+		var methodVersions []string
+		for key := range pathVersionMethod[path] {
+			methodVersions = append(methodVersions, key)
+		}
+
+		version := requestedVersion(req, pathVersion)
 		if version == "" {
-			version = api.CurrentVersion
+			// The default version, or else the newest that has the method
+			version = specification.Version(api)
+			if _, ok := pathVersionMethod[path][version]; !ok {
+				version = newestVersion(methodVersions)
+			}
+		}
+		if _, ok := pathVersionMethod[path][version]; !ok && version == "latest" {
+			version = newestVersion(methodVersions)
+		}
+		method, ok := pathVersionMethod[path][version]
+		if !ok {
+			render.NotFound(w, req)
+			return
 		}
 		versions := getMethodVersions(api, pathVersionMethod[path])
-		method := pathVersionMethod[path][version]
 
 		tmpl := "method"
 		customTmpl := "reference/" + api.ID + "/" + method.ID
@@ -202,43 +376,30 @@ func MethodHandler(specification *spec.APISpecification, api spec.APIGroup, path
 
 		logger.Tracef(nil, "-- template: %s  Version %s", tmpl, version)
 
-		// TODO default to latest if version not found, or 404 ?
-		method = pathVersionMethod[path][version]
-
 		//logger.Debugf(nil, "Method versions:\n")
 		//spew.Dump(versions)
+		page := "/reference/" + api.ID + "/" + method.ID
 
-		render.HTML(w, http.StatusOK, tmpl, render.DefaultVars(req, specification, render.Vars{"Title": method.Name, "API": api, "Method": method, "Version": version, "Versions": versions, "LatestVersion": api.CurrentVersion}))
+		vars := render.DefaultVars(req, specification, render.Vars{"Title": method.Name, "API": api, "Method": method, "Version": version, "Versions": versions, "LatestVersion": api.CurrentVersion, "VersionLinks": versionLinks(specification, version, page), "VersionPage": page, "VersionDeprecation": versionDeprecation(specification, version, page)})
+		render.HTML(w, http.StatusOK, tmpl, vars, embedLayout(w, req, vars)...)
 	}
 }
 
 // ------------------------------------------------------------------------------------------------------------
 // ResourceHandler is a http.Handler for rendering API resource reference docs
-func GlobalResourceHandler(specification *spec.APISpecification, path string) func(w http.ResponseWriter, req *http.Request) {
+func GlobalResourceHandler(specification *spec.APISpecification, path string, pathVersion string) func(w http.ResponseWriter, req *http.Request) {
 	return func(w http.ResponseWriter, req *http.Request) {
 
-		version := req.FormValue("v") // Get the resource version - blank is the latest
-		if version == "" {
-			version = "latest"
+		resource, version, versions := findResource(specification, req, path, pathVersion)
+		if resource == nil {
+			render.NotFound(w, req)
+			return
 		}
-
-		// Get list of versions
-		var versions []string
-		ix := 0
-		versionList := pathVersionResource[path]
-
-		if len(versionList) > 1 {
-			// There is more than one version (there is always a "latest"), so
-			// compile list of those available for resource
-			versions = make([]string, len(pathVersionResource[path]))
-			for key := range versionList {
-				versions[ix] = key
-				ix++
-			}
+		latest := versions[0]
+		if len(versions) < 2 {
+			versions = nil // Version selection is not required
 		}
 
-		resource := pathVersionResource[path][version]
-
 		logger.Debugf(nil, "Render resource "+resource.ID)
 		tmpl := "resource"
 
@@ -249,10 +410,204 @@ func GlobalResourceHandler(specification *spec.APISpecification, path string) fu
 		}
 
 		logger.Tracef(nil, "-- template: %s  Version %s", tmpl, version)
+		page := "/resources/" + resource.ID
+
+		vars := render.DefaultVars(req, specification, render.Vars{"Title": resource.Title, "Resource": resource, "Version": version, "Versions": versions, "LatestVersion": latest, "VersionLinks": versionLinks(specification, version, page), "VersionPage": page, "VersionDeprecation": versionDeprecation(specification, version, page)})
+		render.HTML(w, http.StatusOK, tmpl, vars, embedLayout(w, req, vars)...)
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------------
+// embedLayout renders a method or resource page without navigation or other chrome, for
+// embedding in another site's page, if it was requested with the embed parameter. The
+// page reports its height to the embedding page, so that its iframe can be sized to fit.
+func embedLayout(w http.ResponseWriter, req *http.Request, vars map[string]interface{}) []render.HTMLOptions {
+	if len(req.FormValue("embed")) == 0 {
+		return nil
+	}
+	vars["Embed"] = true
+
+	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+	if len(cfg.EmbedAncestors) > 0 {
+		w.Header().Set("Content-Security-Policy", "frame-ancestors "+strings.Join(cfg.EmbedAncestors, " "))
+	}
+	return []render.HTMLOptions{{Layout: "embed_layout"}}
+}
+
+// ------------------------------------------------------------------------------------------------------------
+// ResourceSchemaHandler is a http.Handler that serves the resolved JSON Schema of a resource, for
+// validators and code generators
+func ResourceSchemaHandler(specification *spec.APISpecification, path string, pathVersion string) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		resource, _, _ := findResource(specification, req, path, pathVersion)
+		if resource == nil {
+			render.NotFound(w, req)
+			return
+		}
+		schema, err := specification.ResourceSchema(resource)
+		if err != nil {
+			logger.Errorf(req, "Error encoding the schema of resource %s: %s", resource.ID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if schema == nil {
+			render.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "application/schema+json")
+		w.Write(schema)
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------------
+// findResource returns the version of the resource at path that is requested, with the versions
+// that have it, newest first. The resource is nil if the requested version does not have it.
+func findResource(specification *spec.APISpecification, req *http.Request, path string, pathVersion string) (*spec.Resource, string, []string) {
+	versionList := pathVersionResource[path]
+	versions := make([]string, 0, len(versionList))
+	for key := range versionList {
+		versions = append(versions, key)
+	}
+	spec.SortVersions(versions)
+
+	version := requestedVersion(req, pathVersion)
+	if version == "" {
+		// The default version, or else the newest that has the resource
+		version = versions[0]
+		if _, ok := versionList[specification.DefaultVersion]; ok {
+			version = specification.DefaultVersion
+		}
+	}
+	if _, ok := versionList[version]; !ok && version == "latest" {
+		version = versions[0]
+	}
+	return versionList[version], version, versions
+}
+
+// ------------------------------------------------------------------------------------------------------------
+// RateLimitsHandler is a http.Handler for the page that explains a specification's rate limits:
+// those of the specification, then the methods with their own.
+func RateLimitsHandler(specification *spec.APISpecification) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var methods []spec.Method
+		for _, api := range specification.APIs {
+			for _, method := range getVersionMethods(specification, api) {
+				if method.OwnRateLimits {
+					methods = append(methods, method)
+				}
+			}
+		}
+		render.HTML(w, http.StatusOK, "rate_limits", render.DefaultVars(req, specification, render.Vars{"Title": i18n.Translate(i18n.Locale(req), "Rate limits"), "RateLimits": specification.RateLimits, "Methods": methods}))
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------------
+// ErrorsHandler is a http.Handler for the page that lists the error resources shared by many methods
+func ErrorsHandler(specification *spec.APISpecification) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		render.HTML(w, http.StatusOK, "error_catalogue", render.DefaultVars(req, specification, render.Vars{"Title": i18n.Translate(i18n.Locale(req), "Errors"), "Errors": specification.Errors}))
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------------
+// ModelsHandler is a http.Handler for the page that lists every data model of a specification
+func ModelsHandler(specification *spec.APISpecification) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		render.StreamHTML(w, http.StatusOK, "models", render.DefaultVars(req, specification, render.Vars{"Title": i18n.Translate(i18n.Locale(req), "Data models"), "Models": specification.Models}))
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------------
+// StatsHandler is a http.Handler for the page that measures how completely a specification is documented
+func StatsHandler(specification *spec.APISpecification) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		render.HTML(w, http.StatusOK, "stats", render.DefaultVars(req, specification, render.Vars{"Title": i18n.Translate(i18n.Locale(req), "Documentation statistics"), "Stats": specification.Stats()}))
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------------
+// StatsJSONHandler is a http.Handler for the statistics of a specification as JSON, for tracking over time
+func StatsJSONHandler(specification *spec.APISpecification) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(specification.Stats())
+	}
+}
 
-		render.HTML(w, http.StatusOK, tmpl, render.DefaultVars(req, specification, render.Vars{"Title": resource.Title, "Resource": resource, "Version": version, "Versions": versions}))
+// ------------------------------------------------------------------------------------------------------------
+// SearchHandler is a http.Handler for the search index of a specification, which the theme's
+// search box fetches
+func SearchHandler(specification *spec.APISpecification) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(specification.SearchJSON())
 	}
 }
 
+// ------------------------------------------------------------------------------------------------------------
+// navigationMethod is a method as the navigation lists it
+type navigationMethod struct {
+	Name string `json:"name"`
+	Href string `json:"href"`
+}
+
+// NavigationHandler is a http.Handler for the navigation of the methods of an API group
+// as JSON, which themes fetch when the navigation is segmented
+func NavigationHandler(apiPath string, methods []spec.Method) func(w http.ResponseWriter, req *http.Request) {
+	list := make([]navigationMethod, 0, len(methods))
+	for _, method := range methods {
+		list = append(list, navigationMethod{Name: method.NavigationName, Href: apiPath + "/" + method.ID})
+	}
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------------
+// PrintHandler renders the whole of a specification as a single document, without
+// navigation, for printing or archiving. A version may be chosen with the v parameter.
+func PrintHandler(specification *spec.APISpecification) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		version := req.FormValue("v")
+
+		var apis []printAPI
+		for _, api := range specification.APIs {
+			methods, ok := api.Versions[version]
+			if len(version) == 0 {
+				methods, ok = getVersionMethods(specification, api), true
+			}
+			if ok && len(methods) > 0 {
+				apis = append(apis, printAPI{API: api, Methods: methods})
+			}
+		}
+		if len(apis) == 0 {
+			render.NotFound(w, req)
+			return
+		}
+
+		// The resources of the version, or of the version shown when none is requested
+		var resources []*spec.Resource
+		for i, r := range specification.NavigationResources {
+			if r.Version == version || (len(version) == 0 && (i == 0 || r.Version == specification.DefaultVersion)) {
+				resources = r.Resources
+			}
+		}
+
+		title := i18n.Translate(i18n.Locale(req), "%s reference", specification.APIInfo.Title)
+		renderPrint(w, req, specification, render.Vars{"Title": title, "PrintAPIs": apis, "PrintResources": resources, "PrintSpecification": true, "Version": version})
+	}
+}
+
+// renderPrint renders PrintAPIs, and any PrintResources, in the print layout
+func renderPrint(w http.ResponseWriter, req *http.Request, specification *spec.APISpecification, vars render.Vars) {
+	render.StreamHTML(w, http.StatusOK, "print", render.DefaultVars(req, specification, vars), render.HTMLOptions{Layout: "print_layout"})
+}
+
+// getVersionMethods returns the methods of the version of an API shown when none is requested
+func getVersionMethods(specification *spec.APISpecification, api spec.APIGroup) []spec.Method {
+	return api.Versions[specification.Version(api)]
+}
+
 // ------------------------------------------------------------------------------------------------------------
 // end
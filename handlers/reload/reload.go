@@ -0,0 +1,70 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package reload
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/spec"
+	"github.com/gorilla/pat"
+)
+
+// Path is the endpoint that reloads the site when POSTed to, or one specification when
+// its ID follows, such as /reload/petstore
+const Path = "/reload"
+
+// Register mounts the reload endpoint, if a reload-token is configured. Calls must give
+// the token as a bearer token. The reload happens in the background, as it replaces the
+// router that is serving the request, so its outcome is logged rather than returned.
+func Register(r *pat.Router, reloadSite func() error, reloadSpecification func(id string) error) {
+	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+
+	if len(cfg.ReloadToken) == 0 {
+		return
+	}
+
+	logger.Infof(nil, "Registering reload endpoint at %s", Path)
+
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(cfg.ReloadToken)) != 1 {
+			logger.Warnf(req, "Unauthorised reload request")
+			http.Error(w, "Unauthorised", http.StatusUnauthorized)
+			return
+		}
+
+		id := strings.TrimPrefix(strings.TrimPrefix(req.URL.Path, Path), "/")
+		switch {
+		case len(id) == 0:
+			go reloadSite()
+		case spec.APISuite[id] != nil:
+			go reloadSpecification(id)
+		default:
+			http.Error(w, "No specification "+id+" is loaded", http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+	r.Path(Path).Methods("POST").HandlerFunc(handler)
+	r.Path(Path + "/{id}").Methods("POST").HandlerFunc(handler)
+}
@@ -92,7 +92,7 @@ func Register(r *pat.Router) {
 		ext := filepath.Ext(path)
 
 		switch ext {
-		case ".json", ".yaml":
+		case ".json", ".yaml", ".raml", ".apib", ".pb", ".binpb", ".protoset":
 			// Strip base path and file extension
 			route := strings.TrimPrefix(path, base)
 
@@ -101,8 +101,10 @@ func Register(r *pat.Router) {
 
 			specMap[route], _ = ioutil.ReadFile(path)
 
-			// Replace URLs in document
-			specMap[route] = []byte(specReplacer.Replace(string(specMap[route])))
+			// Replace URLs in document. Protobuf descriptor sets are binary, and served as they are.
+			if ext == ".json" || ext == ".yaml" || ext == ".raml" || ext == ".apib" {
+				specMap[route] = []byte(specReplacer.Replace(string(specMap[route])))
+			}
 
 			r.Path(route).Methods("GET").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 				serveSpec(w, route)
@@ -115,7 +117,16 @@ func Register(r *pat.Router) {
 
 func serveSpec(w http.ResponseWriter, resource string) {
 	logger.Tracef(nil, "Serve file "+resource)
-	w.Header().Set("Content-Type", "application/json")
+	switch filepath.Ext(resource) {
+	case ".json", ".yaml":
+		w.Header().Set("Content-Type", "application/json")
+	case ".raml":
+		w.Header().Set("Content-Type", "application/raml+yaml")
+	case ".apib":
+		w.Header().Set("Content-Type", "text/vnd.apiblueprint")
+	default:
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
 	w.Header().Set("Cache-control", "public, max-age=259200")
 	w.WriteHeader(200)
 	w.Write(specMap[resource])
@@ -24,6 +24,7 @@ import (
 	"strings"
 
 	//"github.com/dapperdox/dapperdox/assets"
+	"github.com/dapperdox/dapperdox/config"
 	"github.com/dapperdox/dapperdox/logger"
 	"github.com/dapperdox/dapperdox/render"
 	"github.com/dapperdox/dapperdox/render/asset"
@@ -35,7 +36,10 @@ func Register(r *pat.Router) {
 	logger.Debugln(nil, "registering not found handler in static package")
 
 	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		render.HTML(w, http.StatusNotFound, "error", render.DefaultVars(req, nil, map[string]interface{}{"error": "Page not found", "code": 404}))
+		if redirect(w, req) {
+			return
+		}
+		render.NotFound(w, req)
 	})
 
 	logger.Debugln(nil, "registering static content handlers for static package")
@@ -81,3 +85,31 @@ func Register(r *pat.Router) {
 		}
 	}
 }
+
+// ---------------------------------------------------------------------------
+// redirect applies the first configured redirect rule that matches the request,
+// returning false if there is none. Rules are only consulted for paths that would
+// otherwise be not found, so they can never hide a page that exists.
+func redirect(w http.ResponseWriter, req *http.Request) bool {
+	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+	path := req.URL.Path
+
+	for _, rule := range cfg.Redirects() {
+		to := rule.To
+		if prefix := strings.TrimSuffix(rule.From, "*"); prefix != rule.From {
+			if !strings.HasPrefix(path, prefix) {
+				continue
+			}
+			to = strings.TrimSuffix(to, "/") + "/" + strings.TrimPrefix(path, prefix)
+		} else if strings.TrimSuffix(path, "/") != strings.TrimSuffix(rule.From, "/") {
+			continue
+		}
+		if len(req.URL.RawQuery) > 0 && !strings.Contains(to, "?") {
+			to += "?" + req.URL.RawQuery
+		}
+		logger.Debugf(nil, "Redirecting %s to %s (%d)", path, to, rule.Status)
+		http.Redirect(w, req, to, rule.Status)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package terms
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/handlers/mock"
+	"github.com/dapperdox/dapperdox/i18n"
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/proxy"
+	"github.com/dapperdox/dapperdox/render"
+	"github.com/dapperdox/dapperdox/session"
+	"github.com/dapperdox/dapperdox/spec"
+	"github.com/gorilla/pat"
+	"github.com/shurcooL/github_flavored_markdown"
+)
+
+// A specification with terms configured can only be viewed once the reader has
+// accepted them. Until then, Handler sends the reader to the terms page at
+// PathPrefix/<spec-id>, which records their acceptance in a cookie signed with
+// terms-secret, and returns them to the page they asked for.
+
+// PathPrefix prefixes the terms page of each specification
+const PathPrefix = "/_dapperdox/terms/"
+
+// cookiePrefix prefixes the acceptance cookie of each specification
+const cookiePrefix = "dapperdox_terms_"
+
+// maxAge is how long an acceptance is remembered, unless the terms change
+const maxAge = 365 * 24 * time.Hour
+
+var secret []byte
+
+// ---------------------------------------------------------------------------
+// Register sets up the signing of acceptances. RegisterSpecification creates the terms
+// page of each specification that has terms configured.
+func Register(r *pat.Router) {
+	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+
+	if len(cfg.TermsSecret) > 0 {
+		secret = []byte(cfg.TermsSecret)
+	} else if secret == nil {
+		secret = []byte(session.NewID()) // Kept across reloads, so that acceptances are too
+	}
+}
+
+// ---------------------------------------------------------------------------
+// RegisterSpecification creates the terms page of a specification, if it has terms
+// configured. It fails if its terms cannot be read.
+func RegisterSpecification(r *pat.Router, specification *spec.APISpecification) error {
+	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+
+	terms := cfg.Spec(specification.ID).Terms
+	if terms == nil {
+		return nil
+	}
+	text := []byte(terms.Text)
+	if len(terms.File) > 0 {
+		var err error
+		if text, err = ioutil.ReadFile(terms.File); err != nil {
+			return fmt.Errorf("error reading terms of specification '%s': %s", specification.ID, err)
+		}
+	}
+	logger.Infof(nil, "Registering terms of specification '%s' at %s", specification.ID, PathPrefix+specification.ID)
+
+	r.Path(PathPrefix + specification.ID).Methods("GET").HandlerFunc(termsHandler(specification, terms, template.HTML(github_flavored_markdown.Markdown(text))))
+	r.Path(PathPrefix + specification.ID).Methods("POST").HandlerFunc(acceptHandler(specification, terms))
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Handler sends readers who have not accepted the terms of a specification to its
+// terms page, before they view any page of it that is not exempt. Its document, mock
+// server and explorer requests are refused instead, as they are not pages.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		render.SiteLock.Lock()
+		specIDs, page := specificationsOf(req)
+		render.SiteLock.Unlock()
+
+		for _, specID := range specIDs {
+			if accepted(req, specID) {
+				continue
+			}
+			if !page {
+				http.Error(w, "The terms of use of "+specID+" have not been accepted", http.StatusForbidden)
+				return
+			}
+			http.Redirect(w, req, PathPrefix+specID+"?next="+url.QueryEscape(req.URL.RequestURI()), http.StatusSeeOther)
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+// specificationsOf returns the IDs of the specifications whose content a request is
+// for, and whether it is for a page. The mock server and explorer proxy name their
+// specification differently, and a specification's document is served at its location.
+func specificationsOf(req *http.Request) ([]string, bool) {
+	path := req.URL.Path
+
+	if strings.HasPrefix(path, mock.PathPrefix+"/") {
+		specID := strings.SplitN(strings.TrimPrefix(path, mock.PathPrefix+"/"), "/", 2)[0]
+		if _, ok := spec.APISuite[specID]; ok {
+			return []string{specID}, false
+		}
+		return nil, false
+	}
+	if strings.HasPrefix(path, proxy.TryItPath+"/") {
+		return proxy.TargetSpecifications(req), false
+	}
+	for id, specification := range spec.APISuite {
+		if specification.URL == path {
+			return []string{id}, false
+		}
+	}
+
+	specID := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)[0]
+	if _, ok := spec.APISuite[specID]; ok {
+		return []string{specID}, true
+	}
+	return nil, true
+}
+
+// ---------------------------------------------------------------------------
+// accepted reports whether a request may go ahead: its specification has no terms,
+// the page is exempt from them, or the reader has accepted them.
+func accepted(req *http.Request, specID string) bool {
+	cfg, _ := config.Get()
+	terms := cfg.Spec(specID).Terms
+	if terms == nil {
+		return true
+	}
+	for _, pattern := range terms.ExemptPaths {
+		if spec.PathMatches(pattern, req.URL.Path) {
+			return true
+		}
+	}
+	cookie, err := req.Cookie(cookiePrefix + specID)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(cookie.Value), []byte(signature(specID, terms)))
+}
+
+// ---------------------------------------------------------------------------
+// signature is the value of the acceptance cookie for a version of the terms
+func signature(specID string, terms *config.Terms) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(specID + "\x00" + terms.Version))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ---------------------------------------------------------------------------
+
+func termsHandler(specification *spec.APISpecification, terms *config.Terms, text template.HTML) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		title := terms.Title
+		if len(title) == 0 {
+			title = i18n.Translate(i18n.Locale(req), "Terms of use")
+		}
+		render.HTML(w, http.StatusOK, "terms", render.DefaultVars(req, specification, render.Vars{
+			"Title": title,
+			"Terms": text,
+			"Next":  localPath(req.URL.Query().Get("next"), specification),
+		}))
+	}
+}
+
+// ---------------------------------------------------------------------------
+
+func acceptHandler(specification *spec.APISpecification, terms *config.Terms) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		http.SetCookie(w, &http.Cookie{
+			Name:     cookiePrefix + specification.ID,
+			Value:    signature(specification.ID, terms),
+			Path:     "/",
+			MaxAge:   int(maxAge / time.Second),
+			HttpOnly: true,
+			Secure:   req.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, req, localPath(req.FormValue("next"), specification), http.StatusSeeOther)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// localPath only allows a return to a page of this site, so that the terms page can
+// not be used to redirect elsewhere. Readers go to the specification's summary otherwise.
+func localPath(path string, specification *spec.APISpecification) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.Contains(path, "\\") {
+		return "/" + specification.ID + "/reference"
+	}
+	return path
+}
@@ -67,6 +67,12 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	case <-h.timeout():
 		tw.mu.Lock()
+		if tw.streaming {
+			// A streamed page is making progress, and is left to finish
+			tw.mu.Unlock()
+			<-done
+			return
+		}
 		defer tw.mu.Unlock()
 		logger.Traceln(r, "request timed out")
 		if !tw.wroteHeader {
@@ -83,6 +89,7 @@ type writer struct {
 	mu          sync.Mutex
 	timedOut    bool
 	wroteHeader bool
+	streaming   bool // Flushed, so the response is being streamed
 }
 
 func (tw *writer) Header() http.Header {
@@ -99,6 +106,20 @@ func (tw *writer) Write(p []byte) (int, error) {
 	return tw.w.Write(p)
 }
 
+// Flush sends what has been written so far. A response that has been flushed is being
+// streamed, and is no longer subject to the time limit.
+func (tw *writer) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	tw.streaming = true
+	if flusher, ok := tw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
 func (tw *writer) WriteHeader(code int) {
 	tw.mu.Lock()
 	defer tw.mu.Unlock()
@@ -0,0 +1,302 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package i18n
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/render/asset"
+	"gopkg.in/yaml.v2"
+)
+
+// Interface text is written in English in the templates, and translated by looking
+// it up in the message catalog of the page's locale. Catalogs are YAML files of
+// English text to translated text, named after their locale:
+//
+//     assets/i18n/de.yaml
+//     "Try it out!": "Ausprobieren!"
+//
+// Text with no translation in the catalog is shown in English, so a catalog need
+// only translate what it can.
+//
+// A reader can choose a locale with the language switcher, which sets CookieName.
+// Otherwise the locale is negotiated from the browser's Accept-Language.
+
+const catalogPrefix = "assets/i18n/"
+
+// CookieName holds the locale chosen with the language switcher.
+const CookieName = "dapperdox_locale"
+
+// Option is a locale that a reader can switch to.
+type Option struct {
+	Locale string
+	Name   string // The name of the language in that language
+}
+
+// Names of common languages, in that language, for the language switcher.
+var languageNames = map[string]string{
+	"da": "Dansk",
+	"de": "Deutsch",
+	"en": "English",
+	"es": "Español",
+	"fi": "Suomi",
+	"fr": "Français",
+	"it": "Italiano",
+	"ja": "日本語",
+	"ko": "한국어",
+	"nl": "Nederlands",
+	"no": "Norsk",
+	"pl": "Polski",
+	"pt": "Português",
+	"ru": "Русский",
+	"sv": "Svenska",
+	"tr": "Türkçe",
+	"zh": "中文",
+}
+
+var catalogs = map[string]map[string]string{}
+var lock sync.RWMutex
+
+// ---------------------------------------------------------------------------
+// Load reads the message catalogs from the compiled assets, replacing any loaded before.
+func Load() {
+	loaded := map[string]map[string]string{}
+
+	for _, name := range asset.AssetNames() {
+		if !strings.HasPrefix(name, catalogPrefix) || path.Ext(name) != ".yaml" {
+			continue
+		}
+		data, err := asset.Asset(name)
+		if err != nil {
+			continue
+		}
+		var messages map[string]string
+		if err = yaml.Unmarshal(data, &messages); err != nil {
+			logger.Errorf(nil, "Error parsing message catalog %s: %s", name, err)
+			continue
+		}
+		locale := normalise(strings.TrimSuffix(path.Base(name), ".yaml"))
+		logger.Debugf(nil, "Loaded %d messages for locale %s", len(messages), locale)
+		loaded[locale] = messages
+	}
+
+	lock.Lock()
+	catalogs = loaded
+	lock.Unlock()
+}
+
+// ---------------------------------------------------------------------------
+// Locales returns the configured locale, followed by the locales that have a message
+// catalog or documentation content.
+func Locales() []string {
+	cfg, _ := config.Get()
+	def := normalise(cfg.Locale)
+
+	seen := map[string]bool{def: true}
+	var locales []string
+
+	lock.RLock()
+	for locale := range catalogs {
+		if !seen[locale] {
+			seen[locale] = true
+			locales = append(locales, locale)
+		}
+	}
+	lock.RUnlock()
+
+	for _, locale := range cfg.Locales {
+		if locale = normalise(locale); !seen[locale] {
+			seen[locale] = true
+			locales = append(locales, locale)
+		}
+	}
+
+	sort.Strings(locales)
+	return append([]string{def}, locales...)
+}
+
+// ---------------------------------------------------------------------------
+// Options returns the locales for the language switcher, or nil if there is only one.
+func Options() []Option {
+	locales := Locales()
+	if len(locales) < 2 {
+		return nil
+	}
+
+	options := make([]Option, len(locales))
+	for i, locale := range locales {
+		name, ok := languageNames[locale]
+		if !ok {
+			if name, ok = languageNames[base(locale)]; ok {
+				name += " (" + strings.ToUpper(locale[len(base(locale))+1:]) + ")"
+			} else {
+				name = locale
+			}
+		}
+		options[i] = Option{Locale: locale, Name: name}
+	}
+	return options
+}
+
+// ---------------------------------------------------------------------------
+// ContentLocale returns the configured content locale that serves pages in locale,
+// or "" if its pages are the default content.
+func ContentLocale(locale string) string {
+	cfg, _ := config.Get()
+
+	for _, tag := range []string{locale, base(locale)} {
+		for _, content := range cfg.Locales {
+			if normalise(content) == tag {
+				return tag
+			}
+		}
+	}
+	return ""
+}
+
+// ---------------------------------------------------------------------------
+// ContentLocales returns the configured content locales.
+func ContentLocales() []string {
+	cfg, _ := config.Get()
+
+	locales := make([]string, len(cfg.Locales))
+	for i, locale := range cfg.Locales {
+		locales[i] = normalise(locale)
+	}
+	return locales
+}
+
+// ---------------------------------------------------------------------------
+// Locale returns the locale for a request: the one chosen with the language switcher,
+// or the most preferred of the browser's Accept-Language that is available, or else
+// the configured locale.
+func Locale(req *http.Request) string {
+	cfg, _ := config.Get()
+	def := normalise(cfg.Locale)
+
+	if req == nil {
+		return def
+	}
+	if cookie, err := req.Cookie(CookieName); err == nil {
+		chosen := normalise(cookie.Value)
+		for _, locale := range Locales() {
+			if locale == chosen {
+				return locale
+			}
+		}
+	}
+	if cfg.LocaleFixed {
+		return def
+	}
+	return Negotiate(req.Header.Get("Accept-Language"), def)
+}
+
+// ---------------------------------------------------------------------------
+// Negotiate chooses between the available locales by an Accept-Language header
+// value, returning def if none of them are acceptable.
+func Negotiate(acceptLanguage string, def string) string {
+	type preference struct {
+		tag     string
+		quality float64
+	}
+	var preferences []preference
+
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(part, ";")
+		tag := normalise(fields[0])
+		if len(tag) == 0 {
+			continue
+		}
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality > 0 {
+			preferences = append(preferences, preference{tag, quality})
+		}
+	}
+	sort.SliceStable(preferences, func(i, j int) bool { return preferences[i].quality > preferences[j].quality })
+
+	available := make(map[string]bool)
+	for _, locale := range Locales() {
+		available[locale] = true
+	}
+
+	for _, p := range preferences {
+		switch {
+		case p.tag == "*":
+			return def
+		case p.tag == def:
+			return def
+		case available[p.tag]:
+			return p.tag
+		case base(p.tag) == def:
+			return def
+		case available[base(p.tag)]:
+			return base(p.tag)
+		}
+	}
+	return def
+}
+
+// ---------------------------------------------------------------------------
+// Translate returns the text of message in the locale, falling back to the catalog
+// of the locale's base language and then to the message itself. If args are given,
+// the text is used as their format.
+func Translate(locale string, message string, args ...interface{}) string {
+	text := message
+
+	lock.RLock()
+	if t, ok := catalogs[locale][message]; ok {
+		text = t
+	} else if t, ok := catalogs[base(locale)][message]; ok {
+		text = t
+	}
+	lock.RUnlock()
+
+	if len(args) > 0 {
+		return fmt.Sprintf(text, args...)
+	}
+	return text
+}
+
+// ---------------------------------------------------------------------------
+
+func normalise(tag string) string {
+	return strings.Replace(strings.ToLower(strings.TrimSpace(tag)), "_", "-", -1)
+}
+
+func base(tag string) string {
+	if i := strings.Index(tag, "-"); i > 0 {
+		return tag[:i]
+	}
+	return tag
+}
@@ -0,0 +1,115 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/spec"
+)
+
+// ---------------------------------------------------------------------------
+// lintSpecs reports documentation gaps in the loaded specifications: things that
+// are valid OpenAPI, but that leave holes in the rendered documentation.
+func lintSpecs(args []string) int {
+	if err := loadSpecifications(); err != nil {
+		fmt.Fprintf(os.Stderr, "Load specification error: %s\n", err)
+		return 1
+	}
+
+	var findings []string
+	add := func(specID string, format string, args ...interface{}) {
+		findings = append(findings, specID+": "+fmt.Sprintf(format, args...))
+	}
+
+	for id, specification := range spec.APISuite {
+		if len(strings.TrimSpace(specification.APIInfo.Description)) == 0 {
+			add(id, "specification has no description")
+		}
+		for _, api := range specification.APIs {
+			for _, method := range api.Methods {
+				op := strings.ToUpper(method.Method) + " " + method.Path
+
+				if len(method.OperationName) == 0 {
+					add(id, "%s has no operationId", op)
+				}
+				if len(strings.TrimSpace(method.Description)) == 0 {
+					add(id, "%s has no description", op)
+				}
+				params := append(append(append(append([]spec.Parameter{}, method.PathParams...), method.QueryParams...), method.HeaderParams...), method.FormParams...)
+				if method.BodyParam != nil {
+					params = append(params, *method.BodyParam)
+				}
+				for _, p := range params {
+					if len(strings.TrimSpace(p.Description)) == 0 {
+						add(id, "%s parameter %s (%s) has no description", op, p.Name, p.In)
+					}
+				}
+				if len(method.Responses) == 0 && method.DefaultResponse == nil {
+					add(id, "%s has no documented responses", op)
+				}
+				for code, response := range method.Responses {
+					if len(strings.TrimSpace(response.Description)) == 0 {
+						add(id, "%s response %d has no description", op, code)
+					}
+				}
+			}
+		}
+		for _, resources := range specification.ResourceList {
+			for _, resource := range resources {
+				lintResource(id, resource, add)
+			}
+		}
+	}
+
+	sort.Strings(findings)
+	findings = uniq(findings)
+	for _, f := range findings {
+		fmt.Println(f)
+	}
+	if len(findings) > 0 {
+		fmt.Printf("\n%d problem(s)\n", len(findings))
+		return 1
+	}
+	return 0
+}
+
+// ---------------------------------------------------------------------------
+
+func lintResource(specID string, resource *spec.Resource, add func(string, string, ...interface{})) {
+	for name, property := range resource.Properties {
+		if len(strings.TrimSpace(property.Description)) == 0 {
+			add(specID, "resource property %s.%s has no description", resource.ID, name)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+
+func uniq(sorted []string) []string {
+	var out []string
+	for i, s := range sorted {
+		if i == 0 || s != sorted[i-1] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
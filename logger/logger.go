@@ -27,6 +27,9 @@ import (
 	"time"
 )
 
+// RequestIDHeader is the header that carries the ID of a request
+const RequestIDHeader = "X-Request-Id"
+
 // Level is a log level
 type Level int
 
@@ -48,6 +51,9 @@ var (
 	Logf = log.Printf
 	// Logln is the function called for *ln functions
 	Logln = log.Println
+	// Output, if set, is given each message with its level in place of Logf and Logln,
+	// such as to write to the Windows event log
+	Output func(level Level, message string)
 )
 
 type responseCapture struct {
@@ -60,6 +66,13 @@ func (r *responseCapture) WriteHeader(status int) {
 	r.ResponseWriter.WriteHeader(status)
 }
 
+// Flush passes flushes through, so that streamed pages reach the client as they render
+func (r *responseCapture) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
 // Handler wraps a http.Handler and logs the status code and total response time
 func Handler(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
@@ -78,6 +91,20 @@ func Handler(h http.Handler) http.Handler {
 	})
 }
 
+// RequestIDHandler gives each request an ID, keeping one set by the client or a proxy in
+// front of DapperDox, so that its log messages, access log record and error page can be
+// correlated. The ID is returned in the response and passed on by the proxies.
+func RequestIDHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !validRequestID(req.Header.Get(RequestIDHeader)) {
+			req.Header.Del(RequestIDHeader)
+		}
+		w.Header().Set(RequestIDHeader, RequestID(req))
+
+		h.ServeHTTP(w, req)
+	})
+}
+
 // LevelString is a level to string lookup map
 var LevelString = map[Level]string{
 	Error: "error",
@@ -110,6 +137,12 @@ var DefaultLevel = func() Level {
 	return Info
 }()
 
+// Enabled reports whether messages of a level are logged, so that callers can skip
+// the work of preparing those that would not be
+func Enabled(level Level) bool {
+	return level <= DefaultLevel
+}
+
 // Levelf implements log.Printf but includes X-Request-Id and requires a log level
 func Levelf(req *http.Request, level Level, format string, args ...interface{}) {
 	if level > getRequestLevel(req) {
@@ -117,10 +150,14 @@ func Levelf(req *http.Request, level Level, format string, args ...interface{})
 	}
 
 	if req != nil {
-		args = append([]interface{}{getRequestID(req), LevelString[level]}, args...)
+		args = append([]interface{}{RequestID(req), LevelString[level]}, args...)
 		format = "[%s] [%s] " + format
 	}
 
+	if Output != nil {
+		Output(level, fmt.Sprintf(format, args...))
+		return
+	}
 	Logf(format, args...)
 }
 
@@ -131,9 +168,13 @@ func Levelln(req *http.Request, level Level, message ...interface{}) {
 	}
 
 	if req != nil {
-		message = append([]interface{}{fmt.Sprintf("[%s] [%s]", getRequestID(req), LevelString[level])}, message...)
+		message = append([]interface{}{fmt.Sprintf("[%s] [%s]", RequestID(req), LevelString[level])}, message...)
 	}
 
+	if Output != nil {
+		Output(level, strings.TrimSuffix(fmt.Sprintln(message...), "\n"))
+		return
+	}
 	Logln(message...)
 }
 
@@ -202,14 +243,29 @@ func getRequestLevel(req *http.Request) Level {
 	return DefaultLevel
 }
 
-func getRequestID(req *http.Request) (requestID string) {
-	if requestID = req.Header.Get("X-Request-Id"); len(requestID) == 0 {
+// RequestID returns the ID of a request, giving it one if it has none
+func RequestID(req *http.Request) (requestID string) {
+	if requestID = req.Header.Get(RequestIDHeader); len(requestID) == 0 {
 		requestID = randSeq(20)
-		req.Header.Set("X-Request-Id", requestID)
+		req.Header.Set(RequestIDHeader, requestID)
 	}
 	return
 }
 
+// validRequestID is whether an incoming request ID is safe to log and echo: up to
+// 128 visible ASCII characters.
+func validRequestID(requestID string) bool {
+	if len(requestID) == 0 || len(requestID) > 128 {
+		return false
+	}
+	for _, c := range requestID {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
 var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 
 func randSeq(n int) string {
@@ -22,21 +22,38 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/gitrepo"
+	"github.com/dapperdox/dapperdox/handlers/access"
+	"github.com/dapperdox/dapperdox/handlers/accesslog"
+	"github.com/dapperdox/dapperdox/handlers/debug"
+	"github.com/dapperdox/dapperdox/handlers/environments"
+	"github.com/dapperdox/dapperdox/handlers/feed"
+	"github.com/dapperdox/dapperdox/handlers/feedback"
+	"github.com/dapperdox/dapperdox/handlers/githook"
 	"github.com/dapperdox/dapperdox/handlers/guides"
 	"github.com/dapperdox/dapperdox/handlers/home"
+	"github.com/dapperdox/dapperdox/handlers/livereload"
+	"github.com/dapperdox/dapperdox/handlers/mock"
+	"github.com/dapperdox/dapperdox/handlers/oauth"
+	"github.com/dapperdox/dapperdox/handlers/ratelimit"
 	"github.com/dapperdox/dapperdox/handlers/reference"
+	"github.com/dapperdox/dapperdox/handlers/reload"
 	"github.com/dapperdox/dapperdox/handlers/specs"
 	"github.com/dapperdox/dapperdox/handlers/static"
+	"github.com/dapperdox/dapperdox/handlers/terms"
 	"github.com/dapperdox/dapperdox/handlers/timeout"
 	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/metrics"
 	"github.com/dapperdox/dapperdox/network"
 	"github.com/dapperdox/dapperdox/proxy"
 	"github.com/dapperdox/dapperdox/render"
 	"github.com/dapperdox/dapperdox/spec"
+	"github.com/dapperdox/dapperdox/tracing"
 	"github.com/gorilla/pat"
 	"github.com/justinas/alice"
 	"github.com/justinas/nosurf"
@@ -47,8 +64,7 @@ var tlsEnabled bool
 
 // ---------------------------------------------------------------------------
 func main() {
-	tlsEnabled = false
-	log.Printf("DapperDox server version %s starting\n", VERSION)
+	command, args := parseCommand()
 
 	os.Setenv("GOFIGURE_ENV_ARRAY", "1") // Enable gofigure array parsing of env vars
 
@@ -65,15 +81,61 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err = network.ConfigureTransport(); err != nil {
+		logger.Errorf(nil, "error configuring outbound connections: %s", err)
+		os.Exit(1)
+	}
+
+	if err = gitrepo.Sync(); err != nil {
+		logger.Errorf(nil, "error updating git repository: %s", err)
+		os.Exit(1)
+	}
+
+	if cfg.Check {
+		os.Exit(checkConfig(nil))
+	}
+	if command.run != nil {
+		os.Exit(command.run(args))
+	}
+
+	serve()
+}
+
+// ---------------------------------------------------------------------------
+// serve runs the documentation server
+func serve() {
+	tlsEnabled = false
+	log.Printf("DapperDox server version %s starting\n", VERSION)
+
+	cfg, _ := config.Get()
+
+	shutdownTracing, err := tracing.Init(VERSION)
+	if err != nil {
+		logger.Errorf(nil, "error configuring tracing: %s", err)
+		os.Exit(1)
+	}
+	defer shutdownTracing()
+
 	router := pat.New()
-	chain := alice.New(logger.Handler /*, context.ClearHandler*/, timeoutHandler, withCsrf, injectHeaders).Then(router)
+	site.router = &siteRouter{site: router}
+	render.SiteLock = site.RLocker()
+	chain := alice.New(livereload.Handler, logger.RequestIDHandler, accesslog.Handler, metrics.Handler, tracing.Handler, logger.Handler /*, context.ClearHandler*/, access.Handler, ratelimit.Handler, timeoutHandler, withCsrf, injectHeaders, terms.Handler, recoverHandler).Then(site)
 
-	logger.Infof(nil, "listening on %s", cfg.BindAddr)
-	listener, err := net.Listen("tcp", cfg.BindAddr)
+	// The specifications are served to the loader from bind-addr while starting up, or from
+	// a loopback port if systemd passes the socket to serve from, which may be privileged.
+	startupAddr := cfg.BindAddr
+	if network.SocketActivated() {
+		startupAddr = "127.0.0.1:0"
+	}
+	logger.Infof(nil, "listening on %s", startupAddr)
+	listener, err := net.Listen("tcp", startupAddr)
 	if err != nil {
 		logger.Errorf(nil, "%s", err)
 		os.Exit(1)
 	}
+	if network.SocketActivated() {
+		startupAddr = listener.Addr().String() // The port that was chosen
+	}
 
 	var wg sync.WaitGroup
 	var sg sync.WaitGroup
@@ -94,20 +156,20 @@ func main() {
 	specs.Register(router)
 	spec.LoadStatusCodes()
 
-	err = spec.LoadSpecifications(cfg.BindAddr, true)
+	err = spec.LoadSpecifications(startupAddr, cfg.SpecMerge)
 	if err != nil {
 		logger.Errorf(nil, "Load specification error: %s", err)
 		os.Exit(1)
 	}
 
-	render.Register()
-
-	reference.Register(router)
-	guides.Register(router)
-	static.Register(router) // TODO - Static content should be capable of being CDN hosted
-
-	home.Register(router)
-	proxy.Register(router)
+	routes, err := newSiteRouter(router)
+	if err != nil {
+		logger.Errorf(nil, "Error: %s", err)
+		os.Exit(1)
+	}
+	site.Lock()
+	site.router = routes
+	site.Unlock()
 
 	listener.Close() // Stop serving specs
 	wg.Wait()        // wait for go routine serving specs to terminate
@@ -118,26 +180,128 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err := proxy.StartRecorder(recordExample); err != nil {
+		logger.Errorf(nil, "Error starting the recording proxy: %s", err)
+		os.Exit(1)
+	}
+
+	if cfg.Watch {
+		go watchSite()
+	}
+	go reloadOnHangup()
+	if len(cfg.SpecPollInterval) > 0 {
+		interval, err := time.ParseDuration(cfg.SpecPollInterval)
+		if err != nil || interval <= 0 {
+			logger.Errorf(nil, "Invalid spec-poll-interval %s", cfg.SpecPollInterval)
+			os.Exit(1)
+		}
+		go spec.PollRemote(interval, func() {
+			reloadSite()
+		})
+	}
+
 	http.Serve(listener, chain)
 }
 
+// ---------------------------------------------------------------------------
+// registerHandlers registers the documentation routes, once the specifications are loaded.
+// It fails if a handler cannot be set up with the configuration, such as a store that
+// cannot be opened.
+func registerHandlers(router *pat.Router) error {
+	render.Register()
+
+	reference.Register(router)
+	if err := guides.Register(router); err != nil {
+		return err
+	}
+	feed.Register(router)
+	static.Register(router) // TODO - Static content should be capable of being CDN hosted
+
+	home.Register(router)
+	if err := proxy.Register(router); err != nil {
+		return err
+	}
+	metrics.Register(router)
+	debug.Register(router)
+	oauth.Register(router)
+	environments.Register(router)
+	if err := feedback.Register(router); err != nil {
+		return err
+	}
+	mock.Register(router)
+	terms.Register(router)
+	githook.Register(router, reloadSite)
+	reload.Register(router, reloadSite, reloadSpecification)
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// registerSpecification registers the pages of a specification, once the handlers of
+// the site are registered
+func registerSpecification(router *pat.Router, specification *spec.APISpecification) error {
+	reference.RegisterSpecification(router, specification)
+	if err := guides.RegisterSpecification(router, specification); err != nil {
+		return err
+	}
+	feed.RegisterSpecification(router, specification)
+	home.RegisterSpecification(router, specification)
+	return terms.RegisterSpecification(router, specification)
+}
+
 // ---------------------------------------------------------------------------
 func withCsrf(h http.Handler) http.Handler {
 	csrfHandler := nosurf.New(h)
+	csrfHandler.ExemptPath(githook.Path) // Called by git hosts, and verified by its own secret
+	csrfHandler.ExemptFunc(func(req *http.Request) bool {
+		if req.URL.Path == reload.Path || strings.HasPrefix(req.URL.Path, reload.Path+"/") {
+			return true // Called by scripts, and verified by its own token
+		}
+		return strings.HasPrefix(req.URL.Path, mock.PathPrefix+"/") // Called by API consumers' code, and changes nothing
+	})
 	csrfHandler.SetFailureHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		rsn := nosurf.Reason(req).Error()
 		logger.Warnf(req, "failed csrf validation: %s", rsn)
+		render.SiteLock.Lock()
+		defer render.SiteLock.Unlock()
 		render.HTML(w, http.StatusBadRequest, "error", map[string]interface{}{"error": rsn})
 	}))
 	return csrfHandler
 }
 
 // ---------------------------------------------------------------------------
+// Paths that are expected to be long running, and so are exempt from the request timeout.
+var timeoutExempt = []string{debug.PathPrefix, oauth.PathPrefix, proxy.TryItPath}
+
 func timeoutHandler(h http.Handler) http.Handler {
-	return timeout.Handler(h, 1*time.Second, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+	th := timeout.Handler(h, 1*time.Second, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		logger.Warnln(req, "request timed out")
-		render.HTML(w, http.StatusRequestTimeout, "error", map[string]interface{}{"error": "Request timed out"})
+		render.LockedError(w, req, http.StatusRequestTimeout, "408", "Request timed out")
 	}))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		for _, prefix := range timeoutExempt {
+			if strings.HasPrefix(req.URL.Path, prefix) {
+				h.ServeHTTP(w, req)
+				return
+			}
+		}
+		th.ServeHTTP(w, req)
+	})
+}
+
+// ---------------------------------------------------------------------------
+// recoverHandler renders the 500 error page if a handler panics. It must come after
+// timeoutHandler in the chain, as that serves the request in a goroutine of its own.
+func recoverHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				logger.Errorf(req, "panic serving %s: %v", req.URL.Path, err)
+				render.LockedError(w, req, http.StatusInternalServerError, "500", "Internal server error") // The site has unlocked as the panic unwound
+			}
+		}()
+		h.ServeHTTP(w, req)
+	})
 }
 
 // ---------------------------------------------------------------------------
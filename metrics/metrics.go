@@ -0,0 +1,167 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package metrics
+
+import (
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/gorilla/pat"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	requestCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "dapperdox_http_requests_total",
+		Help: "Number of HTTP requests served, by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "dapperdox_http_request_duration_seconds",
+		Help:    "HTTP request latency, by route and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	specLoadDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dapperdox_spec_load_duration_seconds",
+		Help: "Time taken to load and parse each OpenAPI specification.",
+	}, []string{"specification"})
+
+	proxyRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "dapperdox_proxy_requests_total",
+		Help: "Requests passed through the explorer proxy, by proxied path and outcome.",
+	}, []string{"proxy", "outcome"})
+)
+
+func init() {
+	prometheus.MustRegister(requestCount, requestDuration, specLoadDuration, proxyRequests)
+}
+
+type responseCapture struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (r *responseCapture) WriteHeader(status int) {
+	r.statusCode = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush passes flushes through, so that streamed pages reach the client as they render
+func (r *responseCapture) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Register mounts the Prometheus scrape endpoint, if metrics are enabled.
+func Register(r *pat.Router) {
+	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+
+	if !cfg.Metrics {
+		return
+	}
+	logger.Infof(nil, "Registering metrics endpoint /metrics")
+
+	r.Path("/metrics").Methods("GET").Handler(promhttp.Handler())
+}
+
+// ---------------------------------------------------------------------------
+// Handler wraps a http.Handler, recording request counts and latency by route.
+func Handler(h http.Handler) http.Handler {
+	cfg, _ := config.Get()
+
+	if !cfg.Metrics {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		rc := &responseCapture{w, http.StatusOK}
+		s := time.Now()
+
+		h.ServeHTTP(rc, req)
+
+		route := routeLabel(req.URL.Path)
+		requestCount.WithLabelValues(route, req.Method, strconv.Itoa(rc.statusCode)).Inc()
+		requestDuration.WithLabelValues(route, req.Method).Observe(time.Since(s).Seconds())
+	})
+}
+
+// ---------------------------------------------------------------------------
+// ObserveSpecLoad records the time taken to load a specification.
+func ObserveSpecLoad(specification string, d time.Duration) {
+	specLoadDuration.WithLabelValues(specification).Set(d.Seconds())
+}
+
+// ObserveProxy records the outcome of a proxied explorer request. A status of
+// zero indicates that the upstream service could not be reached.
+func ObserveProxy(proxyPath string, status int) {
+	outcome := "error"
+	if status > 0 {
+		outcome = strconv.Itoa(status/100) + "xx"
+	}
+	proxyRequests.WithLabelValues(proxyPath, outcome).Inc()
+}
+
+// ---------------------------------------------------------------------------
+// routeLabel reduces a request path to the shape of the route that served it, so that
+// label cardinality is bounded by the route table rather than the number of pages.
+func routeLabel(path string) string {
+	cfg, _ := config.Get()
+
+	for _, proxyPath := range cfg.ProxyPath {
+		if prefix := strings.SplitN(proxyPath, "=", 2)[0]; strings.HasPrefix(path, prefix) {
+			return prefix
+		}
+	}
+
+	if path == "/" || path == "/metrics" {
+		return path
+	}
+	if filepath.Ext(path) != "" {
+		return "static"
+	}
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	if segments[0] == "guides" {
+		return "/guides/{guide}"
+	}
+	if len(segments) == 1 {
+		return "/{spec}"
+	}
+
+	switch segments[1] {
+	case "reference":
+		return "/{spec}/reference" + strings.Repeat("/{id}", len(segments)-2)
+	case "resources":
+		return "/{spec}/resources/{resource}"
+	case "guides":
+		return "/{spec}/guides/{guide}"
+	}
+	return "other"
+}
+
+// ---------------------------------------------------------------------------
@@ -0,0 +1,41 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package navigation
+
+// Options tell themes how to present the navigation, so that sidebars can be built
+// without hardcoding behaviour.
+type Options struct {
+	Expanded    map[string]bool // API group IDs that start expanded
+	ExpandAll   bool            // All API groups start expanded
+	MaxDepth    int             // 1 lists API groups only, 2 also lists their methods
+	Resources   bool            // Resources get their own navigation section
+	Collapsible bool            // API groups can be collapsed and expanded
+	Segmented   bool            // Methods of API groups other than Current are fetched when shown
+	Current     string          // API group ID of the page
+}
+
+// IsExpanded reports whether the API group should start expanded
+func (o Options) IsExpanded(id string) bool {
+	return o.ExpandAll || o.Expanded[id]
+}
+
+// IsSegmented reports whether the methods of the API group are left out of the page, for
+// the theme to fetch from its navigation.json when the group is shown
+func (o Options) IsSegmented(id string) bool {
+	return o.Segmented && id != o.Current
+}
@@ -0,0 +1,48 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package network
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/config"
+)
+
+// ---------------------------------------------------------------------------
+// ClientIP is the address a request came from. Behind a proxy, that is the last
+// address the proxy added to client-ip-header, such as X-Forwarded-For.
+func ClientIP(req *http.Request) string {
+	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+
+	if len(cfg.ClientIPHeader) > 0 {
+		if values := req.Header.Values(cfg.ClientIPHeader); len(values) > 0 {
+			addresses := strings.Split(values[len(values)-1], ",")
+			if address := strings.TrimSpace(addresses[len(addresses)-1]); len(address) > 0 {
+				return address
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
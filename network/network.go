@@ -20,15 +20,59 @@ package network
 import (
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"github.com/dapperdox/dapperdox/config"
 	"github.com/dapperdox/dapperdox/logger"
 	"net"
+	"os"
+	"strconv"
 )
 
+// listenFdsStart is the first file descriptor that systemd passes to a socket activated
+// service, as described by sd_listen_fds(3)
+const listenFdsStart = 3
+
+// ---------------------------------------------------------------------------
+// SocketActivated reports whether systemd passed the listening socket, so that the
+// server need not bind bind-addr itself.
+func SocketActivated() bool {
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	return err == nil && n > 0 && os.Getenv("LISTEN_PID") == strconv.Itoa(os.Getpid())
+}
+
+// ---------------------------------------------------------------------------
+// activationListener returns the socket passed by systemd, or nil if there is none.
+// It can only be taken once.
+func activationListener() (net.Listener, error) {
+	if !SocketActivated() {
+		return nil, nil
+	}
+	if n, _ := strconv.Atoi(os.Getenv("LISTEN_FDS")); n > 1 {
+		logger.Warnf(nil, "systemd passed %d sockets, only the first is used", n)
+	}
+	// Not for child processes
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	f := os.NewFile(uintptr(listenFdsStart), "systemd socket")
+	defer f.Close()
+	return net.FileListener(f)
+}
+
+// ---------------------------------------------------------------------------
+// GetListener returns the listener of the documentation server: the socket passed by
+// systemd if the server was socket activated, or else bind-addr, secured with TLS if a
+// certificate and key are configured.
 func GetListener(tlsEnabled *bool) (net.Listener, error) {
 
 	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
 
+	activated, err := activationListener()
+	if err != nil {
+		return nil, fmt.Errorf("cannot use the socket passed by systemd: %s", err)
+	}
+
 	useTLS := 0
 	if len(cfg.TLSCertificate) > 0 {
 		useTLS++
@@ -39,6 +83,10 @@ func GetListener(tlsEnabled *bool) (net.Listener, error) {
 
 	// If no cert & key, then we're to run in plain-text mode
 	if useTLS == 0 {
+		if activated != nil {
+			logger.Infof(nil, "listening on %s, passed by systemd, for unsecured connections", activated.Addr())
+			return activated, nil
+		}
 		logger.Infof(nil, "listening on %s for unsecured connections", cfg.BindAddr)
 		return net.Listen("tcp", cfg.BindAddr)
 	}
@@ -67,7 +115,11 @@ func GetListener(tlsEnabled *bool) (net.Listener, error) {
 		Certificates: []tls.Certificate{crt},
 	}
 
-	logger.Infof(nil, "listening on %s for SECURED connections", cfg.BindAddr)
 	*tlsEnabled = true
+	if activated != nil {
+		logger.Infof(nil, "listening on %s, passed by systemd, for SECURED connections", activated.Addr())
+		return tls.NewListener(activated, tlscfg), nil
+	}
+	logger.Infof(nil, "listening on %s for SECURED connections", cfg.BindAddr)
 	return tls.Listen("tcp", cfg.BindAddr, tlscfg)
 }
@@ -0,0 +1,206 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/logger"
+)
+
+// ConfigureTransport sets up http.DefaultTransport, which both specification fetching
+// and the explorer proxy use, with the configured outbound proxy and trusted CAs.
+// The proxy defaults to the HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables.
+func ConfigureTransport() error {
+	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+
+	if len(cfg.OutboundProxy) == 0 && len(cfg.OutboundCABundle) == 0 && len(cfg.OutboundInsecure) == 0 {
+		return configureClientCertificates()
+	}
+
+	base, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return fmt.Errorf("default transport has been replaced")
+	}
+
+	if len(cfg.OutboundProxy) > 0 {
+		proxyURL, err := url.Parse(cfg.OutboundProxy)
+		if err != nil {
+			return fmt.Errorf("invalid outbound-proxy %s: %s", cfg.OutboundProxy, err)
+		}
+		logger.Infof(nil, "Using outbound proxy %s", proxyURL.Host)
+		base.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	// CA bundles are either trusted for all hosts, or for a single host given as host=file
+	global := map[string]bool{}
+	perHost := map[string][]string{}
+	for _, bundle := range cfg.OutboundCABundle {
+		if slice := strings.SplitN(bundle, "=", 2); len(slice) == 2 {
+			perHost[slice[0]] = append(perHost[slice[0]], slice[1])
+		} else {
+			global[bundle] = true
+		}
+	}
+
+	var files []string
+	for file := range global {
+		files = append(files, file)
+	}
+	pool, err := certPool(files)
+	if err != nil {
+		return err
+	}
+
+	t := &hostTransport{
+		hosts:       map[string]http.RoundTripper{},
+		defaultTrip: base,
+	}
+	if pool != nil {
+		t.defaultTrip = withTLS(base, &tls.Config{RootCAs: pool})
+	}
+
+	for host, hostFiles := range perHost {
+		for file := range global {
+			hostFiles = append(hostFiles, file)
+		}
+		hostPool, err := certPool(hostFiles)
+		if err != nil {
+			return err
+		}
+		t.hosts[host] = withTLS(base, &tls.Config{RootCAs: hostPool})
+	}
+
+	for _, host := range cfg.OutboundInsecure {
+		logger.Warnf(nil, "TLS certificate verification is disabled for %s", host)
+		t.hosts[host] = withTLS(base, &tls.Config{InsecureSkipVerify: true})
+	}
+
+	http.DefaultTransport = t
+	return configureClientCertificates()
+}
+
+// ---------------------------------------------------------------------------
+// clientCertTransports present the client certificate of an environment, keyed by its name
+var clientCertTransports = map[string]http.RoundTripper{}
+
+// configureClientCertificates loads the client certificates of the configured environments,
+// for the explorer proxy to present when calling APIs secured with mutual TLS.
+func configureClientCertificates() error {
+	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+
+	for _, environment := range cfg.Environments() {
+		if len(environment.ClientCert) == 0 {
+			continue
+		}
+		cert, err := tls.LoadX509KeyPair(environment.ClientCert, environment.ClientKey)
+		if err != nil {
+			return fmt.Errorf("error loading client certificate of environment %s: %s", environment.Name, err)
+		}
+		logger.Infof(nil, "Using client certificate %s for environment %s", environment.ClientCert, environment.Name)
+		clientCertTransports[environment.Name] = withClientCertificate(http.DefaultTransport, cert)
+	}
+	return nil
+}
+
+// Transport returns the transport for explorer requests made in an environment. It
+// presents the environment's client certificate, if it has one.
+func Transport(environment string) http.RoundTripper {
+	if t, ok := clientCertTransports[environment]; ok {
+		return t
+	}
+	return http.DefaultTransport
+}
+
+func withClientCertificate(rt http.RoundTripper, cert tls.Certificate) http.RoundTripper {
+	switch t := rt.(type) {
+	case *http.Transport:
+		c := t.Clone()
+		if c.TLSClientConfig == nil {
+			c.TLSClientConfig = &tls.Config{}
+		}
+		c.TLSClientConfig.Certificates = []tls.Certificate{cert}
+		return c
+	case *hostTransport:
+		h := &hostTransport{
+			hosts:       map[string]http.RoundTripper{},
+			defaultTrip: withClientCertificate(t.defaultTrip, cert),
+		}
+		for host, trip := range t.hosts {
+			h.hosts[host] = withClientCertificate(trip, cert)
+		}
+		return h
+	}
+	return rt
+}
+
+// ---------------------------------------------------------------------------
+// hostTransport chooses the transport for a request by its host, so that TLS
+// settings can differ between targets.
+type hostTransport struct {
+	hosts       map[string]http.RoundTripper // Keyed by host or host:port
+	defaultTrip http.RoundTripper
+}
+
+func (t *hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt, ok := t.hosts[req.URL.Host]; ok {
+		return rt.RoundTrip(req)
+	}
+	if rt, ok := t.hosts[req.URL.Hostname()]; ok {
+		return rt.RoundTrip(req)
+	}
+	return t.defaultTrip.RoundTrip(req)
+}
+
+// ---------------------------------------------------------------------------
+
+func withTLS(base *http.Transport, tlsConfig *tls.Config) *http.Transport {
+	t := base.Clone()
+	t.TLSClientConfig = tlsConfig
+	return t
+}
+
+// ---------------------------------------------------------------------------
+// certPool returns the system CAs plus those in the given PEM files, or nil if there are no files
+func certPool(files []string) (*x509.CertPool, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	for _, file := range files {
+		pem, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA bundle %s: %s", file, err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", file)
+		}
+		logger.Debugf(nil, "Trusting CA bundle %s", file)
+	}
+	return pool, nil
+}
@@ -0,0 +1,234 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package proxy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/network"
+	"github.com/dapperdox/dapperdox/session"
+)
+
+// When explorer-audit is set, every request the explorer proxy forwards is written to
+// an audit trail: who made it, where it went and what came back. Bodies are left out
+// unless explorer-audit-bodies is set, as they may hold personal data or secrets.
+
+// maxAuditBody is how much of a request or response body is kept in an audit record
+const maxAuditBody = 16 << 10
+
+// webhookTimeout limits how long an audit webhook has to accept a record
+const webhookTimeout = 10 * time.Second
+
+// auditRecord is an explorer request in the audit trail
+type auditRecord struct {
+	Time          string  `json:"time"`
+	RequestID     string  `json:"request_id"`
+	ClientIP      string  `json:"client_ip"`
+	User          string  `json:"user,omitempty"`    // Of basic authentication in front of DapperDox, if any
+	Session       string  `json:"session,omitempty"` // A hash of the session ID, as the ID is itself a credential
+	Specification string  `json:"specification,omitempty"`
+	Environment   string  `json:"environment,omitempty"`
+	Method        string  `json:"method"`
+	URL           string  `json:"url"`
+	Status        int     `json:"status"` // 0 if the API could not be reached
+	Latency       float64 `json:"latency_ms"`
+	RequestBody   *string `json:"request_body,omitempty"`
+	ResponseBody  *string `json:"response_body,omitempty"`
+
+	requestBody, responseBody *bodyCapture
+}
+
+// auditor writes audit records to a sink
+type auditor struct {
+	sink   func(line []byte) error
+	bodies bool
+}
+
+var audit *auditor
+
+// ---------------------------------------------------------------------------
+// newAuditor creates the auditor for the explorer-audit setting: stdout, stderr, a
+// file name or webhook:<url>.
+func newAuditor(location string, bodies bool) (*auditor, error) {
+	if strings.HasPrefix(location, "webhook:") {
+		u, err := url.Parse(strings.TrimPrefix(location, "webhook:"))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("expected webhook:<http or https URL>")
+		}
+		return &auditor{sink: webhookSink(u.String()), bodies: bodies}, nil
+	}
+	return &auditor{sink: fileSink(location), bodies: bodies}, nil
+}
+
+// fileSink appends each record to a file as a line of JSON. The file is opened for
+// each record, so that it may be rotated.
+func fileSink(name string) func(line []byte) error {
+	var lock sync.Mutex
+	return func(line []byte) error {
+		lock.Lock()
+		defer lock.Unlock()
+
+		switch strings.ToLower(name) {
+		case "stdout":
+			_, err := os.Stdout.Write(line)
+			return err
+		case "stderr":
+			_, err := os.Stderr.Write(line)
+			return err
+		}
+
+		f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		if _, err = f.Write(line); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+}
+
+// webhookSink posts each record as JSON to a URL, such as a SIEM collector. It is sent
+// in the background, so that a slow webhook does not hold up the explorer.
+func webhookSink(u string) func(line []byte) error {
+	client := &http.Client{Timeout: webhookTimeout}
+	return func(line []byte) error {
+		go func() {
+			resp, err := client.Post(u, "application/json", bytes.NewReader(line))
+			if err != nil {
+				logger.Errorf(nil, "Error sending explorer audit record to webhook: %s", err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				logger.Errorf(nil, "Error sending explorer audit record to webhook: %s", resp.Status)
+			}
+		}()
+		return nil
+	}
+}
+
+// ---------------------------------------------------------------------------
+// start begins the audit record of an explorer request to target. If bodies are
+// audited, the request body is captured as the proxy reads it, and the response
+// body through the returned writer.
+func (a *auditor) start(w http.ResponseWriter, req *http.Request, target *url.URL) (*auditRecord, http.ResponseWriter) {
+	record := &auditRecord{
+		Time:          time.Now().UTC().Format(time.RFC3339),
+		RequestID:     logger.RequestID(req),
+		ClientIP:      network.ClientIP(req),
+		Specification: req.Header.Get(specHeader),
+		Environment:   req.Header.Get(environmentHeader),
+		Method:        req.Method,
+		URL:           target.String(),
+	}
+	if user, _, ok := req.BasicAuth(); ok {
+		record.User = user
+	}
+	if cookie, err := req.Cookie(session.CookieName); err == nil {
+		sum := sha256.Sum256([]byte(cookie.Value))
+		record.Session = hex.EncodeToString(sum[:8])
+	}
+
+	if !a.bodies {
+		return record, w
+	}
+	record.requestBody = &bodyCapture{}
+	record.responseBody = &bodyCapture{}
+	if req.Body != nil {
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.TeeReader(req.Body, record.requestBody), req.Body}
+	}
+	return record, &teeResponseWriter{w, record.responseBody}
+}
+
+// ---------------------------------------------------------------------------
+// finish completes the record with the outcome of the request and writes it out.
+func (a *auditor) finish(req *http.Request, record *auditRecord, status int, latency time.Duration) {
+	record.Status = status
+	record.Latency = float64(latency.Nanoseconds()) / float64(time.Millisecond)
+	if a.bodies {
+		record.RequestBody = record.requestBody.text()
+		record.ResponseBody = record.responseBody.text()
+	}
+
+	line, err := json.Marshal(record)
+	if err == nil {
+		err = a.sink(append(line, '\n'))
+	}
+	if err != nil {
+		logger.Errorf(req, "Error writing explorer audit record: %s", err)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// bodyCapture keeps the first maxAuditBody bytes written to it
+type bodyCapture struct {
+	bytes.Buffer
+	truncated bool
+}
+
+func (b *bodyCapture) Write(p []byte) (int, error) {
+	if room := maxAuditBody - b.Len(); len(p) > room {
+		b.Buffer.Write(p[:room])
+		b.truncated = true
+	} else {
+		b.Buffer.Write(p)
+	}
+	return len(p), nil
+}
+
+func (b *bodyCapture) text() *string {
+	if b.Len() == 0 {
+		return nil
+	}
+	s := b.String()
+	if b.truncated {
+		s += "…"
+	}
+	return &s
+}
+
+// teeResponseWriter copies the response body it writes to a capture
+type teeResponseWriter struct {
+	http.ResponseWriter
+	body io.Writer
+}
+
+func (t *teeResponseWriter) Write(p []byte) (int, error) {
+	n, err := t.ResponseWriter.Write(p)
+	t.body.Write(p[:n])
+	return n, err
+}
+
+// -----------------------------------------------------------------------------
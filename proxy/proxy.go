@@ -20,7 +20,11 @@ package proxy
 import (
 	"github.com/dapperdox/dapperdox/config"
 	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/metrics"
+	"github.com/dapperdox/dapperdox/tracing"
 	"github.com/gorilla/pat"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -31,6 +35,7 @@ import (
 type responseCapture struct {
 	http.ResponseWriter
 	statusCode int
+	failed     bool
 }
 
 func (r *responseCapture) WriteHeader(status int) {
@@ -40,7 +45,7 @@ func (r *responseCapture) WriteHeader(status int) {
 
 // -----------------------------------------------------------------------------
 
-func Register(r *pat.Router) {
+func Register(r *pat.Router) error {
 	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
 
 	logger.Tracef(nil, "Registering proxied paths:\n")
@@ -55,6 +60,11 @@ func Register(r *pat.Router) {
 		}
 	}
 	logger.Tracef(nil, "Registering proxied paths done.\n")
+
+	if cfg.ExplorerProxy {
+		return registerTryIt(r)
+	}
+	return nil
 }
 
 // -----------------------------------------------------------------------------
@@ -77,20 +87,40 @@ func register(r *pat.Router, routePattern string, target string) {
 			scheme = "https://"
 		}
 		logger.Debugf(r, "Proxy request to: %s%s%s", scheme, r.Host, r.URL.Path)
+		tracing.Inject(r)
+	}
+
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		logger.Errorf(r, "Proxy request to %s failed: %s", target, err)
+		if rc, ok := w.(*responseCapture); ok {
+			rc.failed = true
+		}
+		w.WriteHeader(http.StatusBadGateway)
 	}
 
 	r.PathPrefix(routePattern).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		rc := &responseCapture{w, 0}
+		rc := &responseCapture{w, 0, false}
 		s := time.Now()
 		logger.Tracef(r, "Proxy request started: %v", s)
 
-		proxy.ServeHTTP(rc, r)
+		ctx, span := tracing.Start(r.Context(), "proxy "+routePattern, trace.WithSpanKind(trace.SpanKindClient))
+		defer span.End()
+
+		proxy.ServeHTTP(rc, r.WithContext(ctx))
+
+		span.SetAttributes(attribute.String("proxy.target", target), attribute.Int("http.status_code", rc.statusCode))
 
 		e := time.Now()
 		logger.Tracef(r, "Proxy request completed: %v", e)
 
 		d := e.Sub(s)
 		logger.Infof(r, "PROXY %s %s (%d, %v)", r.Method, r.URL.Path, rc.statusCode, d)
+
+		if rc.failed {
+			metrics.ObserveProxy(routePattern, 0)
+		} else {
+			metrics.ObserveProxy(routePattern, rc.statusCode)
+		}
 	})
 }
 
@@ -0,0 +1,288 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/spec"
+)
+
+// With record-upstream set, a recording proxy listens on record-bind-addr in front of
+// the real API. Every request made through it is forwarded, and the exchange handed to
+// the documentation, which redacts its credentials and shows it as an example of the
+// method it matches. Exchanges that became examples are kept in record-file, if set,
+// as a HAR capture that is read again on restart.
+
+// recorder forwards requests to the upstream API, and records their exchanges
+type recorder struct {
+	record  func(*spec.HAREntry) bool
+	file    string
+	lock    sync.Mutex
+	entries []spec.HAREntry
+}
+
+// ---------------------------------------------------------------------------
+// StartRecorder starts the recording proxy, if record-upstream is set. record is
+// given each exchange, and reports whether it became an example.
+func StartRecorder(record func(*spec.HAREntry) bool) error {
+	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+
+	if len(cfg.RecordUpstream) == 0 {
+		return nil
+	}
+	upstream, err := url.Parse(cfg.RecordUpstream)
+	if err != nil || (upstream.Scheme != "http" && upstream.Scheme != "https") {
+		return fmt.Errorf("record-upstream %s is not an http or https URL", cfg.RecordUpstream)
+	}
+
+	rec := &recorder{record: record, file: cfg.RecordFile}
+	if err := rec.load(); err != nil {
+		return err
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(upstream)
+	od := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		od(r)
+		r.Host = r.URL.Host // Rewrite Host
+	}
+	proxy.Transport = rec
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		logger.Errorf(r, "Recording proxy request to %s failed: %s", cfg.RecordUpstream, err)
+		w.WriteHeader(http.StatusBadGateway)
+	}
+
+	listener, err := net.Listen("tcp", cfg.RecordBindAddr)
+	if err != nil {
+		return fmt.Errorf("error listening on record-bind-addr %s: %s", cfg.RecordBindAddr, err)
+	}
+	logger.Infof(nil, "Recording requests to %s made through %s", cfg.RecordUpstream, cfg.RecordBindAddr)
+
+	go http.Serve(listener, proxy)
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// load reads the exchanges already kept in record-file, so that they are kept with the
+// new ones
+func (r *recorder) load() error {
+	if len(r.file) == 0 {
+		return nil
+	}
+	data, err := ioutil.ReadFile(r.file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading record-file %s: %s", r.file, err)
+	}
+	var har struct {
+		Log struct {
+			Entries []spec.HAREntry `json:"entries"`
+		} `json:"log"`
+	}
+	if err = json.Unmarshal(data, &har); err != nil {
+		return fmt.Errorf("error reading record-file %s: %s", r.file, err)
+	}
+	r.entries = har.Log.Entries
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// RoundTrip forwards a request to the upstream API and records the exchange. Exchanges
+// with bodies larger than maxRecording are passed on as they are, and not recorded.
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	entry := &spec.HAREntry{}
+	entry.StartedDateTime = time.Now().UTC().Format(time.RFC3339Nano)
+	entry.Request.Method = req.Method
+	entry.Request.URL = req.URL.String()
+	entry.Request.Headers = harHeaders(req.Header)
+
+	if req.Body != nil {
+		body, complete, err := readLimited(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body = body
+		if !complete {
+			return http.DefaultTransport.RoundTrip(req)
+		}
+		if len(body.Bytes()) > 0 {
+			entry.Request.PostData.MimeType = req.Header.Get("Content-Type")
+			entry.Request.PostData.Text, _ = harText(body.Bytes(), "")
+		}
+	}
+
+	start := time.Now()
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, complete, err := readLimited(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = body
+	entry.Time = float64(time.Since(start).Nanoseconds()) / float64(time.Millisecond)
+	if !complete {
+		logger.Debugf(req, "Response to %s %s is too large to record", req.Method, req.URL)
+		return resp, nil
+	}
+
+	entry.Response.Status = resp.StatusCode
+	entry.Response.StatusText = http.StatusText(resp.StatusCode)
+	entry.Response.Headers = harHeaders(resp.Header)
+	entry.Response.Content.MimeType = resp.Header.Get("Content-Type")
+	entry.Response.Content.Text, entry.Response.Content.Encoding = harText(body.Bytes(), resp.Header.Get("Content-Encoding"))
+
+	// The documentation is locked while an example is added, which should not hold up
+	// the response
+	go r.add(entry)
+	return resp, nil
+}
+
+// add hands an exchange to the documentation, keeping it in record-file if it became
+// an example
+func (r *recorder) add(entry *spec.HAREntry) {
+	if !r.record(entry) {
+		logger.Debugf(nil, "Recorded %s %s matches no method without an example of its status", entry.Request.Method, entry.Request.URL)
+		return
+	}
+	logger.Infof(nil, "Recorded %s %s (%d) as an example", entry.Request.Method, entry.Request.URL, entry.Response.Status)
+	if len(r.file) == 0 {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.entries = append(r.entries, *entry)
+	if err := r.save(); err != nil {
+		logger.Errorf(nil, "Error writing record-file %s: %s", r.file, err)
+	}
+}
+
+// save writes the recorded exchanges to record-file, replacing it only once the new
+// one is complete
+func (r *recorder) save() error {
+	var har struct {
+		Log struct {
+			Version string `json:"version"`
+			Creator struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"creator"`
+			Entries []spec.HAREntry `json:"entries"`
+		} `json:"log"`
+	}
+	har.Log.Version = "1.2"
+	har.Log.Creator.Name = "DapperDox"
+	har.Log.Creator.Version = "1.0"
+	har.Log.Entries = r.entries
+
+	data, err := json.MarshalIndent(&har, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(r.file), filepath.Base(r.file)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), r.file)
+}
+
+// ---------------------------------------------------------------------------
+// limitedBody is a body that has been read, in full or up to maxRecording bytes, and is
+// replayed to whoever reads it next
+type limitedBody struct {
+	io.Reader
+	io.Closer
+	read []byte
+}
+
+func (b *limitedBody) Bytes() []byte {
+	return b.read
+}
+
+// readLimited reads up to maxRecording bytes of a body, reporting whether that was all
+// of it. The returned body reads the same bytes again, followed by any that are left.
+func readLimited(body io.ReadCloser) (*limitedBody, bool, error) {
+	read, err := ioutil.ReadAll(io.LimitReader(body, maxRecording+1))
+	if err != nil {
+		body.Close()
+		return nil, false, err
+	}
+	return &limitedBody{io.MultiReader(bytes.NewReader(read), body), body, read}, len(read) <= maxRecording, nil
+}
+
+// harHeaders are headers as they are recorded in a HAR capture, in name order
+func harHeaders(header http.Header) []spec.RecordedHeader {
+	var headers []spec.RecordedHeader
+	for name, values := range header {
+		for _, value := range values {
+			headers = append(headers, spec.RecordedHeader{Name: name, Value: value})
+		}
+	}
+	sort.SliceStable(headers, func(i, j int) bool { return headers[i].Name < headers[j].Name })
+	return headers
+}
+
+// harText is a body as it is recorded in a HAR capture, with its encoding: decompressed
+// if it was gzipped, and base64 encoded if it is not text
+func harText(body []byte, contentEncoding string) (string, string) {
+	if strings.EqualFold(contentEncoding, "gzip") {
+		if zr, err := gzip.NewReader(bytes.NewReader(body)); err == nil {
+			if unzipped, err := ioutil.ReadAll(io.LimitReader(zr, maxRecording)); err == nil {
+				body = unzipped
+			}
+		}
+	}
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
@@ -0,0 +1,229 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package proxy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dapperdox/dapperdox/logger"
+)
+
+// With explorer-replay set, the explorer proxy keeps the responses of the APIs in
+// explorer-replay-dir and can play them back, as an offline sandbox for demos and
+// workshops. In record mode every request is forwarded and its response kept. In
+// replay mode the API is never reached, and requests with no recorded response fail.
+// In fallback mode recorded responses are replayed, and other requests forwarded and
+// recorded.
+
+// replayHeader tells the explorer whether a response was replayed
+const replayHeader = "X-Dapperdox-Replay"
+
+// maxRecording is the largest response body that is recorded
+const maxRecording = 10 << 20
+
+// recording is a response kept in explorer-replay-dir, one file each
+type recording struct {
+	Method   string      `json:"method"`
+	URL      string      `json:"url"`
+	Recorded time.Time   `json:"recorded"`
+	Status   int         `json:"status"`
+	Header   http.Header `json:"header"`
+	Body     []byte      `json:"body"`
+}
+
+type replayer struct {
+	mode string
+	dir  string
+	lock sync.Mutex
+}
+
+var replay *replayer
+
+// ---------------------------------------------------------------------------
+// newReplayer creates the replayer for the explorer-replay mode, keeping recordings in dir.
+func newReplayer(mode, dir string) (*replayer, error) {
+	switch mode {
+	case "record", "replay", "fallback":
+	default:
+		return nil, fmt.Errorf("unknown mode %s, expected record, replay or fallback", mode)
+	}
+	if len(dir) == 0 {
+		return nil, fmt.Errorf("explorer-replay-dir is required")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &replayer{mode: mode, dir: dir}, nil
+}
+
+// ---------------------------------------------------------------------------
+// roundTrip replays the recorded response to req, or sends it with rt, recording the
+// response, as the mode allows.
+func (r *replayer) roundTrip(rt http.RoundTripper, req *http.Request) (*http.Response, error) {
+	key, err := r.key(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.mode != "record" {
+		if rec, err := r.load(key); err == nil {
+			logger.Debugf(req, "Replaying response recorded %s for %s %s", rec.Recorded.Format(time.RFC3339), req.Method, req.URL)
+			resp := rec.response(req)
+			resp.Header.Set(replayHeader, "replayed")
+			return resp, nil
+		} else if !os.IsNotExist(err) {
+			logger.Errorf(req, "Error reading recorded response for %s %s: %s", req.Method, req.URL, err)
+		}
+		if r.mode == "replay" {
+			return notRecorded(req), nil
+		}
+	}
+
+	resp, err := rt.RoundTrip(req)
+	if err != nil || !recordable(resp) {
+		return resp, err
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxRecording+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if len(body) > maxRecording {
+		logger.Warnf(req, "Response to %s %s is too large to record", req.Method, req.URL)
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	header := resp.Header.Clone()
+	header.Del("Set-Cookie")
+	rec := &recording{Method: req.Method, URL: req.URL.String(), Recorded: time.Now().UTC(), Status: resp.StatusCode, Header: header, Body: body}
+	if err := r.save(key, rec); err != nil {
+		logger.Errorf(req, "Error recording response for %s %s: %s", req.Method, req.URL, err)
+	}
+	resp.Header.Set(replayHeader, "recorded")
+	return resp, nil
+}
+
+// ---------------------------------------------------------------------------
+// key identifies a request by its method, URL and body, leaving out headers such as
+// credentials that differ between readers. The body is read, and put back.
+func (r *replayer) key(req *http.Request) (string, error) {
+	h := sha256.New()
+	io.WriteString(h, req.Method+" "+req.URL.String()+"\n")
+
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return "", err
+		}
+		h.Write(body)
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (r *replayer) load(key string) (*recording, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	data, err := ioutil.ReadFile(filepath.Join(r.dir, key+".json"))
+	if err != nil {
+		return nil, err
+	}
+	var rec recording
+	if err = json.Unmarshal(data, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (r *replayer) save(key string, rec *recording) error {
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return ioutil.WriteFile(filepath.Join(r.dir, key+".json"), data, 0600)
+}
+
+// ---------------------------------------------------------------------------
+// recordable is whether a response can be kept and played back whole. Upgraded
+// connections and event streams never end, and so cannot.
+func recordable(resp *http.Response) bool {
+	return resp.StatusCode != http.StatusSwitchingProtocols &&
+		!strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream")
+}
+
+// ---------------------------------------------------------------------------
+
+func (rec *recording) response(req *http.Request) *http.Response {
+	header := rec.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set("Content-Length", strconv.Itoa(len(rec.Body)))
+
+	return &http.Response{
+		Status:        strconv.Itoa(rec.Status) + " " + http.StatusText(rec.Status),
+		StatusCode:    rec.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(rec.Body)),
+		ContentLength: int64(len(rec.Body)),
+		Request:       req,
+	}
+}
+
+// notRecorded is the response to a request that has no recording in replay mode
+func notRecorded(req *http.Request) *http.Response {
+	body := []byte(fmt.Sprintf("No response to %s %s has been recorded. Record one with explorer-replay set to record or fallback.\n", req.Method, req.URL))
+
+	header := make(http.Header)
+	header.Set("Content-Type", "text/plain; charset=utf-8")
+	header.Set(replayHeader, "missing")
+
+	rec := &recording{Status: http.StatusBadGateway, Header: header, Body: body}
+	return rec.response(req)
+}
+
+// -----------------------------------------------------------------------------
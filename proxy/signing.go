@@ -0,0 +1,227 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package proxy
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dapperdox/dapperdox/config"
+)
+
+// Headers that the explorer sends the specification and signing credentials in. They
+// are removed before the request is forwarded.
+const (
+	specHeader          = "X-Dapperdox-Spec"
+	signingKeyHeader    = "X-Dapperdox-Signing-Key"
+	signingSecretHeader = "X-Dapperdox-Signing-Secret"
+	signingTokenHeader  = "X-Dapperdox-Signing-Token"
+)
+
+// maxSignedBody limits the size of a request body that is read to be signed
+const maxSignedBody = 10 << 20
+
+// -----------------------------------------------------------------------------
+// sign adds a signature to a request for target, if its specification is configured
+// for signing and the explorer sent credentials.
+func sign(req *http.Request, target *url.URL) error {
+	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+
+	signing := cfg.Spec(req.Header.Get(specHeader)).Signing
+	key := req.Header.Get(signingKeyHeader)
+	secret := req.Header.Get(signingSecretHeader)
+
+	if signing == nil || len(key) == 0 || len(secret) == 0 {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, maxSignedBody+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > maxSignedBody {
+		return errors.New("request body is too large to sign")
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	switch signing.Type {
+	case "hmac":
+		signHMAC(req, target, body, signing, key, secret)
+	case "aws-sigv4":
+		signSigV4(req, target, body, signing, key, secret, req.Header.Get(signingTokenHeader))
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// signHMAC signs the method, path and query, date and a digest of the body:
+//
+//	<method>\n<path?query>\n<date>\n<hex digest of body>
+//
+// and sends it as "HMAC-SHA256 keyId=<key>, signature=<base64 signature>", with the
+// date in the Date header.
+func signHMAC(req *http.Request, target *url.URL, body []byte, signing *config.Signing, key, secret string) {
+	digest, name := sha256.New, "HMAC-SHA256"
+	if signing.Algorithm == "sha512" {
+		digest, name = sha512.New, "HMAC-SHA512"
+	}
+	header := signing.Header
+	if len(header) == 0 {
+		header = "Authorization"
+	}
+
+	date := time.Now().UTC().Format(http.TimeFormat)
+	bodyDigest := digest()
+	bodyDigest.Write(body)
+
+	toSign := strings.Join([]string{req.Method, target.RequestURI(), date, hex.EncodeToString(bodyDigest.Sum(nil))}, "\n")
+	signature := base64.StdEncoding.EncodeToString(hmacSum(digest, []byte(secret), toSign))
+
+	req.Header.Set("Date", date)
+	req.Header.Set(header, name+" keyId="+key+", signature="+signature)
+}
+
+// -----------------------------------------------------------------------------
+// signSigV4 signs a request with AWS Signature Version 4, signing the host and the
+// x-amz- headers that it adds.
+func signSigV4(req *http.Request, target *url.URL, body []byte, signing *config.Signing, key, secret, token string) {
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payload := hex.EncodeToString(sha256Sum(body))
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+	if len(token) > 0 {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+
+	headers := map[string]string{"host": target.Host}
+	for name := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(req.Header.Get(name))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalPath(target),
+		canonicalQuery(target),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payload,
+	}, "\n")
+
+	scope := day + "/" + signing.Region + "/" + signing.Service + "/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sha256Sum([]byte(canonicalRequest)))
+
+	signingKey := hmacSum(sha256.New, []byte("AWS4"+secret), day)
+	for _, part := range []string{signing.Region, signing.Service, "aws4_request"} {
+		signingKey = hmacSum(sha256.New, signingKey, part)
+	}
+	signature := hex.EncodeToString(hmacSum(sha256.New, signingKey, toSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+key+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// canonicalPath encodes each segment of the escaped path again, as SigV4 requires
+// for services other than S3.
+func canonicalPath(target *url.URL) string {
+	segments := strings.Split(target.EscapedPath(), "/")
+	for i, segment := range segments {
+		segments[i] = uriEncode(segment)
+	}
+	path := strings.Join(segments, "/")
+	if len(path) == 0 {
+		path = "/"
+	}
+	return path
+}
+
+// canonicalQuery sorts the query parameters by name and then value
+func canonicalQuery(target *url.URL) string {
+	query := target.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return uriEncode(names[i]) < uriEncode(names[j]) })
+
+	var params []string
+	for _, name := range names {
+		values := make([]string, len(query[name]))
+		for i, value := range query[name] {
+			values[i] = uriEncode(value)
+		}
+		sort.Strings(values)
+		for _, value := range values {
+			params = append(params, uriEncode(name)+"="+value)
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// uriEncode percent encodes everything but the RFC 3986 unreserved characters
+func uriEncode(s string) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			b.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{c})))
+		}
+	}
+	return b.String()
+}
+
+// -----------------------------------------------------------------------------
+
+func hmacSum(digest func() hash.Hash, key []byte, data string) []byte {
+	mac := hmac.New(digest, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Sum(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
@@ -0,0 +1,241 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/metrics"
+	"github.com/dapperdox/dapperdox/network"
+	"github.com/dapperdox/dapperdox/spec"
+	"github.com/dapperdox/dapperdox/tracing"
+	"github.com/gorilla/pat"
+)
+
+// TryItPath is where the explorer sends requests to be forwarded to an API, as
+// TryItPath/<scheme>/<host>/<path>, for APIs that do not allow cross-origin requests.
+const TryItPath = "/_dapperdox/tryit"
+
+// environmentHeader names the explorer environment that a request is made in
+const environmentHeader = "X-Dapperdox-Environment"
+
+// Request headers that belong to the documentation site and are not forwarded
+var siteHeaders = []string{"Cookie", "X-Csrf-Token", "Referer", specHeader, environmentHeader, signingKeyHeader, signingSecretHeader, signingTokenHeader}
+
+// -----------------------------------------------------------------------------
+// registerTryIt mounts the explorer proxy. It fails, keeping the previous audit trail and
+// replay settings, if either is invalid.
+func registerTryIt(r *pat.Router) error {
+	logger.Tracef(nil, "+ %s -> explorer targets\n", TryItPath)
+
+	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+
+	var auditing *auditor
+	if len(cfg.ExplorerAudit) > 0 {
+		var err error
+		if auditing, err = newAuditor(cfg.ExplorerAudit, cfg.ExplorerAuditBody); err != nil {
+			return fmt.Errorf("invalid explorer-audit '%s': %s", cfg.ExplorerAudit, err)
+		}
+	}
+
+	var replaying *replayer
+	if len(cfg.ExplorerReplay) > 0 && cfg.ExplorerReplay != "off" {
+		var err error
+		if replaying, err = newReplayer(cfg.ExplorerReplay, cfg.ExplorerReplayDir); err != nil {
+			return fmt.Errorf("invalid explorer-replay '%s': %s", cfg.ExplorerReplay, err)
+		}
+	}
+
+	audit, replay = auditing, replaying
+	if audit != nil {
+		logger.Infof(nil, "Writing explorer audit trail to %s", cfg.ExplorerAudit)
+	}
+	if replay != nil {
+		logger.Infof(nil, "Explorer proxy in %s mode, keeping responses in %s", cfg.ExplorerReplay, cfg.ExplorerReplayDir)
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			target := req.Context().Value(tryItTarget{}).(*url.URL)
+
+			req.URL = target
+			req.Host = target.Host
+			for _, header := range siteHeaders {
+				req.Header.Del(header)
+			}
+			tracing.Inject(req)
+		},
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			environment, _ := req.Context().Value(tryItEnvironment{}).(string)
+			if replay != nil {
+				return replay.roundTrip(network.Transport(environment), req)
+			}
+			return network.Transport(environment).RoundTrip(req)
+		}),
+		ModifyResponse: func(res *http.Response) error {
+			res.Header.Del("Set-Cookie") // The API's cookies would be set for the documentation site
+
+			// The response is served from the documentation's origin, so it must not be able
+			// to run script there, such as by being opened directly or sniffed as HTML
+			res.Header.Set("Content-Security-Policy", "sandbox")
+			res.Header.Set("X-Content-Type-Options", "nosniff")
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, req *http.Request, err error) {
+			logger.Errorf(req, "Explorer request to %s failed: %s", req.URL, err)
+			if rc, ok := w.(*responseCapture); ok {
+				rc.failed = true
+			}
+			w.WriteHeader(http.StatusBadGateway)
+		},
+	}
+
+	r.PathPrefix(TryItPath + "/").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		target, ok := tryItURL(req)
+		if !ok {
+			http.Error(w, "Not an explorer target", http.StatusForbidden)
+			return
+		}
+
+		if err := sign(req, target); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var record *auditRecord
+		if audit != nil {
+			record, w = audit.start(w, req, target)
+		}
+
+		rc := &responseCapture{w, 0, false}
+		s := time.Now()
+
+		ctx := context.WithValue(req.Context(), tryItTarget{}, target)
+		ctx = context.WithValue(ctx, tryItEnvironment{}, req.Header.Get(environmentHeader))
+		proxy.ServeHTTP(rc, req.WithContext(ctx))
+
+		logger.Infof(req, "TRYIT %s %s (%d, %v)", req.Method, target, rc.statusCode, time.Since(s))
+		status := rc.statusCode
+		if rc.failed {
+			status = 0
+		}
+		metrics.ObserveProxy(TryItPath, status)
+		if record != nil {
+			audit.finish(req, record, status, time.Since(s))
+		}
+	})
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// tryItURL returns the URL that a request to TryItPath is for, and whether its
+// host is one that the explorer may reach.
+func tryItURL(req *http.Request) (*url.URL, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(req.URL.EscapedPath(), TryItPath+"/"), "/", 3)
+	if len(parts) < 2 || (parts[0] != "http" && parts[0] != "https") {
+		return nil, false
+	}
+
+	origin := parts[0] + "://" + parts[1]
+	if !allowedOrigins()[origin] {
+		logger.Debugf(req, "Explorer request to %s refused, as it is not a host of a specification", origin)
+		return nil, false
+	}
+
+	path := "/"
+	if len(parts) == 3 {
+		path += parts[2]
+	}
+	target, err := url.Parse(origin + path)
+	if err != nil {
+		return nil, false
+	}
+	target.RawQuery = req.URL.RawQuery
+	return target, true
+}
+
+// -----------------------------------------------------------------------------
+// TargetSpecifications returns the IDs of the specifications that a request to
+// TryItPath is for: the one the explorer names, if the target is one of its servers, or
+// else every specification that has the target as a server.
+func TargetSpecifications(req *http.Request) []string {
+	parts := strings.SplitN(strings.TrimPrefix(req.URL.EscapedPath(), TryItPath+"/"), "/", 3)
+	if len(parts) < 2 {
+		return nil
+	}
+	origin := parts[0] + "://" + parts[1]
+
+	var ids []string
+	for id, specification := range spec.APISuite {
+		for _, server := range specification.Servers {
+			if u, err := url.Parse(server.URL); err == nil && u.Scheme+"://"+u.Host == origin {
+				if id == req.Header.Get(specHeader) {
+					return []string{id}
+				}
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// -----------------------------------------------------------------------------
+// allowedOrigins are the schemes and hosts of the loaded specifications' servers,
+// and of the configured environments. Reloading the specifications may change them.
+func allowedOrigins() map[string]bool {
+	origins := make(map[string]bool)
+
+	for _, specification := range spec.APISuite {
+		for _, server := range specification.Servers {
+			if u, err := url.Parse(server.URL); err == nil {
+				origins[u.Scheme+"://"+u.Host] = true
+			}
+		}
+	}
+
+	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+	for _, environment := range cfg.Environments() {
+		if u, err := url.Parse(environment.Host); err == nil && len(u.Host) > 0 {
+			origins[u.Scheme+"://"+u.Host] = true
+		}
+	}
+	return origins
+}
+
+// Context keys of the URL that a request is forwarded to, and its environment
+type tryItTarget struct{}
+type tryItEnvironment struct{}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// -----------------------------------------------------------------------------
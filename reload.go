@@ -0,0 +1,354 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/gitrepo"
+	"github.com/dapperdox/dapperdox/handlers/feed"
+	"github.com/dapperdox/dapperdox/handlers/livereload"
+	"github.com/dapperdox/dapperdox/handlers/reload"
+	"github.com/dapperdox/dapperdox/handlers/specs"
+	"github.com/dapperdox/dapperdox/handlers/terms"
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/render/asset"
+	"github.com/dapperdox/dapperdox/spec"
+	"github.com/dapperdox/dapperdox/watch"
+	"github.com/gorilla/pat"
+)
+
+// siteHandler serves the current router, which is replaced wholesale when the site
+// is reloaded, and in part when one specification is. Requests hold a read lock, so a
+// reload waits for those in flight and requests wait for the reload, as the two share
+// the loaded specifications and assets. The handlers that wrap the site take its read
+// lock as render.SiteLock.
+type siteHandler struct {
+	sync.RWMutex
+	router *siteRouter
+}
+
+func (s *siteHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.RLock()
+	defer s.RUnlock()
+	s.router.ServeHTTP(w, req)
+}
+
+var site = &siteHandler{}
+
+// reloading is held by each reload, so that one loads at a time. The APISuite is only
+// changed by reloads, so a reload may read it while loading without locking the site.
+var reloading sync.Mutex
+
+// ---------------------------------------------------------------------------
+// siteRouter gives the pages of each specification a router of their own, so that
+// reloading a specification replaces its routes alone. The paths that a specification's
+// router does not serve fall through to the router of the rest of the site.
+type siteRouter struct {
+	site           *pat.Router
+	specifications map[string]*pat.Router // By specification ID
+}
+
+func (s *siteRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if router, ok := s.specifications[specificationOf(req.URL.Path)]; ok {
+		router.ServeHTTP(w, req)
+		return
+	}
+	s.site.ServeHTTP(w, req)
+}
+
+// newSiteRouter registers the handlers of the site on router, and those of each loaded
+// specification on a router of its own
+func newSiteRouter(router *pat.Router) (*siteRouter, error) {
+	if err := registerHandlers(router); err != nil {
+		return nil, err
+	}
+	s := &siteRouter{site: router, specifications: make(map[string]*pat.Router)}
+	for id, specification := range spec.APISuite {
+		r, err := s.specificationRouter(specification)
+		if err != nil {
+			return nil, err
+		}
+		s.specifications[id] = r
+	}
+	return s, nil
+}
+
+// specificationRouter registers the handlers of a specification on a router of its own
+func (s *siteRouter) specificationRouter(specification *spec.APISpecification) (*pat.Router, error) {
+	router := pat.New()
+	router.NotFoundHandler = s.site
+	if err := registerSpecification(router, specification); err != nil {
+		return nil, err
+	}
+	return router, nil
+}
+
+// specificationOf returns the ID of the specification that a path may be a page of: its
+// first segment, or the segment after the terms page prefix
+func specificationOf(path string) string {
+	if strings.HasPrefix(path, terms.PathPrefix) {
+		path = strings.TrimPrefix(path, terms.PathPrefix)
+	} else {
+		path = strings.TrimPrefix(path, "/")
+	}
+	return strings.SplitN(path, "/", 2)[0]
+}
+
+// ---------------------------------------------------------------------------
+// reloadSite loads the specifications, guides and templates afresh and swaps in a
+// new router. The old site continues to be served if the reload fails.
+func reloadSite() error {
+	if err := gitrepo.Sync(); err != nil {
+		logger.Errorf(nil, "Error updating git repository, reloading from the working copy as it is: %s", err)
+	}
+
+	reloading.Lock()
+	defer reloading.Unlock()
+
+	site.Lock()
+	defer site.Unlock()
+
+	logger.Infof(nil, "Reloading site")
+
+	previous, previousFailures := spec.APISuite, spec.LoadFailures
+	spec.APISuite = nil
+
+	if err := loadSpecifications(); err != nil {
+		logger.Errorf(nil, "Reload failed, continuing with the previous site: %s", err)
+		spec.APISuite, spec.LoadFailures = previous, previousFailures
+		return err
+	}
+
+	asset.Reset()
+
+	router, err := buildRouter()
+	if err != nil {
+		logger.Errorf(nil, "Reload failed, continuing with the previous site: %s", err)
+		spec.APISuite, spec.LoadFailures = previous, previousFailures
+		restoreSite()
+		return err
+	}
+	site.router = router
+
+	livereload.Notify()
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// reloadSpecification loads one specification afresh, while the site continues to be
+// served, and then swaps in a router with its pages. The other specifications, guides
+// and templates are kept as they are, and the old specification continues to be served
+// if the reload fails.
+func reloadSpecification(id string) error {
+	reloading.Lock()
+	defer reloading.Unlock()
+
+	logger.Infof(nil, "Reloading specification %s", id)
+
+	previous := spec.APISuite[id]
+
+	var specification *spec.APISpecification
+	err := serveSpecifications(func(specHost string) (err error) {
+		specification, err = spec.ReloadSpecification(id, specHost)
+		return err
+	})
+	if err != nil {
+		logger.Errorf(nil, "Reload of specification %s failed, continuing with the previous site: %s", id, err)
+		return err
+	}
+
+	site.Lock()
+	defer site.Unlock()
+
+	if err = spec.ReplaceSpecification(id, specification); err != nil {
+		logger.Errorf(nil, "Reload of specification %s failed, continuing with the previous site: %s", id, err)
+		return err
+	}
+	router, err := site.router.specificationRouter(specification)
+	if err != nil {
+		logger.Errorf(nil, "Reload of specification %s failed, continuing with the previous site: %s", id, err)
+		spec.ReplaceSpecification(specification.ID, previous)
+
+		// Registering the new one replaced the reference pages and guides of the previous one
+		if restored, err := site.router.specificationRouter(previous); err == nil {
+			site.router.specifications[id] = restored
+		} else {
+			logger.Errorf(nil, "Error restoring specification %s: %s", id, err)
+		}
+		return err
+	}
+	delete(site.router.specifications, id)
+	site.router.specifications[specification.ID] = router
+	feed.Record()
+
+	livereload.Notify()
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// buildRouter builds a router serving the loaded specifications
+func buildRouter() (*siteRouter, error) {
+	router := pat.New()
+	specs.Register(router)
+	return newSiteRouter(router)
+}
+
+// ---------------------------------------------------------------------------
+// restoreSite builds the router of the previous specifications again after a failed
+// reload, as registering the handlers of the new ones replaces the templates and guides
+// that the previous router renders. The previous router is kept if even that fails.
+func restoreSite() {
+	router, err := buildRouter()
+	if err != nil {
+		logger.Errorf(nil, "Error restoring the previous site: %s", err)
+		return
+	}
+	site.router = router
+}
+
+// ---------------------------------------------------------------------------
+// requestReload asks the server at site-url to reload the site, or one specification,
+// through its reload endpoint
+func requestReload(args []string) int {
+	if len(args) > 1 {
+		fmt.Fprintln(os.Stderr, "reload takes at most one specification ID: reload [spec ID]")
+		return 2
+	}
+	cfg, err := config.Get()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error configuring app: %s\n", err)
+		return 1
+	}
+	if len(cfg.ReloadToken) == 0 {
+		fmt.Fprintln(os.Stderr, "reload requires the reload-token that the server is configured with")
+		return 2
+	}
+
+	target := strings.TrimRight(cfg.SiteURL, "/") + reload.Path
+	if len(args) == 1 {
+		target += "/" + url.PathEscape(args[0])
+	}
+	req, err := http.NewRequest("POST", target, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error requesting reload: %s\n", err)
+		return 1
+	}
+	req.Header.Set("Authorization", "Bearer "+cfg.ReloadToken)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error requesting reload: %s\n", err)
+		return 1
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		fmt.Fprintf(os.Stderr, "reload request to %s failed: %s\n", target, resp.Status)
+		return 1
+	}
+	fmt.Printf("Reload requested from %s. The server logs whether it succeeds.\n", target)
+	return 0
+}
+
+// ---------------------------------------------------------------------------
+// recordExample adds an exchange recorded by the recording proxy to the examples of
+// the methods it matches. The site is locked, as it is served from the same methods.
+func recordExample(entry *spec.HAREntry) bool {
+	site.Lock()
+	defer site.Unlock()
+
+	return spec.RecordExample(entry, "recording proxy")
+}
+
+// ---------------------------------------------------------------------------
+// watchSite reloads the site whenever a specification, guide or template changes
+func watchSite() {
+	cfg, _ := config.Get()
+
+	dirs := []string{cfg.SpecDir, cfg.AssetsDir, cfg.ThemeDir, cfg.DefaultAssetsDir}
+	logger.Infof(nil, "Watching %v for changes", dirs)
+
+	watch.Dirs(dirs, time.Second, func() {
+		reloadSite()
+	})
+}
+
+// ---------------------------------------------------------------------------
+// reloadOnHangup re-reads the configuration and reloads the site whenever the process
+// receives SIGHUP, as is expected of a daemon. The listener is kept, so no connection
+// is dropped, and requests in flight complete first. Settings of the listener itself,
+// such as bind-addr and the TLS certificate, only take effect on restart.
+func reloadOnHangup() {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	for range hangup {
+		logger.Infof(nil, "Received SIGHUP, reloading configuration")
+		restore, err := reloadConfig()
+		if err != nil {
+			logger.Errorf(nil, "Error reloading configuration, continuing with the previous configuration: %s", err)
+			continue
+		}
+		if err := reloadSite(); err != nil {
+			logger.Errorf(nil, "Continuing with the previous configuration, as the site could not be reloaded with the new one")
+			reloading.Lock()
+			site.Lock()
+			restore()
+			restoreSite()
+			site.Unlock()
+			reloading.Unlock()
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// reloadConfig publishes the configuration afresh, returning a function that publishes
+// the previous one again
+func reloadConfig() (func(), error) {
+	site.Lock()
+	defer site.Unlock()
+
+	restore, err := config.Reload()
+	if err != nil {
+		return nil, err
+	}
+	previousLevel := logger.DefaultLevel
+
+	cfg, _ := config.Get()
+	level, err := logger.LevelFromString(cfg.LogLevel)
+	if err != nil {
+		logger.Errorf(nil, "Error setting log level, keeping the previous level: %s", err)
+		return restore, nil
+	}
+	logger.DefaultLevel = level
+
+	return func() {
+		restore()
+		logger.DefaultLevel = previousLevel
+	}, nil
+}
@@ -26,11 +26,13 @@ package asset
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"regexp"
 	//"github.com/davecgh/go-spew/spew"
 	"github.com/dapperdox/dapperdox/config"
 	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/tracing"
 	"github.com/shurcooL/github_flavored_markdown"
 	"io/ioutil"
 	"os"
@@ -56,6 +58,14 @@ func Asset(name string) ([]byte, error) {
 	return nil, fmt.Errorf("Asset %s not found", name)
 }
 
+// ---------------------------------------------------------------------------
+// Reset discards all compiled assets, so that they can be compiled afresh on reload
+func Reset() {
+	_bindata = map[string][]byte{}
+	_metadata = map[string]map[string]string{}
+	gfmReplace = nil
+}
+
 // ---------------------------------------------------------------------------
 func AssetNames() []string {
 	names := make([]string, 0, len(_bindata))
@@ -115,6 +125,9 @@ func Compile(dir string, prefix string) {
 
 	logger.Debugf(nil, "- Scanning directory %s", dir)
 
+	ctx, span := tracing.Start(context.Background(), "compile "+prefix)
+	defer span.End()
+
 	dir = filepath.ToSlash(dir)
 
 	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
@@ -168,14 +181,18 @@ func Compile(dir string, prefix string) {
 					os.Exit(1)
 				}
 
+				_, mdspan := tracing.Start(ctx, "markdown "+relative)
 				for i, heading := range headings {
 					buf = ProcessMarkdown([]byte(sections[i]))
 
 					relative = filepath.Join(mdname, heading, "overlay.tmpl")
 					storeTemplate(prefix, relative, guideReplacer.Replace(string(buf)), meta)
 				}
+				mdspan.End()
 			} else {
+				_, mdspan := tracing.Start(ctx, "markdown "+relative)
 				buf = ProcessMarkdown(buf) // Convert markdown into HTML
+				mdspan.End()
 
 				relative = mdname + ".tmpl"
 				storeTemplate(prefix, relative, guideReplacer.Replace(string(buf)), meta)
@@ -0,0 +1,72 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package render
+
+import (
+	"net/http"
+
+	"github.com/dapperdox/dapperdox/spec"
+)
+
+// Breadcrumb is one step of the trail from the specification list to the current
+// page. The last breadcrumb is the current page.
+type Breadcrumb struct {
+	Title string
+	URL   string
+}
+
+// ----------------------------------------------------------------------------------------
+// breadcrumbs derives the trail for a page from its template data: suite, then
+// specification, then API group, then method or resource.
+func breadcrumbs(req *http.Request, apiSpec *spec.APISpecification, m map[string]interface{}) []Breadcrumb {
+	var trail []Breadcrumb
+
+	if _, ok := m["MultipleSpecs"]; ok {
+		trail = append(trail, Breadcrumb{Title: "Specifications", URL: "/"})
+	}
+	if apiSpec == nil {
+		return trail
+	}
+
+	specPath := "/" + apiSpec.ID
+	trail = append(trail, Breadcrumb{Title: apiSpec.APIInfo.Title, URL: specPath + "/reference"})
+
+	version := ""
+	if v, ok := m["Version"].(string); ok && len(v) > 0 && v != "latest" {
+		version = "?v=" + v
+	}
+
+	if api, ok := m["API"].(spec.APIGroup); ok {
+		apiPath := specPath + "/reference/" + api.ID
+		trail = append(trail, Breadcrumb{Title: api.Name, URL: apiPath + version})
+
+		if method, ok := m["Method"].(spec.Method); ok {
+			trail = append(trail, Breadcrumb{Title: method.Name, URL: apiPath + "/" + method.ID + version})
+		}
+	}
+	if resource, ok := m["Resource"].(*spec.Resource); ok {
+		trail = append(trail, Breadcrumb{Title: resource.Title, URL: specPath + "/resources/" + resource.ID + version})
+	}
+	if _, ok := m["Guide"]; ok {
+		if title, ok := m["Title"].(string); ok {
+			trail = append(trail, Breadcrumb{Title: title, URL: req.URL.Path})
+		}
+	}
+
+	return trail
+}
@@ -0,0 +1,122 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package render
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/spec"
+)
+
+// maxSuggestions is the number of pages an error page suggests in place of the one
+// that was not found.
+const maxSuggestions = 5
+
+// SiteLock is the read lock of the site, which a reload holds for writing while it
+// replaces the specifications and templates. Pages rendered by the handlers that wrap
+// the site, rather than by its routes, must hold it. main gives the site's own lock.
+var SiteLock = new(sync.RWMutex).RLocker()
+
+// ----------------------------------------------------------------------------------------
+// Error renders an error page within the normal page chrome. The template is the
+// first of errors/<page> and error that exists, so a theme or assets directory can
+// give any error its own page, e.g. errors/404 or errors/spec-load-failed.
+//
+// The page is given the error message and code, Suggestions - the pages most like
+// the one requested - LoadFailures, the specifications that could not be loaded and
+// were skipped, and RequestID, for the reader to quote when reporting the error.
+func Error(w http.ResponseWriter, req *http.Request, status int, page string, message string) {
+	m := DefaultVars(req, nil, Vars{"error": message, "code": status})
+	m["Suggestions"] = suggestions(req.URL.Path)
+	m["LoadFailures"] = spec.LoadFailures
+	m["RequestID"] = req.Header.Get(logger.RequestIDHeader)
+
+	name := "error"
+	if TemplateLookup("errors/"+page) != nil {
+		name = "errors/" + page
+	}
+	HTML(w, status, name, m)
+}
+
+// ----------------------------------------------------------------------------------------
+// LockedError renders an error page as Error does, holding SiteLock, for the handlers
+// that wrap the site and so do not hold it already.
+func LockedError(w http.ResponseWriter, req *http.Request, status int, page string, message string) {
+	SiteLock.Lock()
+	defer SiteLock.Unlock()
+
+	Error(w, req, status, page, message)
+}
+
+// ----------------------------------------------------------------------------------------
+// NotFound renders the not found page. If specifications failed to load and were
+// skipped the page may well have been in one of them, so the spec-load-failed page is
+// shown instead, if the theme has one.
+func NotFound(w http.ResponseWriter, req *http.Request) {
+	page := strconv.Itoa(http.StatusNotFound)
+	if len(spec.LoadFailures) > 0 && TemplateLookup("errors/spec-load-failed") != nil {
+		page = "spec-load-failed"
+	}
+	Error(w, req, http.StatusNotFound, page, "Page not found")
+}
+
+// ----------------------------------------------------------------------------------------
+// suggestions scores the entries of the search index of each specification by the
+// number of words they share with the requested path.
+func suggestions(path string) []Breadcrumb {
+	words := spec.SearchWords(path)
+	if len(words) == 0 {
+		return nil
+	}
+
+	type candidate struct {
+		Breadcrumb
+		score int
+	}
+	var candidates []candidate
+
+	for _, specification := range spec.APISuite {
+		for i := range specification.SearchIndex {
+			entry := &specification.SearchIndex[i]
+			if score := entry.Score(words); score > 0 {
+				candidates = append(candidates, candidate{Breadcrumb{Title: entry.Title, URL: entry.Href}, score})
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].URL < candidates[j].URL
+	})
+
+	var found []Breadcrumb
+	for _, c := range candidates {
+		if len(found) == maxSuggestions {
+			break
+		}
+		found = append(found, c.Breadcrumb)
+	}
+	return found
+}
+
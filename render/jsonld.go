@@ -0,0 +1,90 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package render
+
+import (
+	"encoding/json"
+	"html"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/spec"
+)
+
+// ----------------------------------------------------------------------------------------
+// structuredData describes a specification summary or method page as schema.org JSON-LD,
+// for search engines: the specification as a WebAPI, and a method as an APIReference
+// that is part of it. Other pages have none.
+func structuredData(req *http.Request, apiSpec *spec.APISpecification, m map[string]interface{}) template.JS {
+	cfg, _ := config.Get()
+	site := strings.TrimSuffix(cfg.SiteURL, "/")
+	specPath := site + "/" + apiSpec.ID
+
+	api := map[string]interface{}{
+		"@type": "WebAPI",
+		"name":  apiSpec.APIInfo.Title,
+		"url":   specPath + "/reference",
+	}
+	if len(apiSpec.APIInfo.Excerpt) > 0 {
+		api["description"] = apiSpec.APIInfo.Excerpt
+	}
+
+	var data map[string]interface{}
+	if method, ok := m["Method"].(spec.Method); ok {
+		data = map[string]interface{}{
+			"@type":    "APIReference",
+			"headline": method.Name,
+			"name":     method.Name,
+			"url":      site + req.URL.Path,
+			"isPartOf": api,
+		}
+		if description := html.UnescapeString(spec.Excerpt(method.Description)); len(description) > 0 {
+			data["description"] = description
+		}
+	} else if _, ok := m["SpecificationSummary"]; ok {
+		data = api
+		data["documentation"] = specPath + "/reference"
+		if contact := apiSpec.APIInfo.Contact; contact != nil && len(contact.Name) > 0 {
+			provider := map[string]interface{}{"@type": "Organization", "name": contact.Name}
+			if len(contact.URL) > 0 {
+				provider["url"] = contact.URL
+			}
+			data["provider"] = provider
+		}
+		if len(apiSpec.APIInfo.TermsOfService) > 0 {
+			data["termsOfService"] = apiSpec.APIInfo.TermsOfService
+		}
+		if license := apiSpec.APIInfo.License; license != nil && len(license.URL) > 0 {
+			data["license"] = license.URL
+		}
+	} else {
+		return ""
+	}
+	data["@context"] = "https://schema.org"
+
+	// Marshalled JSON escapes <, > and &, so it can not close the script element it is in
+	b, err := json.Marshal(data)
+	if err != nil {
+		logger.Errorf(req, "Error marshalling structured data: %s", err)
+		return ""
+	}
+	return template.JS(b)
+}
@@ -20,17 +20,23 @@ package render
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"html/template"
 	"net/http"
 	"strings"
+	"sync"
 
 	//"github.com/davecgh/go-spew/spew"
 	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/handlers/livereload"
+	"github.com/dapperdox/dapperdox/i18n"
 	"github.com/dapperdox/dapperdox/logger"
 	"github.com/dapperdox/dapperdox/navigation"
 	"github.com/dapperdox/dapperdox/render/asset"
 	"github.com/dapperdox/dapperdox/spec"
+	"github.com/dapperdox/dapperdox/tracing"
 	"github.com/ian-kent/htmlform"
+	"github.com/justinas/nosurf"
 	"github.com/unrolled/render"
 )
 
@@ -41,17 +47,29 @@ var Render *render.Render
 type GuideType []*navigation.NavigationNode
 type overlayPathList []string
 
-var guides = map[string]GuideType{} // Guides are per specification-id, or 'top-level'
+var guides = map[string]map[string]GuideType{} // Guides are per specification-id, or 'top-level', then per content locale
 
 // Vars is a map of variables
 type Vars map[string]interface{}
 
 var counter int
 
+// Locale -> renderer whose t function translates into that locale. Render is
+// the renderer of the configured locale.
+var renderers = map[string]*render.Render{}
+var renderersLock sync.Mutex
+
 // ----------------------------------------------------------------------------------------
 
 func Register() {
+	guides = map[string]map[string]GuideType{}
+
+	renderersLock.Lock()
+	renderers = map[string]*render.Render{}
+	renderersLock.Unlock()
+
 	Render = New()
+	i18n.Load()
 }
 
 // ----------------------------------------------------------------------------------------
@@ -67,10 +85,13 @@ func New() *render.Render {
 	if len(cfg.AssetsDir) != 0 {
 		asset.Compile(cfg.AssetsDir+"/templates", "assets/templates")
 		asset.Compile(cfg.AssetsDir+"/static", "assets/static")
+		asset.Compile(cfg.AssetsDir+"/i18n", "assets/i18n")
 		asset.Compile(cfg.AssetsDir+"/themes/"+cfg.Theme, "assets")
 		compileSections(cfg.AssetsDir)
 	}
 
+	compileSpecThemes(cfg.DefaultAssetsDir, cfg.ThemeDir)
+
 	// Import custom theme from custom directory (if defined)
 	if len(cfg.Theme) != 0 {
 		dir := cfg.DefaultAssetsDir + "/themes"
@@ -90,6 +111,31 @@ func New() *render.Render {
 	// Fallback to local static directory
 	asset.Compile(cfg.DefaultAssetsDir+"/static", "assets/static")
 
+	return newRender(cfg.Locale)
+}
+
+// ----------------------------------------------------------------------------------------
+// forLocale returns the renderer for a locale, creating it the first time it is needed.
+func forLocale(locale string) *render.Render {
+	if len(locale) == 0 || locale == i18n.Locale(nil) {
+		return Render
+	}
+
+	renderersLock.Lock()
+	defer renderersLock.Unlock()
+
+	r, ok := renderers[locale]
+	if !ok {
+		logger.Debugf(nil, "creating renderer for locale %s", locale)
+		r = newRender(locale)
+		renderers[locale] = r
+	}
+	return r
+}
+
+// ----------------------------------------------------------------------------------------
+
+func newRender(locale string) *render.Render {
 	return render.New(render.Options{
 		Asset:      asset.Asset,
 		AssetNames: asset.AssetNames,
@@ -112,6 +158,7 @@ func New() *render.Render {
 			"haveTemplate":  func(n string) *template.Template { return TemplateLookup(n) },
 			"overlay":       func(n string, d ...interface{}) template.HTML { return overlay(n, d) },
 			"getAssetPaths": func(s string, d ...interface{}) []string { return getAssetPaths(s, d) },
+			"t":             func(s string, a ...interface{}) string { return i18n.Translate(locale, s, a...) },
 		}},
 	})
 }
@@ -133,6 +180,25 @@ func compileSectionPart(assetsDir string, spec *spec.APISpecification, part stri
 	asset.Compile(assetsDir+"/sections/"+stem, prefix+stem)
 }
 
+// ----------------------------------------------------------------------------------------
+// compileSpecThemes imports the theme variant of each specification that has one. Its
+// templates take precedence over the site theme for that specification's pages, and its
+// static content is served under /themes/<specification ID>/.
+func compileSpecThemes(defaultAssetsDir string, themeDir string) {
+	dir := defaultAssetsDir + "/themes"
+	if len(themeDir) != 0 {
+		dir = themeDir
+	}
+	for _, specification := range spec.APISuite {
+		if len(specification.Theme) == 0 {
+			continue
+		}
+		logger.Debugf(nil, "- Theme '%s' for specification '%s'", specification.Theme, specification.APIInfo.Title)
+		asset.Compile(dir+"/"+specification.Theme+"/templates", "assets/templates/themes/"+specification.ID)
+		asset.Compile(dir+"/"+specification.Theme+"/static", "assets/static/themes/"+specification.ID)
+	}
+}
+
 // ----------------------------------------------------------------------------------------
 type HTMLWriter struct {
 	h *bufio.Writer
@@ -178,7 +244,8 @@ func overlay(name string, data []interface{}) template.HTML { // TODO Will be sp
 		logger.Tracef(nil, "Applying overlay '%s'\n", overlay)
 		writer := HTMLWriter{h: bufio.NewWriter(&b)}
 
-		r := New()
+		locale, _ := datamap["Locale"].(string)
+		r := forLocale(locale)
 		// data is a single item array (though I've not figured out why yet!)
 		r.HTML(writer, http.StatusOK, overlay, data[0], render.HTMLOptions{Layout: ""})
 		writer.Flush()
@@ -217,13 +284,47 @@ func overlayPaths(name string, datamap map[string]interface{}) []string {
 		getSpecificationSummaryPaths(name, &overlayName, datamap)
 	}
 
-	return overlayName
+	return localisePaths(overlayName, datamap)
 }
 
+// ----------------------------------------------------------------------------------------
+// HTMLOptions is an alias to github.com/unrolled/render.HTMLOptions, so that handlers can
+// choose a layout other than the default
+type HTMLOptions = render.HTMLOptions
+
 // ----------------------------------------------------------------------------------------
 // HTML is an alias to github.com/unrolled/render.Render.HTML
 func HTML(w http.ResponseWriter, status int, name string, binding interface{}, htmlOpt ...render.HTMLOptions) {
-	Render.HTML(w, status, name, binding, htmlOpt...)
+	ctx, name, htmlOpt, locale := prepare(name, binding, htmlOpt)
+
+	_, span := tracing.Start(ctx, "render "+name)
+	defer span.End()
+
+	forLocale(locale).HTML(w, status, name, binding, htmlOpt...)
+}
+
+// prepare takes the context and locale of a page from its binding, and chooses the
+// specification's theme variant of its template and layout, if it has one
+func prepare(name string, binding interface{}, htmlOpt []render.HTMLOptions) (context.Context, string, []render.HTMLOptions, string) {
+	ctx := context.Background()
+	locale := ""
+	if m, ok := binding.(map[string]interface{}); ok {
+		if req, ok := m["Request"].(*http.Request); ok {
+			ctx = req.Context()
+		}
+		// Prefer the specification's theme variant, if it has one
+		if themePath, ok := m["ThemePath"].(string); ok {
+			stem := strings.TrimPrefix(themePath, "/") + "/"
+			if TemplateLookup(stem+name) != nil {
+				name = stem + name
+			}
+			if len(htmlOpt) == 0 && TemplateLookup(stem+"layout") != nil {
+				htmlOpt = []render.HTMLOptions{{Layout: stem + "layout"}}
+			}
+		}
+		locale, _ = m["Locale"].(string)
+	}
+	return ctx, name, htmlOpt, locale
 }
 
 // ----------------------------------------------------------------------------------------
@@ -242,6 +343,16 @@ func DefaultVars(req *http.Request, apiSpec *spec.APISpecification, m Vars) map[
 	cfg, _ := config.Get()
 	m["Config"] = cfg
 	m["APISuite"] = spec.APISuite
+	m["Request"] = req
+	m["CSRFToken"] = nosurf.Token(req)
+	m["Environments"] = cfg.Environments()
+	m["Locale"] = i18n.Locale(req)
+	m["Locales"] = i18n.Options()
+	m["LocaleCookie"] = i18n.CookieName
+	m["Navigation"] = navigationOptions(cfg.NavExpanded, cfg.NavMaxDepth, cfg.NavResources, !cfg.NavFixed)
+	if cfg.Watch {
+		m["LiveReload"] = livereload.Path
+	}
 
 	// If we have a multiple specifications or are forcing a parent "root" page for the single specification
 	// then set MultipleSpecs to true to enable navigation back to the root page.
@@ -250,33 +361,115 @@ func DefaultVars(req *http.Request, apiSpec *spec.APISpecification, m Vars) map[
 	}
 
 	if apiSpec == nil {
-		m["NavigationGuides"] = guides[""] // Global guides
+		m["NavigationLinks"] = cfg.NavigationLinks("")
+		m["NavigationGuides"] = localeGuides("", m["Locale"].(string)) // Global guides
 		m["SpecPath"] = ""
+		m["Breadcrumbs"] = breadcrumbs(req, nil, m)
 
 		return m
 	}
 
 	// Per specification defaults
-	m["NavigationGuides"] = guides[apiSpec.ID]
+	m["NavigationLinks"] = cfg.NavigationLinks(apiSpec.ID)
+	m["NavigationGuides"] = localeGuides(apiSpec.ID, m["Locale"].(string))
 
 	m["ID"] = apiSpec.ID
 	m["SpecPath"] = "/" + apiSpec.ID
 	m["APIs"] = apiSpec.APIs
+	m["TagGroups"] = apiSpec.TagGroups
 	m["APIVersions"] = apiSpec.APIVersions
+	m["SpecVersions"] = apiSpec.OrderedVersions
 	m["Resources"] = apiSpec.ResourceList
+	m["NavigationResources"] = apiSpec.NavigationResources
+	if cfg.NavSegment > 0 && apiSpec.MethodCount() > cfg.NavSegment {
+		// Every page would otherwise carry the methods of every API group
+		nav := m["Navigation"].(navigation.Options)
+		nav.Segmented = true
+		if api, ok := m["API"].(spec.APIGroup); ok {
+			nav.Current = api.ID
+		}
+		m["Navigation"] = nav
+	}
 	m["Info"] = apiSpec.APIInfo
 	m["SpecURL"] = apiSpec.URL
+	m["Servers"] = apiSpec.Servers
+	m["Branding"] = apiSpec.Branding
+	m["RateLimited"] = apiSpec.RateLimited()
+	m["SharedErrors"] = len(apiSpec.Errors) > 0
+	m["DataModels"] = len(apiSpec.Models) > 0
+	m["Statistics"] = cfg.SpecStats && len(apiSpec.APIs) > 0
+	m["Search"] = len(apiSpec.SearchIndex) > 0
+	if len(apiSpec.Theme) > 0 {
+		m["ThemePath"] = "/themes/" + apiSpec.ID
+	}
+	m["Breadcrumbs"] = breadcrumbs(req, apiSpec, m)
+	m["StructuredData"] = structuredData(req, apiSpec, m)
 
 	return m
 }
 
 // ----------------------------------------------------------------------------------------
-func SetGuidesNavigation(apiSpec *spec.APISpecification, guidesnav *[]*navigation.NavigationNode) {
+
+func navigationOptions(expanded []string, maxDepth int, resources bool, collapsible bool) navigation.Options {
+	o := navigation.Options{
+		Expanded:    make(map[string]bool),
+		MaxDepth:    maxDepth,
+		Resources:   resources,
+		Collapsible: collapsible,
+		ExpandAll:   !collapsible,
+	}
+	for _, id := range expanded {
+		if id == "*" {
+			o.ExpandAll = true
+		}
+		o.Expanded[id] = true
+	}
+	return o
+}
+
+// ----------------------------------------------------------------------------------------
+// SetGuidesNavigation registers the guides navigation of a specification, or of the top
+// level if apiSpec is nil, for a content locale ("" for the default content).
+func SetGuidesNavigation(apiSpec *spec.APISpecification, locale string, guidesnav *[]*navigation.NavigationNode) {
 	id := ""
 	if apiSpec != nil {
 		id = apiSpec.ID
 	}
-	guides[id] = *guidesnav
+	if guides[id] == nil {
+		guides[id] = map[string]GuideType{}
+	}
+	guides[id][locale] = *guidesnav
+}
+
+// ----------------------------------------------------------------------------------------
+
+func localeGuides(id string, locale string) GuideType {
+	if nav, ok := guides[id][i18n.ContentLocale(locale)]; ok {
+		return nav
+	}
+	return guides[id][""]
+}
+
+// ----------------------------------------------------------------------------------------
+// localisePaths puts the content locale's variant of each overlay path ahead of the
+// paths, so that a locale's overlays in templates/<locale> take precedence.
+func localisePaths(paths []string, datamap map[string]interface{}) []string {
+	locale, _ := datamap["Locale"].(string)
+	locale = i18n.ContentLocale(locale)
+	if len(locale) == 0 {
+		return paths
+	}
+
+	localised := make([]string, 0, 2*len(paths))
+	for _, path := range paths {
+		if i := strings.Index(path, "/templates/"); i >= 0 {
+			i += len("/templates/")
+			localised = append(localised, path[:i]+locale+"/"+path[i:])
+		} else {
+			localised = append(localised, locale+"/"+path)
+		}
+	}
+	return append(localised, paths...)
 }
 
 // ----------------------------------------------------------------------------------------
@@ -290,24 +483,24 @@ func getAssetPaths(name string, data []interface{}) []string {
 		if _, ok := datamap["Methods"]; ok {
 			// API-group summary page - Shows operations in a group
 			getAPIAssetPaths("", &paths, datamap)
-			return paths
+			return localisePaths(paths, datamap)
 		}
 	}
 	if _, ok := datamap["Method"]; ok {
 		getMethodAssetPaths("", &paths, datamap) // Method page
-		return paths
+		return localisePaths(paths, datamap)
 	}
 	if _, ok := datamap["Resource"]; ok {
 		getResourceAssetPaths("", &paths, datamap) // Resource page
-		return paths
+		return localisePaths(paths, datamap)
 	}
 	if _, ok := datamap["SpecificationList"]; ok {
 		getSpecificationListPaths("", &paths, datamap) // Specification List page
-		return paths
+		return localisePaths(paths, datamap)
 	}
 	if _, ok := datamap["SpecificationSummary"]; ok {
 		getSpecificationSummaryPaths("", &paths, datamap) // Specification List page
-		return paths
+		return localisePaths(paths, datamap)
 	}
 
 	return nil
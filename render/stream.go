@@ -0,0 +1,103 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package render
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/tracing"
+	"github.com/unrolled/render"
+)
+
+// Pages such as an API group with hundreds of methods take a while to render, and
+// github.com/unrolled/render renders the whole page in memory before writing any of it.
+// StreamHTML writes the layout up to the content straight away, so that the browser
+// can fetch styles and scripts, and then the content as it is rendered.
+
+// streamMarker is what the "streamed" template renders, marking where the content
+// goes in the layout
+var streamMarker = []byte("<dapperdox-stream></dapperdox-stream>")
+
+// streamFlushSize is how much of the content is buffered before it is flushed to the
+// client
+const streamFlushSize = 32 << 10
+
+// ----------------------------------------------------------------------------------------
+// StreamHTML renders a page as HTML does, flushing it to the client as it goes. It falls
+// back to HTML if the response cannot be flushed, or the layout has no yield.
+func StreamHTML(w http.ResponseWriter, status int, name string, binding interface{}, htmlOpt ...render.HTMLOptions) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		HTML(w, status, name, binding, htmlOpt...)
+		return
+	}
+	ctx, themed, opts, locale := prepare(name, binding, htmlOpt)
+
+	r := forLocale(locale)
+	content := r.TemplateLookup(themed)
+	if content == nil || r.TemplateLookup("streamed") == nil {
+		HTML(w, status, name, binding, htmlOpt...)
+		return
+	}
+
+	// The layout is rendered around the streamed template, and split where it yields
+	var layout bytes.Buffer
+	writer := HTMLWriter{h: bufio.NewWriter(&layout)}
+	r.HTML(writer, status, "streamed", binding, opts...)
+	writer.Flush()
+
+	at := bytes.Index(layout.Bytes(), streamMarker)
+	if at < 0 {
+		HTML(w, status, name, binding, htmlOpt...)
+		return
+	}
+	head, tail := layout.Bytes()[:at], layout.Bytes()[at+len(streamMarker):]
+
+	_, span := tracing.Start(ctx, "render "+themed)
+	defer span.End()
+
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	w.WriteHeader(status)
+	w.Write(head)
+	flusher.Flush()
+
+	out := bufio.NewWriterSize(flushWriter{w, flusher}, streamFlushSize)
+	if err := content.Execute(out, binding); err != nil {
+		// The status has been sent, so all that can be done is to stop
+		logger.Errorf(nil, "Error streaming template %s: %s", themed, err)
+		out.Flush()
+		return
+	}
+	out.Write(tail)
+	out.Flush()
+}
+
+// flushWriter flushes everything written to it through to the client
+type flushWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	f.flusher.Flush()
+	return n, err
+}
@@ -0,0 +1,177 @@
+//go:build windows
+// +build windows
+
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/logger"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+const serviceName = "DapperDox"
+
+func init() {
+	commands = append(commands,
+		command{"install-service", "install-service [flags]", "Install DapperDox as a Windows service, started with the given flags. Paths must be absolute.", installService},
+		command{"uninstall-service", "uninstall-service", "Remove the DapperDox Windows service.", uninstallService},
+		command{"run-service", "run-service [flags]", "Run as a Windows service. This is used by the service manager.", runService},
+	)
+}
+
+// ---------------------------------------------------------------------------
+// installService registers the service to start automatically, passing on the
+// flags it was given. Services start in the system directory, so relative paths
+// given as flags would not resolve.
+func installService(args []string) int {
+	exe, err := os.Executable()
+	if err != nil {
+		logger.Errorf(nil, "cannot find the DapperDox executable: %s", err)
+		return 1
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		logger.Errorf(nil, "cannot connect to the service manager: %s", err)
+		return 1
+	}
+	defer m.Disconnect()
+
+	s, err := m.CreateService(serviceName, exe, mgr.Config{
+		DisplayName: "DapperDox",
+		Description: "Serves API documentation.",
+		StartType:   mgr.StartAutomatic,
+	}, append([]string{"run-service"}, os.Args[1:]...)...)
+	if err != nil {
+		logger.Errorf(nil, "cannot create the %s service: %s", serviceName, err)
+		return 1
+	}
+	defer s.Close()
+
+	if err = eventlog.InstallAsEventCreate(serviceName, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		s.Delete()
+		logger.Errorf(nil, "cannot register the %s event source: %s", serviceName, err)
+		return 1
+	}
+
+	fmt.Printf("Installed the %s service\n", serviceName)
+	return 0
+}
+
+// ---------------------------------------------------------------------------
+
+func uninstallService(args []string) int {
+	m, err := mgr.Connect()
+	if err != nil {
+		logger.Errorf(nil, "cannot connect to the service manager: %s", err)
+		return 1
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		logger.Errorf(nil, "the %s service is not installed: %s", serviceName, err)
+		return 1
+	}
+	defer s.Close()
+
+	if err = s.Delete(); err != nil {
+		logger.Errorf(nil, "cannot remove the %s service: %s", serviceName, err)
+		return 1
+	}
+	if err = eventlog.Remove(serviceName); err != nil {
+		logger.Warnf(nil, "cannot remove the %s event source: %s", serviceName, err)
+	}
+
+	fmt.Printf("Removed the %s service\n", serviceName)
+	return 0
+}
+
+// ---------------------------------------------------------------------------
+// runService serves the documentation under the service manager, writing log
+// messages to the Windows event log since a service has no console.
+func runService(args []string) int {
+	elog, err := eventlog.Open(serviceName)
+	if err != nil {
+		logger.Errorf(nil, "cannot open the %s event log: %s", serviceName, err)
+		return 1
+	}
+	defer elog.Close()
+
+	logger.Output = func(level logger.Level, message string) {
+		switch level {
+		case logger.Error:
+			elog.Error(1, message)
+		case logger.Warn:
+			elog.Warning(1, message)
+		default:
+			elog.Info(1, message)
+		}
+	}
+	log.SetFlags(0)
+	log.SetOutput(eventLogWriter{elog})
+
+	if err = svc.Run(serviceName, &service{}); err != nil {
+		logger.Errorf(nil, "error running the %s service: %s", serviceName, err)
+		return 1
+	}
+	return 0
+}
+
+// eventLogWriter sends messages written with the standard log package, such as
+// the startup banner, to the event log.
+type eventLogWriter struct {
+	elog *eventlog.Log
+}
+
+func (w eventLogWriter) Write(p []byte) (int, error) {
+	return len(p), w.elog.Info(1, strings.TrimSuffix(string(p), "\n"))
+}
+
+// ---------------------------------------------------------------------------
+
+type service struct{}
+
+// Execute starts the server and waits for the service manager to stop it.
+func (s *service) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+
+	go serve()
+
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for req := range requests {
+		switch req.Cmd {
+		case svc.Interrogate:
+			status <- req.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			logger.Infof(nil, "%s service stopping", serviceName)
+			status <- svc.Status{State: svc.StopPending}
+			return false, 0
+		}
+	}
+	return false, 0
+}
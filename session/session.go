@@ -0,0 +1,150 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package session
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Sessions hold server side state for a browser, such as OAuth2 tokens acquired for
+// the API explorer, so that it need not be kept in the browser. A browser is given
+// an opaque, HTTP only cookie naming its session. Sessions are held in memory, and
+// expire after IdleTimeout without use.
+
+// CookieName is the cookie that holds the session ID
+const CookieName = "dapperdox_session"
+
+// IdleTimeout is how long a session is kept without being used
+const IdleTimeout = 8 * time.Hour
+
+// Session is the state of one browser
+type Session struct {
+	sync.Mutex
+	id       string
+	values   map[string]interface{}
+	lastUsed time.Time
+}
+
+var sessions = map[string]*Session{}
+var lock sync.Mutex
+var lastSweep time.Time
+
+// ---------------------------------------------------------------------------
+// Get returns the session of the browser making the request, starting a new one if
+// it has none, or its session has expired.
+func Get(w http.ResponseWriter, req *http.Request) *Session {
+	if s := Find(req); s != nil {
+		return s
+	}
+
+	s := &Session{
+		id:       NewID(),
+		values:   make(map[string]interface{}),
+		lastUsed: time.Now(),
+	}
+
+	lock.Lock()
+	sessions[s.id] = s
+	lock.Unlock()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    s.id,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   req.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return s
+}
+
+// ---------------------------------------------------------------------------
+// Find returns the session of the browser making the request, or nil if it has none.
+func Find(req *http.Request) *Session {
+	cookie, err := req.Cookie(CookieName)
+	if err != nil {
+		return nil
+	}
+
+	now := time.Now()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if now.Sub(lastSweep) > time.Minute {
+		sweep(now)
+	}
+
+	s, ok := sessions[cookie.Value]
+	if !ok {
+		return nil
+	}
+	s.Lock()
+	s.lastUsed = now
+	s.Unlock()
+	return s
+}
+
+// ---------------------------------------------------------------------------
+// sweep removes expired sessions. The caller must hold lock.
+func sweep(now time.Time) {
+	lastSweep = now
+	for id, s := range sessions {
+		s.Lock()
+		expired := now.Sub(s.lastUsed) > IdleTimeout
+		s.Unlock()
+		if expired {
+			delete(sessions, id)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Value returns a session value, or nil if it is not set.
+func (s *Session) Value(key string) interface{} {
+	s.Lock()
+	defer s.Unlock()
+	return s.values[key]
+}
+
+// ---------------------------------------------------------------------------
+// SetValue sets a session value. A nil value deletes it.
+func (s *Session) SetValue(key string, value interface{}) {
+	s.Lock()
+	defer s.Unlock()
+	if value == nil {
+		delete(s.values, key)
+		return
+	}
+	s.values[key] = value
+}
+
+// ---------------------------------------------------------------------------
+// NewID returns a random, URL safe identifier, suitable for session IDs and OAuth2
+// state and code verifiers.
+func NewID() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err) // The system random number generator is broken
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
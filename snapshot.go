@@ -0,0 +1,228 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/gorilla/pat"
+)
+
+// Files that differ every time the site is rendered, and so are left out of snapshots.
+// Feeds are dated when the specifications are loaded.
+var snapshotSkip = []string{"changes.atom", "changes.rss"}
+
+// snapshotContext is the number of lines either side of the first difference in a
+// file that are shown
+const snapshotContext = 2
+
+// ---------------------------------------------------------------------------
+// snapshotSite renders every page, as the build command does, and compares them with
+// a baseline snapshot, reporting the files that were added, removed or changed. This
+// catches unintended changes from theme or specification changes, such as in CI. With
+// -snapshot-update the baseline is replaced by the pages rendered instead.
+func snapshotSite(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "snapshot requires a baseline directory")
+		return 2
+	}
+	baseline := args[0]
+
+	cfg, err := config.Get()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error configuring app: %s\n", err)
+		return 1
+	}
+	if cfg.ExampleRandom && cfg.ExampleValues {
+		fmt.Fprintln(os.Stderr, "warning: example values vary between runs with -example-random")
+	}
+
+	if err = loadSpecifications(); err != nil {
+		fmt.Fprintf(os.Stderr, "Load specification error: %s\n", err)
+		return 1
+	}
+
+	router, err := newSiteRouter(pat.New())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error registering handlers: %s\n", err)
+		return 1
+	}
+
+	rendered := map[string][]byte{}
+	_, failures := crawlSite(router, func(path, file string, body []byte) error {
+		for _, suffix := range snapshotSkip {
+			if strings.HasSuffix(file, suffix) {
+				return nil
+			}
+		}
+		rendered[filepath.ToSlash(file)] = body
+		return nil
+	})
+
+	if cfg.SnapshotUpdate {
+		if err = updateSnapshot(baseline, rendered); err != nil {
+			fmt.Fprintf(os.Stderr, "error updating snapshot: %s\n", err)
+			return 1
+		}
+		fmt.Printf("\nWrote %d files to the snapshot in %s\n", len(rendered), baseline)
+	} else {
+		changed, err := compareSnapshot(baseline, rendered)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading snapshot: %s\n", err)
+			return 1
+		}
+		if changed > 0 {
+			fmt.Printf("\n%d file(s) differ from the snapshot in %s. Run with -snapshot-update to accept them.\n", changed, baseline)
+			return 1
+		}
+		fmt.Printf("\n%d files match the snapshot in %s\n", len(rendered), baseline)
+	}
+
+	if failures > 0 {
+		fmt.Printf("%d page(s) failed\n", failures)
+		return 1
+	}
+	return 0
+}
+
+// ---------------------------------------------------------------------------
+// snapshotFiles lists the files of a snapshot, relative to its directory and with
+// forward slashes, as rendered files are named.
+func snapshotFiles(dir string) (map[string]bool, error) {
+	files := map[string]bool{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err == nil {
+			files[filepath.ToSlash(rel)] = true
+		}
+		return err
+	})
+	if os.IsNotExist(err) {
+		return files, nil
+	}
+	return files, err
+}
+
+// ---------------------------------------------------------------------------
+
+func compareSnapshot(dir string, rendered map[string][]byte) (int, error) {
+	existing, err := snapshotFiles(dir)
+	if err != nil {
+		return 0, err
+	}
+	if len(existing) == 0 {
+		return 0, fmt.Errorf("%s holds no snapshot. Create one with -snapshot-update", dir)
+	}
+
+	var names []string
+	for name := range rendered {
+		names = append(names, name)
+	}
+	for name := range existing {
+		if _, ok := rendered[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changed := 0
+	for _, name := range names {
+		body, ok := rendered[name]
+		switch {
+		case !ok:
+			fmt.Printf("  REMOVED  %s\n", name)
+			changed++
+		case !existing[name]:
+			fmt.Printf("  ADDED    %s\n", name)
+			changed++
+		default:
+			old, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+			if err != nil {
+				return changed, err
+			}
+			if !bytes.Equal(old, body) {
+				fmt.Printf("  CHANGED  %s\n", name)
+				printDifference(old, body)
+				changed++
+			}
+		}
+	}
+	return changed, nil
+}
+
+// ---------------------------------------------------------------------------
+// printDifference shows the lines around the first difference between two versions
+// of a file, which is usually enough to tell what changed.
+func printDifference(before, after []byte) {
+	a := strings.Split(string(before), "\n")
+	b := strings.Split(string(after), "\n")
+
+	line := 0
+	for line < len(a) && line < len(b) && a[line] == b[line] {
+		line++
+	}
+
+	from := line - snapshotContext
+	if from < 0 {
+		from = 0
+	}
+	fmt.Printf("           first difference at line %d:\n", line+1)
+	for i := from; i < line+snapshotContext+1 && i < len(a); i++ {
+		fmt.Printf("           - %s\n", a[i])
+	}
+	for i := from; i < line+snapshotContext+1 && i < len(b); i++ {
+		fmt.Printf("           + %s\n", b[i])
+	}
+}
+
+// ---------------------------------------------------------------------------
+// updateSnapshot writes the rendered files as the snapshot, removing files of the old
+// snapshot that are no longer rendered.
+func updateSnapshot(dir string, rendered map[string][]byte) error {
+	existing, err := snapshotFiles(dir)
+	if err != nil {
+		return err
+	}
+	for name := range existing {
+		if _, ok := rendered[name]; !ok {
+			if err = os.Remove(filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+				return err
+			}
+		}
+	}
+	for name, body := range rendered {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err = os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return err
+		}
+		if err = ioutil.WriteFile(file, body, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"net/url"
+
+	"github.com/go-openapi/spec"
+)
+
+// Contact is who to ask about a specification, from the contact of its info
+type Contact struct {
+	Name  string
+	URL   string
+	Email string
+}
+
+// License is what a specification's API is offered under, from the license of its info
+type License struct {
+	Name string
+	URL  string // Or "" if the license is only named
+}
+
+// -----------------------------------------------------------------------------
+
+func getContact(info *spec.Info) *Contact {
+	if info.Contact == nil {
+		return nil
+	}
+	contact := &Contact{Name: info.Contact.Name, URL: info.Contact.URL, Email: info.Contact.Email}
+	if len(contact.Name) == 0 && len(contact.URL) == 0 && len(contact.Email) == 0 {
+		return nil
+	}
+	return contact
+}
+
+// -----------------------------------------------------------------------------
+
+func getLicense(info *spec.Info) *License {
+	if info.License == nil || len(info.License.Name) == 0 {
+		return nil
+	}
+	return &License{Name: info.License.Name, URL: info.License.URL}
+}
+
+// -----------------------------------------------------------------------------
+// getTerms splits info.termsOfService into a URL to link to, or else the terms as
+// written, since Swagger 2 allows either while OpenAPI 3 requires a URL.
+func getTerms(info *spec.Info) (string, string) {
+	if u, err := url.Parse(info.TermsOfService); err == nil && len(u.Scheme) > 0 {
+		return info.TermsOfService, ""
+	}
+	return "", info.TermsOfService
+}
+
+// -----------------------------------------------------------------------------
+// HasAbout reports whether there is anything to say about an API beyond its
+// description, for the "About this API" section of its summary.
+func (i Info) HasAbout() bool {
+	return i.Contact != nil || i.License != nil || len(i.TermsOfService) > 0 || len(i.Terms) > 0
+}
@@ -0,0 +1,102 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Anchors are HTML ids that external pages can deep-link to, such as the 429
+// response of POST /payments:
+//
+//     post.payments~response.429
+//
+// They are derived only from what the specification declares - the HTTP method,
+// path, status code, parameter location and name, and property path - so they
+// don't change when operation IDs, summaries or navigation settings do. Each part
+// is escaped so that no two distinct parts can produce the same anchor, and the
+// ~ that ends the operation part never appears in an escaped part.
+
+// -----------------------------------------------------------------------------
+
+func anchor(parts ...string) string {
+	escaped := make([]string, len(parts))
+	for i, part := range parts {
+		escaped[i] = escapeAnchor(part)
+	}
+	return strings.Join(escaped, ".")
+}
+
+// -----------------------------------------------------------------------------
+// escapeAnchor keeps letters, digits and hyphens, and replaces every other byte
+// with an underscore and its hex value.
+func escapeAnchor(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "_%02x", c)
+		}
+	}
+	return b.String()
+}
+
+// -----------------------------------------------------------------------------
+// methodAnchor builds the anchor of an operation from its method and path. Path
+// templates such as {id} become :id, which escaping can never produce.
+func methodAnchor(method, path string) string {
+	parts := []string{strings.ToLower(method)}
+	for _, segment := range strings.Split(strings.Trim(path, "/"), "/") {
+		if len(segment) == 0 {
+			continue
+		}
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			parts = append(parts, ":"+escapeAnchor(segment[1:len(segment)-1]))
+			continue
+		}
+		parts = append(parts, escapeAnchor(segment))
+	}
+	return strings.Join(parts, ".")
+}
+
+// -----------------------------------------------------------------------------
+
+func responseAnchor(method *Method, status int) string {
+	code := "default"
+	if status > 0 {
+		code = strconv.Itoa(status)
+	}
+	return method.Anchor + "~response." + code
+}
+
+// -----------------------------------------------------------------------------
+
+func parameterAnchor(method *Method, in, name string) string {
+	return method.Anchor + "~" + anchor("param", strings.ToLower(in), name)
+}
+
+// -----------------------------------------------------------------------------
+
+func propertyAnchor(fqns []string, name string) string {
+	return "property~" + anchor(append(append([]string{}, fqns...), name)...)
+}
@@ -0,0 +1,156 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+// Object storage specification sources. s3://, gs:// and az:// locations are
+// mapped onto each service's HTTPS API, with credentials from the environment,
+// so no cloud SDKs are needed:
+//
+//   s3://bucket/key    AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN (optional)
+//                      and AWS_REGION (default us-east-1). Unsigned if there are no keys.
+//   gs://bucket/object GOOGLE_OAUTH_ACCESS_TOKEN (optional, for private objects)
+//   az://container/blob AZURE_STORAGE_ACCOUNT, and AZURE_STORAGE_SAS_TOKEN (optional)
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+// newSpecRequest creates the GET request for a specification location
+func newSpecRequest(location string) (*http.Request, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "s3":
+		return newS3Request(u)
+	case "gs":
+		return newGCSRequest(u)
+	case "az":
+		return newAzureRequest(u)
+	}
+	return http.NewRequest("GET", location, nil)
+}
+
+// -----------------------------------------------------------------------------
+
+func newGCSRequest(u *url.URL) (*http.Request, error) {
+	req, err := http.NewRequest("GET", "https://storage.googleapis.com/"+u.Host+u.Path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// -----------------------------------------------------------------------------
+
+func newAzureRequest(u *url.URL) (*http.Request, error) {
+	account := os.Getenv("AZURE_STORAGE_ACCOUNT")
+	if len(account) == 0 {
+		return nil, fmt.Errorf("AZURE_STORAGE_ACCOUNT must be set to load %s", u)
+	}
+	location := "https://" + account + ".blob.core.windows.net/" + u.Host + u.Path
+	if sas := os.Getenv("AZURE_STORAGE_SAS_TOKEN"); len(sas) > 0 {
+		location += "?" + strings.TrimPrefix(sas, "?")
+	}
+	req, err := http.NewRequest("GET", location, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-ms-version", "2020-04-08")
+	return req, nil
+}
+
+// -----------------------------------------------------------------------------
+// newS3Request creates a virtual hosted style request, signed with AWS Signature
+// Version 4 if credentials are available.
+func newS3Request(u *url.URL) (*http.Request, error) {
+	region := os.Getenv("AWS_REGION")
+	if len(region) == 0 {
+		region = "us-east-1"
+	}
+	host := u.Host + ".s3." + region + ".amazonaws.com"
+
+	req, err := http.NewRequest("GET", "https://"+host+u.EscapedPath(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	accessKey := os.Getenv("AWS_ACCESS_KEY_ID")
+	secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if len(accessKey) == 0 || len(secretKey) == 0 {
+		return req, nil
+	}
+
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := hex.EncodeToString(sha256Sum(nil)) // GET has an empty body
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + amzDate + "\n"
+
+	if token := os.Getenv("AWS_SESSION_TOKEN"); len(token) > 0 {
+		req.Header.Set("x-amz-security-token", token)
+		signedHeaders += ";x-amz-security-token"
+		canonicalHeaders += "x-amz-security-token:" + token + "\n"
+	}
+
+	canonicalRequest := strings.Join([]string{"GET", req.URL.EscapedPath(), "", canonicalHeaders, signedHeaders, payloadHash}, "\n")
+
+	scope := day + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sha256Sum([]byte(canonicalRequest)))
+
+	key := hmacSum([]byte("AWS4"+secretKey), day)
+	key = hmacSum(key, region)
+	key = hmacSum(key, "s3")
+	key = hmacSum(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSum(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+	return req, nil
+}
+
+// -----------------------------------------------------------------------------
+
+func sha256Sum(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+func hmacSum(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
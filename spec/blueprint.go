@@ -0,0 +1,923 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/go-openapi/loads"
+)
+
+// API Blueprint documents are converted to the OpenAPI document that the rest of the
+// loader reads. Each resource group is an API group, and each action of a resource a
+// method. Attributes and data structures, written in MSON, become schemas, and the
+// bodies of requests and responses their examples. Schemas are inferred from the
+// examples of bodies that have no attributes.
+
+const blueprintMethods = `GET|POST|PUT|PATCH|DELETE|HEAD|OPTIONS|TRACE|CONNECT|LINK|UNLINK`
+
+var (
+	blueprintMetadata  = regexp.MustCompile(`^([A-Za-z_-]+):\s*(.*)$`)
+	blueprintHeader    = regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*#*\s*$`)
+	blueprintGroup     = regexp.MustCompile(`^Group\s+(.+)$`)
+	blueprintResource  = regexp.MustCompile(`^(.*?)\s*\[(/[^\]]*)\]$`)
+	blueprintAction    = regexp.MustCompile(`^(.*?)\s*\[(` + blueprintMethods + `)(?:\s+(/[^\]]*))?\]$`)
+	blueprintShorthand = regexp.MustCompile(`^(` + blueprintMethods + `)\s+(/\S*)$`)
+	blueprintNamedType = regexp.MustCompile(`^(.+?)\s*(?:\((.+)\))?$`)
+	blueprintItem      = regexp.MustCompile(`^(\s*)[-+*]\s+(.*)$`)
+	blueprintKeyword   = regexp.MustCompile(`^(Parameters|Attributes|Request|Response|Headers|Body|Schema|Model|Relation)\b`)
+	blueprintMediaType = regexp.MustCompile(`\(([^)]*)\)\s*$`)
+	blueprintStatus    = regexp.MustCompile(`^Response\s+([0-9]{3})`)
+	blueprintReference = regexp.MustCompile(`^\[([^\]]+)\]\[\]$`)
+	blueprintQuery     = regexp.MustCompile(`{[?&]([^}]*)}`)
+	blueprintVariable  = regexp.MustCompile(`{[+#./;]?([^}]*)}`)
+	blueprintRef       = regexp.MustCompile(`"#/definitions/([^"]+)"`)
+
+	// blueprintProperty reads an MSON property or parameter, such as
+	// id: 42 (number, required) - The identifier
+	blueprintProperty = regexp.MustCompile("^(?:`([^`]+)`|([^:(]+?))?(?:\\s*:\\s*(.*?))?\\s*(?:\\(([^)]*)\\))?\\s*(?:-\\s+(.*))?$")
+)
+
+// blueprintSection is the content under a header of a document
+type blueprintSection struct {
+	level int
+	title string
+	lines []string
+}
+
+// blueprintNode is a list item, with the lines under it that are not list items, such
+// as the body of a request
+type blueprintNode struct {
+	text     string
+	lines    []string
+	children []*blueprintNode
+}
+
+// blueprintPayload is a request or response, or the model of a resource
+type blueprintPayload struct {
+	mediaType string
+	headers   [][2]string
+	schema    map[string]interface{}
+	example   interface{}
+}
+
+type blueprintResourceSection struct {
+	name       string
+	uri        string
+	tag        string
+	parameters []map[string]interface{}
+}
+
+type blueprintConverter struct {
+	definitions map[string]interface{}
+	models      map[string]*blueprintPayload
+}
+
+// -----------------------------------------------------------------------------
+
+func isBlueprint(location string) bool {
+	return path.Ext(strings.SplitN(location, "?", 2)[0]) == ".apib"
+}
+
+// -----------------------------------------------------------------------------
+// loadBlueprint reads the API Blueprint document in file as an OpenAPI document
+func loadBlueprint(file string) (*loads.Document, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, sections := parseBlueprint(string(data))
+	if format, ok := metadata["FORMAT"]; ok && format != "1A" {
+		return nil, fmt.Errorf("API Blueprint format %s is not supported", format)
+	}
+
+	c := &blueprintConverter{
+		definitions: map[string]interface{}{},
+		models:      map[string]*blueprintPayload{},
+	}
+	doc, err := c.document(metadata, sections)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return loads.Analyzed(raw, "")
+}
+
+// -----------------------------------------------------------------------------
+// parseBlueprint splits a document into its metadata, such as HOST, and the sections
+// under its headers. Content before the first header is in a section of level 0.
+func parseBlueprint(text string) (map[string]string, []*blueprintSection) {
+	lines := strings.Split(strings.Replace(strings.Replace(text, "\r", "", -1), "\t", "    ", -1), "\n")
+
+	metadata := map[string]string{}
+	for len(lines) > 0 {
+		m := blueprintMetadata.FindStringSubmatch(lines[0])
+		if m == nil {
+			break
+		}
+		metadata[m[1]] = strings.TrimSpace(m[2])
+		lines = lines[1:]
+	}
+
+	section := &blueprintSection{}
+	sections := []*blueprintSection{section}
+	fenced := false
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+		}
+		if m := blueprintHeader.FindStringSubmatch(line); m != nil && !fenced {
+			section = &blueprintSection{level: len(m[1]), title: m[2]}
+			sections = append(sections, section)
+			continue
+		}
+		section.lines = append(section.lines, line)
+	}
+	return metadata, sections
+}
+
+// list splits the content of a section into its description and the list that
+// follows. The list starts at the first item that is a keyword, such as + Response,
+// as lists in descriptions are part of them, unless any item starts it, as in data
+// structures.
+func (s *blueprintSection) list(anyItem bool) (string, []*blueprintNode) {
+	lines := s.lines
+	start := len(lines)
+	for i, line := range lines {
+		if m := blueprintItem.FindStringSubmatch(line); m != nil && len(m[1]) < 4 && (anyItem || blueprintKeyword.MatchString(m[2])) {
+			start = i
+			break
+		}
+	}
+	description := strings.TrimSpace(strings.Join(lines[:start], "\n"))
+
+	type open struct {
+		indent int
+		node   *blueprintNode
+	}
+	var roots []*blueprintNode
+	var stack []open
+	fenced := false
+	for _, line := range lines[start:] {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+		}
+		m := blueprintItem.FindStringSubmatch(line)
+		// Lines indented eight spaces more than the item they are in are code
+		if m != nil && !fenced && (len(stack) == 0 || len(m[1]) < stack[len(stack)-1].indent+8) {
+			indent := len(m[1])
+			for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+				stack = stack[:len(stack)-1]
+			}
+			node := &blueprintNode{text: strings.TrimSpace(m[2])}
+			if len(stack) == 0 {
+				roots = append(roots, node)
+			} else {
+				parent := stack[len(stack)-1].node
+				parent.children = append(parent.children, node)
+			}
+			stack = append(stack, open{indent, node})
+			continue
+		}
+		if len(stack) > 0 {
+			top := stack[len(stack)-1].node
+			top.lines = append(top.lines, line)
+		}
+	}
+	return description, roots
+}
+
+// description is the content of a section that is not a list of keywords
+func (s *blueprintSection) description() string {
+	description, _ := s.list(false)
+	return description
+}
+
+// body is the code under a node, without its indentation or fences
+func (n *blueprintNode) body() string {
+	indent := -1
+	var lines []string
+	for _, line := range n.lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			continue
+		}
+		lines = append(lines, line)
+		if trimmed := strings.TrimLeft(line, " "); len(trimmed) > 0 {
+			if i := len(line) - len(trimmed); indent < 0 || i < indent {
+				indent = i
+			}
+		}
+	}
+	for i, line := range lines {
+		if len(line) >= indent && indent > 0 {
+			lines[i] = line[indent:]
+		} else {
+			lines[i] = strings.TrimLeft(line, " ")
+		}
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n ")
+}
+
+// keyword is the keyword a node starts with, such as Response
+func (n *blueprintNode) keyword() string {
+	return blueprintKeyword.FindString(n.text)
+}
+
+// child finds the child of a node that starts with a keyword
+func (n *blueprintNode) child(keyword string) *blueprintNode {
+	for _, child := range n.children {
+		if child.keyword() == keyword {
+			return child
+		}
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// document builds the OpenAPI document of the sections of an API Blueprint
+func (c *blueprintConverter) document(metadata map[string]string, sections []*blueprintSection) (map[string]interface{}, error) {
+	info := map[string]interface{}{"title": "", "version": "", "description": strings.TrimSpace(strings.Join(sections[0].lines, "\n"))}
+	doc := map[string]interface{}{"swagger": "2.0", "info": info}
+	setBaseURI(doc, metadata["HOST"])
+
+	// Data structures are named before the resources that use them
+	for i := 0; i < len(sections); i++ {
+		if sections[i].title != "Data Structures" {
+			continue
+		}
+		level := sections[i].level
+		for i++; i < len(sections) && sections[i].level > level; i++ {
+			c.addDataStructure(sections[i])
+		}
+		i--
+	}
+
+	paths := map[string]interface{}{}
+	var tags []interface{}
+	addTag := func(name string, description string) {
+		for _, tag := range tags {
+			if tag.(map[string]interface{})["name"] == name {
+				return
+			}
+		}
+		tags = append(tags, map[string]interface{}{"name": name, "description": description})
+	}
+
+	group := ""
+	var resource *blueprintResourceSection
+	dataLevel := 0
+	for _, section := range sections[1:] {
+		if dataLevel > 0 && section.level > dataLevel {
+			continue
+		}
+		dataLevel = 0
+
+		title := section.title
+		switch {
+		case title == "Data Structures":
+			dataLevel = section.level
+		case blueprintGroup.MatchString(title):
+			group = blueprintGroup.FindStringSubmatch(title)[1]
+			addTag(group, section.description())
+			resource = nil
+		case blueprintShorthand.MatchString(title):
+			m := blueprintShorthand.FindStringSubmatch(title)
+			resource = c.resource(section, "", m[2], group)
+			addTag(resource.tag, "")
+			c.addAction(paths, resource, section, m[1], "", "")
+		case blueprintResource.MatchString(title):
+			m := blueprintResource.FindStringSubmatch(title)
+			resource = c.resource(section, m[1], m[2], group)
+			if len(group) == 0 {
+				addTag(resource.tag, section.description())
+			}
+		case blueprintAction.MatchString(title):
+			m := blueprintAction.FindStringSubmatch(title)
+			if resource == nil {
+				if len(m[3]) == 0 {
+					logger.Warnf(nil, "API Blueprint action %s is not in a resource", title)
+					continue
+				}
+				resource = &blueprintResourceSection{uri: m[3], tag: group}
+				if len(group) == 0 {
+					resource.tag = m[3]
+				}
+				addTag(resource.tag, "")
+			}
+			c.addAction(paths, resource, section, m[2], m[3], m[1])
+		case len(info["title"].(string)) == 0 && resource == nil && len(group) == 0:
+			info["title"] = title
+			info["description"] = strings.TrimSpace(info["description"].(string) + "\n\n" + strings.Join(section.lines, "\n"))
+		}
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("API Blueprint has no actions")
+	}
+	if len(info["title"].(string)) == 0 {
+		info["title"] = "API Blueprint"
+	}
+
+	// Types that are used but not described are objects, as far as the documentation goes
+	used, err := json.Marshal([]interface{}{paths, c.definitions})
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range blueprintRef.FindAllStringSubmatch(string(used), -1) {
+		if _, ok := c.definitions[ref[1]]; !ok {
+			logger.Warnf(nil, "API Blueprint type %s is not described", ref[1])
+			c.definitions[ref[1]] = map[string]interface{}{"type": "object", "title": ref[1]}
+		}
+	}
+
+	doc["tags"] = tags
+	doc["paths"] = paths
+	if len(c.definitions) > 0 {
+		doc["definitions"] = c.definitions
+	}
+	return doc, nil
+}
+
+// addDataStructure names the type of a data structure section, such as Note (object)
+func (c *blueprintConverter) addDataStructure(section *blueprintSection) {
+	m := blueprintNamedType.FindStringSubmatch(section.title)
+	description, nodes := section.list(true)
+	schema := c.mson(m[2], "", nodes)
+	if schema["type"] == "string" && len(m[2]) == 0 {
+		schema["type"] = "object"
+	}
+	schema = withFacet(schema, "title", m[1])
+	if len(description) > 0 {
+		schema["description"] = description
+	}
+	c.definitions[m[1]] = schema
+}
+
+// -----------------------------------------------------------------------------
+// resource reads the parameters and model of a resource section
+func (c *blueprintConverter) resource(section *blueprintSection, name string, uri string, group string) *blueprintResourceSection {
+	resource := &blueprintResourceSection{name: name, uri: uri, tag: group}
+	if len(resource.tag) == 0 {
+		resource.tag = name
+		if len(name) == 0 {
+			resource.tag = uri
+		}
+	}
+	_, nodes := section.list(false)
+	for _, node := range nodes {
+		switch node.keyword() {
+		case "Parameters":
+			resource.parameters = c.parameters(node)
+		case "Attributes":
+			// Attributes such as (Note) refer to a data structure that is already named
+			if _, ok := c.definitions[name]; !ok && len(name) > 0 {
+				c.definitions[name] = withFacet(c.attributes(node), "title", name)
+			}
+		case "Model":
+			if len(name) > 0 {
+				c.models[name] = c.payload(node)
+			}
+		}
+	}
+	return resource
+}
+
+// addAction adds the operation of an action section, whose URI is the resource's
+// unless it has its own
+func (c *blueprintConverter) addAction(paths map[string]interface{}, resource *blueprintResourceSection, section *blueprintSection, method string, uri string, name string) {
+	if len(uri) == 0 {
+		uri = resource.uri
+	}
+	route := blueprintQuery.ReplaceAllString(uri, "")
+	route = blueprintVariable.ReplaceAllStringFunc(route, func(variable string) string {
+		return "{" + strings.TrimRight(blueprintVariable.FindStringSubmatch(variable)[1], "*") + "}"
+	})
+
+	summary := name
+	if len(summary) == 0 {
+		summary = method + " " + route
+	}
+	description, nodes := section.list(false)
+	operation := map[string]interface{}{
+		"tags":        []string{resource.tag},
+		"summary":     summary,
+		"description": description,
+	}
+
+	// Parameters of the action take the place of those of the resource. Those not in
+	// the URI template are query parameters, if the action declares them.
+	declared := map[string]map[string]interface{}{}
+	var names []string
+	declare := func(parameters []map[string]interface{}, action bool) {
+		for _, parameter := range parameters {
+			name := parameter["name"].(string)
+			if action {
+				names = append(names, name)
+			}
+			declared[name] = parameter
+		}
+	}
+	declare(resource.parameters, false)
+	var requests, responses []*blueprintNode
+	for _, node := range nodes {
+		switch node.keyword() {
+		case "Parameters":
+			declare(c.parameters(node), true)
+		case "Request":
+			requests = append(requests, node)
+		case "Response":
+			responses = append(responses, node)
+		}
+	}
+
+	// Parameters are in the path or query, as the URI template has them
+	var parameters []interface{}
+	for _, m := range blueprintVariable.FindAllStringSubmatch(route, -1) {
+		parameter, ok := declared[m[1]]
+		if !ok {
+			parameter = map[string]interface{}{"name": m[1], "type": "string"}
+		}
+		parameter = blueprintParameter(parameter, "path")
+		parameter["required"] = true
+		parameters = append(parameters, parameter)
+		delete(declared, m[1])
+	}
+	for _, m := range blueprintQuery.FindAllStringSubmatch(uri, -1) {
+		for _, name := range strings.Split(m[1], ",") {
+			name = strings.TrimRight(strings.TrimSpace(name), "*")
+			parameter, ok := declared[name]
+			if !ok {
+				parameter = map[string]interface{}{"name": name, "type": "string", "required": false}
+			}
+			parameters = append(parameters, blueprintParameter(parameter, "query"))
+			delete(declared, name)
+		}
+	}
+	for _, name := range names {
+		if parameter, ok := declared[name]; ok {
+			parameters = append(parameters, blueprintParameter(parameter, "query"))
+		}
+	}
+
+	var consumes, produces []string
+	for i, node := range requests {
+		payload := c.payload(node)
+		if len(payload.mediaType) > 0 && !contains(consumes, payload.mediaType) {
+			consumes = append(consumes, payload.mediaType)
+		}
+		if i > 0 {
+			continue
+		}
+		for _, header := range payload.headers {
+			if !strings.EqualFold(header[0], "Content-Type") {
+				parameters = append(parameters, map[string]interface{}{
+					"name": header[0], "in": "header", "required": false, "type": "string", "x-example": header[1],
+				})
+			}
+		}
+		if schema := payload.bodySchema(); schema != nil {
+			parameters = append(parameters, map[string]interface{}{
+				"name": "body", "in": "body", "required": true, "schema": schema,
+			})
+		}
+	}
+
+	responseMap := map[string]interface{}{}
+	for _, node := range responses {
+		code := "200"
+		if m := blueprintStatus.FindStringSubmatch(node.text); m != nil {
+			code = m[1]
+		}
+		if _, ok := responseMap[code]; ok {
+			continue
+		}
+		status, _ := strconv.Atoi(code)
+		response := map[string]interface{}{"description": http.StatusText(status)}
+
+		payload := c.payload(node)
+		if len(payload.mediaType) > 0 && !contains(produces, payload.mediaType) {
+			produces = append(produces, payload.mediaType)
+		}
+		if schema := payload.bodySchema(); schema != nil {
+			response["schema"] = schema
+		}
+		if payload.example != nil && len(payload.mediaType) > 0 {
+			response["examples"] = map[string]interface{}{payload.mediaType: payload.example}
+		}
+		if len(payload.headers) > 0 {
+			headers := map[string]interface{}{}
+			for _, header := range payload.headers {
+				headers[header[0]] = map[string]interface{}{"type": "string", "x-example": header[1]}
+			}
+			response["headers"] = headers
+		}
+		responseMap[code] = response
+	}
+	if len(responseMap) == 0 {
+		responseMap["200"] = map[string]interface{}{"description": http.StatusText(http.StatusOK)}
+	}
+
+	operation["parameters"] = parameters
+	operation["responses"] = responseMap
+	if len(consumes) > 0 {
+		operation["consumes"] = consumes
+	}
+	if len(produces) > 0 {
+		operation["produces"] = produces
+	}
+
+	operations, ok := paths[route].(map[string]interface{})
+	if !ok {
+		operations = map[string]interface{}{}
+		paths[route] = operations
+	}
+	operations[strings.ToLower(method)] = operation
+}
+
+// blueprintParameter places a parameter in the path or query
+func blueprintParameter(parameter map[string]interface{}, in string) map[string]interface{} {
+	p := make(map[string]interface{}, len(parameter)+1)
+	for key, value := range parameter {
+		p[key] = value
+	}
+	p["in"] = in
+	return p
+}
+
+// -----------------------------------------------------------------------------
+// payload reads a request, response or model. Its body is its example, and its
+// schema is that of its attributes or schema section, if it has one.
+func (c *blueprintConverter) payload(node *blueprintNode) *blueprintPayload {
+	payload := &blueprintPayload{}
+	if m := blueprintMediaType.FindStringSubmatch(node.text); m != nil {
+		payload.mediaType = strings.TrimSpace(m[1])
+	}
+
+	body := ""
+	if len(node.children) == 0 {
+		body = node.body()
+		// A reference to the model of a resource, such as [Note][]
+		if m := blueprintReference.FindStringSubmatch(body); m != nil {
+			if model, ok := c.models[m[1]]; ok {
+				mediaType := payload.mediaType
+				*payload = *model
+				if len(mediaType) > 0 {
+					payload.mediaType = mediaType
+				}
+				return payload
+			}
+			logger.Warnf(nil, "API Blueprint model %s is not described", m[1])
+			return payload
+		}
+	}
+	for _, child := range node.children {
+		switch child.keyword() {
+		case "Headers":
+			for _, line := range strings.Split(child.body(), "\n") {
+				if parts := strings.SplitN(line, ":", 2); len(parts) == 2 {
+					payload.headers = append(payload.headers, [2]string{strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])})
+				}
+			}
+		case "Body":
+			body = child.body()
+		case "Schema":
+			var schema map[string]interface{}
+			if err := json.Unmarshal([]byte(child.body()), &schema); err == nil {
+				delete(schema, "$schema")
+				delete(schema, "id")
+				payload.schema = schema
+			}
+		case "Attributes":
+			payload.schema = c.attributes(child)
+		}
+	}
+
+	if len(body) > 0 {
+		payload.example = body
+		if len(payload.mediaType) == 0 || strings.Contains(payload.mediaType, "json") {
+			var decoded interface{}
+			if err := json.Unmarshal([]byte(body), &decoded); err == nil {
+				payload.example = decoded
+			}
+		}
+	}
+	return payload
+}
+
+// bodySchema is the schema of a payload's body, with its example. Bodies without
+// schemas have the schema of their example.
+func (p *blueprintPayload) bodySchema() map[string]interface{} {
+	schema := p.schema
+	if schema == nil {
+		if p.example == nil {
+			return nil
+		}
+		schema = exampleSchema(p.example)
+	}
+	if p.example != nil {
+		schema = withFacet(copyYAML(schema).(map[string]interface{}), "example", p.example)
+	}
+	return schema
+}
+
+// exampleSchema infers the schema of a decoded JSON example
+func exampleSchema(example interface{}) map[string]interface{} {
+	switch e := example.(type) {
+	case map[string]interface{}:
+		properties := map[string]interface{}{}
+		for key, value := range e {
+			properties[key] = exampleSchema(value)
+		}
+		return map[string]interface{}{"type": "object", "properties": properties}
+	case []interface{}:
+		items := map[string]interface{}{}
+		if len(e) > 0 {
+			items = exampleSchema(e[0])
+		}
+		return map[string]interface{}{"type": "array", "items": items}
+	case float64:
+		if e == float64(int64(e)) {
+			return map[string]interface{}{"type": "integer"}
+		}
+		return map[string]interface{}{"type": "number"}
+	case bool:
+		return map[string]interface{}{"type": "boolean"}
+	}
+	return map[string]interface{}{"type": "string"}
+}
+
+// -----------------------------------------------------------------------------
+// parameters reads the parameters of a Parameters section, such as
+// id: 42 (number, required) - The identifier
+func (c *blueprintConverter) parameters(node *blueprintNode) []map[string]interface{} {
+	var parameters []map[string]interface{}
+	for _, child := range node.children {
+		m := blueprintProperty.FindStringSubmatch(child.text)
+		if m == nil {
+			continue
+		}
+		name := strings.Trim(m[1]+m[2], "*_ ")
+		attributes := blueprintAttributes(m[4])
+
+		schema := c.mson(m[4], m[3], child.children)
+		parameter := map[string]interface{}{
+			"name":     name,
+			"required": !contains(attributes, "optional"),
+			"type":     "string",
+		}
+		if description := strings.TrimSpace(m[5] + "\n\n" + child.body()); len(description) > 0 {
+			parameter["description"] = description
+		}
+		for _, facet := range []string{"type", "enum", "default", "items"} {
+			if value, ok := schema[facet]; ok {
+				parameter[facet] = value
+			}
+		}
+		if example, ok := schema["example"]; ok {
+			parameter["x-example"] = example
+		}
+		switch parameter["type"] {
+		case "object":
+			parameter["type"] = "string"
+		case "array":
+			if items := asMap(parameter["items"]); items["type"] == nil || items["type"] == "object" {
+				parameter["items"] = map[string]interface{}{"type": "string"}
+			}
+		}
+		parameters = append(parameters, parameter)
+	}
+	return parameters
+}
+
+// attributes is the schema of an Attributes section, such as Attributes (Note)
+func (c *blueprintConverter) attributes(node *blueprintNode) map[string]interface{} {
+	typeSpec := ""
+	if m := blueprintMediaType.FindStringSubmatch(node.text); m != nil {
+		typeSpec = m[1]
+	}
+	return c.mson(typeSpec, "", node.children)
+}
+
+// -----------------------------------------------------------------------------
+// mson is the JSON schema of an MSON type, such as array[Note] or
+// number, required, with its sample value and the items nested in it
+func (c *blueprintConverter) mson(typeSpec string, sample string, children []*blueprintNode) map[string]interface{} {
+	attributes := blueprintAttributes(typeSpec)
+	typeName := ""
+	for _, attribute := range attributes {
+		switch attribute {
+		case "required", "optional", "fixed", "fixed-type", "nullable", "sample", "default":
+		default:
+			if len(typeName) == 0 {
+				typeName = attribute
+			}
+		}
+	}
+	sample = strings.Trim(strings.TrimSpace(sample), "`")
+
+	var schema map[string]interface{}
+	inner := ""
+	if open := strings.Index(typeName, "["); open > 0 && strings.HasSuffix(typeName, "]") {
+		inner = strings.TrimSpace(strings.SplitN(typeName[open+1:len(typeName)-1], ",", 2)[0])
+		typeName = typeName[:open]
+	}
+	switch typeName {
+	case "":
+		if hasProperties(children) {
+			schema = map[string]interface{}{"type": "object"}
+		} else {
+			schema = map[string]interface{}{"type": "string"}
+		}
+	case "string", "number", "boolean", "object":
+		schema = map[string]interface{}{"type": typeName}
+	case "array":
+		schema = map[string]interface{}{"type": "array", "items": map[string]interface{}{}}
+		if len(inner) > 0 {
+			schema["items"] = c.mson(inner, "", nil)
+		}
+	case "enum":
+		if len(inner) == 0 {
+			inner = "string"
+		}
+		schema = c.mson(inner, "", nil)
+	default:
+		schema = map[string]interface{}{"$ref": "#/definitions/" + typeName}
+	}
+
+	var properties map[string]interface{}
+	var required []string
+	var enum []interface{}
+	var allOf []interface{}
+	for _, child := range children {
+		text := child.text
+		switch {
+		case strings.HasPrefix(text, "Default:"):
+			schema = withFacet(schema, "default", blueprintValue(schema, strings.TrimSpace(strings.TrimPrefix(text, "Default:"))))
+			continue
+		case strings.HasPrefix(text, "Sample:"):
+			sample = strings.Trim(strings.TrimSpace(strings.TrimPrefix(text, "Sample:")), "`")
+			continue
+		case strings.HasPrefix(text, "Include "):
+			allOf = append(allOf, map[string]interface{}{"$ref": "#/definitions/" + strings.TrimSpace(strings.TrimPrefix(text, "Include "))})
+			continue
+		}
+
+		// Members of an enum were called values before MSON
+		members, kind := []*blueprintNode{child}, typeName
+		switch text {
+		case "Members", "Values":
+			members, kind = child.children, "enum"
+		case "Items", "Properties", "One Of":
+			members = child.children
+		}
+		for _, member := range members {
+			m := blueprintProperty.FindStringSubmatch(member.text)
+			if m == nil {
+				continue
+			}
+			switch kind {
+			case "enum":
+				enum = append(enum, blueprintValue(schema, strings.Trim(m[1]+m[2], "`")))
+			case "array":
+				items := c.mson(m[4], "", member.children)
+				if len(m[4]) == 0 && len(m[3]) == 0 && c.isType(m[2]) {
+					items = c.mson(m[2], "", member.children) // A type, such as + Note
+				}
+				schema["items"] = items
+			default:
+				if properties == nil {
+					properties = map[string]interface{}{}
+				}
+				name := strings.Trim(m[1]+m[2], "*_ ")
+				property := c.mson(m[4], m[3], member.children)
+				if len(m[5]) > 0 {
+					property = withFacet(property, "description", m[5])
+				}
+				properties[name] = property
+				if contains(blueprintAttributes(m[4]), "required") {
+					required = append(required, name)
+				}
+			}
+		}
+	}
+
+	if properties != nil || len(allOf) > 0 {
+		if _, ok := schema["$ref"]; ok {
+			allOf = append([]interface{}{schema}, allOf...)
+			schema = map[string]interface{}{"type": "object"}
+		}
+		if properties != nil {
+			schema["properties"] = properties
+		}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		if len(allOf) > 0 {
+			schema["allOf"] = allOf
+		}
+	}
+	if len(enum) > 0 {
+		schema["enum"] = enum
+	}
+	if len(sample) > 0 {
+		if schema["type"] == "array" {
+			var values []interface{}
+			for _, value := range strings.Split(sample, ",") {
+				values = append(values, blueprintValue(asMap(schema["items"]), strings.TrimSpace(value)))
+			}
+			schema = withFacet(schema, "example", values)
+		} else {
+			schema = withFacet(schema, "example", blueprintValue(schema, sample))
+		}
+	}
+	if contains(attributes, "nullable") {
+		schema = withFacet(schema, "x-nullable", true)
+	}
+	return schema
+}
+
+// blueprintAttributes splits the type attributes of an MSON item, such as
+// array[Note, Author], required
+func blueprintAttributes(typeSpec string) []string {
+	var attributes []string
+	depth, start := 0, 0
+	for i, r := range typeSpec + "," {
+		switch r {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				if attribute := strings.TrimSpace(typeSpec[start:i]); len(attribute) > 0 {
+					attributes = append(attributes, attribute)
+				}
+				start = i + 1
+			}
+		}
+	}
+	return attributes
+}
+
+// blueprintValue converts a sample or default value to the type of its schema
+func blueprintValue(schema map[string]interface{}, value string) interface{} {
+	value = strings.Trim(value, "`")
+	switch schema["type"] {
+	case "number", "integer":
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			return number
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+// isType tells a named type from a sample value, in the items of an array
+func (c *blueprintConverter) isType(name string) bool {
+	if _, ok := c.definitions[name]; ok {
+		return true
+	}
+	return len(name) > 0 && unicode.IsUpper([]rune(name)[0]) && !strings.Contains(name, " ")
+}
+
+// hasProperties tells whether the items nested in an MSON item are properties, rather
+// than just its default or sample
+func hasProperties(children []*blueprintNode) bool {
+	for _, child := range children {
+		if !strings.HasPrefix(child.text, "Default:") && !strings.HasPrefix(child.text, "Sample:") {
+			return true
+		}
+	}
+	return false
+}
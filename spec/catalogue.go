@@ -0,0 +1,149 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/go-openapi/spec"
+)
+
+// excerptLength is the most characters of a description shown in the specification list
+const excerptLength = 200
+
+// Logo is an image that represents a specification, given by the x-logo extension of
+// its info, as ReDoc uses.
+type Logo struct {
+	URL             string
+	AltText         string
+	BackgroundColor string
+	Href            string // Where the logo links to, or "" for the specification
+}
+
+// Branding gives a specification's pages their own identity, in a portal of many
+type Branding struct {
+	Logo        *Logo  // From the configuration file, or else x-logo. May be nil
+	Favicon     string // URL, or "" for the site's icon
+	AccentColor string // CSS color, or "" for the theme's
+}
+
+// Category is a heading of the specification list, and the specifications under it
+type Category struct {
+	ID             string
+	Name           string // Empty for the specifications that are in no category
+	Specifications []*APISpecification
+}
+
+// -----------------------------------------------------------------------------
+// Catalogue groups the specifications that are not hidden by category, for the
+// specification list. Categories are in alphabetical order, followed by the
+// specifications with none, and specifications are in order of title.
+func Catalogue() []Category {
+	byName := make(map[string]*Category)
+	var categories []*Category
+
+	for _, specification := range APISuite {
+		if specification.Hidden {
+			continue
+		}
+		category, ok := byName[specification.Category]
+		if !ok {
+			category = &Category{ID: TitleToKebab(specification.Category), Name: specification.Category}
+			byName[specification.Category] = category
+			categories = append(categories, category)
+		}
+		category.Specifications = append(category.Specifications, specification)
+	}
+
+	sort.Slice(categories, func(i, j int) bool {
+		if categories[i].Name == "" || categories[j].Name == "" {
+			return categories[j].Name == ""
+		}
+		return strings.ToLower(categories[i].Name) < strings.ToLower(categories[j].Name)
+	})
+
+	catalogue := make([]Category, len(categories))
+	for i, category := range categories {
+		sort.Slice(category.Specifications, func(a, b int) bool {
+			return strings.ToLower(category.Specifications[a].APIInfo.Title) < strings.ToLower(category.Specifications[b].APIInfo.Title)
+		})
+		catalogue[i] = *category
+	}
+	return catalogue
+}
+
+// -----------------------------------------------------------------------------
+
+func getLogo(info *spec.Info) *Logo {
+	logo, ok := info.Extensions["x-logo"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	l := &Logo{}
+	l.URL, _ = logo["url"].(string)
+	l.AltText, _ = logo["altText"].(string)
+	l.BackgroundColor, _ = logo["backgroundColor"].(string)
+	l.Href, _ = logo["href"].(string)
+	if len(l.URL) == 0 {
+		return nil
+	}
+	return l
+}
+
+// -----------------------------------------------------------------------------
+
+func getBranding(logo *Logo, specCfg *config.SpecConfig) *Branding {
+	if len(specCfg.Logo) > 0 {
+		logo = &Logo{URL: specCfg.Logo}
+	}
+	return &Branding{
+		Logo:        logo,
+		Favicon:     specCfg.Favicon,
+		AccentColor: specCfg.AccentColor,
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+var markdownLink = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+var markup = regexp.MustCompile("<[^>]*>|[*_`#>]")
+
+// Excerpt returns the first paragraph of a markdown description as plain text,
+// shortened at a word to at most excerptLength characters.
+func Excerpt(description string) string {
+	description = strings.TrimSpace(strings.Replace(description, "\r\n", "\n", -1))
+	if i := strings.Index(description, "\n\n"); i >= 0 {
+		description = description[:i]
+	}
+	description = markdownLink.ReplaceAllString(description, "$1")
+	description = markup.ReplaceAllString(description, "")
+	description = strings.Join(strings.Fields(description), " ")
+
+	if utf8.RuneCountInString(description) <= excerptLength {
+		return description
+	}
+	short := string([]rune(description)[:excerptLength])
+	if i := strings.LastIndex(short, " "); i > 0 {
+		short = short[:i]
+	}
+	return strings.TrimRight(short, ".,;:") + "…"
+}
@@ -0,0 +1,164 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OperationSummary is what is compared of an operation between two versions of a
+// specification. It is small enough to be kept between loads.
+type OperationSummary struct {
+	Parameters map[string]bool `json:"parameters"` // Whether each is required, keyed by "location name"
+	Responses  []int           `json:"responses"`
+	Deprecated bool            `json:"deprecated,omitempty"`
+}
+
+// Change is one difference between two versions of a specification
+type Change struct {
+	Operation   string // "METHOD path"
+	Description string // Such as "parameter query limit added"
+	Breaking    bool
+}
+
+// -----------------------------------------------------------------------------
+
+func (c Change) String() string {
+	return c.Operation + " " + c.Description
+}
+
+// -----------------------------------------------------------------------------
+// OperationSummaries summarizes the methods of a specification, keyed by "METHOD path"
+func (c *APISpecification) OperationSummaries() map[string]OperationSummary {
+	ops := make(map[string]OperationSummary)
+	for _, api := range c.APIs {
+		for _, method := range api.Methods {
+			summary := OperationSummary{Parameters: make(map[string]bool), Deprecated: method.Deprecated}
+			for _, list := range [][]Parameter{method.PathParams, method.QueryParams, method.HeaderParams, method.FormParams} {
+				for _, p := range list {
+					summary.Parameters[p.In+" "+p.Name] = p.Required
+				}
+			}
+			if method.BodyParam != nil {
+				summary.Parameters["body "+method.BodyParam.Name] = method.BodyParam.Required
+			}
+			for code := range method.Responses {
+				summary.Responses = append(summary.Responses, code)
+			}
+			sort.Ints(summary.Responses)
+			ops[strings.ToUpper(method.Method)+" "+method.Path] = summary
+		}
+	}
+	return ops
+}
+
+// -----------------------------------------------------------------------------
+// DiffOperations compares two versions of a specification operation by operation.
+// Removed operations, parameters and responses, and newly required parameters, are
+// breaking changes.
+func DiffOperations(before, after map[string]OperationSummary) []Change {
+	var changes []Change
+	change := func(breaking bool, op string, description string) {
+		changes = append(changes, Change{Operation: op, Description: description, Breaking: breaking})
+	}
+
+	for _, op := range sortedKeys(before) {
+		if _, ok := after[op]; !ok {
+			change(true, op, "removed")
+		}
+	}
+	for _, op := range sortedKeys(after) {
+		old, ok := before[op]
+		if !ok {
+			change(false, op, "added")
+			continue
+		}
+		updated := after[op]
+
+		for _, name := range sortedKeys(old.Parameters) {
+			if _, ok := updated.Parameters[name]; !ok {
+				change(true, op, "parameter "+name+" removed")
+			}
+		}
+		for _, name := range sortedKeys(updated.Parameters) {
+			required := updated.Parameters[name]
+			wasRequired, ok := old.Parameters[name]
+			switch {
+			case !ok && required:
+				change(true, op, "required parameter "+name+" added")
+			case !ok:
+				change(false, op, "parameter "+name+" added")
+			case required && !wasRequired:
+				change(true, op, "parameter "+name+" is now required")
+			case !required && wasRequired:
+				change(false, op, "parameter "+name+" is no longer required")
+			}
+		}
+
+		for _, code := range old.Responses {
+			if !containsCode(updated.Responses, code) {
+				change(true, op, "response "+strconv.Itoa(code)+" removed")
+			}
+		}
+		for _, code := range updated.Responses {
+			if !containsCode(old.Responses, code) {
+				change(false, op, "response "+strconv.Itoa(code)+" added")
+			}
+		}
+
+		if updated.Deprecated && !old.Deprecated {
+			change(false, op, "deprecated")
+		}
+	}
+	return changes
+}
+
+// -----------------------------------------------------------------------------
+
+func containsCode(codes []int, code int) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]OperationSummary:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]bool:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]interface{}:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,129 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"sort"
+	"strings"
+)
+
+// minErrorUses is how many operations must return a resource as an error for it to be
+// listed on the errors page, as a shared error model rather than one operation's error.
+const minErrorUses = 2
+
+// SharedError is a resource that many methods return as their error response
+type SharedError struct {
+	Resource *Resource
+	Statuses []ErrorStatus // The status codes it is returned with, in order
+	Uses     []ErrorUse    // The methods that return it
+}
+
+// ErrorStatus is a status code that a shared error is returned with
+type ErrorStatus struct {
+	Status      int // 0 for the default response
+	Description string
+}
+
+// ErrorUse is a method that returns a shared error
+type ErrorUse struct {
+	Method *Method
+	Status int // 0 for the default response
+}
+
+// -----------------------------------------------------------------------------
+// errorCatalogue finds the resources that are returned as errors, with a 4xx or 5xx
+// status or as the default response, by the methods of the versions shown when none is
+// requested. Those returned by fewer than minErrorUses methods are left out. The most
+// used come first.
+func (c *APISpecification) errorCatalogue() []SharedError {
+	byResource := make(map[*Resource]*SharedError)
+	var errors []*SharedError
+
+	use := func(resource *Resource, method *Method, status int) {
+		if resource == nil {
+			return
+		}
+		e, ok := byResource[resource]
+		if !ok {
+			e = &SharedError{Resource: resource}
+			byResource[resource] = e
+			errors = append(errors, e)
+		}
+		e.Uses = append(e.Uses, ErrorUse{Method: method, Status: status})
+	}
+
+	for _, api := range c.APIs {
+		methods := api.Versions[c.Version(api)]
+		for i := range methods {
+			method := &methods[i]
+			for status, response := range method.Responses {
+				if status >= 400 {
+					use(response.Resource, method, status)
+				}
+			}
+			if method.DefaultResponse != nil {
+				use(method.DefaultResponse.Resource, method, 0)
+			}
+		}
+	}
+
+	var catalogue []SharedError
+	for _, e := range errors {
+		methods := make(map[*Method]bool)
+		statuses := make(map[int]bool)
+		for _, u := range e.Uses {
+			methods[u.Method] = true
+			if !statuses[u.Status] {
+				statuses[u.Status] = true
+				e.Statuses = append(e.Statuses, ErrorStatus{Status: u.Status, Description: errorStatusDescription(u.Status)})
+			}
+		}
+		if len(methods) < minErrorUses {
+			continue
+		}
+		sort.Slice(e.Statuses, func(i, j int) bool {
+			// The default response last
+			if e.Statuses[i].Status == 0 || e.Statuses[j].Status == 0 {
+				return e.Statuses[j].Status == 0 && e.Statuses[i].Status != 0
+			}
+			return e.Statuses[i].Status < e.Statuses[j].Status
+		})
+		sort.SliceStable(e.Uses, func(i, j int) bool {
+			if e.Uses[i].Method.Path != e.Uses[j].Method.Path {
+				return e.Uses[i].Method.Path < e.Uses[j].Method.Path
+			}
+			return e.Uses[i].Method.Method < e.Uses[j].Method.Method
+		})
+		catalogue = append(catalogue, *e)
+	}
+
+	sort.SliceStable(catalogue, func(i, j int) bool {
+		if len(catalogue[i].Uses) != len(catalogue[j].Uses) {
+			return len(catalogue[i].Uses) > len(catalogue[j].Uses)
+		}
+		return strings.ToLower(catalogue[i].Resource.Title) < strings.ToLower(catalogue[j].Resource.Title)
+	})
+	return catalogue
+}
+
+func errorStatusDescription(status int) string {
+	if status == 0 {
+		return "default"
+	}
+	return HTTPStatusDescription(status)
+}
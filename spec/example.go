@@ -0,0 +1,276 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"regexp/syntax"
+	"strings"
+	"time"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/go-openapi/spec"
+)
+
+// exampleSeed varies the synthesised example values. It is the configured example-seed,
+// so that examples are the same each time the specifications are loaded, unless
+// example-random asks for a new value for each load.
+var exampleSeed int64
+
+// -----------------------------------------------------------------------------
+// synthesiseExample returns a plausible value for a primitive from the enum, format
+// or pattern of its schema, or nil if there is nothing to go on. The values of each
+// property depend only on the seed and where the property is, and not on the order
+// that properties are processed in.
+func synthesiseExample(s *spec.Schema, fqns []string) interface{} {
+	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+	if !cfg.ExampleValues {
+		return nil
+	}
+
+	h := fnv.New64a()
+	fmt.Fprint(h, exampleSeed, fqns)
+	rnd := rand.New(rand.NewSource(int64(h.Sum64())))
+
+	if len(s.Enum) > 0 {
+		return s.Enum[rnd.Intn(len(s.Enum))]
+	}
+
+	if value, ok := exampleOfFormat(s.Format, rnd); ok {
+		return value
+	}
+
+	if len(s.Pattern) > 0 {
+		if value, ok := exampleOfPattern(s.Pattern, rnd); ok {
+			return value
+		}
+	}
+	return nil
+}
+
+var exampleWords = []string{"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf", "hotel"}
+
+// exampleEpoch is the earliest of the synthesised dates
+var exampleEpoch = time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func exampleOfFormat(format string, rnd *rand.Rand) (string, bool) {
+	word := exampleWords[rnd.Intn(len(exampleWords))]
+	when := exampleEpoch.Add(time.Duration(rnd.Int63n(3*365*24*3600)) * time.Second)
+
+	switch format {
+	case "uuid":
+		b := make([]byte, 16)
+		rnd.Read(b)
+		b[6] = b[6]&0x0f | 0x40 // Version 4
+		b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), true
+	case "date-time":
+		return when.Format(time.RFC3339), true
+	case "date":
+		return when.Format("2006-01-02"), true
+	case "email":
+		return word + "@example.com", true
+	case "uri", "url":
+		return "https://example.com/" + word, true
+	case "hostname":
+		return word + ".example.com", true
+	case "ipv4":
+		return fmt.Sprintf("192.0.2.%d", 1+rnd.Intn(254)), true // Documentation range, RFC 5737
+	case "ipv6":
+		return fmt.Sprintf("2001:db8::%x", 1+rnd.Intn(0xfffe)), true // Documentation range, RFC 3849
+	case "byte":
+		return base64.StdEncoding.EncodeToString([]byte(word)), true
+	case "password":
+		return "********", true
+	}
+	return "", false
+}
+
+// -----------------------------------------------------------------------------
+// exampleOfPattern returns a string that matches a regular expression. Unbounded
+// repeats are kept short.
+func exampleOfPattern(pattern string, rnd *rand.Rand) (string, bool) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return "", false
+	}
+	var b strings.Builder
+	if !generate(&b, re.Simplify(), rnd) {
+		return "", false
+	}
+	return b.String(), true
+}
+
+func generate(b *strings.Builder, re *syntax.Regexp, rnd *rand.Rand) bool {
+	repeat := func(n int) bool {
+		for i := 0; i < n; i++ {
+			if !generate(b, re.Sub[0], rnd) {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch re.Op {
+	case syntax.OpLiteral:
+		b.WriteString(string(re.Rune))
+	case syntax.OpCharClass:
+		return generateClass(b, re.Rune, rnd)
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		b.WriteByte(byte('a' + rnd.Intn(26)))
+	case syntax.OpCapture:
+		return generate(b, re.Sub[0], rnd)
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if !generate(b, sub, rnd) {
+				return false
+			}
+		}
+	case syntax.OpAlternate:
+		return generate(b, re.Sub[rnd.Intn(len(re.Sub))], rnd)
+	case syntax.OpStar:
+		return repeat(rnd.Intn(3))
+	case syntax.OpPlus:
+		return repeat(1 + rnd.Intn(3))
+	case syntax.OpQuest:
+		return repeat(rnd.Intn(2))
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText,
+		syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+	default:
+		return false
+	}
+	return true
+}
+
+// generateClass writes a character of a class, given as lo-hi pairs, preferring
+// printable ASCII so that negated classes do not give control characters.
+func generateClass(b *strings.Builder, ranges []rune, rnd *rand.Rand) bool {
+	var printable [][2]rune
+	for i := 0; i+1 < len(ranges); i += 2 {
+		lo, hi := ranges[i], ranges[i+1]
+		if lo < '!' {
+			lo = '!'
+		}
+		if hi > '~' {
+			hi = '~'
+		}
+		if lo <= hi {
+			printable = append(printable, [2]rune{lo, hi})
+		}
+	}
+	if len(printable) == 0 {
+		if len(ranges) == 0 {
+			return false
+		}
+		b.WriteRune(ranges[0])
+		return true
+	}
+	r := printable[rnd.Intn(len(printable))]
+	b.WriteRune(r[0] + rune(rnd.Intn(int(r[1]-r[0])+1)))
+	return true
+}
+
+// exampleKey returns a key for example JSON that matches the key pattern of a map
+func exampleKey(pattern string, fqns []string) (string, bool) {
+	h := fnv.New64a()
+	fmt.Fprint(h, exampleSeed, fqns)
+	return exampleOfPattern(pattern, rand.New(rand.NewSource(int64(h.Sum64()))))
+}
+
+// -----------------------------------------------------------------------------
+// exampleOf returns the value of a primitive in example JSON: the synthesised value
+// if there is one, or else the name of its type.
+func (r *Resource) exampleOf(typeName string) interface{} {
+	if r.example != nil {
+		return r.example
+	}
+	return typeName
+}
+
+// -----------------------------------------------------------------------------
+// ExampleValue returns a value of the response body for the mock server to give: the
+// examples of its schema, with values made up for anything that has none. It is nil
+// if the response has no schema.
+func (r *Response) ExampleValue() interface{} {
+	if len(r.Schema) == 0 {
+		return nil
+	}
+	var s spec.Schema
+	if err := json.Unmarshal([]byte(r.Schema), &s); err != nil {
+		return nil
+	}
+	return exampleValue(&s, nil, 0)
+}
+
+// exampleDepth bounds how deep exampleValue goes into nested schemas
+const exampleDepth = 10
+
+func exampleValue(s *spec.Schema, fqns []string, depth int) interface{} {
+	if s.Example != nil {
+		return s.Example
+	}
+	if depth > exampleDepth || len(s.Ref.String()) > 0 {
+		return nil // Recursive schemas keep their $refs
+	}
+
+	switch {
+	case s.Type.Contains("array") || s.Items != nil:
+		if s.Items == nil || s.Items.Schema == nil {
+			return []interface{}{}
+		}
+		return []interface{}{exampleValue(s.Items.Schema, fqns, depth+1)}
+
+	case s.Type.Contains("object") || len(s.Properties) > 0 || len(s.AllOf) > 0:
+		object := make(map[string]interface{})
+		for i := range s.AllOf {
+			if all, ok := exampleValue(&s.AllOf[i], fqns, depth+1).(map[string]interface{}); ok {
+				for name, value := range all {
+					object[name] = value
+				}
+			}
+		}
+		for name, property := range s.Properties {
+			property := property
+			object[name] = exampleValue(&property, append(fqns[:len(fqns):len(fqns)], name), depth+1)
+		}
+		return object
+	}
+
+	if value := synthesiseExample(s, fqns); value != nil {
+		return value
+	}
+	if len(s.Enum) > 0 {
+		return s.Enum[0]
+	}
+	switch {
+	case s.Type.Contains("integer"), s.Type.Contains("number"):
+		return 0
+	case s.Type.Contains("boolean"):
+		return true
+	case s.Type.Contains("string"):
+		if value, ok := exampleOfFormat(s.Format, rand.New(rand.NewSource(exampleSeed))); ok {
+			return value
+		}
+		return "string"
+	}
+	return nil
+}
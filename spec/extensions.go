@@ -0,0 +1,69 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package spec
+
+import (
+	"strings"
+
+	"github.com/go-openapi/spec"
+)
+
+// -----------------------------------------------------------------------------
+// recognisedExtensions are the vendor extensions that DapperDox interprets itself,
+// in lower case. Any others are passed through to the templates, so that overlays
+// can render organisation specific metadata.
+var recognisedExtensions = map[string]bool{
+	"x-category":              true,
+	"x-deprecatedversions":    true,
+	"x-displayorder":          true,
+	"x-enum-descriptions":     true,
+	"x-enum-varnames":         true,
+	"x-enumdescriptions":      true,
+	"x-excludefromoperations": true,
+	"x-explode":               true,
+	"x-logo":                  true,
+	"x-navigatemethodsbyname": true,
+	"x-operationname":         true,
+	"x-pathname":              true,
+	"x-ratelimit":             true,
+	"x-schema-name":           true,
+	"x-servers":               true,
+	"x-sortmethodsby":         true,
+	"x-style":                 true,
+	"x-taggroups":             true,
+	"x-version":               true,
+}
+
+// extensions returns the unrecognised vendor extensions of each of exts, keyed by their
+// name as written in the specification. Later extensions replace earlier ones of the
+// same name. It returns nil if there are none.
+func extensions(exts ...spec.Extensions) map[string]interface{} {
+	var found map[string]interface{}
+	for _, e := range exts {
+		for name, value := range e {
+			lower := strings.ToLower(name)
+			if !strings.HasPrefix(lower, "x-") || recognisedExtensions[lower] {
+				continue
+			}
+			if found == nil {
+				found = make(map[string]interface{})
+			}
+			found[name] = value
+		}
+	}
+	return found
+}
@@ -0,0 +1,118 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/logger"
+)
+
+// -----------------------------------------------------------------------------
+// fetchSpec downloads a specification to a temporary file, with the configured
+// timeouts and retries, so that a slow or flaky registry cannot hang startup. The
+// returned function removes the file.
+func fetchSpec(location string) (string, func(), error) {
+	body, err := fetchWithRetries(location)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// The extension tells the loader whether the document is JSON or YAML
+	ext := path.Ext(strings.SplitN(location, "?", 2)[0])
+	file, err := ioutil.TempFile("", "dapperdox-spec-*"+ext)
+	if err != nil {
+		return "", nil, err
+	}
+	defer file.Close()
+
+	remove := func() { os.Remove(file.Name()) }
+
+	if _, err = file.Write(body); err != nil {
+		remove()
+		return "", nil, err
+	}
+	return file.Name(), remove, nil
+}
+
+// -----------------------------------------------------------------------------
+// fetchWithRetries downloads a document with the configured timeouts and retries
+func fetchWithRetries(location string) ([]byte, error) {
+	cfg, _ := config.Get()
+
+	connectTimeout, err := time.ParseDuration(cfg.SpecConnectTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid spec-connect-timeout %s", cfg.SpecConnectTimeout)
+	}
+	readTimeout, err := time.ParseDuration(cfg.SpecReadTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid spec-read-timeout %s", cfg.SpecReadTimeout)
+	}
+
+	transport := http.DefaultTransport
+	if t, ok := transport.(*http.Transport); ok {
+		t = t.Clone()
+		t.DialContext = (&net.Dialer{Timeout: connectTimeout}).DialContext
+		t.TLSHandshakeTimeout = connectTimeout
+		transport = t
+	}
+	client := &http.Client{Transport: transport, Timeout: connectTimeout + readTimeout}
+
+	var body []byte
+	backoff := time.Second
+
+	for attempt := 0; ; attempt++ {
+		body, err = fetch(client, location)
+		if err == nil || attempt >= cfg.SpecFetchRetries {
+			break
+		}
+		logger.Warnf(nil, "Error fetching %s, retrying in %s: %s", location, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	return body, err
+}
+
+// -----------------------------------------------------------------------------
+
+func fetch(client *http.Client, location string) ([]byte, error) {
+	req, err := newSpecRequest(location)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
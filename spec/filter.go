@@ -0,0 +1,100 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"path"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/go-openapi/spec"
+)
+
+// -----------------------------------------------------------------------------
+// operationFilter leaves operations out of the documentation, as configured for a
+// specification, so that one specification can be published as several portals.
+type operationFilter struct {
+	basePath       string
+	includeTags    map[string]bool
+	excludeTags    map[string]bool
+	excludePaths   []string
+	excludeMethods map[string]bool
+}
+
+func newOperationFilter(specCfg *config.SpecConfig, basePath string) *operationFilter {
+	f := &operationFilter{
+		basePath:       strings.TrimSuffix(basePath, "/"),
+		includeTags:    stringSet(specCfg.IncludeTags),
+		excludeTags:    stringSet(specCfg.ExcludeTags),
+		excludePaths:   specCfg.ExcludePaths,
+		excludeMethods: make(map[string]bool),
+	}
+	for _, method := range specCfg.ExcludeMethods {
+		f.excludeMethods[strings.ToLower(method)] = true
+	}
+	return f
+}
+
+func stringSet(values []string) map[string]bool {
+	set := make(map[string]bool)
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
+
+// excludes is true if the operation is to be left out. Paths are matched as written
+// in the specification and with the base path, so a pattern can be written either way.
+func (f *operationFilter) excludes(o *spec.Operation, fullPath, method string) bool {
+	if f.excludeMethods[strings.ToLower(method)] {
+		return true
+	}
+	declaredPath := strings.TrimPrefix(fullPath, f.basePath)
+	for _, pattern := range f.excludePaths {
+		if PathMatches(pattern, declaredPath) || PathMatches(pattern, fullPath) {
+			return true
+		}
+	}
+
+	included := len(f.includeTags) == 0
+	for _, tag := range o.Tags {
+		if f.excludeTags[tag] {
+			return true
+		}
+		if f.includeTags[tag] {
+			included = true
+		}
+	}
+	return !included
+}
+
+// PathMatches matches a path against a pattern, in which * matches within a segment
+// of the path, and a trailing /** matches the path and every path below it.
+func PathMatches(pattern, p string) bool {
+	if strings.HasSuffix(pattern, "/**") {
+		pattern = strings.TrimSuffix(pattern, "/**")
+		n := len(strings.Split(pattern, "/"))
+		segments := strings.Split(p, "/")
+		if len(segments) < n {
+			return false
+		}
+		p = strings.Join(segments[:n], "/")
+	}
+	matched, _ := path.Match(pattern, p)
+	return matched
+}
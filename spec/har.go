@@ -0,0 +1,351 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/logger"
+)
+
+// Requests and responses recorded in HAR captures, such as those saved by browser
+// developer tools or an API gateway, are examples of the methods whose paths they
+// match. Credentials are redacted from them.
+
+// harExamples is the most examples of a method, each of a different status code
+const harExamples = 3
+
+// harBodyLimit is the size of the largest body shown. Larger ones are left out.
+const harBodyLimit = 64 * 1024
+
+const harRedacted = "[redacted]"
+
+// harRedactedHeaders carry credentials, and are redacted whatever the security
+// schemes of a method
+var harRedactedHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+	"x-api-key":           true,
+	"x-auth-token":        true,
+}
+
+var harPathParameter = regexp.MustCompile(`{[^}]+}`)
+
+// Exchange is a request and its response, recorded in a HAR capture
+type Exchange struct {
+	Source     string // Name of the file it was recorded in
+	URL        string // Path and query of the request
+	Request    RecordedMessage
+	Status     int
+	StatusText string
+	Response   RecordedMessage
+}
+
+// RecordedMessage is the headers and body of a recorded request or response
+type RecordedMessage struct {
+	Headers   []RecordedHeader
+	MediaType string
+	Body      string // Pretty printed if JSON. Empty if binary or too large.
+}
+
+type RecordedHeader struct {
+	Name  string
+	Value string
+}
+
+// HAREntry is an entry of a HAR file, of which only the parts used are read
+type HAREntry struct {
+	StartedDateTime string  `json:"startedDateTime"`
+	Time            float64 `json:"time"` // Milliseconds
+	Request         struct {
+		Method   string           `json:"method"`
+		URL      string           `json:"url"`
+		Headers  []RecordedHeader `json:"headers"`
+		PostData struct {
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+		} `json:"postData"`
+	} `json:"request"`
+	Response struct {
+		Status     int              `json:"status"`
+		StatusText string           `json:"statusText"`
+		Headers    []RecordedHeader `json:"headers"`
+		Content    struct {
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+			Encoding string `json:"encoding"`
+		} `json:"content"`
+	} `json:"response"`
+	source string
+}
+
+// -----------------------------------------------------------------------------
+// readHAR reads the entries of HAR files
+func readHAR(files []string) ([]HAREntry, error) {
+	var entries []HAREntry
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading HAR file %s: %s", file, err)
+		}
+		var har struct {
+			Log struct {
+				Entries []HAREntry `json:"entries"`
+			} `json:"log"`
+		}
+		if err = json.Unmarshal(data, &har); err != nil {
+			return nil, fmt.Errorf("error reading HAR file %s: %s", file, err)
+		}
+		for _, entry := range har.Log.Entries {
+			entry.source = filepath.Base(file)
+			entries = append(entries, entry)
+		}
+		logger.Infof(nil, "Read %d requests from HAR file %s", len(har.Log.Entries), file)
+	}
+	return entries, nil
+}
+
+// -----------------------------------------------------------------------------
+// addRecordedExamples adds the entries that match the methods of a specification to
+// them, as examples. Each entry is added to the method with the most specific path
+// that it matches, of every version of the API that has it.
+func (c *APISpecification) addRecordedExamples(entries []HAREntry) int {
+	type candidate struct {
+		method   *Method
+		pattern  *regexp.Regexp
+		literals int // Characters of the path that are not parameters
+	}
+	var candidates []candidate
+	add := func(methods []Method) {
+		for i := range methods {
+			m := &methods[i]
+			parts := harPathParameter.Split(m.Path, -1)
+			for p := range parts {
+				parts[p] = regexp.QuoteMeta(parts[p])
+			}
+			pattern := regexp.MustCompile("^" + strings.Join(parts, "[^/]+") + "$")
+			candidates = append(candidates, candidate{m, pattern, len(harPathParameter.ReplaceAllString(m.Path, ""))})
+		}
+	}
+	for a := range c.APIs {
+		api := &c.APIs[a]
+		add(api.Methods)
+		for version := range api.Versions {
+			add(api.Versions[version])
+		}
+	}
+
+	matched := 0
+	for _, entry := range entries {
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil {
+			continue
+		}
+		var best []*Method
+		bestLiterals := -1
+		for _, candidate := range candidates {
+			if !strings.EqualFold(candidate.method.Method, entry.Request.Method) || !candidate.pattern.MatchString(u.Path) {
+				continue
+			}
+			if candidate.literals > bestLiterals {
+				best = nil
+				bestLiterals = candidate.literals
+			}
+			if candidate.literals == bestLiterals {
+				best = append(best, candidate.method)
+			}
+		}
+		for _, method := range best {
+			if addExchange(method, entry, u) {
+				matched++
+			}
+		}
+	}
+	return matched
+}
+
+// addExchange adds an entry to the examples of a method, unless it already has one of
+// the same status code, or enough
+func addExchange(method *Method, entry HAREntry, u *url.URL) bool {
+	if len(method.Recorded) >= harExamples {
+		return false
+	}
+	for _, exchange := range method.Recorded {
+		if exchange.Status == entry.Response.Status {
+			return false
+		}
+	}
+
+	// Credentials of the method's security schemes are redacted, besides the usual ones
+	var schemes []*SecurityScheme
+	for _, security := range method.Security {
+		schemes = append(schemes, security.Scheme)
+	}
+	headers, queries := apiKeys(schemes)
+
+	target := u.Path
+	if query := redactQuery(u, queries); len(query) > 0 {
+		target += "?" + query
+	}
+
+	body := entry.Response.Content.Text
+	if entry.Response.Content.Encoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			decoded = nil
+		}
+		body = string(decoded)
+	}
+
+	statusText := entry.Response.StatusText
+	if len(statusText) == 0 {
+		statusText = http.StatusText(entry.Response.Status)
+	}
+
+	method.Recorded = append(method.Recorded, Exchange{
+		Source:     entry.source,
+		URL:        target,
+		Status:     entry.Response.Status,
+		StatusText: statusText,
+		Request: RecordedMessage{
+			Headers:   recordedHeaders(entry.Request.Headers, headers),
+			MediaType: entry.Request.PostData.MimeType,
+			Body:      recordedBody(entry.Request.PostData.MimeType, entry.Request.PostData.Text),
+		},
+		Response: RecordedMessage{
+			Headers:   recordedHeaders(entry.Response.Headers, headers),
+			MediaType: entry.Response.Content.MimeType,
+			Body:      recordedBody(entry.Response.Content.MimeType, body),
+		},
+	})
+	return true
+}
+
+// RecordExample redacts the credentials from an exchange recorded by the recording
+// proxy, and adds it to the examples of the methods it matches. It reports whether the
+// exchange became an example, and so is worth keeping.
+func RecordExample(entry *HAREntry, source string) bool {
+	var schemes []*SecurityScheme
+	for _, specification := range APISuite {
+		for name := range specification.SecurityDefinitions {
+			scheme := specification.SecurityDefinitions[name]
+			schemes = append(schemes, &scheme)
+		}
+	}
+	entry.Sanitise(schemes)
+	entry.source = source
+
+	added := false
+	for _, specification := range APISuite {
+		if specification.addRecordedExamples([]HAREntry{*entry}) > 0 {
+			added = true
+		}
+	}
+	return added
+}
+
+// Sanitise redacts credentials from an entry: the usual headers, and the API keys of
+// security schemes
+func (e *HAREntry) Sanitise(schemes []*SecurityScheme) {
+	headers, queries := apiKeys(schemes)
+	e.Request.Headers = recordedHeaders(e.Request.Headers, headers)
+	e.Response.Headers = recordedHeaders(e.Response.Headers, headers)
+	if u, err := url.Parse(e.Request.URL); err == nil && len(u.RawQuery) > 0 {
+		u.RawQuery = redactQuery(u, queries)
+		e.Request.URL = u.String()
+	}
+}
+
+// apiKeys are the lower case names of the headers, and the names of the query
+// parameters, that carry the API keys of security schemes
+func apiKeys(schemes []*SecurityScheme) (map[string]bool, map[string]bool) {
+	headers := map[string]bool{}
+	queries := map[string]bool{}
+	for _, scheme := range schemes {
+		if scheme == nil || !scheme.IsApiKey {
+			continue
+		}
+		switch scheme.ParamLocation {
+		case "header":
+			headers[strings.ToLower(scheme.ParamName)] = true
+		case "query":
+			queries[scheme.ParamName] = true
+		}
+	}
+	return headers, queries
+}
+
+// redactQuery is the query of a URL, with the values of the named parameters redacted
+func redactQuery(u *url.URL, names map[string]bool) string {
+	query := u.Query()
+	for name := range names {
+		if _, ok := query[name]; ok {
+			query.Set(name, harRedacted)
+		}
+	}
+	return strings.Replace(query.Encode(), url.QueryEscape(harRedacted), harRedacted, -1)
+}
+
+// recordedHeaders redacts credentials from recorded headers, and leaves out the
+// pseudo-headers of HTTP/2
+func recordedHeaders(recorded []RecordedHeader, credentials map[string]bool) []RecordedHeader {
+	var headers []RecordedHeader
+	for _, header := range recorded {
+		if strings.HasPrefix(header.Name, ":") {
+			continue
+		}
+		name := strings.ToLower(header.Name)
+		if harRedactedHeaders[name] || credentials[name] {
+			header.Value = harRedacted
+		}
+		headers = append(headers, header)
+	}
+	return headers
+}
+
+// recordedBody is a body as it is shown: pretty printed if JSON, and left out if it
+// is binary or too large
+func recordedBody(mediaType string, body string) string {
+	if len(body) > harBodyLimit {
+		return ""
+	}
+	mediaType = strings.ToLower(mediaType)
+	switch {
+	case strings.Contains(mediaType, "json"):
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, []byte(body), "", "    "); err == nil {
+			return indented.String()
+		}
+		return body
+	case strings.HasPrefix(mediaType, "text/"), strings.Contains(mediaType, "xml"),
+		strings.Contains(mediaType, "x-www-form-urlencoded"), strings.Contains(mediaType, "javascript"):
+		return body
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"sort"
+
+	"github.com/dapperdox/dapperdox/logger"
+)
+
+// LinkedResource is the same resource as documented by another specification
+type LinkedResource struct {
+	SpecID    string
+	SpecTitle string
+	Resource  *Resource
+}
+
+// -----------------------------------------------------------------------------
+// linkResources links resources that several specifications share, so that each
+// resource page can show where else it is used. Resources are the same if they
+// have the same title, or with mode "definition", also an identical schema.
+func linkResources(specs []*APISpecification, mode string) {
+	if mode != "title" && mode != "definition" {
+		if mode != "" && mode != "off" {
+			logger.Errorf(nil, "Error: Invalid spec-link-resources value %s", mode)
+		}
+		return
+	}
+
+	type key struct{ version, title string }
+	shared := make(map[key][]*APISpecification)
+	found := make(map[key][]*Resource)
+
+	for _, s := range specs {
+		for version, resources := range s.ResourceList {
+			for _, resource := range resources {
+				if len(resource.Title) == 0 {
+					continue
+				}
+				k := key{version, resource.Title}
+				shared[k] = append(shared[k], s)
+				found[k] = append(found[k], resource)
+			}
+		}
+	}
+
+	for k, resources := range found {
+		for i, resource := range resources {
+			for j, other := range resources {
+				if i == j || shared[k][i] == shared[k][j] {
+					continue
+				}
+				if mode == "definition" && resource.Schema != other.Schema {
+					continue
+				}
+				resource.Linked = append(resource.Linked, LinkedResource{
+					SpecID:    shared[k][j].ID,
+					SpecTitle: shared[k][j].APIInfo.Title,
+					Resource:  other,
+				})
+			}
+			sort.Slice(resource.Linked, func(a, b int) bool { return resource.Linked[a].SpecID < resource.Linked[b].SpecID })
+		}
+	}
+}
@@ -0,0 +1,292 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/logger"
+)
+
+// -----------------------------------------------------------------------------
+// mergeSpecifications combines several loaded specifications into one. API groups
+// of the same ID are collated, in the order that they first appear across the
+// specifications, which are taken in configuration order. Methods and resources
+// whose IDs clash are given unique IDs, so that every page has its own URL. It fails if
+// the nav.yaml of the merged specification cannot be read.
+func mergeSpecifications(title string, specs []*APISpecification) (*APISpecification, error) {
+	merged := &APISpecification{
+		ID:                  TitleToKebab(title),
+		APIInfo:             Info{Title: title},
+		SecurityDefinitions: make(map[string]SecurityScheme),
+		DefaultSecurity:     make(map[string]Security),
+		ResourceList:        make(map[string]map[string]*Resource),
+	}
+
+	cfg, _ := config.Get()
+	specCfg := cfg.Spec(merged.ID)
+	merged.Theme = specCfg.Theme
+	merged.DefaultVersion = specCfg.DefaultVersion
+	merged.Hidden = specCfg.Hidden
+	merged.Category = specCfg.Category
+	merged.Branding = getBranding(nil, specCfg)
+	merged.Deprecations = make(map[string]*VersionDeprecation)
+
+	groups := make(map[string]int) // API group ID -> index in merged.APIs
+
+	for _, s := range specs {
+		logger.Debugf(nil, "Merging specification '%s'", s.APIInfo.Title)
+
+		if len(specs) == 1 {
+			merged.APIInfo.Description = s.APIInfo.Description
+			merged.APIInfo.Excerpt = s.APIInfo.Excerpt
+			merged.APIInfo.Logo = s.APIInfo.Logo
+			merged.APIInfo.Contact = s.APIInfo.Contact
+			merged.APIInfo.License = s.APIInfo.License
+			merged.APIInfo.TermsOfService = s.APIInfo.TermsOfService
+			merged.APIInfo.Terms = s.APIInfo.Terms
+			merged.Branding = getBranding(s.APIInfo.Logo, specCfg)
+			merged.RateLimits = s.RateLimits
+		}
+		if len(merged.URL) == 0 {
+			merged.URL = s.URL
+		}
+		for _, server := range s.Servers {
+			if !hasServer(merged.Servers, server.URL) {
+				merged.Servers = append(merged.Servers, server)
+			}
+		}
+		if len(merged.Category) == 0 {
+			merged.Category = s.Category
+		}
+
+		for name, scheme := range s.SecurityDefinitions {
+			merged.SecurityDefinitions[name] = scheme
+		}
+		for name, security := range s.DefaultSecurity {
+			merged.DefaultSecurity[name] = security
+		}
+		merged.DefaultRequirements = append(merged.DefaultRequirements, s.DefaultRequirements...)
+		merged.Models = append(merged.Models, s.Models...)
+		for name, value := range s.Extensions {
+			if merged.Extensions == nil {
+				merged.Extensions = make(map[string]interface{})
+			}
+			merged.Extensions[name] = value
+		}
+		for version, deprecation := range s.Deprecations {
+			merged.Deprecations[version] = deprecation
+		}
+
+		// Resources first, so that renamed resources are linked from the methods that use them
+		for _, version := range sortedVersions(s.ResourceList) {
+			if merged.ResourceList[version] == nil {
+				merged.ResourceList[version] = make(map[string]*Resource)
+			}
+			resources := s.ResourceList[version]
+			for _, id := range sortedResourceIDs(resources) {
+				resource := resources[id]
+				if existing, ok := merged.ResourceList[version][id]; ok {
+					if existing == resource || existing.Schema == resource.Schema {
+						continue // The same resource, shared between specifications
+					}
+					resource.ID = uniqueResourceID(merged.ResourceList[version], id+"-"+s.ID)
+				}
+				merged.ResourceList[version][resource.ID] = resource
+			}
+		}
+
+		for _, api := range s.APIs {
+			if len(specs) > 1 {
+				// Each specification's limits are for its own methods, so they are listed with them
+				for _, methods := range api.Versions {
+					for m := range methods {
+						methods[m].OwnRateLimits = len(methods[m].RateLimits) > 0
+					}
+				}
+			}
+			i, ok := groups[api.ID]
+			if !ok {
+				groups[api.ID] = len(merged.APIs)
+				versions := make(map[string][]Method)
+				for version, methods := range api.Versions {
+					versions[version] = append([]Method(nil), methods...)
+				}
+				api.Versions = versions
+				merged.APIs = append(merged.APIs, api)
+				continue
+			}
+
+			group := &merged.APIs[i]
+			for version, methods := range api.Versions {
+				for _, method := range methods {
+					method.ID = uniqueMethodID(group.Versions[version], method.ID)
+					group.Versions[version] = append(group.Versions[version], method)
+				}
+			}
+			group.Consumes = appendMissing(group.Consumes, api.Consumes)
+			group.Produces = appendMissing(group.Produces, api.Produces)
+		}
+	}
+
+	for i := range merged.APIs {
+		merged.setCurrentVersion(&merged.APIs[i])
+	}
+	merged.APIVersions = groupByVersion(merged.APIs)
+	merged.OrderedVersions = allVersions(merged.APIs)
+	merged.Errors = merged.errorCatalogue()
+	sortModels(merged.Models)
+	nav, err := LoadNavigation(merged.ID)
+	if err != nil {
+		return nil, err
+	}
+	merged.applyNavigation(nav, nil)
+	if merged.TagGroups == nil {
+		merged.TagGroups = mergeTagGroups(specs, merged.APIs)
+	}
+	merged.NavigationResources = merged.navigationResources()
+	for version, deprecation := range specCfg.Deprecations {
+		merged.Deprecations[version] = newDeprecation(version, deprecation)
+	}
+
+	return merged, nil
+}
+
+// -----------------------------------------------------------------------------
+// mergeTagGroups concatenates the tag groups of the specifications, collating groups of
+// the same name, with the merged API groups in them. API groups that are in no group are
+// listed last. It is nil if none of the specifications group their APIs.
+func mergeTagGroups(specs []*APISpecification, apis APISet) []TagGroup {
+	byID := make(map[string]APIGroup)
+	for _, api := range apis {
+		byID[api.ID] = api
+	}
+	grouped := make(map[string]bool)
+	index := make(map[string]int) // Group name -> index in groups
+
+	var groups []TagGroup
+	for _, s := range specs {
+		for _, group := range s.TagGroups {
+			if len(group.Name) == 0 {
+				continue // Listed with the rest of the ungrouped APIs
+			}
+			i, ok := index[group.Name]
+			if !ok {
+				i = len(groups)
+				index[group.Name] = i
+				groups = append(groups, TagGroup{ID: group.ID, Name: group.Name})
+			}
+			for _, api := range group.APIs {
+				if a, ok := byID[api.ID]; ok && !grouped[api.ID] {
+					groups[i].APIs = append(groups[i].APIs, a)
+					grouped[api.ID] = true
+				}
+			}
+		}
+	}
+	if groups == nil {
+		return nil
+	}
+
+	ungrouped := TagGroup{}
+	for _, api := range apis {
+		if !grouped[api.ID] {
+			ungrouped.APIs = append(ungrouped.APIs, api)
+		}
+	}
+	if len(ungrouped.APIs) > 0 {
+		groups = append(groups, ungrouped)
+	}
+	return groups
+}
+
+func hasServer(servers []Server, url string) bool {
+	for _, server := range servers {
+		if server.URL == url {
+			return true
+		}
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------
+
+func uniqueMethodID(methods []Method, id string) string {
+	unique := id
+	for n := 2; ; n++ {
+		clash := false
+		for _, m := range methods {
+			if m.ID == unique {
+				clash = true
+				break
+			}
+		}
+		if !clash {
+			return unique
+		}
+		unique = id + "-" + strconv.Itoa(n)
+	}
+}
+
+func uniqueResourceID(resources map[string]*Resource, id string) string {
+	unique := id
+	for n := 2; ; n++ {
+		if _, clash := resources[unique]; !clash {
+			return unique
+		}
+		unique = id + "-" + strconv.Itoa(n)
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+func sortedVersions(m map[string]map[string]*Resource) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedResourceIDs(m map[string]*Resource) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func appendMissing(list []string, items []string) []string {
+	for _, item := range items {
+		found := false
+		for _, l := range list {
+			if l == item {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,78 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"sort"
+
+	"github.com/go-openapi/spec"
+)
+
+// -----------------------------------------------------------------------------
+// getModels documents every definition as a resource of each version, so that models
+// that no operation references still get pages. A definition that an operation does
+// reference keeps the resource built for it, which lists its methods. The models are
+// returned in title order, as resources of the newest version.
+func (c *APISpecification) getModels(definitions spec.Definitions, versions []string) []*Resource {
+	if c.ResourceList == nil {
+		c.ResourceList = make(map[string]map[string]*Resource)
+	}
+
+	names := make([]string, 0, len(definitions))
+	for name := range definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var models []*Resource
+	for _, name := range names {
+		var model *Resource
+		for _, version := range versions {
+			if c.ResourceList[version] == nil {
+				c.ResourceList[version] = make(map[string]*Resource)
+			}
+
+			definition := definitions[name]
+			schema := resourceSchema(&definition)
+			r, example, isArray := c.resourceFromSchema(&definition, &Method{}, nil, false)
+			if r == nil {
+				continue
+			}
+			if existing, ok := c.ResourceList[version][r.ID]; ok {
+				r = existing
+			} else {
+				r.Schema = jsonResourceToString(example, isArray)
+				r.schema = schema
+				r.origin = Definition
+				c.ResourceList[version][r.ID] = r
+			}
+			if model == nil {
+				model = r
+			}
+		}
+		if model != nil {
+			models = append(models, model)
+		}
+	}
+	sortModels(models)
+	return models
+}
+
+func sortModels(models []*Resource) {
+	sort.SliceStable(models, func(i, j int) bool { return models[i].Title < models[j].Title })
+}
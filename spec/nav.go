@@ -0,0 +1,222 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/logger"
+	"gopkg.in/yaml.v2"
+)
+
+// Navigation is the editorial order of a specification's navigation, read from the
+// nav.yaml file beside its section assets, as sections/<spec-id>/nav.yaml in the assets
+// directory, or nav.yaml in the assets directory for the guides of the site. Anything
+// that it does not list follows what it does, in the order it would have without it.
+type Navigation struct {
+	Groups    []NavigationGroup `yaml:"groups"`    // Headed groups of APIs, replacing x-tagGroups
+	APIs      []NavigationAPI   `yaml:"apis"`      // APIs in order, when they are not grouped
+	Resources []string          `yaml:"resources"` // Resource IDs
+	Guides    []string          `yaml:"guides"`    // Guide paths below the guides directory, such as intro/authentication
+}
+
+type NavigationGroup struct {
+	Name string          `yaml:"name"`
+	APIs []NavigationAPI `yaml:"apis"`
+}
+
+// NavigationAPI is an API group, by ID or tag name, with its methods by ID
+type NavigationAPI struct {
+	ID      string   `yaml:"id"`
+	Methods []string `yaml:"methods"`
+}
+
+// -----------------------------------------------------------------------------
+// LoadNavigation reads the nav.yaml of a specification, or of the site if specID is
+// empty. It returns nil if there is none, and an error if it cannot be read or parsed.
+func LoadNavigation(specID string) (*Navigation, error) {
+	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+	if len(cfg.AssetsDir) == 0 {
+		return nil, nil
+	}
+	name := filepath.Join(cfg.AssetsDir, "nav.yaml")
+	if len(specID) > 0 {
+		name = filepath.Join(cfg.AssetsDir, "sections", specID, "nav.yaml")
+	}
+
+	data, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %s", name, err)
+	}
+	logger.Infof(nil, "Ordering navigation from %s", name)
+
+	var nav Navigation
+	if err = yaml.Unmarshal(data, &nav); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %s", name, err)
+	}
+	return &nav, nil
+}
+
+// GuideOrder returns the position of each listed guide, keyed by path
+func (nav *Navigation) GuideOrder() map[string]int {
+	return positions(nav.Guides)
+}
+
+func positions(ids []string) map[string]int {
+	order := make(map[string]int)
+	for i, id := range ids {
+		if _, ok := order[id]; !ok {
+			order[id] = i
+		}
+	}
+	return order
+}
+
+// -----------------------------------------------------------------------------
+// applyNavigation orders the APIs, their methods and the resources as nav.yaml lists
+// them, and groups the APIs if it has groups. tagAPIs maps tag names to API IDs.
+func (c *APISpecification) applyNavigation(nav *Navigation, tagAPIs map[string]string) {
+	c.Navigation = nav
+	if nav == nil {
+		return
+	}
+
+	apis := nav.APIs
+	for _, group := range nav.Groups {
+		apis = append(apis, group.APIs...)
+	}
+	apiID := func(id string) string {
+		if tagID, ok := tagAPIs[id]; ok {
+			return tagID
+		}
+		return id
+	}
+
+	var ids []string
+	methods := make(map[string][]string)
+	for _, api := range apis {
+		ids = append(ids, apiID(api.ID))
+		methods[apiID(api.ID)] = api.Methods
+	}
+	order := positions(ids)
+	sort.SliceStable(c.APIs, func(i, j int) bool {
+		return listedBefore(order, c.APIs[i].ID, c.APIs[j].ID)
+	})
+
+	for _, api := range c.APIs {
+		methodOrder := positions(methods[api.ID])
+		for _, versionMethods := range api.Versions {
+			sort.SliceStable(versionMethods, func(i, j int) bool {
+				return listedBefore(methodOrder, versionMethods[i].ID, versionMethods[j].ID)
+			})
+		}
+	}
+
+	if len(nav.Groups) > 0 {
+		byID := make(map[string]APIGroup)
+		for _, api := range c.APIs {
+			byID[api.ID] = api
+		}
+		grouped := make(map[string]bool)
+
+		c.TagGroups = nil
+		for _, group := range nav.Groups {
+			tagGroup := TagGroup{ID: TitleToKebab(group.Name), Name: group.Name}
+			for _, api := range group.APIs {
+				id := apiID(api.ID)
+				if a, ok := byID[id]; ok && !grouped[id] {
+					tagGroup.APIs = append(tagGroup.APIs, a)
+					grouped[id] = true
+				}
+			}
+			c.TagGroups = append(c.TagGroups, tagGroup)
+		}
+
+		ungrouped := TagGroup{}
+		for _, api := range c.APIs {
+			if !grouped[api.ID] {
+				ungrouped.APIs = append(ungrouped.APIs, api)
+			}
+		}
+		if len(ungrouped.APIs) > 0 {
+			c.TagGroups = append(c.TagGroups, ungrouped)
+		}
+	}
+}
+
+// listedBefore is true if a is listed in order before b, or if a is listed and b is not
+func listedBefore(order map[string]int, a, b string) bool {
+	ia, aListed := order[a]
+	ib, bListed := order[b]
+	if aListed && bListed {
+		return ia < ib
+	}
+	return aListed && !bListed
+}
+
+// -----------------------------------------------------------------------------
+// VersionResources are the resources of a version, in navigation order
+type VersionResources struct {
+	Version   string
+	Resources []*Resource
+}
+
+// navigationResources lists the resources of each version in the order of nav.yaml,
+// and then by ID.
+func (c *APISpecification) navigationResources() []VersionResources {
+	var order map[string]int
+	if c.Navigation != nil {
+		order = positions(c.Navigation.Resources)
+	}
+
+	var list []VersionResources
+	for _, version := range sortedVersions(c.ResourceList) {
+		var resources []*Resource
+		for _, id := range sortedResourceIDs(c.ResourceList[version]) {
+			resources = append(resources, c.ResourceList[version][id])
+		}
+		sort.SliceStable(resources, func(i, j int) bool {
+			return listedBefore(order, resources[i].ID, resources[j].ID)
+		})
+		list = append(list, VersionResources{Version: version, Resources: resources})
+	}
+	return list
+}
+
+// MethodCount is the number of methods the reference navigation lists, of the current
+// version of each API group and of the other versions
+func (c *APISpecification) MethodCount() int {
+	count := 0
+	for _, api := range c.APIs {
+		count += len(api.Methods)
+	}
+	for _, apis := range c.APIVersions {
+		for _, api := range apis {
+			count += len(api.Methods)
+		}
+	}
+	return count
+}
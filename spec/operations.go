@@ -0,0 +1,93 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/go-openapi/spec"
+)
+
+// -----------------------------------------------------------------------------
+// extraOperations reads the operations of each path that spec.PathItem has no field
+// for, and so are lost when the specification is loaded: trace, and the custom methods
+// of an OpenAPI 3.2 additionalOperations map, such as QUERY. They are read from the raw
+// document, with their parameters and responses expanded against the specification.
+// The result is keyed by path, then lower case method.
+func extraOperations(raw json.RawMessage, root *spec.Swagger) map[string]map[string]*spec.Operation {
+	var document struct {
+		Paths map[string]struct {
+			Trace                *spec.Operation            `json:"trace"`
+			AdditionalOperations map[string]*spec.Operation `json:"additionalOperations"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(raw, &document); err != nil {
+		logger.Warnf(nil, "Error reading trace and custom method operations: %s", err)
+		return nil
+	}
+
+	extra := make(map[string]map[string]*spec.Operation)
+	add := func(path, method string, o *spec.Operation) {
+		if o == nil {
+			return
+		}
+		for i := range o.Parameters {
+			if err := spec.ExpandParameterWithRoot(&o.Parameters[i], root, nil); err != nil {
+				logger.Warnf(nil, "Error expanding parameter of %s %s: %s", method, path, err)
+			}
+		}
+		if o.Responses != nil {
+			if o.Responses.Default != nil {
+				if err := spec.ExpandResponseWithRoot(o.Responses.Default, root, nil); err != nil {
+					logger.Warnf(nil, "Error expanding default response of %s %s: %s", method, path, err)
+				}
+			}
+			for status, response := range o.Responses.StatusCodeResponses {
+				if err := spec.ExpandResponseWithRoot(&response, root, nil); err != nil {
+					logger.Warnf(nil, "Error expanding response %d of %s %s: %s", status, method, path, err)
+				}
+				o.Responses.StatusCodeResponses[status] = response
+			}
+		}
+		if extra[path] == nil {
+			extra[path] = make(map[string]*spec.Operation)
+		}
+		extra[path][strings.ToLower(method)] = o
+	}
+
+	for path, item := range document.Paths {
+		add(path, "trace", item.Trace)
+		for method, o := range item.AdditionalOperations {
+			add(path, method, o)
+		}
+	}
+	return extra
+}
+
+// sortedMethods returns the methods of a path's extra operations in alphabetical order
+func sortedMethods(operations map[string]*spec.Operation) []string {
+	var methods []string
+	for method := range operations {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
@@ -0,0 +1,212 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/go-openapi/spec"
+)
+
+// -----------------------------------------------------------------------------
+// orderedPaths returns the paths in the order that they are declared in the raw
+// specification document, so that untagged specifications render the same way on
+// every load. Any path not found in the document follows, sorted.
+func orderedPaths(raw json.RawMessage, paths map[string]spec.PathItem) []string {
+	var ordered []string
+	seen := make(map[string]bool)
+
+	for _, path := range declaredPaths(raw) {
+		if _, ok := paths[path]; ok && !seen[path] {
+			ordered = append(ordered, path)
+			seen[path] = true
+		}
+	}
+
+	var rest []string
+	for path := range paths {
+		if !seen[path] {
+			rest = append(rest, path)
+		}
+	}
+	sort.Strings(rest)
+
+	return append(ordered, rest...)
+}
+
+// -----------------------------------------------------------------------------
+// declaredPaths reads the keys of the top level paths object, in document order.
+// Maps lose the order, so the document is read as a stream of tokens.
+func declaredPaths(raw json.RawMessage) []string {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return nil
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil
+		}
+		if key != "paths" {
+			var skip json.RawMessage
+			if err = dec.Decode(&skip); err != nil {
+				return nil
+			}
+			continue
+		}
+
+		if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+			return nil
+		}
+		var paths []string
+		for dec.More() {
+			path, err := dec.Token()
+			if err != nil {
+				return paths
+			}
+			if p, ok := path.(string); ok {
+				paths = append(paths, p)
+			}
+			var skip json.RawMessage
+			if err = dec.Decode(&skip); err != nil {
+				return paths
+			}
+		}
+		return paths
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// declaredProperties reads the property names of every properties object in the raw
+// documents, in document order. As the schemas are expanded without their location,
+// each order is keyed by the set of names it orders, so that an expanded schema can
+// find its declaration order by its property names.
+func declaredProperties(raws ...json.RawMessage) map[string][]string {
+	orders := make(map[string][]string)
+	for _, raw := range raws {
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		if t, err := dec.Token(); err == nil {
+			walkProperties(dec, t, orders)
+		}
+	}
+	return orders
+}
+
+// walkProperties reads the value that starts with token t, recording the order of the
+// properties objects within it.
+func walkProperties(dec *json.Decoder, t json.Token, orders map[string][]string) error {
+	switch t {
+	case json.Delim('{'):
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			value, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if key != "properties" || value != json.Delim('{') {
+				if err = walkProperties(dec, value, orders); err != nil {
+					return err
+				}
+				continue
+			}
+
+			var names []string
+			for dec.More() {
+				name, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				if n, ok := name.(string); ok {
+					names = append(names, n)
+				}
+				schema, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				if err = walkProperties(dec, schema, orders); err != nil {
+					return err
+				}
+			}
+			if _, err = dec.Token(); err != nil {
+				return err
+			}
+			// The first declaration of a set of names wins
+			if _, ok := orders[propertySetKey(names)]; !ok {
+				orders[propertySetKey(names)] = names
+			}
+		}
+		_, err := dec.Token()
+		return err
+
+	case json.Delim('['):
+		for dec.More() {
+			value, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if err = walkProperties(dec, value, orders); err != nil {
+				return err
+			}
+		}
+		_, err := dec.Token()
+		return err
+	}
+	return nil
+}
+
+func propertySetKey(names []string) string {
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, "\n")
+}
+
+// -----------------------------------------------------------------------------
+// propertyNames returns the names of a schema's properties in the order that they
+// are declared, or sorted if the declaration cannot be found.
+func (c *APISpecification) propertyNames(s *spec.Schema) []string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names) // As propertySetKey would, without copying them
+	if declared, ok := c.declaredProperties[strings.Join(names, "\n")]; ok {
+		return declared
+	}
+	return names
+}
+
+// orderProperties sorts a resource's property names as configured by property-order.
+// They are already in declaration order, which the sorts keep for equal properties.
+func (c *APISpecification) orderProperties(r *Resource) {
+	switch c.propertyOrder {
+	case "name":
+		sort.Strings(r.PropertyNames)
+	case "required":
+		sort.SliceStable(r.PropertyNames, func(i, j int) bool {
+			return r.Properties[r.PropertyNames[i]].Required && !r.Properties[r.PropertyNames[j]].Required
+		})
+	}
+}
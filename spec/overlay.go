@@ -0,0 +1,207 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+// Specification overlays enrich a loaded specification without editing it. An
+// overlay file is either a JSON Merge Patch (RFC 7386), or an OpenAPI Overlay
+// document of actions, whose targets are JSONPath expressions limited to child
+// names ($.paths['/pets'].get), wildcards (.* and [*]) and array indexes ([0]).
+// Either may be written in JSON or YAML.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// -----------------------------------------------------------------------------
+// applyOverlays applies each overlay file in turn to the raw specification document
+func applyOverlays(raw []byte, files []string) ([]byte, error) {
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		overlay, err := readOverlay(file)
+		if err != nil {
+			return nil, err
+		}
+
+		if o, ok := overlay.(map[string]interface{}); ok && o["overlay"] != nil {
+			doc, err = applyOverlayActions(doc, o)
+			if err != nil {
+				return nil, fmt.Errorf("error applying overlay %s: %s", file, err)
+			}
+		} else {
+			doc = mergePatch(doc, overlay)
+		}
+	}
+
+	return json.Marshal(doc)
+}
+
+// -----------------------------------------------------------------------------
+
+func readOverlay(file string) (interface{}, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var overlay interface{}
+	if err = yaml.Unmarshal(data, &overlay); err != nil {
+		return nil, fmt.Errorf("error parsing overlay %s: %s", file, err)
+	}
+	return normalizeYAML(overlay), nil
+}
+
+// normalizeYAML converts the map[interface{}]interface{} that YAML decodes to into the
+// map[string]interface{} that JSON decodes to, so that the two can be merged.
+func normalizeYAML(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprintf("%v", key)] = normalizeYAML(value)
+		}
+		return m
+	case []interface{}:
+		for i := range v {
+			v[i] = normalizeYAML(v[i])
+		}
+	}
+	return v
+}
+
+// -----------------------------------------------------------------------------
+// mergePatch applies an RFC 7386 JSON Merge Patch: objects are merged recursively,
+// null removes a member, and anything else replaces the target.
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{})
+	}
+	for key, value := range p {
+		if value == nil {
+			delete(t, key)
+		} else {
+			t[key] = mergePatch(t[key], value)
+		}
+	}
+	return t
+}
+
+// -----------------------------------------------------------------------------
+
+func applyOverlayActions(doc interface{}, overlay map[string]interface{}) (interface{}, error) {
+	actions, _ := overlay["actions"].([]interface{})
+
+	for _, a := range actions {
+		action, ok := a.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("action is not an object")
+		}
+		target, _ := action["target"].(string)
+		path, err := parseJSONPath(target)
+		if err != nil {
+			return nil, err
+		}
+
+		remove, _ := action["remove"].(bool)
+		update := action["update"]
+
+		doc = walkJSONPath(doc, path, func(node interface{}) (interface{}, bool) {
+			if remove {
+				return nil, false
+			}
+			return mergePatch(node, update), true
+		})
+	}
+	return doc, nil
+}
+
+// -----------------------------------------------------------------------------
+
+var jsonPathSegment = regexp.MustCompile(`^(?:\.([A-Za-z0-9_\-$]+|\*)|\['([^']*)'\]|\["([^"]*)"\]|\[(\*|\d+)\])`)
+
+func parseJSONPath(target string) ([]string, error) {
+	if !strings.HasPrefix(target, "$") {
+		return nil, fmt.Errorf("target %s must start with $", target)
+	}
+	var path []string
+	rest := target[1:]
+	for len(rest) > 0 {
+		m := jsonPathSegment.FindStringSubmatch(rest)
+		if m == nil {
+			return nil, fmt.Errorf("unsupported target %s", target)
+		}
+		path = append(path, m[1]+m[2]+m[3]+m[4])
+		rest = rest[len(m[0]):]
+	}
+	return path, nil
+}
+
+// walkJSONPath calls fn with every node the path selects, replacing each with the
+// result, or removing it if fn returns false.
+func walkJSONPath(node interface{}, path []string, fn func(interface{}) (interface{}, bool)) interface{} {
+	if len(path) == 0 {
+		result, keep := fn(node)
+		if !keep {
+			return nil
+		}
+		return result
+	}
+
+	segment, rest := path[0], path[1:]
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for key, child := range n {
+			if segment != "*" && segment != key {
+				continue
+			}
+			if updated := walkJSONPath(child, rest, fn); updated == nil && len(rest) == 0 {
+				delete(n, key)
+			} else {
+				n[key] = updated
+			}
+		}
+	case []interface{}:
+		var kept []interface{}
+		for i, child := range n {
+			if segment != "*" && segment != strconv.Itoa(i) {
+				kept = append(kept, child)
+				continue
+			}
+			if updated := walkJSONPath(child, rest, fn); updated != nil || len(rest) > 0 {
+				kept = append(kept, updated)
+			}
+		}
+		return kept
+	}
+	return node
+}
@@ -0,0 +1,121 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/logger"
+)
+
+// remoteState holds the cache validators last returned for a remote specification,
+// and the sha256 of its content
+type remoteState struct {
+	etag         string
+	lastModified string
+	sum          [sha256.Size]byte
+}
+
+// -----------------------------------------------------------------------------
+// PollRemote re-fetches the URL based specifications every interval, using
+// conditional requests so that an unchanged specification costs only a 304.
+// changed is called when any of them has changed. It does not return.
+func PollRemote(interval time.Duration, changed func()) {
+	cfg, _ := config.Get()
+
+	state := make(map[string]*remoteState)
+	for _, location := range cfg.SpecFilename {
+		if !isLocalSpecUrl(location) {
+			state[location] = &remoteState{}
+		}
+	}
+	if len(state) == 0 {
+		return
+	}
+
+	logger.Infof(nil, "Polling %d remote specification(s) every %s", len(state), interval)
+
+	client := &http.Client{Timeout: interval}
+
+	// The first poll only records the validators, as the specifications are already loaded
+	for location, s := range state {
+		s.poll(client, location)
+	}
+
+	for range time.Tick(interval) {
+		modified := false
+		for location, s := range state {
+			if s.poll(client, location) {
+				logger.Infof(nil, "Remote specification %s has changed", location)
+				modified = true
+			}
+		}
+		if modified {
+			changed()
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------
+// poll makes a conditional request for the specification, returning true if it has changed
+func (s *remoteState) poll(client *http.Client, location string) bool {
+	req, err := newSpecRequest(location)
+	if err != nil {
+		logger.Errorf(nil, "Error polling %s: %s", location, err)
+		return false
+	}
+	if len(s.etag) > 0 {
+		req.Header.Set("If-None-Match", s.etag)
+	}
+	if len(s.lastModified) > 0 {
+		req.Header.Set("If-Modified-Since", s.lastModified)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		logger.Warnf(nil, "Error polling %s: %s", location, err)
+		return false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode != http.StatusNotModified {
+			logger.Warnf(nil, "Error polling %s: status %d", location, resp.StatusCode)
+		}
+		return false
+	}
+
+	// A server that gives no validators cannot answer conditionally, so the content
+	// is compared with that last fetched.
+	hash := sha256.New()
+	if _, err := io.Copy(hash, resp.Body); err != nil {
+		logger.Warnf(nil, "Error polling %s: %s", location, err)
+		return false
+	}
+
+	previous := s.sum
+	s.etag = resp.Header.Get("ETag")
+	s.lastModified = resp.Header.Get("Last-Modified")
+	copy(s.sum[:], hash.Sum(nil))
+
+	return previous != s.sum
+}
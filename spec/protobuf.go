@@ -0,0 +1,396 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-openapi/loads"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// gRPC APIs are documented from a protobuf FileDescriptorSet, as written by
+// protoc --descriptor_set_out --include_source_info, or a buf image from buf build.
+// The set is converted to the OpenAPI document that the rest of the loader reads:
+// each service is an API group, each RPC a POST method at /<package>.<service>/<rpc>,
+// as gRPC sends it, and each message and enum a definition. Comments in the .proto
+// files become descriptions.
+
+// descriptorExtensions are the file extensions of descriptor sets
+var descriptorExtensions = []string{".pb", ".binpb", ".protoset"}
+
+// packageVersion finds a version such as v1 at the end of a package name
+var packageVersion = regexp.MustCompile(`\.(v[0-9]+[a-z0-9]*)$`)
+
+// Source code info paths, from the field numbers of descriptor.proto
+const (
+	fileMessagePath   = 4
+	fileEnumPath      = 5
+	fileServicePath   = 6
+	messageFieldPath  = 2
+	messageNestedPath = 3
+	messageEnumPath   = 4
+	serviceMethodPath = 2
+	enumValuePath     = 2
+)
+
+// wellKnownTypes are the JSON schemas of the google.protobuf types, which have a
+// JSON form of their own
+var wellKnownTypes = map[string]map[string]interface{}{
+	"google.protobuf.Timestamp":   {"type": "string", "format": "date-time"},
+	"google.protobuf.Duration":    {"type": "string", "example": "1.5s"},
+	"google.protobuf.FieldMask":   {"type": "string", "example": "name,address.city"},
+	"google.protobuf.Empty":       {"type": "object", "title": "Empty"},
+	"google.protobuf.Struct":      {"type": "object", "title": "Struct"},
+	"google.protobuf.Value":       {},
+	"google.protobuf.ListValue":   {"type": "array", "items": map[string]interface{}{}},
+	"google.protobuf.Any":         {"type": "object", "title": "Any", "properties": map[string]interface{}{"@type": map[string]interface{}{"type": "string"}}},
+	"google.protobuf.DoubleValue": {"type": "number", "format": "double"},
+	"google.protobuf.FloatValue":  {"type": "number", "format": "float"},
+	"google.protobuf.Int64Value":  {"type": "string", "format": "int64"},
+	"google.protobuf.UInt64Value": {"type": "string", "format": "uint64"},
+	"google.protobuf.Int32Value":  {"type": "integer", "format": "int32"},
+	"google.protobuf.UInt32Value": {"type": "integer", "format": "int64"},
+	"google.protobuf.BoolValue":   {"type": "boolean"},
+	"google.protobuf.StringValue": {"type": "string"},
+	"google.protobuf.BytesValue":  {"type": "string", "format": "byte"},
+}
+
+// -----------------------------------------------------------------------------
+
+func isDescriptorSet(location string) bool {
+	ext := path.Ext(strings.SplitN(location, "?", 2)[0])
+	for _, e := range descriptorExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------
+// loadDescriptorSet reads the descriptor set in file as an OpenAPI document
+func loadDescriptorSet(file string) (*loads.Document, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err = proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("error reading protobuf descriptor set: %s", err)
+	}
+
+	doc, err := descriptorSetDocument(&set)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return loads.Analyzed(raw, "")
+}
+
+// -----------------------------------------------------------------------------
+// descriptorSetDocument builds the OpenAPI document of the services of a descriptor set
+func descriptorSetDocument(set *descriptorpb.FileDescriptorSet) (map[string]interface{}, error) {
+	definitions := map[string]interface{}{}
+	paths := map[string]interface{}{}
+	var tags []interface{}
+	var packages []string
+	seen := map[string]bool{}
+
+	for _, file := range set.GetFile() {
+		comments := sourceComments(file)
+		prefix := ""
+		if len(file.GetPackage()) > 0 {
+			prefix = file.GetPackage() + "."
+		}
+
+		for i, message := range file.GetMessageType() {
+			addMessage(definitions, message, prefix, comments, []int32{fileMessagePath, int32(i)})
+		}
+		for i, enum := range file.GetEnumType() {
+			definitions[prefix+enum.GetName()] = enumSchema(enum, comments, []int32{fileEnumPath, int32(i)})
+		}
+
+		if len(file.GetService()) > 0 && !seen[file.GetPackage()] {
+			seen[file.GetPackage()] = true
+			packages = append(packages, file.GetPackage())
+		}
+		for s, service := range file.GetService() {
+			tags = append(tags, map[string]interface{}{
+				"name":        service.GetName(),
+				"description": comments[pathKey(fileServicePath, int32(s))],
+			})
+			for m, method := range service.GetMethod() {
+				route := "/" + prefix + service.GetName() + "/" + method.GetName()
+				paths[route] = map[string]interface{}{
+					"post": rpcOperation(service, method, comments[pathKey(fileServicePath, int32(s), serviceMethodPath, int32(m))]),
+				}
+			}
+		}
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("protobuf descriptor set has no services")
+	}
+
+	// Messages of other files are only defined if the set includes their files, as
+	// protoc --include_imports does. The well known types are always defined, if used.
+	used, err := json.Marshal([]interface{}{paths, definitions})
+	if err != nil {
+		return nil, err
+	}
+	for name, schema := range wellKnownTypes {
+		if strings.Contains(string(used), `"#/definitions/`+name+`"`) {
+			definitions[name] = schema
+		}
+	}
+
+	title := strings.Join(packages, ", ")
+	info := map[string]interface{}{"title": title, "version": ""}
+	if len(packages) == 1 {
+		if version := packageVersion.FindStringSubmatch(packages[0]); version != nil {
+			info["version"] = version[1]
+		}
+	}
+	if len(title) == 0 {
+		info["title"] = "gRPC API"
+	}
+
+	return map[string]interface{}{
+		"swagger":                 "2.0",
+		"info":                    info,
+		"consumes":                []string{"application/grpc"},
+		"produces":                []string{"application/grpc"},
+		"tags":                    tags,
+		"paths":                   paths,
+		"definitions":             definitions,
+		"x-navigateMethodsByName": true,
+	}, nil
+}
+
+// -----------------------------------------------------------------------------
+// rpcOperation documents an RPC as an operation. Streamed requests and responses are
+// arrays of their messages, as far as the documentation goes.
+func rpcOperation(service *descriptorpb.ServiceDescriptorProto, method *descriptorpb.MethodDescriptorProto, comment string) map[string]interface{} {
+	request := messageRef(method.GetInputType())
+	response := messageRef(method.GetOutputType())
+
+	streaming := ""
+	switch {
+	case method.GetClientStreaming() && method.GetServerStreaming():
+		streaming = "bidirectional"
+	case method.GetClientStreaming():
+		streaming = "client"
+	case method.GetServerStreaming():
+		streaming = "server"
+	}
+	if method.GetClientStreaming() {
+		request = map[string]interface{}{"type": "array", "items": request}
+	}
+	if method.GetServerStreaming() {
+		response = map[string]interface{}{"type": "array", "items": response}
+	}
+
+	summary := strings.SplitN(comment, "\n", 2)[0]
+	if len(summary) == 0 {
+		summary = method.GetName()
+	}
+
+	operation := map[string]interface{}{
+		"tags":        []string{service.GetName()},
+		"operationId": method.GetName(),
+		"summary":     summary,
+		"description": comment,
+		"parameters": []interface{}{
+			map[string]interface{}{"name": "body", "in": "body", "required": true, "schema": request},
+		},
+		"responses": map[string]interface{}{
+			"200": map[string]interface{}{"description": "OK", "schema": response},
+		},
+		"deprecated": method.GetOptions().GetDeprecated() || service.GetOptions().GetDeprecated(),
+	}
+	if len(streaming) > 0 {
+		operation["x-grpc-streaming"] = streaming
+	}
+	return operation
+}
+
+// -----------------------------------------------------------------------------
+// addMessage adds the definition of a message, and of the messages and enums nested
+// in it. Map entries are not added, as map fields are objects.
+func addMessage(definitions map[string]interface{}, message *descriptorpb.DescriptorProto, prefix string, comments map[string]string, at []int32) {
+	if message.GetOptions().GetMapEntry() {
+		return
+	}
+	name := prefix + message.GetName()
+
+	mapEntries := map[string]*descriptorpb.DescriptorProto{}
+	for i, nested := range message.GetNestedType() {
+		if nested.GetOptions().GetMapEntry() {
+			mapEntries["."+name+"."+nested.GetName()] = nested
+		}
+		addMessage(definitions, nested, name+".", comments, append(append([]int32{}, at...), messageNestedPath, int32(i)))
+	}
+	for i, enum := range message.GetEnumType() {
+		definitions[name+"."+enum.GetName()] = enumSchema(enum, comments, append(append([]int32{}, at...), messageEnumPath, int32(i)))
+	}
+
+	properties := map[string]interface{}{}
+	for i, field := range message.GetField() {
+		schema := fieldSchema(field, mapEntries)
+		if comment := comments[pathKey(append(append([]int32{}, at...), messageFieldPath, int32(i))...)]; len(comment) > 0 {
+			schema["description"] = comment
+		}
+		properties[jsonName(field)] = schema
+	}
+
+	definitions[name] = map[string]interface{}{
+		"type":        "object",
+		"title":       message.GetName(),
+		"description": comments[pathKey(at...)],
+		"properties":  properties,
+	}
+}
+
+// -----------------------------------------------------------------------------
+// fieldSchema is the JSON schema of a field, following the proto3 JSON mapping, in
+// which 64 bit integers are strings.
+func fieldSchema(field *descriptorpb.FieldDescriptorProto, mapEntries map[string]*descriptorpb.DescriptorProto) map[string]interface{} {
+	if entry, ok := mapEntries[field.GetTypeName()]; ok && len(entry.GetField()) == 2 {
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": fieldSchema(entry.GetField()[1], mapEntries),
+		}
+	}
+
+	var schema map[string]interface{}
+	switch field.GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_DOUBLE:
+		schema = map[string]interface{}{"type": "number", "format": "double"}
+	case descriptorpb.FieldDescriptorProto_TYPE_FLOAT:
+		schema = map[string]interface{}{"type": "number", "format": "float"}
+	case descriptorpb.FieldDescriptorProto_TYPE_INT32, descriptorpb.FieldDescriptorProto_TYPE_SINT32, descriptorpb.FieldDescriptorProto_TYPE_SFIXED32:
+		schema = map[string]interface{}{"type": "integer", "format": "int32"}
+	case descriptorpb.FieldDescriptorProto_TYPE_UINT32, descriptorpb.FieldDescriptorProto_TYPE_FIXED32:
+		schema = map[string]interface{}{"type": "integer", "format": "int64"}
+	case descriptorpb.FieldDescriptorProto_TYPE_INT64, descriptorpb.FieldDescriptorProto_TYPE_SINT64, descriptorpb.FieldDescriptorProto_TYPE_SFIXED64:
+		schema = map[string]interface{}{"type": "string", "format": "int64"}
+	case descriptorpb.FieldDescriptorProto_TYPE_UINT64, descriptorpb.FieldDescriptorProto_TYPE_FIXED64:
+		schema = map[string]interface{}{"type": "string", "format": "uint64"}
+	case descriptorpb.FieldDescriptorProto_TYPE_BOOL:
+		schema = map[string]interface{}{"type": "boolean"}
+	case descriptorpb.FieldDescriptorProto_TYPE_BYTES:
+		schema = map[string]interface{}{"type": "string", "format": "byte"}
+	case descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, descriptorpb.FieldDescriptorProto_TYPE_GROUP, descriptorpb.FieldDescriptorProto_TYPE_ENUM:
+		schema = messageRef(field.GetTypeName())
+	default:
+		schema = map[string]interface{}{"type": "string"}
+	}
+
+	if field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED {
+		schema = map[string]interface{}{"type": "array", "items": schema}
+	}
+	if field.GetOptions().GetDeprecated() {
+		schema["x-deprecated"] = true
+	}
+	return schema
+}
+
+// -----------------------------------------------------------------------------
+// enumSchema is the JSON schema of an enum, whose values are their names in JSON
+func enumSchema(enum *descriptorpb.EnumDescriptorProto, comments map[string]string, at []int32) map[string]interface{} {
+	var values []string
+	descriptions := map[string]string{}
+	for i, value := range enum.GetValue() {
+		values = append(values, value.GetName())
+		if comment := comments[pathKey(append(append([]int32{}, at...), enumValuePath, int32(i))...)]; len(comment) > 0 {
+			descriptions[value.GetName()] = comment
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":        "string",
+		"title":       enum.GetName(),
+		"description": comments[pathKey(at...)],
+		"enum":        values,
+	}
+	if len(descriptions) > 0 {
+		schema["x-enumDescriptions"] = descriptions
+	}
+	return schema
+}
+
+// -----------------------------------------------------------------------------
+// messageRef refers to the definition of a fully qualified type name, such as
+// .helloworld.HelloRequest
+func messageRef(typeName string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/definitions/" + strings.TrimPrefix(typeName, ".")}
+}
+
+func jsonName(field *descriptorpb.FieldDescriptorProto) string {
+	if name := field.GetJsonName(); len(name) > 0 {
+		return name
+	}
+	return field.GetName()
+}
+
+// -----------------------------------------------------------------------------
+// sourceComments are the comments of a file's declarations, keyed by pathKey of their
+// source code info path. Leading comments are preferred to trailing ones.
+func sourceComments(file *descriptorpb.FileDescriptorProto) map[string]string {
+	comments := map[string]string{}
+	for _, location := range file.GetSourceCodeInfo().GetLocation() {
+		comment := location.GetLeadingComments()
+		if len(strings.TrimSpace(comment)) == 0 {
+			comment = location.GetTrailingComments()
+		}
+		if comment = cleanComment(comment); len(comment) > 0 {
+			comments[pathKey(location.GetPath()...)] = comment
+		}
+	}
+	return comments
+}
+
+// cleanComment removes the space that protoc leaves after each // of a comment
+func cleanComment(comment string) string {
+	lines := strings.Split(strings.TrimSpace(comment), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func pathKey(path ...int32) string {
+	parts := make([]string, len(path))
+	for i, p := range path {
+		parts[i] = strconv.Itoa(int(p))
+	}
+	return strings.Join(parts, ".")
+}
+
+// -----------------------------------------------------------------------------
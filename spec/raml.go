@@ -0,0 +1,1242 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/go-openapi/loads"
+	"gopkg.in/yaml.v2"
+)
+
+// RAML 1.0 specifications are converted to the OpenAPI document that the rest of the
+// loader reads. Resource types and traits are applied to the resources and methods that
+// use them, types become definitions, and each top level resource is an API group.
+// Includes and libraries are read relative to the specification.
+
+// ramlInclude matches an !include tag. Tags are lost by the YAML decoder, so they are
+// turned into strings before decoding.
+var ramlInclude = regexp.MustCompile(`!include\s+([^\s#,\]}]+)`)
+
+// ramlParameter matches a resource type or trait parameter, such as
+// <<resourcePathName | !singularize>>
+var ramlParameter = regexp.MustCompile(`<<\s*([A-Za-z0-9_]+)\s*((?:\|\s*![a-z]+\s*)*)>>`)
+
+// ramlUriParameter matches the URI parameters of a resource path
+var ramlUriParameter = regexp.MustCompile(`{([^}]+)}`)
+
+var ramlMethods = []string{"get", "put", "post", "delete", "options", "head", "patch"}
+
+// ramlGrants maps the OAuth 2.0 grants of RAML to the flows of OpenAPI, in the order
+// of preference, as OpenAPI 2.0 allows one flow per security scheme.
+var ramlGrants = []struct{ grant, flow string }{
+	{"authorization_code", "accessCode"},
+	{"implicit", "implicit"},
+	{"password", "password"},
+	{"client_credentials", "application"},
+}
+
+// ramlIncludeDepth limits includes of includes, in case they refer to each other
+const ramlIncludeDepth = 16
+
+// ramlLoader holds the declarations of a RAML specification and its libraries, keyed
+// by name. Library declarations are prefixed by their namespace, as they are used.
+type ramlLoader struct {
+	types         map[string]interface{}
+	traits        map[string]interface{}
+	resourceTypes map[string]interface{}
+	schemes       map[string]interface{}
+	definitions   map[string]interface{}
+	security      map[string]interface{}
+	mediaTypes    []string
+}
+
+// -----------------------------------------------------------------------------
+
+func isRAML(location string) bool {
+	return path.Ext(strings.SplitN(location, "?", 2)[0]) == ".raml"
+}
+
+// -----------------------------------------------------------------------------
+// loadRAML reads the RAML specification in file as an OpenAPI document. Includes are
+// relative to base, where the specification came from.
+func loadRAML(file string, base string) (*loads.Document, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	header := strings.TrimSpace(strings.SplitN(string(data), "\n", 2)[0])
+	if !strings.HasPrefix(header, "#%RAML 1.0") {
+		return nil, fmt.Errorf("%s is not a RAML 1.0 specification", base)
+	}
+	if header != "#%RAML 1.0" {
+		return nil, fmt.Errorf("%s is a RAML fragment (%s), not an API", base, strings.TrimPrefix(header, "#%RAML 1.0 "))
+	}
+
+	root, err := parseRAML(data, base, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode the top level again, to list the resources in the order they were written
+	var order yaml.MapSlice
+	if err = yaml.Unmarshal(ramlInclude.ReplaceAll(data, []byte(`"!include $1"`)), &order); err != nil {
+		return nil, err
+	}
+	var resources []string
+	for _, item := range order {
+		if key := fmt.Sprint(item.Key); strings.HasPrefix(key, "/") {
+			resources = append(resources, key)
+		}
+	}
+
+	l := &ramlLoader{
+		types:         map[string]interface{}{},
+		traits:        map[string]interface{}{},
+		resourceTypes: map[string]interface{}{},
+		schemes:       map[string]interface{}{},
+		definitions:   map[string]interface{}{},
+	}
+	if err = l.declare(root, "", base, 0); err != nil {
+		return nil, err
+	}
+
+	doc, err := l.document(root, resources)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return loads.Analyzed(raw, "")
+}
+
+// -----------------------------------------------------------------------------
+// parseRAML decodes a RAML document or library
+func parseRAML(data []byte, base string, depth int) (map[string]interface{}, error) {
+	value, err := decodeRAML(data, base, depth)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return map[string]interface{}{}, nil
+	}
+	document, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s is not a RAML document", base)
+	}
+	return document, nil
+}
+
+// decodeRAML decodes a RAML document, or a YAML document it includes, replacing its
+// includes with what they include
+func decodeRAML(data []byte, base string, depth int) (interface{}, error) {
+	var value interface{}
+	if err := yaml.Unmarshal(ramlInclude.ReplaceAll(data, []byte(`"!include $1"`)), &value); err != nil {
+		return nil, fmt.Errorf("error reading %s: %s", base, err)
+	}
+	return resolveIncludes(normalizeYAML(value), base, depth)
+}
+
+func resolveIncludes(value interface{}, base string, depth int) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if !strings.HasPrefix(v, "!include ") {
+			return v, nil
+		}
+		if depth >= ramlIncludeDepth {
+			return nil, fmt.Errorf("includes of %s are nested too deeply", base)
+		}
+		location := ramlLocation(base, strings.TrimPrefix(v, "!include "))
+		data, err := readRAML(location)
+		if err != nil {
+			return nil, fmt.Errorf("error including %s: %s", location, err)
+		}
+		switch path.Ext(location) {
+		case ".raml", ".yaml", ".yml":
+			return decodeRAML(data, location, depth+1)
+		}
+		return string(data), nil // JSON and XML schemas, examples and documentation
+	case map[string]interface{}:
+		for key, item := range v {
+			resolved, err := resolveIncludes(item, base, depth)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = resolved
+		}
+	case []interface{}:
+		for i, item := range v {
+			resolved, err := resolveIncludes(item, base, depth)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = resolved
+		}
+	}
+	return value, nil
+}
+
+// ramlLocation is where a document referred to by another one at base is
+func ramlLocation(base string, ref string) string {
+	if !isLocalSpecUrl(ref) {
+		return ref
+	}
+	if isLocalSpecUrl(base) {
+		if filepath.IsAbs(ref) {
+			return ref
+		}
+		return filepath.Join(filepath.Dir(base), ref)
+	}
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return baseURL.ResolveReference(refURL).String()
+}
+
+func readRAML(location string) ([]byte, error) {
+	if isLocalSpecUrl(location) {
+		return ioutil.ReadFile(location)
+	}
+	return fetchWithRetries(location)
+}
+
+// -----------------------------------------------------------------------------
+// declare records the types, traits, resource types and security schemes declared by a
+// specification or library, and by the libraries it uses.
+func (l *ramlLoader) declare(document map[string]interface{}, prefix string, base string, depth int) error {
+	for kind, declarations := range map[string]map[string]interface{}{
+		"types":           l.types,
+		"schemas":         l.types, // Deprecated in RAML 1.0, but still found
+		"traits":          l.traits,
+		"resourceTypes":   l.resourceTypes,
+		"securitySchemes": l.schemes,
+	} {
+		for name, declaration := range asMap(document[kind]) {
+			declarations[prefix+name] = declaration
+		}
+	}
+
+	uses := asMap(document["uses"])
+	for _, namespace := range sortedKeys(uses) {
+		if depth >= ramlIncludeDepth {
+			return fmt.Errorf("libraries of %s are nested too deeply", base)
+		}
+		location := ramlLocation(base, asString(uses[namespace]))
+		data, err := readRAML(location)
+		if err != nil {
+			return fmt.Errorf("error reading library %s: %s", location, err)
+		}
+		library, err := parseRAML(data, location, depth+1)
+		if err != nil {
+			return err
+		}
+		if err = l.declare(library, prefix+namespace+".", location, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// document builds the OpenAPI document of a RAML specification
+func (l *ramlLoader) document(root map[string]interface{}, resources []string) (map[string]interface{}, error) {
+	title := asString(root["title"])
+	if len(title) == 0 {
+		title = "RAML API"
+	}
+	version := asString(root["version"])
+
+	description := asString(root["description"])
+	for _, item := range asSlice(root["documentation"]) {
+		section := asMap(item)
+		description += "\n\n## " + asString(section["title"]) + "\n\n" + asString(section["content"])
+	}
+
+	doc := map[string]interface{}{
+		"swagger": "2.0",
+		"info": map[string]interface{}{
+			"title":       title,
+			"version":     version,
+			"description": strings.TrimSpace(description),
+		},
+	}
+
+	setBaseURI(doc, strings.Replace(asString(root["baseUri"]), "{version}", version, -1))
+	if protocols := asSlice(root["protocols"]); len(protocols) > 0 {
+		var schemes []string
+		for _, protocol := range protocols {
+			schemes = append(schemes, strings.ToLower(asString(protocol)))
+		}
+		doc["schemes"] = schemes
+	}
+
+	l.mediaTypes = asStrings(asSlice(root["mediaType"]))
+	if len(l.mediaTypes) == 0 {
+		l.mediaTypes = []string{"application/json"}
+	}
+	doc["consumes"] = l.mediaTypes
+	doc["produces"] = l.mediaTypes
+
+	// Types are defined before they are used by parameters, which cannot refer to them
+	for _, name := range sortedKeys(l.types) {
+		schema := l.schema(l.types[name])
+		if _, ok := schema["title"]; !ok && schema["$ref"] == nil {
+			schema["title"] = name[strings.LastIndex(name, ".")+1:]
+		}
+		l.definitions[name] = schema
+	}
+	if len(l.definitions) > 0 {
+		doc["definitions"] = l.definitions
+	}
+
+	if l.security = l.securityDefinitions(); len(l.security) > 0 {
+		doc["securityDefinitions"] = l.security
+	}
+	if security := l.requirements(root["securedBy"]); security != nil {
+		doc["security"] = security
+	}
+
+	paths := map[string]interface{}{}
+	var tags []interface{}
+	for _, key := range resources {
+		resource := l.applyResourceType(asMap(root[key]), key, 0)
+		tag := asString(resource["displayName"])
+		if len(tag) == 0 {
+			tag = strings.TrimPrefix(key, "/")
+		}
+		if len(tag) == 0 {
+			tag = title
+		}
+		tags = append(tags, map[string]interface{}{
+			"name":        tag,
+			"description": asString(resource["description"]),
+		})
+		l.addResource(paths, key, resource, tag, nil, root["securedBy"])
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("RAML specification has no methods")
+	}
+	doc["tags"] = tags
+	doc["paths"] = paths
+	return doc, nil
+}
+
+// setBaseURI sets the host, base path and scheme of an OpenAPI document from the
+// URI that the API is at. URIs with parameters in the host are left out.
+func setBaseURI(doc map[string]interface{}, baseURI string) {
+	u, err := url.Parse(baseURI)
+	if len(baseURI) == 0 || err != nil || strings.Contains(u.Host, "{") {
+		return
+	}
+	if len(u.Host) > 0 {
+		doc["host"] = u.Host
+	}
+	if basePath := strings.TrimSuffix(u.Path, "/"); len(basePath) > 0 {
+		doc["basePath"] = basePath
+	}
+	if len(u.Scheme) > 0 {
+		doc["schemes"] = []string{strings.ToLower(u.Scheme)}
+	}
+}
+
+// -----------------------------------------------------------------------------
+// addResource adds the methods of a resource, whose resource type has been applied,
+// and of the resources nested in it
+func (l *ramlLoader) addResource(paths map[string]interface{}, route string, resource map[string]interface{}, tag string, uriParameters []interface{}, securedBy interface{}) {
+	if secured, ok := resource["securedBy"]; ok {
+		securedBy = secured
+	}
+	uriParameters = append(append([]interface{}{}, uriParameters...), l.parameters(resource["uriParameters"], "path")...)
+
+	operations := map[string]interface{}{}
+	for _, method := range ramlMethods {
+		declaration, ok := resource[method]
+		if !ok {
+			continue
+		}
+		m := asMap(declaration)
+		traits := append(append([]interface{}{}, asSlice(resource["is"])...), asSlice(m["is"])...)
+		m = l.applyTraits(m, traits, map[string]string{
+			"resourcePath":     route,
+			"resourcePathName": resourcePathName(route),
+			"methodName":       method,
+		})
+		operations[method] = l.operation(method, route, m, tag, uriParameters, securedBy)
+	}
+	if len(operations) > 0 {
+		paths[route] = operations
+	}
+
+	for _, key := range sortedKeys(resource) {
+		if !strings.HasPrefix(key, "/") {
+			continue
+		}
+		child := l.applyResourceType(asMap(resource[key]), route+key, 0)
+		l.addResource(paths, route+key, child, tag, uriParameters, securedBy)
+	}
+}
+
+// -----------------------------------------------------------------------------
+// operation documents a method of a resource
+func (l *ramlLoader) operation(method string, route string, m map[string]interface{}, tag string, uriParameters []interface{}, securedBy interface{}) map[string]interface{} {
+	description := asString(m["description"])
+	summary := asString(m["displayName"])
+	if len(summary) == 0 {
+		summary = strings.TrimSpace(strings.SplitN(description, "\n", 2)[0])
+	}
+	if len(summary) == 0 {
+		summary = strings.ToUpper(method) + " " + route
+	}
+
+	// Every URI parameter of the path is declared once, the innermost declaration winning
+	declared := map[string]interface{}{}
+	for _, parameter := range uriParameters {
+		declared[asString(asMap(parameter)["name"])] = parameter
+	}
+	var parameters []interface{}
+	for _, match := range ramlUriParameter.FindAllStringSubmatch(route, -1) {
+		parameter, ok := declared[match[1]]
+		if !ok {
+			parameter = map[string]interface{}{"name": match[1], "in": "path", "required": true, "type": "string"}
+		}
+		parameters = append(parameters, parameter)
+	}
+	parameters = append(parameters, l.parameters(m["queryParameters"], "query")...)
+	parameters = append(parameters, l.parameters(m["headers"], "header")...)
+
+	operation := map[string]interface{}{
+		"tags":        []string{tag},
+		"summary":     summary,
+		"description": description,
+	}
+
+	if body, ok := m["body"]; ok {
+		mediaTypes, declaration, chosen := l.body(body)
+		operation["consumes"] = mediaTypes
+		if chosen == "application/x-www-form-urlencoded" || chosen == "multipart/form-data" {
+			parameters = append(parameters, l.parameters(l.properties(declaration), "formData")...)
+		} else {
+			schema := l.schema(declaration)
+			if example, ok := ramlExample(declaration, chosen); ok {
+				schema = withFacet(schema, "example", example)
+			}
+			parameters = append(parameters, map[string]interface{}{
+				"name": "body", "in": "body", "required": true, "schema": schema,
+			})
+		}
+	}
+	operation["parameters"] = parameters
+
+	responses := map[string]interface{}{}
+	var produces []string
+	declarations := asMap(m["responses"])
+	for _, code := range sortedKeys(declarations) {
+		declaration := asMap(declarations[code])
+		response := map[string]interface{}{"description": asString(declaration["description"])}
+		if len(asString(response["description"])) == 0 {
+			status, _ := strconv.Atoi(code)
+			response["description"] = http.StatusText(status)
+		}
+
+		if headers := l.parameters(declaration["headers"], "header"); len(headers) > 0 {
+			h := map[string]interface{}{}
+			for _, header := range headers {
+				header := asMap(header)
+				name := asString(header["name"])
+				delete(header, "name")
+				delete(header, "in")
+				delete(header, "required")
+				h[name] = header
+			}
+			response["headers"] = h
+		}
+		if body, ok := declaration["body"]; ok {
+			mediaTypes, declaration, chosen := l.body(body)
+			response["schema"] = l.schema(declaration)
+			if example, ok := ramlExample(declaration, chosen); ok {
+				response["examples"] = map[string]interface{}{chosen: example}
+			}
+			for _, mediaType := range mediaTypes {
+				if !contains(produces, mediaType) {
+					produces = append(produces, mediaType)
+				}
+			}
+		}
+		responses[code] = response
+	}
+	if len(responses) == 0 {
+		responses["200"] = map[string]interface{}{"description": http.StatusText(http.StatusOK)}
+	}
+	operation["responses"] = responses
+	if len(produces) > 0 {
+		operation["produces"] = produces
+	}
+
+	if secured, ok := m["securedBy"]; ok {
+		securedBy = secured
+	}
+	if security := l.requirements(securedBy); security != nil {
+		operation["security"] = security
+	}
+	if deprecated, ok := m["(deprecated)"]; ok && deprecated != false {
+		operation["deprecated"] = true
+	}
+	return operation
+}
+
+// -----------------------------------------------------------------------------
+// body finds the media types of a body, and the type of the one documented. Bodies
+// without media types have the default media types of the specification.
+func (l *ramlLoader) body(body interface{}) ([]string, interface{}, string) {
+	declarations := asMap(body)
+	var mediaTypes []string
+	for _, key := range sortedKeys(declarations) {
+		if strings.Contains(key, "/") {
+			mediaTypes = append(mediaTypes, key)
+		}
+	}
+	if len(mediaTypes) == 0 {
+		return l.mediaTypes, body, l.mediaTypes[0]
+	}
+
+	chosen := mediaTypes[0]
+	for _, mediaType := range mediaTypes {
+		if strings.Contains(mediaType, "json") {
+			chosen = mediaType
+			break
+		}
+	}
+	return mediaTypes, declarations[chosen], chosen
+}
+
+// properties finds the properties of a type, following the types it is declared as
+func (l *ramlLoader) properties(declaration interface{}) interface{} {
+	for i := 0; i < ramlIncludeDepth; i++ {
+		switch d := declaration.(type) {
+		case map[string]interface{}:
+			if properties, ok := d["properties"]; ok {
+				return properties
+			}
+			declaration = d["type"]
+		case string:
+			declaration = l.types[l.typeName(d)]
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// schema is the JSON schema of a RAML type declaration
+func (l *ramlLoader) schema(declaration interface{}) map[string]interface{} {
+	switch d := declaration.(type) {
+	case nil:
+		return map[string]interface{}{"type": "string"}
+	case string:
+		return l.typeExpression(d)
+	case []interface{}:
+		var all []interface{}
+		for _, parent := range d {
+			all = append(all, l.schema(parent))
+		}
+		return map[string]interface{}{"allOf": all}
+	case map[string]interface{}:
+		return l.typeDeclaration(d)
+	}
+	return map[string]interface{}{"type": "string"}
+}
+
+func (l *ramlLoader) typeDeclaration(d map[string]interface{}) map[string]interface{} {
+	parent, ok := d["type"]
+	if !ok {
+		parent = d["schema"]
+	}
+
+	var schema map[string]interface{}
+	switch {
+	case parent != nil:
+		schema = l.schema(parent)
+	case d["properties"] != nil:
+		schema = map[string]interface{}{"type": "object"}
+	case d["items"] != nil:
+		schema = map[string]interface{}{"type": "array"}
+	default:
+		schema = map[string]interface{}{"type": "string"}
+	}
+
+	facets := map[string]interface{}{}
+	for _, facet := range []string{"description", "enum", "pattern", "minLength", "maxLength", "minimum", "maximum",
+		"multipleOf", "minItems", "maxItems", "uniqueItems", "minProperties", "maxProperties", "default",
+		"discriminator", "additionalProperties"} {
+		if value, ok := d[facet]; ok {
+			facets[facet] = value
+		}
+	}
+	if displayName, ok := d["displayName"]; ok {
+		facets["title"] = asString(displayName)
+	}
+	if format := asString(d["format"]); len(format) > 0 {
+		switch format {
+		case "int8", "int16":
+			format = "int32"
+		case "long":
+			format = "int64"
+		}
+		facets["format"] = format
+	}
+	if example, ok := ramlExample(d, ""); ok {
+		facets["example"] = example
+	}
+	if items, ok := d["items"]; ok {
+		facets["items"] = l.schema(items)
+	}
+	if _, ok := d["properties"]; ok {
+		properties := map[string]interface{}{}
+		var required []string
+		declarations := asMap(d["properties"])
+		for _, name := range sortedKeys(declarations) {
+			property := name
+			optional := strings.HasSuffix(name, "?")
+			if optional {
+				property = strings.TrimSuffix(name, "?")
+			}
+			if r, ok := asMap(declarations[name])["required"].(bool); ok {
+				optional = !r
+			}
+			properties[property] = l.schema(declarations[name])
+			if !optional {
+				required = append(required, property)
+			}
+		}
+		facets["properties"] = properties
+		if len(required) > 0 {
+			facets["required"] = required
+		}
+	}
+
+	// Siblings of a $ref are ignored, so a type refining another is all of it
+	if len(facets) == 0 {
+		return schema
+	}
+	if _, ok := schema["$ref"]; ok {
+		schema = map[string]interface{}{"allOf": []interface{}{schema}}
+	}
+	for facet, value := range facets {
+		schema[facet] = value
+	}
+	return schema
+}
+
+// typeExpression is the JSON schema of a type expression, such as Person[] or
+// string | nil. Inline JSON schemas are used as they are.
+func (l *ramlLoader) typeExpression(expression string) map[string]interface{} {
+	expression = strings.TrimSpace(expression)
+
+	if strings.HasPrefix(expression, "{") {
+		var schema map[string]interface{}
+		if err := json.Unmarshal([]byte(expression), &schema); err == nil {
+			delete(schema, "$schema")
+			delete(schema, "id")
+			return schema
+		}
+		return map[string]interface{}{"type": "object"}
+	}
+	if strings.HasPrefix(expression, "<") {
+		return map[string]interface{}{"type": "string", "format": "xml"}
+	}
+
+	if strings.HasSuffix(expression, "[]") {
+		return map[string]interface{}{"type": "array", "items": l.typeExpression(strings.TrimSuffix(expression, "[]"))}
+	}
+	if strings.HasPrefix(expression, "(") && strings.HasSuffix(expression, ")") {
+		return l.typeExpression(expression[1 : len(expression)-1])
+	}
+
+	// OpenAPI 2.0 has no unions, so the first type of one is documented
+	if members := strings.Split(expression, "|"); len(members) > 1 {
+		nullable := false
+		var schema map[string]interface{}
+		for _, member := range members {
+			if member = strings.TrimSpace(member); member == "nil" {
+				nullable = true
+			} else if schema == nil {
+				schema = l.typeExpression(member)
+			}
+		}
+		if schema == nil {
+			schema = map[string]interface{}{"type": "string"}
+		}
+		if nullable {
+			schema = withFacet(schema, "x-nullable", true)
+		}
+		return schema
+	}
+
+	switch expression {
+	case "string", "number", "integer", "boolean", "object":
+		return map[string]interface{}{"type": expression}
+	case "array":
+		return map[string]interface{}{"type": "array", "items": map[string]interface{}{}}
+	case "date-only":
+		return map[string]interface{}{"type": "string", "format": "date"}
+	case "datetime", "datetime-only":
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case "time-only":
+		return map[string]interface{}{"type": "string", "format": "time"}
+	case "file":
+		return map[string]interface{}{"type": "string", "format": "binary"}
+	case "nil":
+		return map[string]interface{}{"type": "string", "x-nullable": true}
+	case "any":
+		return map[string]interface{}{}
+	}
+
+	if name := l.typeName(expression); len(name) > 0 {
+		return map[string]interface{}{"$ref": "#/definitions/" + name}
+	}
+	logger.Warnf(nil, "RAML type %s is not declared", expression)
+	return map[string]interface{}{"type": "string"}
+}
+
+// typeName finds the declaration of a type. Types used within a library are declared
+// without its namespace, so are found by the end of their names.
+func (l *ramlLoader) typeName(name string) string {
+	if _, ok := l.types[name]; ok {
+		return name
+	}
+	for _, declared := range sortedKeys(l.types) {
+		if strings.HasSuffix(declared, "."+name) {
+			return declared
+		}
+	}
+	return ""
+}
+
+// -----------------------------------------------------------------------------
+// parameters documents URI and query parameters, headers and form fields. Parameters
+// are required unless they are marked optional.
+func (l *ramlLoader) parameters(declarations interface{}, in string) []interface{} {
+	var parameters []interface{}
+	d := asMap(declarations)
+	for _, name := range sortedKeys(d) {
+		declaration := d[name]
+		optional := strings.HasSuffix(name, "?")
+		if r, ok := asMap(declaration)["required"].(bool); ok {
+			optional = !r
+		}
+
+		schema := l.schema(declaration)
+		if ref, ok := schema["$ref"].(string); ok {
+			schema = asMap(l.definitions[strings.TrimPrefix(ref, "#/definitions/")])
+		}
+
+		parameter := map[string]interface{}{
+			"name":     strings.TrimSuffix(name, "?"),
+			"in":       in,
+			"required": in == "path" || !optional,
+			"type":     "string",
+		}
+		for _, facet := range []string{"description", "type", "format", "enum", "pattern", "minLength", "maxLength",
+			"minimum", "maximum", "multipleOf", "minItems", "maxItems", "uniqueItems", "default"} {
+			if value, ok := schema[facet]; ok {
+				parameter[facet] = value
+			}
+		}
+		switch parameter["type"] {
+		case "object", nil:
+			parameter["type"] = "string"
+		case "array":
+			items := asMap(schema["items"])
+			if items["type"] == nil || items["type"] == "object" {
+				items = map[string]interface{}{"type": "string"}
+			}
+			parameter["items"] = items
+			if in == "query" || in == "formData" {
+				parameter["collectionFormat"] = "multi"
+			}
+		}
+		// Values substituted into traits are strings, whatever their type
+		if value, ok := parameter["default"].(string); ok {
+			switch parameter["type"] {
+			case "integer", "number":
+				if number, err := strconv.ParseFloat(value, 64); err == nil {
+					parameter["default"] = number
+				}
+			case "boolean":
+				if b, err := strconv.ParseBool(value); err == nil {
+					parameter["default"] = b
+				}
+			}
+		}
+		if in == "formData" && parameter["format"] == "binary" {
+			parameter["type"] = "file"
+			delete(parameter, "format")
+		}
+		if example, ok := schema["example"]; ok {
+			parameter["x-example"] = example
+		}
+		parameters = append(parameters, parameter)
+	}
+	return parameters
+}
+
+// -----------------------------------------------------------------------------
+// securityDefinitions documents the security schemes that OpenAPI 2.0 can describe
+func (l *ramlLoader) securityDefinitions() map[string]interface{} {
+	definitions := map[string]interface{}{}
+	for _, name := range sortedKeys(l.schemes) {
+		scheme := asMap(l.schemes[name])
+		settings := asMap(scheme["settings"])
+		definition := map[string]interface{}{"description": asString(scheme["description"])}
+
+		switch schemeType := asString(scheme["type"]); schemeType {
+		case "OAuth 2.0":
+			definition["type"] = "oauth2"
+			grants := asSlice(settings["authorizationGrants"])
+			for _, g := range ramlGrants {
+				if contains(asStrings(grants), g.grant) {
+					definition["flow"] = g.flow
+					break
+				}
+			}
+			if definition["flow"] == nil {
+				logger.Warnf(nil, "RAML security scheme %s has no OAuth 2.0 grant that OpenAPI 2.0 describes", name)
+				continue
+			}
+			if flow := definition["flow"]; flow == "accessCode" || flow == "implicit" {
+				definition["authorizationUrl"] = asString(settings["authorizationUri"])
+			}
+			if flow := definition["flow"]; flow != "implicit" {
+				definition["tokenUrl"] = asString(settings["accessTokenUri"])
+			}
+			scopes := map[string]interface{}{}
+			for _, scope := range asStrings(asSlice(settings["scopes"])) {
+				scopes[scope] = ""
+			}
+			definition["scopes"] = scopes
+		case "Basic Authentication":
+			definition["type"] = "basic"
+		default:
+			// Pass Through, Digest, OAuth 1.0 and x- schemes pass a header or query parameter
+			describedBy := asMap(scheme["describedBy"])
+			definition["type"] = "apiKey"
+			if headers := sortedKeys(asMap(describedBy["headers"])); len(headers) > 0 {
+				definition["in"] = "header"
+				definition["name"] = strings.TrimSuffix(headers[0], "?")
+			} else if query := sortedKeys(asMap(describedBy["queryParameters"])); len(query) > 0 {
+				definition["in"] = "query"
+				definition["name"] = strings.TrimSuffix(query[0], "?")
+			} else if schemeType == "Digest Authentication" || schemeType == "OAuth 1.0" {
+				definition["in"] = "header"
+				definition["name"] = "Authorization"
+			} else {
+				logger.Warnf(nil, "RAML security scheme %s describes no header or query parameter", name)
+				continue
+			}
+		}
+		definitions[name] = definition
+	}
+	return definitions
+}
+
+// requirements lists the security requirements of securedBy. A null scheme means that
+// the security is optional.
+func (l *ramlLoader) requirements(securedBy interface{}) []interface{} {
+	if securedBy == nil {
+		return nil
+	}
+	definitions := l.security
+	var security []interface{}
+	for _, item := range asSlice(securedBy) {
+		switch s := item.(type) {
+		case nil:
+			security = append(security, map[string]interface{}{})
+		case string:
+			if definitions[s] != nil {
+				security = append(security, map[string]interface{}{s: []string{}})
+			}
+		case map[string]interface{}:
+			for _, name := range sortedKeys(s) {
+				if definitions[name] != nil {
+					scopes := asStrings(asSlice(asMap(s[name])["scopes"]))
+					if scopes == nil {
+						scopes = []string{}
+					}
+					security = append(security, map[string]interface{}{name: scopes})
+				}
+			}
+		}
+	}
+	return security
+}
+
+// -----------------------------------------------------------------------------
+// applyResourceType merges the resource type of a resource, and the types it is of,
+// into it. Methods of the type that end in ? only apply if the resource has them.
+func (l *ramlLoader) applyResourceType(resource map[string]interface{}, route string, depth int) map[string]interface{} {
+	name, parameters := ramlReference(resource["type"])
+	if len(name) == 0 || depth >= ramlIncludeDepth {
+		return resource
+	}
+	declaration, ok := l.resourceTypes[name]
+	if !ok {
+		logger.Warnf(nil, "RAML resource type %s is not declared", name)
+		return resource
+	}
+	parameters["resourcePath"] = route
+	parameters["resourcePathName"] = resourcePathName(route)
+
+	resourceType := asMap(substituteRAML(copyYAML(declaration), parameters))
+	delete(resourceType, "usage")
+	resourceType = l.applyResourceType(resourceType, route, depth+1)
+
+	merged := asMap(copyYAML(resource))
+	delete(merged, "type")
+	for key, value := range resourceType {
+		if strings.HasSuffix(key, "?") {
+			key = strings.TrimSuffix(key, "?")
+			if _, ok := merged[key]; !ok {
+				continue
+			}
+		}
+		if key == "type" {
+			continue
+		}
+		merged[key] = mergeRAML(merged[key], value, key)
+	}
+	return merged
+}
+
+// applyTraits merges the traits a method is, into it
+func (l *ramlLoader) applyTraits(method map[string]interface{}, traits []interface{}, parameters map[string]string) map[string]interface{} {
+	merged := asMap(copyYAML(method))
+	delete(merged, "is")
+	for _, item := range traits {
+		name, traitParameters := ramlReference(item)
+		declaration, ok := l.traits[name]
+		if !ok {
+			logger.Warnf(nil, "RAML trait %s is not declared", name)
+			continue
+		}
+		for key, value := range parameters {
+			traitParameters[key] = value
+		}
+		trait := asMap(substituteRAML(copyYAML(declaration), traitParameters))
+		delete(trait, "usage")
+		for key, value := range trait {
+			merged[key] = mergeRAML(merged[key], value, key)
+		}
+	}
+	return merged
+}
+
+// ramlReference reads the use of a trait or resource type, which is either its name,
+// or its name and the values of its parameters
+func ramlReference(reference interface{}) (string, map[string]string) {
+	parameters := map[string]string{}
+	switch r := reference.(type) {
+	case string:
+		return r, parameters
+	case map[string]interface{}:
+		for name, values := range r {
+			for key, value := range asMap(values) {
+				parameters[key] = asString(value)
+			}
+			return name, parameters
+		}
+	}
+	return "", parameters
+}
+
+// mergeRAML merges what a trait or resource type declares into what a method or
+// resource declares, which takes precedence
+func mergeRAML(declared interface{}, inherited interface{}, key string) interface{} {
+	if declared == nil {
+		return inherited
+	}
+	if key == "is" {
+		return append(asSlice(inherited), asSlice(declared)...)
+	}
+	d, ok := declared.(map[string]interface{})
+	i, iok := inherited.(map[string]interface{})
+	if !ok || !iok {
+		return declared
+	}
+	for k, value := range i {
+		d[k] = mergeRAML(d[k], value, k)
+	}
+	return d
+}
+
+// substituteRAML replaces the parameters in the keys and values of a trait or
+// resource type
+func substituteRAML(value interface{}, parameters map[string]string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return ramlParameter.ReplaceAllStringFunc(v, func(match string) string {
+			parts := ramlParameter.FindStringSubmatch(match)
+			result, ok := parameters[parts[1]]
+			if !ok {
+				return match
+			}
+			for _, function := range strings.Split(parts[2], "|") {
+				result = transformRAML(result, strings.TrimPrefix(strings.TrimSpace(function), "!"))
+			}
+			return result
+		})
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[substituteRAML(key, parameters).(string)] = substituteRAML(item, parameters)
+		}
+		return m
+	case []interface{}:
+		for i, item := range v {
+			v[i] = substituteRAML(item, parameters)
+		}
+	}
+	return value
+}
+
+// transformRAML applies a parameter function, such as singularize
+func transformRAML(value string, function string) string {
+	words := splitWords(value)
+	switch function {
+	case "singularize":
+		switch {
+		case strings.HasSuffix(value, "ies"):
+			return strings.TrimSuffix(value, "ies") + "y"
+		case strings.HasSuffix(value, "ses"), strings.HasSuffix(value, "xes"):
+			return strings.TrimSuffix(value, "es")
+		case strings.HasSuffix(value, "s") && !strings.HasSuffix(value, "ss"):
+			return strings.TrimSuffix(value, "s")
+		}
+	case "pluralize":
+		switch {
+		case len(value) > 1 && strings.HasSuffix(value, "y") && !strings.ContainsAny(value[len(value)-2:len(value)-1], "aeiou"):
+			return strings.TrimSuffix(value, "y") + "ies"
+		case strings.HasSuffix(value, "s"), strings.HasSuffix(value, "x"):
+			return value + "es"
+		default:
+			return value + "s"
+		}
+	case "uppercase":
+		return strings.ToUpper(value)
+	case "lowercase":
+		return strings.ToLower(value)
+	case "lowercamelcase", "uppercamelcase":
+		for i, word := range words {
+			words[i] = strings.ToUpper(word[:1]) + strings.ToLower(word[1:])
+		}
+		result := strings.Join(words, "")
+		if function == "lowercamelcase" && len(result) > 0 {
+			result = strings.ToLower(result[:1]) + result[1:]
+		}
+		return result
+	case "lowerunderscorecase":
+		return strings.ToLower(strings.Join(words, "_"))
+	case "upperunderscorecase":
+		return strings.ToUpper(strings.Join(words, "_"))
+	case "lowerhyphencase":
+		return strings.ToLower(strings.Join(words, "-"))
+	case "upperhyphencase":
+		return strings.ToUpper(strings.Join(words, "-"))
+	}
+	return value
+}
+
+// splitWords splits a name at hyphens, underscores, spaces and changes of case
+func splitWords(name string) []string {
+	var words []string
+	var word []rune
+	runes := []rune(name)
+	for i, r := range runes {
+		if r == '-' || r == '_' || unicode.IsSpace(r) {
+			if len(word) > 0 {
+				words = append(words, string(word))
+			}
+			word = nil
+			continue
+		}
+		if unicode.IsUpper(r) && len(word) > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			words = append(words, string(word))
+			word = nil
+		}
+		word = append(word, r)
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+	return words
+}
+
+// resourcePathName is the last segment of a resource path that is not a URI parameter
+func resourcePathName(route string) string {
+	segments := strings.Split(route, "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		if len(segments[i]) > 0 && !strings.Contains(segments[i], "{") {
+			return segments[i]
+		}
+	}
+	return ""
+}
+
+// -----------------------------------------------------------------------------
+// ramlExample finds the example of a type declaration, or the first of its examples.
+// Examples of JSON bodies that were included as text are decoded.
+func ramlExample(declaration interface{}, mediaType string) (interface{}, bool) {
+	d := asMap(declaration)
+	example, ok := d["example"]
+	if !ok {
+		examples := asMap(d["examples"])
+		names := sortedKeys(examples)
+		if len(names) == 0 {
+			return nil, false
+		}
+		example = examples[names[0]]
+	}
+	if isExampleWithFacets(example) {
+		example = asMap(example)["value"]
+	}
+	if text, ok := example.(string); ok && (len(mediaType) == 0 || strings.Contains(mediaType, "json")) {
+		var decoded interface{}
+		if err := json.Unmarshal(bytes.TrimSpace([]byte(text)), &decoded); err == nil {
+			if _, isString := decoded.(string); !isString {
+				example = decoded
+			}
+		}
+	}
+	return example, true
+}
+
+// isExampleWithFacets tells an example given with facets, such as displayName or
+// strict, from an example of an object
+func isExampleWithFacets(example interface{}) bool {
+	m, ok := example.(map[string]interface{})
+	if _, found := m["value"]; !ok || !found {
+		return false
+	}
+	for key := range m {
+		switch key {
+		case "value", "displayName", "description", "strict":
+		default:
+			if !strings.HasPrefix(key, "(") { // Annotations
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// withFacet adds a facet to a schema, which is wrapped if it is a $ref
+func withFacet(schema map[string]interface{}, facet string, value interface{}) map[string]interface{} {
+	if _, ok := schema["$ref"]; ok {
+		schema = map[string]interface{}{"allOf": []interface{}{schema}}
+	}
+	schema[facet] = value
+	return schema
+}
+
+// copyYAML copies a decoded document, so that the declarations it is built from are
+// not changed by merges
+func copyYAML(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[key] = copyYAML(item)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, item := range v {
+			s[i] = copyYAML(item)
+		}
+		return s
+	}
+	return value
+}
+
+// -----------------------------------------------------------------------------
+
+func asMap(value interface{}) map[string]interface{} {
+	if m, ok := value.(map[string]interface{}); ok {
+		return m
+	}
+	return map[string]interface{}{}
+}
+
+// asSlice reads a value that may be a single item or a list of them
+func asSlice(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return v
+	}
+	return []interface{}{value}
+}
+
+func asString(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
+
+func asStrings(values []interface{}) []string {
+	var s []string
+	for _, value := range values {
+		s = append(s, asString(value))
+	}
+	return s
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
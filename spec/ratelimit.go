@@ -0,0 +1,126 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"sort"
+
+	"github.com/go-openapi/spec"
+	"github.com/shurcooL/github_flavored_markdown"
+)
+
+// RateLimit is a limit on the rate of requests, given by an x-rateLimit extension
+// on an operation or, for all operations, the specification:
+//
+//	x-rateLimit:
+//	  limit: 100
+//	  window: 1 minute
+//	  scope: API key
+//	  description: Requests over the limit are rejected with 429.
+//	  headers:
+//	    X-RateLimit-Remaining: Requests left in the window
+//
+// The extension may also be a list of limits, such as a burst and a daily limit.
+// headers may be a list of names rather than a map of name to description.
+type RateLimit struct {
+	Limit       int
+	Window      string
+	Scope       string // Who the limit is counted for, such as API key or IP address
+	Description string // HTML
+	Headers     []RateLimitHeader
+}
+
+// RateLimitHeader is a response header that tells clients about a rate limit
+type RateLimitHeader struct {
+	Name        string
+	Description string
+}
+
+// -----------------------------------------------------------------------------
+// getRateLimits returns the limits of an x-rateLimit extension, or nil if there is none
+
+func getRateLimits(extensions spec.Extensions) []RateLimit {
+	var limits []RateLimit
+
+	switch extension := extensions["x-rateLimit"].(type) {
+	case map[string]interface{}:
+		limits = append(limits, rateLimit(extension))
+	case []interface{}:
+		for _, l := range extension {
+			if m, ok := l.(map[string]interface{}); ok {
+				limits = append(limits, rateLimit(m))
+			}
+		}
+	}
+	return limits
+}
+
+func rateLimit(m map[string]interface{}) RateLimit {
+	var l RateLimit
+
+	switch limit := m["limit"].(type) {
+	case float64:
+		l.Limit = int(limit)
+	case int:
+		l.Limit = limit
+	}
+	l.Window, _ = m["window"].(string)
+	l.Scope, _ = m["scope"].(string)
+	if description, ok := m["description"].(string); ok {
+		l.Description = string(github_flavored_markdown.Markdown([]byte(description)))
+	}
+
+	switch headers := m["headers"].(type) {
+	case map[string]interface{}:
+		var names []string
+		for name := range headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			description, _ := headers[name].(string)
+			l.Headers = append(l.Headers, RateLimitHeader{Name: name, Description: description})
+		}
+	case []interface{}:
+		for _, h := range headers {
+			if name, ok := h.(string); ok {
+				l.Headers = append(l.Headers, RateLimitHeader{Name: name})
+			}
+		}
+	}
+	return l
+}
+
+// -----------------------------------------------------------------------------
+// RateLimited reports whether any of the specification's methods are rate limited,
+// so that it has a rate limits page.
+func (c *APISpecification) RateLimited() bool {
+	if len(c.RateLimits) > 0 {
+		return true
+	}
+	for _, api := range c.APIs {
+		for _, methods := range api.Versions {
+			for _, method := range methods {
+				if len(method.RateLimits) > 0 {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dapperdox/dapperdox/logger"
+	"github.com/go-openapi/spec"
+	"gopkg.in/yaml.v2"
+)
+
+// refCache holds the documents that $refs to other files point to, so that each is
+// fetched once however often it is referenced. It is emptied whenever the
+// specifications are loaded, so that reloads see changes.
+var refCache = map[string]json.RawMessage{}
+var refCacheLock sync.Mutex
+
+func init() {
+	spec.PathLoader = loadRef
+}
+
+func resetRefCache() {
+	refCacheLock.Lock()
+	refCache = map[string]json.RawMessage{}
+	refCacheLock.Unlock()
+}
+
+// cachedRefs returns the referenced documents loaded so far
+func cachedRefs() []json.RawMessage {
+	refCacheLock.Lock()
+	defer refCacheLock.Unlock()
+
+	var locations []string
+	for location := range refCache {
+		locations = append(locations, location)
+	}
+	sort.Strings(locations)
+
+	var documents []json.RawMessage
+	for _, location := range locations {
+		documents = append(documents, refCache[location])
+	}
+	return documents
+}
+
+// -----------------------------------------------------------------------------
+// loadRef loads the document that a $ref points to, from a file or with the same
+// timeouts, retries and credentials as specifications. YAML documents are converted
+// to JSON for the expander.
+func loadRef(location string) (json.RawMessage, error) {
+	refCacheLock.Lock()
+	defer refCacheLock.Unlock()
+
+	if document, ok := refCache[location]; ok {
+		return document, nil
+	}
+	logger.Debugf(nil, "Loading referenced document %s", location)
+
+	var data []byte
+	var err error
+	if isLocalSpecUrl(location) {
+		data, err = ioutil.ReadFile(strings.TrimPrefix(location, "file://"))
+	} else {
+		data, err = fetchWithRetries(location)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading %s: %s", location, err)
+	}
+
+	switch strings.ToLower(path.Ext(strings.SplitN(location, "?", 2)[0])) {
+	case ".yaml", ".yml":
+		var document interface{}
+		if err = yaml.Unmarshal(data, &document); err != nil {
+			return nil, fmt.Errorf("error parsing %s: %s", location, err)
+		}
+		if data, err = json.Marshal(normalizeYAML(document)); err != nil {
+			return nil, err
+		}
+	}
+
+	refCache[location] = json.RawMessage(data)
+	return refCache[location], nil
+}
@@ -0,0 +1,60 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/go-openapi/spec"
+)
+
+// jsonSchemaDraft is the JSON Schema version that Swagger 2 schemas are a subset of
+const jsonSchemaDraft = "http://json-schema.org/draft-04/schema#"
+
+// -----------------------------------------------------------------------------
+// resourceSchema encodes the schema that a resource is built from. A resource built
+// from an array is of its items, so the schema is too. It must be called before
+// resourceFromSchema, which may alter the schema.
+func resourceSchema(s *spec.Schema) []byte {
+	if s.Type.Contains("array") && s.Items != nil && s.Items.Schema != nil {
+		s = s.Items.Schema
+	}
+	schema, _ := json.Marshal(s)
+	return schema
+}
+
+// ResourceSchema returns the resolved JSON Schema of a resource, or nil if it has none.
+// Recursive schemas keep their $refs, so the definitions are included for them.
+func (c *APISpecification) ResourceSchema(r *Resource) ([]byte, error) {
+	if len(r.schema) == 0 {
+		return nil, nil
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(r.schema, &schema); err != nil {
+		return nil, err
+	}
+	schema["$schema"] = jsonSchemaDraft
+	if _, ok := schema["title"]; !ok && len(r.Title) > 0 {
+		schema["title"] = r.Title
+	}
+	if bytes.Contains(r.schema, []byte(`"#/definitions/`)) && len(c.definitions) > 0 {
+		schema["definitions"] = c.definitions
+	}
+	return JSONMarshalIndent(schema)
+}
@@ -0,0 +1,135 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/dapperdox/dapperdox/logger"
+)
+
+// Each specification has a search index of its method and resource pages. It is built
+// from the specification as it loads, rather than by crawling the rendered pages, and
+// encoded once, so that serving it and writing it into a static build cost nothing more.
+
+// SearchEntry is a page that the search index finds
+type SearchEntry struct {
+	Title string `json:"title"`
+	Text  string `json:"text,omitempty"` // Words besides the title that find the page
+	Href  string `json:"href"`
+	Kind  string `json:"kind"` // method or resource
+
+	words map[string]bool // Of the title, text and href, for Score
+}
+
+// pageWords appear in the paths of many pages, so say nothing about which page is wanted.
+var pageWords = map[string]bool{"reference": true, "resources": true, "guides": true}
+
+// -----------------------------------------------------------------------------
+// indexSearch builds the search index of the specification, with an entry for the
+// unversioned page of each method and resource. The version shown by default describes
+// the page, or the newest that has it.
+func (c *APISpecification) indexSearch() {
+	var index []SearchEntry
+
+	for _, api := range c.APIs {
+		versions := append([]string{c.Version(api)}, c.OrderedVersions...)
+		seen := make(map[string]bool)
+		for _, version := range versions {
+			for _, method := range api.Versions[version] {
+				if seen[method.ID] {
+					continue
+				}
+				seen[method.ID] = true
+
+				title := method.Name
+				if len(title) == 0 {
+					title = method.NavigationName
+				}
+				index = append(index, SearchEntry{
+					Title: title,
+					Text:  strings.Join([]string{api.Name, method.OperationName, strings.ToUpper(method.Method), method.Path}, " "),
+					Href:  "/" + c.ID + "/reference/" + api.ID + "/" + method.ID,
+					Kind:  "method",
+				})
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, version := range c.OrderedVersions {
+		for _, id := range sortedResourceIDs(c.ResourceList[version]) {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			resource := c.ResourceList[version][id]
+			index = append(index, SearchEntry{
+				Title: resource.Title,
+				Text:  id,
+				Href:  "/" + c.ID + "/resources/" + id,
+				Kind:  "resource",
+			})
+		}
+	}
+
+	for i := range index {
+		index[i].words = SearchWords(index[i].Title + " " + index[i].Text + " " + index[i].Href)
+	}
+
+	c.SearchIndex = index
+	data, err := json.Marshal(index)
+	if err != nil {
+		logger.Errorf(nil, "Error encoding the search index of specification %s: %s", c.ID, err)
+		return
+	}
+	c.searchJSON = data
+}
+
+// SearchJSON is the search index, encoded as JSON
+func (c *APISpecification) SearchJSON() []byte {
+	return c.searchJSON
+}
+
+// -----------------------------------------------------------------------------
+// SearchWords splits text into the lower case words that the search index matches on,
+// leaving out single characters and the words of every page path.
+func SearchWords(s string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	}) {
+		if len(word) > 1 && !pageWords[word] {
+			words[word] = true
+		}
+	}
+	return words
+}
+
+// Score is the number of the words that the entry shares
+func (e *SearchEntry) Score(words map[string]bool) int {
+	score := 0
+	for word := range words {
+		if e.words[word] {
+			score++
+		}
+	}
+	return score
+}
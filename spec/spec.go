@@ -26,9 +26,11 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/dapperdox/dapperdox/config"
 	"github.com/dapperdox/dapperdox/logger"
+	"github.com/dapperdox/dapperdox/metrics"
 	//"github.com/davecgh/go-spew/spew"
 	"github.com/go-openapi/loads"
 	"github.com/go-openapi/spec"
@@ -44,12 +46,63 @@ type APISpecification struct {
 
 	SecurityDefinitions map[string]SecurityScheme
 	DefaultSecurity     map[string]Security
+	DefaultRequirements []SecurityRequirement           // Alternatives of DefaultSecurity
 	ResourceList        map[string]map[string]*Resource // Version->ResourceName->Resource
 	APIVersions         map[string]APISet               // Version->APISet
+	OrderedVersions     []string                        // Every version of the APIs, newest first
+	Deprecations        map[string]*VersionDeprecation  // Version->deprecation, for deprecated versions
+	RateLimits          []RateLimit                     // From x-rateLimit, for the methods with none of their own
+	Errors              []SharedError                   // Resources that many methods return as errors
+	Models              []*Resource                     // Every definition, when all-definitions is set
+	TagGroups           []TagGroup                      // From nav.yaml or x-tagGroups, or nil
+	Navigation          *Navigation                     // From nav.yaml, or nil
+	NavigationResources []VersionResources              // ResourceList, in navigation order
+	Servers             []Server                        // Targets for explorer requests, the first being the default
+	SearchIndex         []SearchEntry                   // Method and resource pages, see indexSearch
+	Extensions          map[string]interface{}
+
+	// Per specification settings from the configuration file
+	Theme          string // Theme variant, or "" to use the site theme
+	DefaultVersion string // Version to show when none is requested, or "" for the current version
+	Hidden         bool   // Not shown in the specification list
+	Category       string // Heading of the specification list that it is under, or ""
+	Branding       *Branding
+
+	methodCount        int                 // Methods processed, giving their declaration order
+	propertyOrder      string              // spec, name or required, from the configuration
+	declaredProperties map[string][]string // Declaration orders of properties, see declaredProperties
+	markdownCache      map[string]string   // HTML of the descriptions converted while parsing, see markdown
+	definitions        spec.Definitions    // For the $refs of recursive resource schemas
+	filter             *operationFilter    // Operations left out by the configuration
+	searchJSON         []byte              // SearchIndex, encoded once it is built
+	err                error               // First problem found while parsing, see fail
 }
 
 var APISuite map[string]*APISpecification
 
+// Server is a target that the API explorer can send requests to
+type Server struct {
+	URL         string // Scheme and host
+	Description string
+}
+
+// LoadFailure records a specification that could not be loaded, and was skipped because
+// it is optional or spec-failures is skip, so that pages can explain why it is missing.
+type LoadFailure struct {
+	Location string
+	Error    string
+}
+
+var LoadFailures []LoadFailure
+
+// Version returns the version of the API to show when none is requested
+func (c *APISpecification) Version(api APIGroup) string {
+	if _, ok := api.Versions[c.DefaultVersion]; ok {
+		return c.DefaultVersion
+	}
+	return api.CurrentVersion
+}
+
 // GetByName returns an API by name
 func (c *APISpecification) GetByName(name string) *APIGroup {
 	for _, a := range c.APIs {
@@ -72,9 +125,23 @@ func (c *APISpecification) GetByID(id string) *APIGroup {
 
 type APISet []APIGroup
 
+// TagGroup is a named group of tags from the x-tagGroups extension, giving a
+// two level navigation of group, then API (tag), then method.
+type TagGroup struct {
+	ID   string
+	Name string // Empty for the APIs that are in no group
+	APIs APISet
+}
+
 type Info struct {
-	Title       string
-	Description string
+	Title          string
+	Description    string
+	Excerpt        string   // Plain text opening of the description, for the specification list
+	Logo           *Logo    // From x-logo, or nil
+	Contact        *Contact // Or nil
+	License        *License // Or nil
+	TermsOfService string   // URL of the terms of service, or ""
+	Terms          string   // The terms of service themselves, when not given as a URL
 }
 
 // APIGroup parents all grouped API methods (Grouping controlled by tagging, if used, or by method path otherwise)
@@ -84,12 +151,15 @@ type APIGroup struct {
 	URL                    *url.URL
 	MethodNavigationByName bool
 	MethodSortBy           []string
+	MethodOrder            string              // spec, summary or method. Overrides MethodSortBy if set
 	Versions               map[string][]Method // All versions, keyed by version string.
+	OrderedVersions        []string            // Keys of Versions, newest first
 	Methods                []Method            // The current version
 	CurrentVersion         string              // The latest version in operation for the API
 	Info                   *Info
 	Consumes               []string
 	Produces               []string
+	Extensions             map[string]interface{}
 }
 
 type Version struct {
@@ -105,21 +175,31 @@ type OAuth2Scheme struct {
 }
 
 type SecurityScheme struct {
-	IsApiKey      bool
-	IsBasic       bool
-	IsOAuth2      bool
-	Type          string
-	Description   string
-	ParamName     string
-	ParamLocation string
+	IsApiKey         bool
+	IsBasic          bool
+	IsOAuth2         bool
+	IsBearer         bool
+	IsOpenIDConnect  bool
+	Type             string
+	Description      string
+	ParamName        string
+	ParamLocation    string
+	BearerFormat     string // Hint of how bearer tokens are formatted, such as JWT
+	OpenIDConnectUrl string // Discovery document of an openIdConnect scheme
 	OAuth2Scheme
 }
 
 type Security struct {
+	Name   string // Of the scheme in securityDefinitions
 	Scheme *SecurityScheme
 	Scopes map[string]string
 }
 
+// SecurityRequirement is one way to authorise a request, which needs every scheme in
+// it. A request needs only one of its alternative requirements, and an empty requirement
+// means that it can be made without authorisation.
+type SecurityRequirement []Security
+
 // Method represents an API method
 type Method struct {
 	ID              string
@@ -139,9 +219,19 @@ type Method struct {
 	Responses       map[int]Response
 	DefaultResponse *Response // A ptr to allow of easy checking of its existance in templates
 	Resources       []*Resource
-	Security        map[string]Security
+	Security        map[string]Security   // Every scheme the method accepts, by type
+	Requirements    []SecurityRequirement // The alternative combinations of Security that authorise a request
+	OwnSecurity     bool                  // Declares security that replaces the specification's default
+	Public          bool                  // Can be called without authorisation
 	APIGroup        *APIGroup
 	SortKey         string
+	DisplayOrder    *int   // From x-displayOrder, ordering the method before those without
+	Anchor          string // Stable HTML id for deep links, e.g. post.payments.:id
+	Extensions      map[string]interface{}
+	RateLimits      []RateLimit
+	OwnRateLimits   bool // RateLimits are the operation's own, rather than the specification's
+	Deprecated      bool
+	Recorded        []Exchange // Requests and responses recorded in har-file captures
 }
 
 // Parameter represents an API method parameter
@@ -154,8 +244,15 @@ type Parameter struct {
 	Required                    bool
 	Type                        []string
 	Enum                        []string
-	Resource                    *Resource // For "in body" parameters
-	IsArray                     bool      // "in body" parameter is an array
+	EnumDescriptions            map[string]string // Meaning of each enum value, from x-enumDescriptions or x-enum-varnames
+	Default                     string            // Value assumed when none is given, or ""
+	Resource                    *Resource         // For "in body" parameters
+	IsArray                     bool              // "in body" parameter is an array
+	IsFile                      bool              // formData parameter is a file upload, or files if Type is an array
+	Style                       string            // How an array or object value is serialized, using the OpenAPI 3 style names
+	Explode                     bool              // Each array item or object property is sent as a separate parameter
+	Anchor                      string            // Stable HTML id for deep links
+	Extensions                  map[string]interface{}
 }
 
 // Response represents an API method response
@@ -165,6 +262,8 @@ type Response struct {
 	Resource          *Resource
 	Headers           []Header
 	IsArray           bool
+	Anchor            string // Stable HTML id for deep links
+	Schema            string // JSON schema of the body, for the explorer to validate responses against
 }
 
 type ResourceOrigin int
@@ -172,6 +271,7 @@ type ResourceOrigin int
 const (
 	RequestBody ResourceOrigin = iota
 	MethodResponse
+	Definition // A definition that no operation references, documented by all-definitions
 )
 
 // Resource represents an API resource
@@ -184,12 +284,21 @@ type Resource struct {
 	Schema                string
 	Type                  []string // Will contain two elements if an array or map [0]=array [1]=What type is in the array
 	Properties            map[string]*Resource
+	PropertyNames         []string // Keys of Properties, in the order that they are shown
 	Required              bool
 	ReadOnly              bool
 	ExcludeFromOperations []string
 	Methods               map[string]*Method
 	Enum                  []string
+	EnumDescriptions      map[string]string // Meaning of each enum value, from x-enumDescriptions or x-enum-varnames
+	Extends               []*Resource       // Named definitions that it includes with allOf
+	KeyPattern            string            // For a map from patternProperties, the pattern its keys match
+	Linked                []LinkedResource  // The same resource in other specifications
+	Anchor                string            // Stable HTML id for deep links to a property
+	Extensions            map[string]interface{}
 	origin                ResourceOrigin
+	example               interface{} // Synthesised value of a primitive, or nil
+	schema                []byte      // JSON schema it was built from, see ResourceSchema
 }
 
 type Header struct {
@@ -213,13 +322,56 @@ var sortTypes = map[string]bool{
 	"summary":    true,
 }
 
+// Methods in the order that method-order "method" sorts them
+var httpMethodOrder = map[string]int{
+	"get":     0,
+	"post":    1,
+	"put":     2,
+	"patch":   3,
+	"delete":  4,
+	"head":    5,
+	"options": 6,
+}
+
+var propertyOrderTypes = map[string]bool{
+	"spec":     true,
+	"name":     true,
+	"required": true,
+}
+
+var methodOrderTypes = map[string]bool{
+	"spec":    true,
+	"summary": true,
+	"method":  true,
+}
+
 type SortMethods []Method
 
-func (a SortMethods) Len() int           { return len(a) }
-func (a SortMethods) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a SortMethods) Less(i, j int) bool { return a[i].SortKey < a[j].SortKey }
+func (a SortMethods) Len() int      { return len(a) }
+func (a SortMethods) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a SortMethods) Less(i, j int) bool {
+	oi, oj := a[i].DisplayOrder, a[j].DisplayOrder
+	switch {
+	case oi != nil && oj != nil && *oi != *oj:
+		return *oi < *oj
+	case oi != nil && oj == nil:
+		return true
+	case oi == nil && oj != nil:
+		return false
+	}
+	return a[i].SortKey < a[j].SortKey
+}
 
-func (api *APIGroup) getMethodSortKey(path, method, operation, navigation, summary string) string {
+func (api *APIGroup) getMethodSortKey(sequence int, path, method, operation, navigation, summary string) string {
+
+	switch api.MethodOrder {
+	case "spec":
+		return fmt.Sprintf("%06d", sequence)
+	case "summary":
+		return strings.ToLower(summary) + "~" + path
+	case "method":
+		return fmt.Sprintf("%02d~%s", httpMethodOrder[method], path)
+	}
 
 	// Handle a list of sort-by values, so that ordering can be fixed.
 	// Sorting by path alone does not work because ordering changes around GET/POST/PUT Etc
@@ -262,37 +414,157 @@ func LoadSpecifications(specHost string, collapse bool) error {
 		return err
 	}
 
-	if strings.HasPrefix(specHost, "0.0.0.0") {
-		splithost := strings.Split(specHost, ":")
-		splithost[0] = "127.0.0.1"
-		specHost = strings.Join(splithost, ":")
-		logger.Tracef(nil, "Serving specifications from %s\n", specHost)
+	resetRefCache()
+
+	exampleSeed = int64(cfg.ExampleSeed)
+	if cfg.ExampleRandom {
+		exampleSeed = time.Now().UnixNano()
 	}
 
-	for _, specLocation := range cfg.SpecFilename {
+	specHost = loopbackHost(specHost)
 
-		var ok bool
-		var specification *APISpecification
+	var loaded []*APISpecification
+	LoadFailures = nil
 
-		if specification, ok = APISuite[""]; !ok || !collapse {
-			specification = &APISpecification{}
-		}
+	recorded, err := readRecorded(cfg.HARFile, cfg.RecordFile)
+	if err != nil {
+		logger.Errorf(nil, "%s", err)
+		return err
+	}
+
+	for _, specLocation := range cfg.SpecFilename {
+
+		specification := &APISpecification{}
 
+		start := time.Now()
 		err = specification.Load(specLocation, specHost)
 		if err != nil {
+			if cfg.SpecFailures == "skip" || isOptional(cfg.SpecOptional, specLocation) {
+				logger.Errorf(nil, "Skipping specification %s: %s", specLocation, err)
+				LoadFailures = append(LoadFailures, LoadFailure{Location: specLocation, Error: err.Error()})
+				continue
+			}
 			return err
 		}
+		metrics.ObserveSpecLoad(specification.ID, time.Since(start))
+		if added := specification.addRecordedExamples(recorded); added > 0 {
+			logger.Infof(nil, "Added %d recorded examples to specification %s", added, specification.ID)
+		}
 
-		if collapse {
-			//specification.ID = "api"
+		loaded = append(loaded, specification)
+	}
+
+	if collapse && len(loaded) > 0 {
+		title := cfg.SpecMergeTitle
+		if len(title) == 0 {
+			title = loaded[0].APIInfo.Title
 		}
+		merged, err := mergeSpecifications(title, loaded)
+		if err != nil {
+			logger.Errorf(nil, "%s", err)
+			return err
+		}
+		loaded = []*APISpecification{merged}
+	}
+	linkResources(loaded, cfg.LinkResources)
 
+	for _, specification := range loaded {
+		specification.indexSearch()
 		APISuite[specification.ID] = specification
 	}
 
 	return nil
 }
 
+// -----------------------------------------------------------------------------
+// ReloadSpecification loads one specification of the APISuite afresh, for ReplaceSpecification
+// to put in its place. The APISuite is left as it is, so that it can be served while the
+// specification loads. Merged specifications and those with linked resources depend on
+// each other, and so can only be reloaded together.
+func ReloadSpecification(id string, specHost string) (*APISpecification, error) {
+	cfg, err := config.Get()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.SpecMerge {
+		return nil, fmt.Errorf("specifications are merged, and can only be reloaded together")
+	}
+	if cfg.LinkResources == "title" || cfg.LinkResources == "definition" {
+		return nil, fmt.Errorf("specifications link resources, and can only be reloaded together")
+	}
+	previous, ok := APISuite[id]
+	if !ok {
+		return nil, fmt.Errorf("no specification %s is loaded", id)
+	}
+
+	resetRefCache() // The documents it refers to may have changed too
+
+	recorded, err := readRecorded(cfg.HARFile, cfg.RecordFile)
+	if err != nil {
+		return nil, err
+	}
+
+	specification := &APISpecification{}
+	start := time.Now()
+	if err = specification.Load(previous.URL, loopbackHost(specHost)); err != nil {
+		return nil, err
+	}
+	metrics.ObserveSpecLoad(specification.ID, time.Since(start))
+	if added := specification.addRecordedExamples(recorded); added > 0 {
+		logger.Infof(nil, "Added %d recorded examples to specification %s", added, specification.ID)
+	}
+	specification.indexSearch()
+
+	return specification, nil
+}
+
+// -----------------------------------------------------------------------------
+// ReplaceSpecification puts a reloaded specification in the place of the one of the
+// APISuite with the ID given. Its ID changes with its title, and it is refused if it
+// would take the ID of another specification.
+func ReplaceSpecification(id string, specification *APISpecification) error {
+	if _, ok := APISuite[id]; !ok {
+		return fmt.Errorf("no specification %s is loaded", id)
+	}
+	if specification.ID != id && APISuite[specification.ID] != nil {
+		return fmt.Errorf("specification %s is now titled %s, which is the title of another specification", id, specification.APIInfo.Title)
+	}
+	delete(APISuite, id)
+	APISuite[specification.ID] = specification
+	return nil
+}
+
+// loopbackHost is the host that specifications are served from, for connecting to
+func loopbackHost(specHost string) string {
+	if strings.HasPrefix(specHost, "0.0.0.0") {
+		splithost := strings.Split(specHost, ":")
+		splithost[0] = "127.0.0.1"
+		specHost = strings.Join(splithost, ":")
+		logger.Tracef(nil, "Serving specifications from %s\n", specHost)
+	}
+	return specHost
+}
+
+// readRecorded reads the exchanges of the HAR files, and of the record-file if it exists,
+// for adding to specifications as examples
+func readRecorded(harFiles []string, recordFile string) ([]HAREntry, error) {
+	if _, err := os.Stat(recordFile); err == nil {
+		harFiles = append(append([]string{}, harFiles...), recordFile)
+	}
+	return readHAR(harFiles)
+}
+
+// -----------------------------------------------------------------------------
+
+func isOptional(optional []string, specLocation string) bool {
+	for _, o := range optional {
+		if o == specLocation {
+			return true
+		}
+	}
+	return false
+}
+
 // -----------------------------------------------------------------------------
 // Load loads API specs from the supplied host (usually local!)
 func (c *APISpecification) Load(specLocation string, specHost string) error {
@@ -307,8 +579,35 @@ func (c *APISpecification) Load(specLocation string, specHost string) error {
 	if err != nil {
 		return err
 	}
+	return c.parse(document)
+}
+
+// -----------------------------------------------------------------------------
+// LoadFile loads an API spec directly from a file or URL, rather than from the
+// specifications being served, for tools that compare or inspect spec files.
+func (c *APISpecification) LoadFile(location string) error {
+	c.URL = location
+
+	document, err := loadSpec(location)
+	if err != nil {
+		return err
+	}
+	return c.parse(document)
+}
+
+// -----------------------------------------------------------------------------
+
+func (c *APISpecification) parse(document *loads.Document) error {
 	apispec := document.Spec()
 
+	c.markdownCache = make(map[string]string)
+	defer func() { c.markdownCache = nil }()
+
+	// Overrides are keyed by ID, which is taken from info.title as it is below
+	if cfg, err := config.Get(); err == nil {
+		overrideLocation(apispec, cfg.Spec(TitleToKebab(apispec.Info.Title)))
+	}
+
 	basePath := apispec.BasePath
 	basePathLen := len(basePath)
 	// Ignore basepath if it is a single '/'
@@ -328,23 +627,54 @@ func (c *APISpecification) Load(specLocation string, specHost string) error {
 
 	c.APIInfo.Description = string(github_flavored_markdown.Markdown([]byte(apispec.Info.Description)))
 	c.APIInfo.Title = apispec.Info.Title
+	c.APIInfo.Excerpt = Excerpt(apispec.Info.Description)
+	c.APIInfo.Logo = getLogo(apispec.Info)
+	c.APIInfo.Contact = getContact(apispec.Info)
+	c.APIInfo.License = getLicense(apispec.Info)
+	c.APIInfo.TermsOfService, c.APIInfo.Terms = getTerms(apispec.Info)
+	c.Extensions = extensions(apispec.Extensions, apispec.Info.Extensions)
 
 	if len(c.APIInfo.Title) == 0 {
-		logger.Errorf(nil, "Error: Specification %s does not have a info.title member.\n", c.URL)
-		os.Exit(1)
+		return fmt.Errorf("specification %s does not have a info.title member", c.URL)
 	}
 
 	logger.Tracef(nil, "Parse OpenAPI specification '%s'\n", c.APIInfo.Title)
 
 	c.ID = TitleToKebab(c.APIInfo.Title)
 
-	c.getSecurityDefinitions(apispec)
+	c.getSecurityDefinitions(apispec, document.Raw())
 	c.getDefaultSecurity(apispec)
 
+	cfg, err := config.Get()
+	if err != nil {
+		return err
+	}
+	specCfg := cfg.Spec(c.ID)
+	c.filter = newOperationFilter(specCfg, apispec.BasePath)
+
+	// The ID is always taken from info.title, so that it does not change with the display title
+	if len(specCfg.Title) > 0 {
+		c.APIInfo.Title = specCfg.Title
+	}
+	c.Theme = specCfg.Theme
+	c.DefaultVersion = specCfg.DefaultVersion
+	c.Hidden = specCfg.Hidden
+	c.Branding = getBranding(c.APIInfo.Logo, specCfg)
+	c.Category, _ = apispec.Info.Extensions["x-category"].(string)
+	if len(specCfg.Category) > 0 {
+		c.Category = specCfg.Category
+	}
+	c.Servers = getServers(apispec, specCfg.Servers)
+	c.Deprecations = getDeprecations(apispec, specCfg.Deprecations)
+	c.RateLimits = getRateLimits(apispec.Extensions)
+
 	methodNavByName := false // Should methods in the navigation be presented by type (GET, POST) or name (string)?
 	if byname, ok := apispec.Extensions["x-navigateMethodsByName"].(bool); ok {
 		methodNavByName = byname
 	}
+	if specCfg.NavigateMethodsByName != nil {
+		methodNavByName = *specCfg.NavigateMethodsByName
+	}
 
 	var methodSortBy []string
 	if sortByList, ok := apispec.Extensions["x-sortMethodsBy"].([]interface{}); ok {
@@ -357,17 +687,47 @@ func (c *APISpecification) Load(specLocation string, specHost string) error {
 			}
 		}
 	}
+	c.propertyOrder = specCfg.PropertyOrder
+	if len(c.propertyOrder) > 0 && !propertyOrderTypes[c.propertyOrder] {
+		logger.Errorf(nil, "Error: Invalid property-order value %s for specification %s\n", c.propertyOrder, c.ID)
+		c.propertyOrder = ""
+	}
+	c.definitions = apispec.Definitions
+	c.declaredProperties = declaredProperties(append([]json.RawMessage{document.Raw()}, cachedRefs()...)...)
+
+	methodOrder := specCfg.MethodOrder
+	if len(methodOrder) > 0 && !methodOrderTypes[methodOrder] {
+		logger.Errorf(nil, "Error: Invalid method-order value %s for specification %s\n", methodOrder, c.ID)
+		methodOrder = ""
+	}
+	if len(specCfg.SortMethodsBy) > 0 {
+		methodSortBy = nil
+		for _, keyname := range specCfg.SortMethodsBy {
+			if _, ok := sortTypes[keyname]; !ok {
+				logger.Errorf(nil, "Error: Invalid sort-methods-by value %s for specification %s\n", keyname, c.ID)
+			} else {
+				methodSortBy = append(methodSortBy, keyname)
+			}
+		}
+	}
 
 	//logger.Printf(nil, "DUMP OF ENTIRE SWAGGER SPEC\n")
 	//spew.Dump(document)
 
+	tagAPIs := make(map[string]string) // Tag name -> API group ID
+	apiOrder := make(map[string]int)   // API group ID -> x-displayOrder of its tag
+	extra := extraOperations(document.Raw(), apispec)
+	versions := specVersions(document.Analyzer.AllPaths(), extra)
+
+	allPaths := document.Analyzer.AllPaths()
+	paths := orderedPaths(document.Raw(), allPaths) // Once, as it tokenizes the whole document
+
 	// Use the top level TAGS to order the API resources/endpoints
 	// If Tags: [] is not defined, or empty, then no filtering or ordering takes place,
 	// and all API paths will be documented..
 	for _, tag := range getTags(apispec) {
 		logger.Tracef(nil, "  In tag loop...\n")
 		// Tag matching may not be as expected if multiple paths have the same TAG (which is technically permitted)
-		var ok bool
 
 		var api *APIGroup
 		groupingByTag := false
@@ -392,12 +752,17 @@ func (c *APISpecification) Load(specLocation string, specHost string) error {
 				Info: &c.APIInfo,
 				MethodNavigationByName: methodNavByName,
 				MethodSortBy:           methodSortBy,
+				MethodOrder:            methodOrder,
 				Consumes:               apispec.Consumes,
 				Produces:               apispec.Produces,
+				Versions:               make(map[string][]Method),
+				Extensions:             extensions(tag.Extensions),
 			}
 		}
 
-		for path, pathItem := range document.Analyzer.AllPaths() {
+		for _, path := range paths {
+			pathItem := allPaths[path]
+			operations := extra[path]
 			logger.Tracef(nil, "    In path loop...\n")
 
 			if basePathLen > 0 {
@@ -413,54 +778,264 @@ func (c *APISpecification) Load(specLocation string, specHost string) error {
 					Info: &c.APIInfo,
 					MethodNavigationByName: methodNavByName,
 					MethodSortBy:           methodSortBy,
+					MethodOrder:            methodOrder,
 					Consumes:               apispec.Consumes,
 					Produces:               apispec.Produces,
+					Versions:               make(map[string][]Method),
 				}
 			}
 
-			var ver string
-			if ver, ok = pathItem.Extensions["x-version"].(string); !ok {
-				ver = "latest"
-			}
-			api.CurrentVersion = ver
-
-			c.getMethods(tag, api, &api.Methods, &pathItem, path, ver) // Current version
-			//c.getVersions(tag, api, pathItem.Versions, path)           // All versions
+			c.getMethods(tag, api, &pathItem, path, versions, operations)
 
 			// If API was populated (will not be if tags do not match), add to set
-			if !groupingByTag && len(api.Methods) > 0 {
+			if !groupingByTag && len(api.Versions) > 0 {
 				logger.Tracef(nil, "    + Adding %s\n", name)
 
-				sort.Sort(SortMethods(api.Methods))
+				c.setCurrentVersion(api)
 				c.APIs = append(c.APIs, *api) // All APIs (versioned within)
 			}
 		}
 
-		if groupingByTag && len(api.Methods) > 0 {
+		if groupingByTag && len(api.Versions) > 0 {
 			logger.Tracef(nil, "    + Adding %s\n", name)
 
-			sort.Sort(SortMethods(api.Methods))
+			c.setCurrentVersion(api)
 			c.APIs = append(c.APIs, *api) // All APIs (versioned within)
+			tagAPIs[tag.Name] = api.ID
+			if order := displayOrder(tag.Extensions); order != nil {
+				apiOrder[api.ID] = *order
+			}
 		}
 	}
 
-	// Build a API map, grouping by version
-	for _, api := range c.APIs {
-		for v, _ := range api.Versions {
-			if c.APIVersions == nil {
-				c.APIVersions = make(map[string]APISet)
-			}
-			// Create copy of API and set Methods array to be correct for the version we are building
-			napi := api
-			napi.Methods = napi.Versions[v]
-			napi.Versions = nil
-			c.APIVersions[v] = append(c.APIVersions[v], napi) // Group APIs by version
+	sortAPIs(c.APIs, apiOrder, specCfg.APIOrder, tagAPIs)
+	nav, err := LoadNavigation(c.ID)
+	if err != nil {
+		return err
+	}
+	c.applyNavigation(nav, tagAPIs)
+
+	if c.TagGroups == nil {
+		c.TagGroups = getTagGroups(apispec, c.APIs, tagAPIs)
+	}
+
+	c.APIVersions = groupByVersion(c.APIs)
+	c.OrderedVersions = allVersions(c.APIs)
+	c.Errors = c.errorCatalogue()
+	if cfg.AllDefinitions {
+		c.Models = c.getModels(apispec.Definitions, versions)
+	}
+	c.NavigationResources = c.navigationResources()
+
+	return c.err
+}
+
+// -----------------------------------------------------------------------------
+// fail records a problem that makes the specification unusable. Parsing carries on
+// past it, so that the remaining problems are logged too, and parse returns the first.
+func (c *APISpecification) fail(format string, args ...interface{}) {
+	err := fmt.Errorf(format, args...)
+	logger.Errorf(nil, "Error: %s", err)
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+// -----------------------------------------------------------------------------
+// sortAPIs orders the API groups: first those listed in the configured order (by tag
+// name or API ID), then those with an x-displayOrder, then the rest in the order that
+// they were declared.
+func sortAPIs(apis APISet, apiOrder map[string]int, configOrder []string, tagAPIs map[string]string) {
+	configured := make(map[string]int)
+	for i, name := range configOrder {
+		if id, ok := tagAPIs[name]; ok {
+			name = id
+		}
+		configured[name] = i
+	}
+
+	rank := func(api APIGroup) (int, int) {
+		if i, ok := configured[api.ID]; ok {
+			return 0, i
+		}
+		if order, ok := apiOrder[api.ID]; ok {
+			return 1, order
 		}
+		return 2, 0
 	}
 
+	sort.SliceStable(apis, func(i, j int) bool {
+		ci, oi := rank(apis[i])
+		cj, oj := rank(apis[j])
+		if ci != cj {
+			return ci < cj
+		}
+		return oi < oj
+	})
+}
+
+// -----------------------------------------------------------------------------
+// displayOrder returns the x-displayOrder extension, or nil if there is none
+func displayOrder(extensions spec.Extensions) *int {
+	switch order := extensions["x-displayOrder"].(type) {
+	case float64:
+		i := int(order)
+		return &i
+	case int:
+		return &order
+	}
 	return nil
 }
 
+// -----------------------------------------------------------------------------
+// enumDescriptions returns the meaning of each enum value, keyed by value. The
+// x-enumDescriptions extension gives them either as a map of value to description, or as
+// a list in the order of the enum, as x-enum-descriptions does. x-enum-varnames, the
+// names that code generators give the values, is used for values with no description.
+func enumDescriptions(enum []string, extensions spec.Extensions) map[string]string {
+	descriptions := make(map[string]string)
+
+	fromList := func(list []interface{}) {
+		for i, d := range list {
+			if text, ok := d.(string); ok && i < len(enum) && len(descriptions[enum[i]]) == 0 {
+				descriptions[enum[i]] = text
+			}
+		}
+	}
+
+	switch d := extensions["x-enumDescriptions"].(type) {
+	case map[string]interface{}:
+		for value, description := range d {
+			if text, ok := description.(string); ok {
+				descriptions[value] = text
+			}
+		}
+	case []interface{}:
+		fromList(d)
+	}
+	if list, ok := extensions["x-enum-descriptions"].([]interface{}); ok {
+		fromList(list)
+	}
+	if list, ok := extensions["x-enum-varnames"].([]interface{}); ok {
+		fromList(list)
+	}
+
+	if len(descriptions) == 0 {
+		return nil
+	}
+	return descriptions
+}
+
+// -----------------------------------------------------------------------------
+// overrideLocation replaces the basePath, host and schemes of a specification with
+// those configured for it, for specs generated with internal hostnames that are
+// published through a gateway.
+func overrideLocation(apispec *spec.Swagger, specCfg *config.SpecConfig) {
+	if len(specCfg.BasePath) > 0 {
+		apispec.BasePath = specCfg.BasePath
+	}
+	if len(specCfg.Host) > 0 {
+		apispec.Host = specCfg.Host
+	}
+	if len(specCfg.Schemes) > 0 {
+		apispec.Schemes = specCfg.Schemes
+	}
+}
+
+// -----------------------------------------------------------------------------
+// getServers lists the targets of explorer requests: the host with each of the
+// specification's schemes, then those of the x-servers extension and the configuration file.
+func getServers(apispec *spec.Swagger, configured []config.Server) []Server {
+	var servers []Server
+	seen := make(map[string]bool)
+
+	add := func(u, description string) {
+		u = strings.TrimSuffix(u, "/")
+		if seen[u] {
+			return
+		}
+		seen[u] = true
+		servers = append(servers, Server{URL: u, Description: description})
+	}
+
+	if len(apispec.Host) > 0 {
+		schemes := apispec.Schemes
+		if len(schemes) == 0 {
+			schemes = []string{"http"}
+		}
+		for _, scheme := range schemes {
+			add(scheme+"://"+apispec.Host, "")
+		}
+	}
+
+	if list, ok := apispec.Extensions["x-servers"].([]interface{}); ok {
+		for _, s := range list {
+			entry, _ := s.(map[string]interface{})
+			u, _ := entry["url"].(string)
+			if len(u) == 0 {
+				logger.Errorf(nil, "Error: Invalid x-servers entry %v\n", s)
+				continue
+			}
+			description, _ := entry["description"].(string)
+			add(u, description)
+		}
+	}
+
+	for _, server := range configured {
+		add(server.URL, server.Description)
+	}
+	return servers
+}
+
+// -----------------------------------------------------------------------------
+// getTagGroups groups the APIs by the x-tagGroups extension, in the order given there.
+// APIs whose tag is in no group follow in an unnamed group.
+func getTagGroups(specification *spec.Swagger, apis APISet, tagAPIs map[string]string) []TagGroup {
+	groups, ok := specification.Extensions["x-tagGroups"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	byID := make(map[string]APIGroup)
+	for _, api := range apis {
+		byID[api.ID] = api
+	}
+
+	var tagGroups []TagGroup
+	grouped := make(map[string]bool)
+
+	for _, g := range groups {
+		group, ok := g.(map[string]interface{})
+		if !ok {
+			logger.Errorf(nil, "Error: Invalid x-tagGroups entry %v\n", g)
+			continue
+		}
+		name, _ := group["name"].(string)
+		tags, _ := group["tags"].([]interface{})
+
+		tagGroup := TagGroup{ID: TitleToKebab(name), Name: name}
+		for _, t := range tags {
+			tag, _ := t.(string)
+			if id, ok := tagAPIs[tag]; ok && !grouped[id] {
+				tagGroup.APIs = append(tagGroup.APIs, byID[id])
+				grouped[id] = true
+			}
+		}
+		tagGroups = append(tagGroups, tagGroup)
+	}
+
+	ungrouped := TagGroup{}
+	for _, api := range apis {
+		if !grouped[api.ID] {
+			ungrouped.APIs = append(ungrouped.APIs, api)
+		}
+	}
+	if len(ungrouped.APIs) > 0 {
+		tagGroups = append(tagGroups, ungrouped)
+	}
+	return tagGroups
+}
+
 // -----------------------------------------------------------------------------
 
 func getTags(specification *spec.Swagger) []spec.Tag {
@@ -476,41 +1051,40 @@ func getTags(specification *spec.Swagger) []spec.Tag {
 }
 
 // -----------------------------------------------------------------------------
+// getMethods adds the operations of a path to each version of the API that they are in.
+// extra are the operations with methods that spec.PathItem does not have, such as trace.
 
-func (c *APISpecification) getVersions(tag spec.Tag, api *APIGroup, versions map[string]spec.PathItem, path string) {
-	if versions == nil {
-		return
-	}
-	api.Versions = make(map[string][]Method)
+func (c *APISpecification) getMethods(tag spec.Tag, api *APIGroup, pi *spec.PathItem, path string, versions []string, extra map[string]*spec.Operation) {
 
-	for v, pi := range versions {
-		logger.Tracef(nil, "Process version %s\n", v)
-		var method []Method
-		c.getMethods(tag, api, &method, &pi, path, v)
-		api.Versions[v] = method
+	c.getMethod(tag, api, versions, pi, pi.Get, path, "get")
+	c.getMethod(tag, api, versions, pi, pi.Post, path, "post")
+	c.getMethod(tag, api, versions, pi, pi.Put, path, "put")
+	c.getMethod(tag, api, versions, pi, pi.Delete, path, "delete")
+	c.getMethod(tag, api, versions, pi, pi.Head, path, "head")
+	c.getMethod(tag, api, versions, pi, pi.Options, path, "options")
+	c.getMethod(tag, api, versions, pi, pi.Patch, path, "patch")
+	for _, method := range sortedMethods(extra) {
+		c.getMethod(tag, api, versions, pi, extra[method], path, method)
 	}
 }
 
 // -----------------------------------------------------------------------------
 
-func (c *APISpecification) getMethods(tag spec.Tag, api *APIGroup, methods *[]Method, pi *spec.PathItem, path string, version string) {
-
-	c.getMethod(tag, api, methods, version, pi, pi.Get, path, "get")
-	c.getMethod(tag, api, methods, version, pi, pi.Post, path, "post")
-	c.getMethod(tag, api, methods, version, pi, pi.Put, path, "put")
-	c.getMethod(tag, api, methods, version, pi, pi.Delete, path, "delete")
-	c.getMethod(tag, api, methods, version, pi, pi.Head, path, "head")
-	c.getMethod(tag, api, methods, version, pi, pi.Options, path, "options")
-	c.getMethod(tag, api, methods, version, pi, pi.Patch, path, "patch")
-}
-
-// -----------------------------------------------------------------------------
-
-func (c *APISpecification) getMethod(tag spec.Tag, api *APIGroup, methods *[]Method, version string, pathitem *spec.PathItem, operation *spec.Operation, path, methodname string) {
+func (c *APISpecification) getMethod(tag spec.Tag, api *APIGroup, versions []string, pathitem *spec.PathItem, operation *spec.Operation, path, methodname string) {
 	if operation == nil {
 		logger.Tracef(nil, "Skipping %s %s - Operation is nil.", path, methodname)
 		return
 	}
+	if c.filter != nil && c.filter.excludes(operation, path, methodname) {
+		logger.Tracef(nil, "Skipping %s %s - Excluded by the configuration.", path, methodname)
+		return
+	}
+	process := func() {
+		for _, version := range operationVersions(pathitem, operation, versions) {
+			method := c.processMethod(api, pathitem, operation, path, methodname, version)
+			api.Versions[version] = append(api.Versions[version], *method)
+		}
+	}
 	// Filter and sort by matching current top-level tag with the operation tags.
 	// If Tagging is not used by spec, then process each operation without filtering.
 	taglen := len(operation.Tags)
@@ -520,15 +1094,13 @@ func (c *APISpecification) getMethod(tag spec.Tag, api *APIGroup, methods *[]Met
 			logger.Tracef(nil, "Skipping %s - Operation does not contain a tag member, and tagging is in use.", operation.Summary)
 			return
 		}
-		method := c.processMethod(api, pathitem, operation, path, methodname, version)
-		*methods = append(*methods, *method)
+		process()
 	} else {
 		logger.Tracef(nil, "    > Check tags")
 		for _, t := range operation.Tags {
 			logger.Tracef(nil, "      - Compare tag '%s' with '%s'\n", tag.Name, t)
 			if tag.Name == "" || t == tag.Name {
-				method := c.processMethod(api, pathitem, operation, path, methodname, version)
-				*methods = append(*methods, *method)
+				process()
 			}
 		}
 	}
@@ -536,18 +1108,37 @@ func (c *APISpecification) getMethod(tag spec.Tag, api *APIGroup, methods *[]Met
 
 // -----------------------------------------------------------------------------
 
-func (c *APISpecification) getSecurityDefinitions(spec *spec.Swagger) {
+func (c *APISpecification) getSecurityDefinitions(spec *spec.Swagger, raw json.RawMessage) {
 
 	if c.SecurityDefinitions == nil {
 		c.SecurityDefinitions = make(map[string]SecurityScheme)
 	}
 
+	// The OpenAPI 3 http and openIdConnect scheme members are not kept by spec.SecurityScheme
+	var document struct {
+		SecurityDefinitions map[string]struct {
+			Scheme           string `json:"scheme"`
+			BearerFormat     string `json:"bearerFormat"`
+			OpenIDConnectURL string `json:"openIdConnectUrl"`
+		} `json:"securityDefinitions"`
+	}
+	if err := json.Unmarshal(raw, &document); err != nil {
+		logger.Warnf(nil, "Error reading security schemes: %s", err)
+	}
+
 	for n, d := range spec.SecurityDefinitions {
 		stype := d.Type
+		extra := document.SecurityDefinitions[n]
+
+		if stype == "http" {
+			// Named after the http authentication scheme, so that an operation can
+			// accept both basic and bearer authentication.
+			stype = strings.ToLower(extra.Scheme)
+		}
 
 		def := &SecurityScheme{
-			Description:   string(github_flavored_markdown.Markdown([]byte(d.Description))),
-			Type:          stype,  // basic, apiKey or oauth2
+			Description:   c.markdown(d.Description),
+			Type:          stype,  // basic, apiKey, oauth2, openIdConnect, or another http scheme such as bearer
 			ParamName:     d.Name, // name of header to be used if ParamLocation is 'header'
 			ParamLocation: d.In,   // Either query or header
 		}
@@ -568,6 +1159,14 @@ func (c *APISpecification) getSecurityDefinitions(spec *spec.Swagger) {
 				def.Scopes[s] = n
 			}
 		}
+		if stype == "bearer" {
+			def.IsBearer = true
+			def.BearerFormat = extra.BearerFormat
+		}
+		if stype == "openIdConnect" {
+			def.IsOpenIDConnect = true
+			def.OpenIDConnectUrl = extra.OpenIDConnectURL
+		}
 
 		c.SecurityDefinitions[n] = *def
 	}
@@ -577,20 +1176,21 @@ func (c *APISpecification) getSecurityDefinitions(spec *spec.Swagger) {
 
 func (c *APISpecification) getDefaultSecurity(spec *spec.Swagger) {
 	c.DefaultSecurity = make(map[string]Security)
-	c.processSecurity(spec.Security, c.DefaultSecurity)
+	c.DefaultRequirements = c.processSecurity(spec.Security, c.DefaultSecurity)
 }
 
 // -----------------------------------------------------------------------------
-func (p *Parameter) setType(src spec.Parameter) {
+func (p *Parameter) setType(src spec.Parameter) error {
+	style, _ := src.Extensions.GetString("x-style")
 	if src.Type == "array" {
-		if len(src.CollectionFormat) == 0 {
-			logger.Errorf(nil, "Error: Request parameter %s is an array without declaring the collectionFormat.\n", src.Name)
-			os.Exit(1)
+		if len(src.CollectionFormat) == 0 && len(style) == 0 {
+			return fmt.Errorf("request parameter %s is an array without declaring the collectionFormat", src.Name)
 		}
 		p.Type = append(p.Type, src.Type)
 		p.CollectionFormat = src.CollectionFormat
 		p.CollectionFormatDescription = collectionFormatDescription(src.CollectionFormat)
 	}
+	p.setStyle(src, style)
 	var ptype string
 	var format string
 
@@ -605,6 +1205,31 @@ func (p *Parameter) setType(src spec.Parameter) {
 		ptype = format
 	}
 	p.Type = append(p.Type, ptype)
+	p.IsFile = ptype == "file"
+	return nil
+}
+
+// setStyle works out how an array or object parameter is serialized. Swagger 2 declares
+// this with collectionFormat, which is mapped to its OpenAPI 3 style and explode. The
+// OpenAPI 3 style and explode can also be given directly with x-style and x-explode,
+// which allows deepObject query parameters, for example.
+func (p *Parameter) setStyle(src spec.Parameter, style string) {
+	if len(style) == 0 {
+		if src.Type != "array" && src.Type != "object" {
+			return
+		}
+		p.Style, p.Explode = collectionFormatStyle(src.CollectionFormat, src.In)
+		return
+	}
+	p.Style = style
+	// Like OpenAPI 3, form parameters explode by default
+	p.Explode = style == "form"
+	if explode, ok := src.Extensions.GetBool("x-explode"); ok {
+		p.Explode = explode
+	}
+	if len(p.CollectionFormatDescription) == 0 {
+		p.CollectionFormatDescription = styleDescription(p.Style, p.Explode)
+	}
 }
 
 func (p *Parameter) setEnums(src spec.Parameter) {
@@ -619,6 +1244,11 @@ func (p *Parameter) setEnums(src spec.Parameter) {
 		es = append(es, fmt.Sprintf("%s", e))
 	}
 	p.Enum = es
+
+	p.EnumDescriptions = enumDescriptions(es, src.Extensions)
+	if p.EnumDescriptions == nil && src.Items != nil {
+		p.EnumDescriptions = enumDescriptions(es, src.Items.Extensions)
+	}
 }
 
 // -----------------------------------------------------------------------------
@@ -652,12 +1282,13 @@ func (c *APISpecification) processMethod(api *APIGroup, pathItem *spec.PathItem,
 		navigationName = o.Summary
 	}
 
-	sortkey := api.getMethodSortKey(path, methodname, operationName, navigationName, o.Summary)
+	c.methodCount++
+	sortkey := api.getMethodSortKey(c.methodCount, path, methodname, operationName, navigationName, o.Summary)
 
 	method := &Method{
 		ID:             CamelToKebab(id),
 		Name:           o.Summary,
-		Description:    string(github_flavored_markdown.Markdown([]byte(o.Description))),
+		Description:    c.markdown(o.Description),
 		Method:         methodname,
 		Path:           path,
 		Responses:      make(map[int]Response),
@@ -665,6 +1296,10 @@ func (c *APISpecification) processMethod(api *APIGroup, pathItem *spec.PathItem,
 		OperationName:  operationName,
 		APIGroup:       api,
 		SortKey:        sortkey,
+		DisplayOrder:   displayOrder(o.Extensions),
+		Anchor:         methodAnchor(methodname, path),
+		Extensions:     extensions(o.Extensions),
+		Deprecated:     o.Deprecated,
 	}
 	if len(o.Consumes) > 0 {
 		method.Consumes = o.Consumes
@@ -676,6 +1311,11 @@ func (c *APISpecification) processMethod(api *APIGroup, pathItem *spec.PathItem,
 	} else {
 		method.Produces = api.Produces
 	}
+	if method.RateLimits = getRateLimits(o.Extensions); method.RateLimits != nil {
+		method.OwnRateLimits = true
+	} else {
+		method.RateLimits = c.RateLimits
+	}
 
 	// If Tagging is not used by spec to select, group and order API paths to document, then
 	// complete the missing names.
@@ -689,8 +1329,7 @@ func (c *APISpecification) processMethod(api *APIGroup, pathItem *spec.PathItem,
 	if api.Name == "" {
 		name := o.Summary
 		if name == "" {
-			logger.Errorf(nil, "Error: Operation '%s' does not have an operationId or summary member.", id)
-			os.Exit(1)
+			c.fail("operation '%s' does not have an operationId or summary member", id)
 		}
 		api.Name = name
 		api.ID = TitleToKebab(name)
@@ -704,11 +1343,18 @@ func (c *APISpecification) processMethod(api *APIGroup, pathItem *spec.PathItem,
 		p := Parameter{
 			Name:        param.Name,
 			In:          param.In,
-			Description: string(github_flavored_markdown.Markdown([]byte(param.Description))),
+			Description: c.markdown(param.Description),
 			Required:    param.Required,
+			Extensions:  extensions(param.Extensions),
+		}
+		p.Anchor = parameterAnchor(method, param.In, param.Name)
+		if err := p.setType(param); err != nil {
+			c.fail("%s", err)
 		}
-		p.setType(param)
 		p.setEnums(param)
+		if param.Default != nil {
+			p.Default = fmt.Sprint(param.Default)
+		}
 
 		switch strings.ToLower(param.In) {
 		case "formdata":
@@ -717,12 +1363,17 @@ func (c *APISpecification) processMethod(api *APIGroup, pathItem *spec.PathItem,
 			method.PathParams = append(method.PathParams, p)
 		case "body":
 			if param.Schema == nil {
-				logger.Errorf(nil, "Error: 'in body' parameter %s is missing a schema declaration.\n", param.Name)
-				os.Exit(1)
+				c.fail("'in body' parameter %s is missing a schema declaration", param.Name)
+				continue
 			}
 			var body map[string]interface{}
+			schema := resourceSchema(param.Schema)
 			p.Resource, body, p.IsArray = c.resourceFromSchema(param.Schema, method, nil, true)
+			if p.Resource == nil {
+				continue // The schema has no title, which resourceFromSchema recorded
+			}
 			p.Resource.Schema = jsonResourceToString(body, p.IsArray)
+			p.Resource.schema = schema
 			p.Resource.origin = RequestBody
 			method.BodyParam = &p
 			c.crossLinkMethodAndResource(p.Resource, method, version)
@@ -735,12 +1386,13 @@ func (c *APISpecification) processMethod(api *APIGroup, pathItem *spec.PathItem,
 
 	// Compile resources from response declaration
 
-	if o.Responses == nil {
-		logger.Errorf(nil, "Error: Operation %s %s is missing a responses declaration.\n", methodname, path)
-		os.Exit(1)
+	// Operations without responses are documented as having none, rather than stopping the load
+	responses := o.Responses
+	if responses == nil {
+		logger.Warnf(nil, "Operation %s %s is missing a responses declaration", methodname, path)
+		responses = &spec.Responses{}
 	}
-	// FIXME - Dies if there are no responses...
-	for status, response := range o.Responses.StatusCodeResponses {
+	for status, response := range responses.StatusCodeResponses {
 		logger.Tracef(nil, "Response for status %d", status)
 		//spew.Dump(response)
 
@@ -753,20 +1405,31 @@ func (c *APISpecification) processMethod(api *APIGroup, pathItem *spec.PathItem,
 		}
 		rsp := c.buildResponse(&response, method, version)
 		(*rsp).StatusDescription = HTTPStatusDescription(status)
+		(*rsp).Anchor = responseAnchor(method, status)
 		method.Responses[status] = *rsp
 
 	}
 
-	if o.Responses.Default != nil {
-		rsp := c.buildResponse(o.Responses.Default, method, version)
+	if responses.Default != nil {
+		rsp := c.buildResponse(responses.Default, method, version)
+		rsp.Anchor = responseAnchor(method, 0)
 		method.DefaultResponse = rsp
 	}
 
-	// If no Security given for operation, then the global defaults are appled.
+	// If no Security given for operation, then the global defaults are appled. An empty
+	// list of requirements is given to make a public operation of a secured API.
 	method.Security = make(map[string]Security)
-	if c.processSecurity(o.Security, method.Security) == false {
+	method.Requirements = c.processSecurity(o.Security, method.Security)
+	if method.Requirements == nil && len(o.Security) > 0 {
+		logger.Warnf(nil, "Operation %s %s has no security requirement that can be met, so the default applies", methodname, path)
+	}
+	if method.Requirements == nil && (o.Security == nil || len(o.Security) > 0) {
 		method.Security = c.DefaultSecurity
+		method.Requirements = c.DefaultRequirements
+	} else {
+		method.OwnSecurity = c.DefaultRequirements != nil
 	}
+	method.Public = method.Requirements == nil || optionalSecurity(method.Requirements)
 
 	return method
 }
@@ -781,24 +1444,31 @@ func (c *APISpecification) buildResponse(resp *spec.Response, method *Method, ve
 		var r *Resource
 		var is_array bool
 		var example_json map[string]interface{}
+		var schema []byte
 
 		if resp.Schema != nil {
+			schema, _ = json.Marshal(resp.Schema) // Before resourceFromSchema, which may alter it
+			resourceJSONSchema := resourceSchema(resp.Schema)
 			r, example_json, is_array = c.resourceFromSchema(resp.Schema, method, nil, false)
 
 			if r != nil {
 				r.Schema = jsonResourceToString(example_json, false)
+				r.schema = resourceJSONSchema
 				r.origin = MethodResponse
 				vres = c.crossLinkMethodAndResource(r, method, version)
 			}
 		}
 		response = &Response{
-			Description: string(github_flavored_markdown.Markdown([]byte(resp.Description))),
+			Description: c.markdown(resp.Description),
 			Resource:    vres,
 			IsArray:     is_array,
+			Schema:      string(schema),
 		}
 		method.Resources = append(method.Resources, response.Resource) // Add the resource to the method which uses it
 
-		response.compileHeaders(resp)
+		if err := response.compileHeaders(resp); err != nil {
+			c.fail("%s", err)
+		}
 	}
 	return response
 }
@@ -855,6 +1525,13 @@ func (c *APISpecification) crossLinkMethodAndResource(resource *Resource, method
 		c.ResourceList[version][resource.ID] = vres // If we've already got the resource, this does nothing
 	}
 
+	// Give the definitions that the resource extends pages of their own, so that they can be linked to
+	for _, parent := range vres.Extends {
+		if _, ok := c.ResourceList[version][parent.ID]; !ok {
+			c.ResourceList[version][parent.ID] = parent
+		}
+	}
+
 	return vres
 }
 
@@ -912,10 +1589,47 @@ func collectionFormatDescription(format string) string {
 	return ""
 }
 
-func (r *Response) compileHeaders(sr *spec.Response) {
+// collectionFormatStyle returns the OpenAPI 3 style and explode equivalent to a Swagger 2
+// collectionFormat, which defaults to csv.
+func collectionFormatStyle(format, in string) (string, bool) {
+	switch format {
+	case "ssv":
+		return "spaceDelimited", false
+	case "tsv":
+		return "tabDelimited", false // Not in OpenAPI 3, which dropped tsv
+	case "pipes":
+		return "pipeDelimited", false
+	case "multi":
+		return "form", true
+	}
+	if in == "query" || in == "formData" {
+		return "form", false
+	}
+	return "simple", false
+}
+
+var styleTable = map[string]string{
+	"simple":         "comma separated",
+	"form":           "comma separated",
+	"spaceDelimited": "space separated",
+	"tabDelimited":   "tab separated",
+	"pipeDelimited":  "pipe separated",
+	"deepObject":     "deep object",
+	"label":          "label prefixed",
+	"matrix":         "matrix prefixed",
+}
+
+func styleDescription(style string, explode bool) string {
+	if style == "form" && explode {
+		return collectionFormatDescription("multi")
+	}
+	return styleTable[style]
+}
+
+func (r *Response) compileHeaders(sr *spec.Response) error {
 
 	if sr.Headers == nil {
-		return
+		return nil
 	}
 	for name, params := range sr.Headers {
 
@@ -927,8 +1641,7 @@ func (r *Response) compileHeaders(sr *spec.Response) {
 		htype := getType(params)
 		if params.Type == "array" {
 			if len(params.CollectionFormat) == 0 {
-				logger.Errorf(nil, "Error: Response header %s is an array without declaring the collectionFormat.\n", name)
-				os.Exit(1)
+				return fmt.Errorf("response header %s is an array without declaring the collectionFormat", name)
 			}
 			header.Type = append(header.Type, params.Type)
 			header.CollectionFormat = params.CollectionFormat
@@ -943,37 +1656,92 @@ func (r *Response) compileHeaders(sr *spec.Response) {
 
 		r.Headers = append(r.Headers, *header)
 	}
+	return nil
 }
 
 // -----------------------------------------------------------------------------
 
-func (c *APISpecification) processSecurity(s []map[string][]string, security map[string]Security) bool {
+// processSecurity adds the schemes of the alternative security requirements s to security,
+// and returns the requirements. It returns nil if s has none, when the defaults apply.
+func (c *APISpecification) processSecurity(s []map[string][]string, security map[string]Security) []SecurityRequirement {
 
-	count := 0
+	var requirements []SecurityRequirement
 	for _, sec := range s {
-		for n, scopes := range sec {
+		requirement := SecurityRequirement{}
+		known := true
+
+		var names []string
+		for n := range sec {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+
+		for _, n := range names {
+			scopes := sec[n]
 			// Lookup security name in definitions
-			if scheme, ok := c.SecurityDefinitions[n]; ok {
-				count++
+			scheme, ok := c.SecurityDefinitions[n]
+			if !ok {
+				logger.Warnf(nil, "Security requirement names %s, which is not in the securityDefinitions", n)
+				known = false
+				continue
+			}
 
-				// Add security
-				security[scheme.Type] = Security{
-					Scheme: &scheme,
-					Scopes: make(map[string]string),
-				}
+			sc := Security{
+				Name:   n,
+				Scheme: &scheme,
+				Scopes: make(map[string]string),
+			}
 
-				if scheme.IsOAuth2 {
-					// Populate method specific scopes by cross referencing SecurityDefinitions
-					for _, scope := range scopes {
-						if scope_desc, ok := scheme.Scopes[scope]; ok {
-							security[scheme.Type].Scopes[scope] = scope_desc
-						}
+			if scheme.IsOAuth2 {
+				// Populate method specific scopes by cross referencing SecurityDefinitions
+				for _, scope := range scopes {
+					if scope_desc, ok := scheme.Scopes[scope]; ok {
+						sc.Scopes[scope] = scope_desc
 					}
 				}
 			}
+			if scheme.IsOpenIDConnect {
+				// Scopes are described by the provider's discovery document, not the specification
+				for _, scope := range scopes {
+					sc.Scopes[scope] = ""
+				}
+			}
+
+			// Add security
+			security[scheme.Type] = sc
+			requirement = append(requirement, sc)
+		}
+
+		// A requirement with an undefined scheme cannot be met, and must not be
+		// mistaken for one that needs no authorisation.
+		if known {
+			requirements = append(requirements, requirement)
+		}
+	}
+	return requirements
+}
+
+// optionalSecurity is true if one of the alternative requirements is empty, so that a
+// request can be made without authorisation.
+func optionalSecurity(requirements []SecurityRequirement) bool {
+	for _, requirement := range requirements {
+		if len(requirement) == 0 {
+			return true
 		}
 	}
-	return count != 0
+	return false
+}
+
+// ScopedSecurity returns the schemes of the method that need scopes, in name order
+func (m *Method) ScopedSecurity() []Security {
+	var scoped []Security
+	for _, security := range m.Security {
+		if len(security.Scopes) > 0 {
+			scoped = append(scoped, security)
+		}
+	}
+	sort.Slice(scoped, func(i, j int) bool { return scoped[i].Name < scoped[j].Name })
+	return scoped
 }
 
 // -----------------------------------------------------------------------------
@@ -1055,9 +1823,10 @@ func (c *APISpecification) resourceFromSchema(s *spec.Schema, method *Method, fq
 		return nil, nil, false
 	}
 
-	stype := checkPropertyType(s)
-	logger.Tracef(nil, "resourceFromSchema: Schema type: %s\n", stype)
-	logger.Tracef(nil, "FQNS: %s\n", fqNS)
+	if logger.Enabled(logger.Trace) {
+		logger.Tracef(nil, "resourceFromSchema: Schema type: %s\n", checkPropertyType(s))
+		logger.Tracef(nil, "FQNS: %s\n", fqNS)
+	}
 	logger.Tracef(nil, "CHECK schema type and items\n")
 	//spew.Dump(s)
 
@@ -1117,11 +1886,15 @@ func (c *APISpecification) resourceFromSchema(s *spec.Schema, method *Method, fq
 		s.Type[len(s.Type)-1] = s.Format
 	}
 
-	id := TitleToKebab(s.Title)
+	title := s.Title
+	if len(fqNS) == 0 && len(title) == 0 {
+		title = derivedTitle(s, method, isRequestResource)
+	}
+	id := TitleToKebab(title)
 
 	if len(fqNS) == 0 && id == "" {
-		logger.Errorf(nil, "Error: %s %s references a model definition that does not have a title member.", strings.ToUpper(method.Method), method.Path)
-		os.Exit(1)
+		c.fail("%s %s references a model definition that does not have a title member. Set schema-names to derive to name it automatically", strings.ToUpper(method.Method), method.Path)
+		return nil, nil, false
 	}
 
 	// Ignore ID (from title element) for all but child-objects...
@@ -1164,23 +1937,25 @@ func (c *APISpecification) resourceFromSchema(s *spec.Schema, method *Method, fq
 	// If there is no description... the case where we have an array of objects. See issue/11
 	var description string
 	if original_s.Description != "" {
-		description = string(github_flavored_markdown.Markdown([]byte(original_s.Description)))
+		description = c.markdown(original_s.Description)
 	} else {
 		description = original_s.Title
 	}
 
-	logger.Tracef(nil, "Create resource %s [%s]\n", id, s.Title)
+	logger.Tracef(nil, "Create resource %s [%s]\n", id, title)
 	if is_array {
 		logger.Tracef(nil, "- Is Arrays\n")
 	}
 
 	r := &Resource{
-		ID:          id,
-		Title:       s.Title,
-		Description: description,
-		Type:        s.Type,
-		Properties:  make(map[string]*Resource),
-		FQNS:        resourceFQNS,
+		ID:            id,
+		Title:         title,
+		Description:   description,
+		Type:          s.Type,
+		Properties:    make(map[string]*Resource, len(s.Properties)),
+		PropertyNames: make([]string, 0, len(s.Properties)),
+		FQNS:          resourceFQNS,
+		Extensions:    extensions(s.Extensions),
 	}
 
 	if s.Example != nil {
@@ -1195,6 +1970,13 @@ func (c *APISpecification) resourceFromSchema(s *spec.Schema, method *Method, fq
 		for _, e := range s.Enum {
 			r.Enum = append(r.Enum, fmt.Sprintf("%s", e))
 		}
+		r.EnumDescriptions = enumDescriptions(r.Enum, s.Extensions)
+		if r.EnumDescriptions == nil && s != original_s {
+			r.EnumDescriptions = enumDescriptions(r.Enum, original_s.Extensions)
+		}
+	}
+	if !s.Type.Contains("object") {
+		r.example = synthesiseExample(s, fqNS)
 	}
 
 	r.ReadOnly = original_s.ReadOnly
@@ -1209,15 +1991,24 @@ func (c *APISpecification) resourceFromSchema(s *spec.Schema, method *Method, fq
 	}
 
 	required := make(map[string]bool)
-	json_representation := make(map[string]interface{})
+	json_representation := make(map[string]interface{}, len(s.Properties))
 
 	logger.Tracef(nil, "Call compileproperties...\n")
 	c.compileproperties(s, r, method, id, required, json_representation, myFQNS, chopped, isRequestResource)
 
 	for allof := range s.AllOf {
 		c.compileproperties(&s.AllOf[allof], r, method, id, required, json_representation, myFQNS, chopped, isRequestResource)
+
+		// A titled member is a named definition, which this resource inherits from
+		if parent := &s.AllOf[allof]; len(parent.Title) > 0 && TitleToKebab(parent.Title) != r.ID {
+			if p, _, _ := c.resourceFromSchema(parent, method, nil, isRequestResource); p != nil {
+				r.Extends = append(r.Extends, p)
+			}
+		}
 	}
 
+	c.orderProperties(r)
+
 	logger.Tracef(nil, "resourceFromSchema done\n")
 
 	return r, json_representation, is_array
@@ -1235,21 +2026,61 @@ func (c *APISpecification) compileproperties(s *spec.Schema, r *Resource, method
 		required[n] = true
 	}
 
-	for name, property := range s.Properties {
+	for _, name := range c.propertyNames(s) {
+		property := s.Properties[name]
 		c.processProperty(&property, name, r, method, id, required, json_rep, myFQNS, chopped, isRequestResource)
 	}
 
 	// Special case to deal with AdditionalProperties (which really just boils down to declaring a
 	// map of 'type' (string, int, object etc).
 	if s.AdditionalProperties != nil && s.AdditionalProperties.Allows {
-		name := "<key>"
-		ap := s.AdditionalProperties.Schema
-		ap.Type = spec.StringOrArray([]string{"map", ap.Type[0]}) // massage type so that it is a map of 'type'
+		c.processProperty(mapSchema(s.AdditionalProperties.Schema), "<key>", r, method, id, required, json_rep, myFQNS, chopped, isRequestResource)
+	}
+
+	// PatternProperties (OpenAPI 3.1) are maps whose keys match a pattern. The example
+	// JSON gets a key that matches.
+	patterns := make([]string, 0, len(s.PatternProperties))
+	for pattern := range s.PatternProperties {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	for _, pattern := range patterns {
+		ps := s.PatternProperties[pattern]
+		name := "<" + pattern + ">"
 
-		c.processProperty(ap, name, r, method, id, required, json_rep, myFQNS, chopped, isRequestResource)
+		c.processProperty(mapSchema(&ps), name, r, method, id, required, json_rep, myFQNS, chopped, isRequestResource)
+		if property, ok := r.Properties[name]; ok {
+			property.KeyPattern = pattern
+		}
+		if example, ok := json_rep[name]; ok {
+			if key, ok := exampleKey(pattern, append(append([]string{}, myFQNS...), name)); ok {
+				delete(json_rep, name)
+				json_rep[key] = example
+			}
+		}
 	}
 }
 
+// mapSchema returns a copy of the schema of a map's values, with its type massaged to be
+// a map of 'type'. The schema itself is left alone, as it is shared between the request
+// and response resources that it appears in. A nil schema, from additionalProperties: true,
+// is a map of any type.
+func mapSchema(s *spec.Schema) *spec.Schema {
+	var ms spec.Schema
+	valueType := "any"
+
+	if s != nil {
+		ms = *s
+		valueType = "object" // Such as a named definition that gives properties but no type
+		if len(s.Type) > 0 {
+			valueType = s.Type[0]
+		}
+	}
+	ms.Type = spec.StringOrArray([]string{"map", valueType})
+	return &ms
+}
+
 // -----------------------------------------------------------------------------
 
 func (c *APISpecification) processProperty(s *spec.Schema, name string, r *Resource, method *Method, id string, required map[string]bool, json_rep map[string]interface{}, myFQNS []string, chopped bool, isRequestResource bool) {
@@ -1279,23 +2110,27 @@ func (c *APISpecification) processProperty(s *spec.Schema, name string, r *Resou
 		return
 	}
 
+	if _, ok := r.Properties[name]; !ok {
+		r.PropertyNames = append(r.PropertyNames, name)
+	}
 	r.Properties[name] = resource
+	resource.Anchor = propertyAnchor(resource.FQNS, resource.ID)
 	json_rep[name] = json_resource
 
 	if _, ok := required[name]; ok {
-		r.Properties[name].Required = true
+		resource.Required = true
 	}
-	logger.Tracef(nil, "resource property %s type: %s\n", name, r.Properties[name].Type[0])
+	logger.Tracef(nil, "resource property %s type: %s\n", name, resource.Type[0])
 
-	if strings.ToLower(r.Properties[name].Type[0]) != "object" {
+	if strings.ToLower(resource.Type[0]) != "object" {
 		// Arrays of objects need to be handled as a special case
-		if strings.ToLower(r.Properties[name].Type[0]) == "array" {
+		if strings.ToLower(resource.Type[0]) == "array" {
 			logger.Tracef(nil, "Processing an array property %s", name)
 			if s.Items != nil {
 				if s.Items.Schema != nil {
 					// Some outputs (example schema, member description) are generated differently
 					// if the array member references an object or a primitive type
-					r.Properties[name].Description = string(github_flavored_markdown.Markdown([]byte(s.Description)))
+					resource.Description = c.markdown(s.Description)
 
 					// If here, we have no json_resource returned from resourceFromSchema, then the property
 					// is an array of primitive, so construct either an array of string or array of object
@@ -1305,7 +2140,7 @@ func (c *APISpecification) processProperty(s *spec.Schema, name string, r *Resou
 						array_obj = append(array_obj, json_resource)
 						json_rep[name] = array_obj
 					} else {
-						var array_obj []string
+						var array_obj []interface{}
 						// We stored the real type of the primitive in Type array index 1 (see the note in
 						// resourceFromSchema). There is a special case of an array of object where EVERY
 						// member of the object is read-only and filtered out due to isRequestResource being true.
@@ -1316,9 +2151,9 @@ func (c *APISpecification) processProperty(s *spec.Schema, name string, r *Resou
 						// value of nil. This shouldn't happen often, as a more correct spec will declare the
 						// array member as readOnly!
 						//
-						if len(r.Properties[name].Type) > 1 {
+						if len(resource.Type) > 1 {
 							// Got an array of primitives
-							array_obj = append(array_obj, r.Properties[name].Type[1])
+							array_obj = append(array_obj, resource.exampleOf(resource.Type[1]))
 						}
 						json_rep[name] = array_obj
 					}
@@ -1332,15 +2167,15 @@ func (c *APISpecification) processProperty(s *spec.Schema, name string, r *Resou
 				array_obj = append(array_obj, json_resource)
 				json_rep[name] = array_obj
 			}
-		} else if strings.ToLower(r.Properties[name].Type[0]) == "map" { // not array, so a map?
-			if strings.ToLower(r.Properties[name].Type[1]) == "object" {
+		} else if strings.ToLower(resource.Type[0]) == "map" { // not array, so a map?
+			if strings.ToLower(resource.Type[1]) == "object" {
 				json_rep[name] = json_resource // A map of objects
 			} else {
-				json_rep[name] = r.Properties[name].Type[1] // map of primitive
+				json_rep[name] = resource.exampleOf(resource.Type[1]) // map of primitive
 			}
 		} else {
 			// We're NOT an array, map or object, so a primitive
-			json_rep[name] = r.Properties[name].Type[0]
+			json_rep[name] = resource.exampleOf(resource.Type[0])
 		}
 	} else {
 		// We're an object
@@ -1353,7 +2188,8 @@ func (c *APISpecification) processProperty(s *spec.Schema, name string, r *Resou
 
 func prepareNamespace(myFQNS []string, id string, name string, chopped bool) []string {
 
-	newFQNS := append([]string{}, myFQNS...) // create slice
+	newFQNS := make([]string, len(myFQNS), len(myFQNS)+2) // create slice, with room for what is appended
+	copy(newFQNS, myFQNS)
 
 	if chopped && len(id) > 0 {
 		logger.Tracef(nil, "Append ID onto newFQNZ %s + '%s'", newFQNS, id)
@@ -1367,13 +2203,22 @@ func prepareNamespace(myFQNS []string, id string, name string, chopped bool) []s
 
 // -----------------------------------------------------------------------------
 
-var kababExclude = regexp.MustCompile("[^\\w\\s]") // Any non word or space character
-
+// TitleToKebab lower cases a title, drops any character that is not an ASCII word or
+// space character, and replaces spaces with hyphens. It is called for every resource,
+// so it avoids the allocations of a regular expression.
 func TitleToKebab(s string) string {
 	s = strings.ToLower(s)
-	s = string(kababExclude.ReplaceAll([]byte(s), []byte("")))
-	s = strings.Replace(s, " ", "-", -1)
-	return s
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case ch == ' ':
+			b.WriteByte('-')
+		case ch >= 'a' && ch <= 'z', ch >= '0' && ch <= '9', ch == '_', ch == '\t', ch == '\n', ch == '\f', ch == '\r':
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
 }
 
 // -----------------------------------------------------------------------------
@@ -1390,18 +2235,58 @@ func loadSpec(url string) (*loads.Document, error) {
 
 	logger.Infof(nil, "Importing OpenAPI specifications from %s", url)
 
-	document, err := loads.Spec(url)
+	// Relative $refs to other files are resolved against where the specification came
+	// from, rather than the temporary file it is fetched to.
+	relativeBase := url
+
+	if !isLocalSpecUrl(url) {
+		file, remove, err := fetchSpec(url)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching %s: %s", url, err)
+		}
+		defer remove()
+		url = file
+	}
+
+	var document *loads.Document
+	var err error
+	if isDescriptorSet(relativeBase) {
+		document, err = loadDescriptorSet(url)
+	} else if isRAML(relativeBase) {
+		document, err = loadRAML(url, relativeBase)
+	} else if isBlueprint(relativeBase) {
+		document, err = loadBlueprint(url)
+	} else {
+		document, err = loads.Spec(url)
+	}
 	if err != nil {
 		//logger.Errorf(nil, "Error: go-openapi/loads filed to load spec url [%s]: %s", url, err)
 		return nil, err
 	}
 
+	// Overlays are configured by specification ID, which comes from the title
+	cfg, _ := config.Get()
+	if overlays := cfg.Spec(TitleToKebab(document.Spec().Info.Title)).Overlays; len(overlays) > 0 {
+		logger.Infof(nil, "Applying overlays %v", overlays)
+
+		raw, err := applyOverlays(document.Raw(), overlays)
+		if err != nil {
+			return nil, err
+		}
+		if document, err = loads.Analyzed(raw, ""); err != nil {
+			return nil, err
+		}
+	}
+
 	//options := &spec.ExpandOptions{
 	//	RelativeBase: "/Users/csmith1/src/go/src/github.com/dapperdox/dapperdox-demo/specifications",
 	//}
 
-	// TODO Allow relative references https://github.com/go-openapi/spec/issues/14
-	err = spec.ExpandSpec(document.Spec(), nil)
+	if cfg.SchemaNames == "derive" {
+		titleDefinitions(document.Spec())
+	}
+
+	err = spec.ExpandSpec(document.Spec(), &spec.ExpandOptions{RelativeBase: relativeBase})
 	if err != nil {
 		//logger.Errorf(nil, "Error: go-openapi/spec filed to expand spec: %s", err)
 		return nil, err
@@ -1410,21 +2295,77 @@ func loadSpec(url string) (*loads.Document, error) {
 	return document, nil
 }
 
+// -----------------------------------------------------------------------------
+// titleDefinitions gives each definition that has no title its x-schema-name, or else
+// its name, before expansion copies the definitions to where they are referenced.
+func titleDefinitions(sw *spec.Swagger) {
+	for name, schema := range sw.Definitions {
+		if len(schema.Title) > 0 {
+			continue
+		}
+		schema.Title = name
+		if schemaName, ok := schema.Extensions["x-schema-name"].(string); ok && len(schemaName) > 0 {
+			schema.Title = schemaName
+		}
+		sw.Definitions[name] = schema
+	}
+}
+
+// derivedTitle names a request or response schema that has no title, when schema-names
+// is derive: from its x-schema-name, or else from the operation and the schema's role,
+// such as createUserRequest. Otherwise it returns "".
+func derivedTitle(s *spec.Schema, method *Method, isRequestResource bool) string {
+	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+	if cfg.SchemaNames != "derive" {
+		return ""
+	}
+	if schemaName, ok := s.Extensions["x-schema-name"].(string); ok && len(schemaName) > 0 {
+		return schemaName
+	}
+
+	role := "Response"
+	if isRequestResource {
+		role = "Request"
+	}
+	words := strings.Split(method.ID, "-") // The ID is the kebab case operation ID
+	for i := 1; i < len(words); i++ {
+		if len(words[i]) > 0 {
+			words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+		}
+	}
+	return strings.Join(words, "") + role
+}
+
+// -----------------------------------------------------------------------------
+// markdown converts a description to HTML. The definitions that are referenced from
+// many places are expanded into each of them, so the same descriptions are converted
+// over and over, and the conversions are remembered while the specification is parsed.
+func (c *APISpecification) markdown(text string) string {
+	if html, ok := c.markdownCache[text]; ok {
+		return html
+	}
+	html := string(github_flavored_markdown.Markdown([]byte(text)))
+	if c.markdownCache != nil {
+		c.markdownCache[text] = html
+	}
+	return html
+}
+
 // -----------------------------------------------------------------------------
 // Wrapper around MarshalIndent to prevent < > & from being escaped
 func JSONMarshalIndent(v interface{}) ([]byte, error) {
-	b, err := json.MarshalIndent(v, "", "    ")
-
-	b = bytes.Replace(b, []byte("\\u003c"), []byte("<"), -1)
-	b = bytes.Replace(b, []byte("\\u003e"), []byte(">"), -1)
-	b = bytes.Replace(b, []byte("\\u0026"), []byte("&"), -1)
-	return b, err
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	err := enc.Encode(v)
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), err
 }
 
 // -----------------------------------------------------------------------------
 
 func isLocalSpecUrl(specUrl string) bool {
-	match, err := regexp.MatchString("(?i)^https?://.+", specUrl)
+	match, err := regexp.MatchString("(?i)^(https?|s3|gs|az)://.+", specUrl)
 	if err != nil {
 		panic(fmt.Sprintf("Attempted to match against an invalid regexp: %s", err))
 	}
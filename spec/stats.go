@@ -0,0 +1,175 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"sort"
+	"strings"
+)
+
+// Stats measure how completely a specification is documented, for tracking the
+// quality of documentation across the teams that write it.
+type Stats struct {
+	Coverage
+	Resources     int                 `json:"resources"`
+	Groups        []GroupStats        `json:"groups"`
+	ResponseCodes []ResponseCodeCount `json:"responseCodes"` // By status code
+}
+
+// Coverage counts the operations, of a specification or an API group, that have each
+// kind of documentation.
+type Coverage struct {
+	Operations          int `json:"operations"`
+	Described           int `json:"described"`    // Have a description
+	WithExamples        int `json:"withExamples"` // Have an example request or response body
+	WithErrors          int `json:"withErrors"`   // Document a 4xx, 5xx or default response
+	Secured             int `json:"secured"`      // Require authorization
+	Deprecated          int `json:"deprecated"`
+	Parameters          int `json:"parameters"`
+	DescribedParameters int `json:"describedParameters"`
+}
+
+// GroupStats is the coverage of one API group
+type GroupStats struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Coverage
+}
+
+// ResponseCodeCount is the number of operations that document a status code
+type ResponseCodeCount struct {
+	Code       int `json:"code"`
+	Operations int `json:"operations"`
+}
+
+// -----------------------------------------------------------------------------
+// Percent is n as a percentage of the operations, for templates
+func (c Coverage) Percent(n int) int {
+	if c.Operations == 0 {
+		return 0
+	}
+	return n * 100 / c.Operations
+}
+
+// ParameterPercent is the percentage of parameters that are described
+func (c Coverage) ParameterPercent() int {
+	if c.Parameters == 0 {
+		return 0
+	}
+	return c.DescribedParameters * 100 / c.Parameters
+}
+
+// -----------------------------------------------------------------------------
+// Stats measures the documentation of the version of each API group that is shown by
+// default.
+func (c *APISpecification) Stats() Stats {
+	var stats Stats
+	codes := make(map[int]int)
+
+	for _, api := range c.APIs {
+		group := GroupStats{ID: api.ID, Name: api.Name}
+		for _, method := range api.Versions[c.Version(api)] {
+			group.add(method)
+			for code := range method.Responses {
+				codes[code]++
+			}
+		}
+		stats.Groups = append(stats.Groups, group)
+		stats.Coverage.sum(group.Coverage)
+	}
+
+	for code, n := range codes {
+		stats.ResponseCodes = append(stats.ResponseCodes, ResponseCodeCount{Code: code, Operations: n})
+	}
+	sort.Slice(stats.ResponseCodes, func(i, j int) bool { return stats.ResponseCodes[i].Code < stats.ResponseCodes[j].Code })
+
+	if len(c.APIs) > 0 {
+		stats.Resources = len(c.ResourceList[c.Version(c.APIs[0])])
+	}
+	return stats
+}
+
+// -----------------------------------------------------------------------------
+
+func (c *Coverage) add(method Method) {
+	c.Operations++
+	if len(strings.TrimSpace(method.Description)) > 0 {
+		c.Described++
+	}
+	if hasExample(method) {
+		c.WithExamples++
+	}
+	if method.DefaultResponse != nil {
+		c.WithErrors++
+	} else {
+		for code := range method.Responses {
+			if code >= 400 {
+				c.WithErrors++
+				break
+			}
+		}
+	}
+	if len(method.Requirements) > 0 && !method.Public {
+		c.Secured++
+	}
+	if method.Deprecated {
+		c.Deprecated++
+	}
+
+	params := append(append(append(append([]Parameter{}, method.PathParams...), method.QueryParams...), method.HeaderParams...), method.FormParams...)
+	if method.BodyParam != nil {
+		params = append(params, *method.BodyParam)
+	}
+	for _, p := range params {
+		c.Parameters++
+		if len(strings.TrimSpace(p.Description)) > 0 {
+			c.DescribedParameters++
+		}
+	}
+}
+
+func (c *Coverage) sum(o Coverage) {
+	c.Operations += o.Operations
+	c.Described += o.Described
+	c.WithExamples += o.WithExamples
+	c.WithErrors += o.WithErrors
+	c.Secured += o.Secured
+	c.Deprecated += o.Deprecated
+	c.Parameters += o.Parameters
+	c.DescribedParameters += o.DescribedParameters
+}
+
+// hasExample is whether the specification gives an example of the method's request
+// or of any of its responses, or one was recorded, rather than leaving it to be
+// synthesized.
+func hasExample(method Method) bool {
+	if len(method.Recorded) > 0 {
+		return true
+	}
+	if method.BodyParam != nil && method.BodyParam.Resource != nil && len(method.BodyParam.Resource.Example) > 0 {
+		return true
+	}
+	for _, response := range method.Responses {
+		if response.Resource != nil && len(response.Resource.Example) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,162 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Responses are validated against the JSON schema of the documented response for their
+// status, using the parts of JSON schema that OpenAPI 2 allows. This is the validation
+// the explorer does in explorer.js, and the two should be kept in step, so that the
+// verify command and the explorer agree.
+
+// -----------------------------------------------------------------------------
+// ValidateResponse returns the problems with a JSON response body, each prefixed with the
+// JSON pointer to where it is. schema is a Response.Schema, and an empty one allows any body.
+func ValidateResponse(schema string, body []byte) []string {
+	if len(schema) == 0 {
+		return nil
+	}
+	var s, value interface{}
+	if err := json.Unmarshal([]byte(schema), &s); err != nil {
+		return []string{fmt.Sprintf("/: the documented schema is not valid JSON: %s", err)}
+	}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return []string{fmt.Sprintf("/: the body is not valid JSON: %s", err)}
+	}
+	return validateJSON(s, value, "", nil)
+}
+
+func validateJSON(s interface{}, value interface{}, path string, errors []string) []string {
+	schema, ok := s.(map[string]interface{})
+	if !ok || schema["$ref"] != nil {
+		return errors // Unexpanded (circular) references are not followed
+	}
+	at := path
+	if len(at) == 0 {
+		at = "/"
+	}
+
+	if value == nil {
+		if nullable, _ := schema["x-nullable"].(bool); !nullable && schema["type"] != nil {
+			errors = append(errors, at+": null is not allowed")
+		}
+		return errors
+	}
+
+	typ := jsonType(value)
+	types := asStrings(asSlice(schema["type"]))
+	if len(types) > 0 && !contains(types, typ) && !(typ == "integer" && contains(types, "number")) {
+		return append(errors, fmt.Sprintf("%s: expected %s, got %s", at, strings.Join(types, " or "), typ))
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && !inEnum(enum, value) {
+		v, _ := json.Marshal(value)
+		e, _ := json.Marshal(enum)
+		errors = append(errors, fmt.Sprintf("%s: %s is not one of %s", at, v, e))
+	}
+
+	switch v := value.(type) {
+	case string:
+		length := float64(utf8.RuneCountInString(v))
+		if min, ok := schema["minLength"].(float64); ok && length < min {
+			errors = append(errors, fmt.Sprintf("%s: shorter than %v", at, min))
+		}
+		if max, ok := schema["maxLength"].(float64); ok && length > max {
+			errors = append(errors, fmt.Sprintf("%s: longer than %v", at, max))
+		}
+		if pattern, ok := schema["pattern"].(string); ok && len(pattern) > 0 {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(v) {
+				errors = append(errors, fmt.Sprintf("%s: does not match %s", at, pattern))
+			}
+		}
+	case float64:
+		if min, ok := schema["minimum"].(float64); ok && v < min {
+			errors = append(errors, fmt.Sprintf("%s: less than %v", at, min))
+		}
+		if max, ok := schema["maximum"].(float64); ok && v > max {
+			errors = append(errors, fmt.Sprintf("%s: greater than %v", at, max))
+		}
+	case []interface{}:
+		if min, ok := schema["minItems"].(float64); ok && float64(len(v)) < min {
+			errors = append(errors, fmt.Sprintf("%s: fewer than %v items", at, min))
+		}
+		if max, ok := schema["maxItems"].(float64); ok && float64(len(v)) > max {
+			errors = append(errors, fmt.Sprintf("%s: more than %v items", at, max))
+		}
+		for i, item := range v {
+			errors = validateJSON(schema["items"], item, fmt.Sprintf("%s/%d", path, i), errors)
+		}
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		for _, name := range asStrings(asSlice(schema["required"])) {
+			if _, ok := v[name]; !ok {
+				errors = append(errors, path+"/"+name+": is required")
+			}
+		}
+		for _, name := range sortedKeys(v) {
+			if property, ok := properties[name]; ok {
+				errors = validateJSON(property, v[name], path+"/"+name, errors)
+			} else if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+				errors = append(errors, path+"/"+name+": is not a documented property")
+			} else if additional, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+				errors = validateJSON(additional, v[name], path+"/"+name, errors)
+			}
+		}
+	}
+
+	for _, s := range asSlice(schema["allOf"]) {
+		errors = validateJSON(s, value, path, errors)
+	}
+	return errors
+}
+
+// jsonType is the JSON schema type of a decoded JSON value
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+// inEnum is whether a value is one of an enum's. Values are compared as JSON, as
+// arrays and objects cannot be compared directly.
+func inEnum(enum []interface{}, value interface{}) bool {
+	v, _ := json.Marshal(value)
+	for _, e := range enum {
+		if j, _ := json.Marshal(e); string(j) == string(v) {
+			return true
+		}
+	}
+	return false
+}
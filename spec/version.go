@@ -0,0 +1,284 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/go-openapi/spec"
+)
+
+// unversioned is the version of specifications whose operations have no x-version
+const unversioned = "latest"
+
+// -----------------------------------------------------------------------------
+// specVersions lists the versions that operations are documented in, given by the
+// x-version extension of the operation or its path. Operations without one are in
+// every version, and if there are none all operations are in the one unversioned API.
+func specVersions(paths map[string]spec.PathItem, extra map[string]map[string]*spec.Operation) []string {
+	seen := make(map[string]bool)
+	var versions []string
+
+	add := func(extensions spec.Extensions) {
+		if v, ok := versionExtension(extensions); ok && !seen[v] {
+			seen[v] = true
+			versions = append(versions, v)
+		}
+	}
+	for _, pathItem := range paths {
+		add(pathItem.Extensions)
+		for _, o := range []*spec.Operation{pathItem.Get, pathItem.Post, pathItem.Put, pathItem.Delete, pathItem.Head, pathItem.Options, pathItem.Patch} {
+			if o != nil {
+				add(o.Extensions)
+			}
+		}
+	}
+	for _, operations := range extra {
+		for _, o := range operations {
+			add(o.Extensions)
+		}
+	}
+	if len(versions) == 0 {
+		return []string{unversioned}
+	}
+	SortVersions(versions)
+	return versions
+}
+
+// -----------------------------------------------------------------------------
+// operationVersions returns the versions that an operation is documented in
+func operationVersions(pathItem *spec.PathItem, o *spec.Operation, versions []string) []string {
+	if v, ok := versionExtension(o.Extensions); ok {
+		return []string{v}
+	}
+	if v, ok := versionExtension(pathItem.Extensions); ok {
+		return []string{v}
+	}
+	return versions
+}
+
+// versionExtension returns x-version, which YAML specifications may give as a number
+func versionExtension(extensions spec.Extensions) (string, bool) {
+	switch v := extensions["x-version"].(type) {
+	case string:
+		return v, len(v) > 0
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case int:
+		return strconv.Itoa(v), true
+	}
+	return "", false
+}
+
+// -----------------------------------------------------------------------------
+// setCurrentVersion sorts the methods of each version of an API, and makes its newest
+// version current. Methods are those of the version shown when none is requested,
+// which is the configured default version if the API has it.
+func (c *APISpecification) setCurrentVersion(api *APIGroup) {
+	var versions []string
+	for v, methods := range api.Versions {
+		sort.Stable(SortMethods(methods))
+		versions = append(versions, v)
+	}
+	SortVersions(versions)
+
+	api.OrderedVersions = versions
+	api.CurrentVersion = ""
+	if len(versions) > 0 {
+		api.CurrentVersion = versions[0]
+	}
+	api.Methods = api.Versions[c.Version(*api)]
+}
+
+// allVersions lists every version of the APIs, newest first
+func allVersions(apis APISet) []string {
+	seen := make(map[string]bool)
+	var versions []string
+	for _, api := range apis {
+		for _, v := range api.OrderedVersions {
+			if !seen[v] {
+				seen[v] = true
+				versions = append(versions, v)
+			}
+		}
+	}
+	SortVersions(versions)
+	return versions
+}
+
+// -----------------------------------------------------------------------------
+// groupByVersion collates the APIs of each version, for navigation between versions.
+// It returns nil when there is only one version.
+func groupByVersion(apis APISet) map[string]APISet {
+	grouped := make(map[string]APISet)
+	for _, api := range apis {
+		for v, methods := range api.Versions {
+			// A copy of the API, with the methods of the version being built
+			napi := api
+			napi.Methods = methods
+			napi.Versions = nil
+			grouped[v] = append(grouped[v], napi)
+		}
+	}
+	if len(grouped) < 2 {
+		return nil
+	}
+	return grouped
+}
+
+// -----------------------------------------------------------------------------
+// VersionDeprecation marks a version as deprecated, for a banner on its pages
+type VersionDeprecation struct {
+	Version     string
+	Sunset      string // Date the version is withdrawn, or ""
+	Replacement string // Version to use instead, or ""
+	Message     string
+}
+
+// getDeprecations returns the deprecated versions, given by the x-deprecatedVersions
+// extension and then the configuration file. The extension is either a list of
+// versions, or a map of version to an object with sunset, replacement and message.
+func getDeprecations(apispec *spec.Swagger, configured map[string]config.Deprecation) map[string]*VersionDeprecation {
+	deprecations := make(map[string]*VersionDeprecation)
+
+	switch extension := apispec.Extensions["x-deprecatedVersions"].(type) {
+	case []interface{}:
+		for _, v := range extension {
+			if version, ok := v.(string); ok {
+				deprecations[version] = &VersionDeprecation{Version: version}
+			}
+		}
+	case map[string]interface{}:
+		for version, v := range extension {
+			d := &VersionDeprecation{Version: version}
+			if details, ok := v.(map[string]interface{}); ok {
+				d.Sunset, _ = details["sunset"].(string)
+				d.Replacement, _ = details["replacement"].(string)
+				d.Message, _ = details["message"].(string)
+			}
+			deprecations[version] = d
+		}
+	}
+	for version, deprecation := range configured {
+		deprecations[version] = newDeprecation(version, deprecation)
+	}
+	return deprecations
+}
+
+func newDeprecation(version string, deprecation config.Deprecation) *VersionDeprecation {
+	return &VersionDeprecation{
+		Version:     version,
+		Sunset:      deprecation.Sunset,
+		Replacement: deprecation.Replacement,
+		Message:     deprecation.Message,
+	}
+}
+
+// -----------------------------------------------------------------------------
+// SortVersions orders versions newest first. Versions are compared a part at a time,
+// numbers by value, so that v10 is newer than v9 and 1.10 newer than 1.9. As with
+// semantic versions, a pre-release is older than its release: 2.0.0-rc.1 is older
+// than 2.0.0, and v2beta1 older than v2. The unversioned "latest" is newest of all.
+func SortVersions(versions []string) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		return compareVersions(versions[i], versions[j]) > 0
+	})
+}
+
+func compareVersions(a, b string) int {
+	if a == b {
+		return 0
+	}
+	if a == unversioned {
+		return 1
+	}
+	if b == unversioned {
+		return -1
+	}
+	pa, pb := versionParts(a), versionParts(b)
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		na, errA := strconv.Atoi(pa[i])
+		nb, errB := strconv.Atoi(pb[i])
+		switch {
+		case errA == nil && errB == nil:
+			if na != nb {
+				if na > nb {
+					return 1
+				}
+				return -1
+			}
+		case pa[i] != pb[i]:
+			if pa[i] > pb[i] {
+				return 1
+			}
+			return -1
+		}
+	}
+	// One is the other with more parts: a pre-release if the next part is not a number
+	switch {
+	case len(pa) > len(pb):
+		if isPreRelease(pa[len(pb)]) {
+			return -1
+		}
+		return 1
+	case len(pa) < len(pb):
+		if isPreRelease(pb[len(pa)]) {
+			return 1
+		}
+		return -1
+	}
+	return strings.Compare(a, b)
+}
+
+func isPreRelease(part string) bool {
+	_, err := strconv.Atoi(part)
+	return err != nil
+}
+
+// versionParts splits a version into runs of digits and of letters, dropping any
+// leading v and the separators between parts.
+func versionParts(version string) []string {
+	version = strings.TrimPrefix(strings.ToLower(version), "v")
+
+	var parts []string
+	var part []rune
+	digits := false
+	for _, r := range version {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if len(part) > 0 {
+				parts = append(parts, string(part))
+			}
+			part = nil
+			continue
+		}
+		if len(part) > 0 && unicode.IsDigit(r) != digits {
+			parts = append(parts, string(part))
+			part = nil
+		}
+		digits = unicode.IsDigit(r)
+		part = append(part, r)
+	}
+	if len(part) > 0 {
+		parts = append(parts, string(part))
+	}
+	return parts
+}
@@ -0,0 +1,142 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/dapperdox/dapperdox"
+
+var enabled bool
+
+type responseCapture struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (r *responseCapture) WriteHeader(status int) {
+	r.statusCode = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush passes flushes through, so that streamed pages reach the client as they render
+func (r *responseCapture) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Init configures OTLP trace export, if a trace endpoint is configured. The
+// returned function flushes any buffered spans and must be called on shutdown.
+func Init(version string) (func(), error) {
+	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
+
+	if len(cfg.TraceEndpoint) == 0 {
+		return func() {}, nil
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.TraceEndpoint)}
+	if cfg.TraceInsecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName("dapperdox"),
+			semconv.ServiceVersion(version),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	enabled = true
+
+	logger.Infof(nil, "Exporting traces to %s", cfg.TraceEndpoint)
+
+	return func() {
+		if err := provider.Shutdown(context.Background()); err != nil {
+			logger.Errorf(nil, "Error flushing traces: %s", err)
+		}
+	}, nil
+}
+
+// ---------------------------------------------------------------------------
+// Start starts a span as a child of any span in ctx. When tracing is not
+// configured the global no-op tracer is used, so this is always safe to call.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// ---------------------------------------------------------------------------
+// Handler wraps a http.Handler in a server span, continuing any trace passed
+// in the request headers.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !enabled {
+			h.ServeHTTP(w, req)
+			return
+		}
+
+		ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		ctx, span := Start(ctx, "HTTP "+req.Method, trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+
+		span.SetAttributes(
+			attribute.String("http.method", req.Method),
+			attribute.String("http.target", req.URL.Path),
+		)
+
+		rc := &responseCapture{w, http.StatusOK}
+		h.ServeHTTP(rc, req.WithContext(ctx))
+
+		span.SetAttributes(attribute.Int("http.status_code", rc.statusCode))
+		if rc.statusCode >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(rc.statusCode))
+		}
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Inject adds the trace context of req to its headers, for outbound requests.
+func Inject(req *http.Request) {
+	if enabled {
+		otel.GetTextMapPropagator().Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+	}
+}
+
+// ---------------------------------------------------------------------------
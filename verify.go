@@ -0,0 +1,298 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dapperdox/dapperdox/config"
+	"github.com/dapperdox/dapperdox/network"
+	"github.com/dapperdox/dapperdox/spec"
+)
+
+// verifyTimeout limits how long each operation has to respond
+const verifyTimeout = 30 * time.Second
+
+// verifyBodyLimit is the largest response body that is validated
+const verifyBodyLimit = 10 << 20
+
+// verifyVariable is a {{name}} reference to an environment variable, as the explorer
+// substitutes them
+var verifyVariable = regexp.MustCompile(`\{\{\s*([\w.-]+)\s*\}\}`)
+
+// ---------------------------------------------------------------------------
+// verifyAPI calls each documented operation against a live API, with the example
+// values of its parameters, and reports responses whose status is not documented or
+// whose body does not conform to the documented schema. The target is a configured
+// explorer environment, whose API key, headers, variables and client certificate are
+// used, or the URL of the API.
+func verifyAPI(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "verify requires an environment name or the URL of the API: verify <environment or URL>")
+		return 2
+	}
+	cfg, err := config.Get()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error configuring app: %s\n", err)
+		return 1
+	}
+	environment, err := verifyEnvironment(cfg.Environments(), args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	if err = loadSpecifications(); err != nil {
+		fmt.Fprintf(os.Stderr, "Load specification error: %s\n", err)
+		return 1
+	}
+
+	client := &http.Client{
+		Transport: network.Transport(environment.Name),
+		Timeout:   verifyTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse // Redirects are responses to verify, like any other
+		},
+	}
+
+	var ids []string
+	for id := range spec.APISuite {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	passed, failed, skipped := 0, 0, 0
+	for _, id := range ids {
+		for _, api := range spec.APISuite[id].APIs {
+			for _, method := range api.Methods {
+				op := id + ": " + strings.ToUpper(method.Method) + " " + method.Path
+
+				req, reason := verifyRequest(&method, environment, cfg.VerifyUnsafe)
+				if len(reason) > 0 {
+					fmt.Printf("SKIP %s: %s\n", op, reason)
+					skipped++
+					continue
+				}
+				status, problems := verifyResponse(client, req, &method)
+				if len(problems) == 0 {
+					fmt.Printf("PASS %s (%d)\n", op, status)
+					passed++
+					continue
+				}
+				fmt.Printf("FAIL %s (%d)\n", op, status)
+				for _, problem := range problems {
+					fmt.Printf("       %s\n", problem)
+				}
+				failed++
+			}
+		}
+	}
+
+	fmt.Printf("\n%d passed, %d failed, %d skipped\n", passed, failed, skipped)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// ---------------------------------------------------------------------------
+// verifyEnvironment is the configured environment of a name, or an environment that
+// has only the host of a URL
+func verifyEnvironment(environments []config.Environment, target string) (config.Environment, error) {
+	for _, environment := range environments {
+		if environment.Name == target {
+			if len(environment.Host) == 0 {
+				return environment, fmt.Errorf("environment %s has no host to verify", target)
+			}
+			return environment, nil
+		}
+	}
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return config.Environment{}, fmt.Errorf("%s is neither a configured environment nor an http or https URL", target)
+	}
+	return config.Environment{Host: u.Scheme + "://" + u.Host}, nil
+}
+
+// ---------------------------------------------------------------------------
+// verifyRequest builds the request that verifies a method, or gives the reason it is
+// skipped
+func verifyRequest(method *spec.Method, environment config.Environment, unsafe bool) (*http.Request, string) {
+	verb := strings.ToUpper(method.Method)
+	if !unsafe && verb != "GET" && verb != "HEAD" && verb != "OPTIONS" {
+		return nil, "may change data, and -verify-unsafe is not set"
+	}
+
+	path := method.Path
+	for _, p := range method.PathParams {
+		value, ok := verifyValue(p, environment)
+		if !ok {
+			return nil, fmt.Sprintf("path parameter %s has no example value", p.Name)
+		}
+		path = strings.Replace(path, "{"+p.Name+"}", url.PathEscape(value), -1)
+	}
+
+	query := url.Values{}
+	for _, p := range method.QueryParams {
+		if value, ok := verifyValue(p, environment); ok {
+			query.Set(p.Name, value)
+		} else if p.Required {
+			return nil, fmt.Sprintf("query parameter %s has no example value", p.Name)
+		}
+	}
+
+	header := http.Header{}
+	for _, p := range method.HeaderParams {
+		if value, ok := verifyValue(p, environment); ok {
+			header.Set(p.Name, value)
+		} else if p.Required {
+			return nil, fmt.Sprintf("header %s has no example value", p.Name)
+		}
+	}
+
+	var body io.Reader
+	if method.BodyParam != nil && method.BodyParam.Resource != nil {
+		example := method.BodyParam.Resource.Example
+		if len(example) == 0 {
+			example = method.BodyParam.Resource.Schema
+		}
+		body = strings.NewReader(verifySubstitute(example, environment))
+		header.Set("Content-Type", "application/json")
+	} else if len(method.FormParams) > 0 {
+		form := url.Values{}
+		for _, p := range method.FormParams {
+			if value, ok := verifyValue(p, environment); ok && !p.IsFile {
+				form.Set(p.Name, value)
+			} else if p.Required {
+				return nil, fmt.Sprintf("form parameter %s has no example value", p.Name)
+			}
+		}
+		body = strings.NewReader(form.Encode())
+		header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	// The environment's API key is given to every API key scheme of the method, as the
+	// explorer does
+	if len(environment.APIKey) > 0 {
+		for _, security := range method.Security {
+			if security.Scheme == nil || !security.Scheme.IsApiKey {
+				continue
+			}
+			switch security.Scheme.ParamLocation {
+			case "header":
+				header.Set(security.Scheme.ParamName, environment.APIKey)
+			case "query":
+				query.Set(security.Scheme.ParamName, environment.APIKey)
+			}
+		}
+	}
+	for name, value := range environment.Headers {
+		header.Set(name, verifySubstitute(value, environment))
+	}
+	for _, produces := range method.Produces {
+		if strings.Contains(produces, "json") {
+			header.Set("Accept", produces)
+			break
+		}
+	}
+
+	target := strings.TrimRight(environment.Host, "/") + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(verb, target, body)
+	if err != nil {
+		return nil, err.Error()
+	}
+	req.Header = header
+	return req, ""
+}
+
+// verifyValue is the example value of a parameter: its x-example, default or first
+// enum value, with the environment's variables substituted
+func verifyValue(p spec.Parameter, environment config.Environment) (string, bool) {
+	var value string
+	if example, ok := p.Extensions["x-example"]; ok && example != nil {
+		if list, ok := example.([]interface{}); ok {
+			var values []string
+			for _, v := range list {
+				values = append(values, fmt.Sprint(v))
+			}
+			value = strings.Join(values, ",")
+		} else {
+			value = fmt.Sprint(example)
+		}
+	} else if len(p.Default) > 0 {
+		value = p.Default
+	} else if len(p.Enum) > 0 {
+		value = p.Enum[0]
+	} else {
+		return "", false
+	}
+	return verifySubstitute(value, environment), true
+}
+
+// verifySubstitute replaces {{name}} with the value of the environment's variable
+func verifySubstitute(text string, environment config.Environment) string {
+	return verifyVariable.ReplaceAllStringFunc(text, func(match string) string {
+		if value, ok := environment.Variables[verifyVariable.FindStringSubmatch(match)[1]]; ok {
+			return value
+		}
+		return match
+	})
+}
+
+// ---------------------------------------------------------------------------
+// verifyResponse makes a request and checks its response against the documented
+// responses of the method
+func verifyResponse(client *http.Client, req *http.Request, method *spec.Method) (int, []string) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, []string{err.Error()}
+	}
+	defer resp.Body.Close()
+
+	response, ok := method.Responses[resp.StatusCode]
+	if !ok {
+		if method.DefaultResponse == nil {
+			return resp.StatusCode, []string{fmt.Sprintf("status %d is not documented", resp.StatusCode)}
+		}
+		response = *method.DefaultResponse
+	}
+	if len(response.Schema) == 0 || !strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		return resp.StatusCode, nil
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, verifyBodyLimit+1))
+	if err != nil {
+		return resp.StatusCode, []string{fmt.Sprintf("error reading the response: %s", err)}
+	}
+	if len(body) > verifyBodyLimit {
+		return resp.StatusCode, []string{"the response is too large to validate"}
+	}
+	return resp.StatusCode, spec.ValidateResponse(response.Schema, body)
+}
@@ -0,0 +1,81 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package watch
+
+// Package watch polls directory trees for changes. Polling is used rather than
+// file system notifications as it behaves the same on every platform and over
+// network and container mounts, and the trees watched are small.
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dapperdox/dapperdox/logger"
+)
+
+// ---------------------------------------------------------------------------
+// Dirs calls changed whenever a file under any of the directories is added,
+// removed or modified. It polls every interval and does not return.
+func Dirs(dirs []string, interval time.Duration, changed func()) {
+	last := snapshot(dirs)
+
+	for range time.Tick(interval) {
+		current := snapshot(dirs)
+		if !same(last, current) {
+			logger.Infof(nil, "Change detected in watched files")
+			changed()
+		}
+		last = current
+	}
+}
+
+// ---------------------------------------------------------------------------
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+func snapshot(dirs []string) map[string]fileState {
+	files := make(map[string]fileState)
+	for _, dir := range dirs {
+		if len(dir) == 0 {
+			continue
+		}
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				files[path] = fileState{size: info.Size(), modTime: info.ModTime()}
+			}
+			return nil
+		})
+	}
+	return files
+}
+
+func same(a, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, state := range a {
+		if other, ok := b[path]; !ok || other != state {
+			return false
+		}
+	}
+	return true
+}